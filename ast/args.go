@@ -35,7 +35,9 @@ type NamedArgs struct {
 
 // NewNamedArgs 在代码节点内新建命名参数列表 此命名参数列表名字args 所属代码节点为此代码节点
 func (node *Script) NewNamedArgs() *NamedArgs {
-	expr := &NamedArgs{}
+	expr := &NamedArgs{
+		Items: make(map[string]Expr),
+	}
 	expr.Init("args", node)
 	return expr
 }
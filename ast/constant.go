@@ -5,6 +5,10 @@
 
 package ast
 
+import (
+	"math/big"
+)
+
 // String 字面量字符串常量
 type String struct {
 	BaseExpr
@@ -38,18 +42,28 @@ func (node *Script) NewFloat(val float64) *Float {
 // Int 字面量整形常量
 type Int struct {
 	BaseExpr
-	Value int64
+	Value int64    // 值的64位补码 大于MaxInt64的uint64值为对应的负数
+	Big   *big.Int // 任意精度的原始值 用于按目标类型检查取值范围
 }
 
 // NewInt 在代码节点内新建字面量整形常量
 func (node *Script) NewInt(val int64) *Int {
+	return node.NewBigInt(big.NewInt(val))
+}
+
+// NewBigInt 在代码节点内新建任意精度的字面量整形常量 Value为val的低64位补码
+func (node *Script) NewBigInt(val *big.Int) *Int {
 	expr := &Int{
-		Value: val,
+		Value: int64(new(big.Int).And(val, maxUint64).Uint64()),
+		Big:   new(big.Int).Set(val),
 	}
 	expr.Init("int", node)
 	return expr
 }
 
+// maxUint64 64位掩码
+var maxUint64 = new(big.Int).SetUint64(^uint64(0))
+
 // Bool 字面量布尔值常量
 type Bool struct {
 	BaseExpr      // 内嵌基本表达式
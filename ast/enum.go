@@ -26,15 +26,15 @@ type Enum struct {
 
 // NewEnum 在代码内新建枚举 此枚举节点的父节点为此代码节点
 func (node *Script) NewEnum(name string, length uint, signed bool) (expr *Enum) {
-	// 枚举类型的长度仅支持1,2,4字节 TODO
+	// 枚举类型的长度仅支持1,2,4,8字节
 	gserrors.Require(func() bool {
 		switch length {
-		case 1, 2, 4:
+		case 1, 2, 4, 8:
 			return true
 		default:
 			return false
 		}
-	}(), "the enum type length can only be 1,2,4,got :%d", length)
+	}(), "the enum type length can only be 1,2,4,8,got :%d", length)
 	// 确保生成的Enum对象的Values值要被初始化 不能为nil
 	defer gserrors.Ensure(func() bool {
 		return expr.Values != nil
@@ -54,9 +54,9 @@ func (node *Script) NewEnum(name string, length uint, signed bool) (expr *Enum)
 // NewVal 在枚举内生成一个枚举值
 func (node *Enum) NewVal(name string, val int64) (result *EnumVal, ok bool) {
 	defer gserrors.Ensure(func() bool {
-		return ok == (node.Values[name] == result)
+		return node.Values[name] == result
 	}, "post condition check")
-	// 检查枚举中是否已有同名枚举值 有则直接返回
+	// 检查枚举中是否已有同名枚举值 有则返回已有的枚举值
 	if result, ok = node.Values[name]; ok {
 		ok = !ok
 		return
@@ -67,6 +67,8 @@ func (node *Enum) NewVal(name string, val int64) (result *EnumVal, ok bool) {
 	}
 	// 指定枚举值的名字及所属代码节点
 	result.Init(name, node.Script())
+	// 枚举值的父节点为此枚举节点
+	result.SetParent(node)
 	// 加入枚举节点枚举值字典
 	node.Values[name] = result
 	ok = true
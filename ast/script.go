@@ -39,6 +39,24 @@ func (node *Package) NewScript(name string) (script *Script, err error) {
 	return
 }
 
+// AddScript 将在其他包节点中新建的代码节点及其声明的类型加入到此包节点 用于合并单独解析的代码节点
+// 包内已有同名类型时返回已有的类型old及代码节点内重名的类型dup
+func (node *Package) AddScript(script *Script) (old Expr, dup Expr, err error) {
+	if exist, ok := node.Scripts[script.Name()]; ok {
+		err = gserrors.Newf(ErrAst, "duplicate script named:%s", exist.Name())
+		return
+	}
+	script.pkg = node
+	script.SetParent(node)
+	node.Scripts[script.Name()] = script
+	for _, expr := range script.Types {
+		if old, ok := node.NewType(expr); !ok {
+			return old, expr, nil
+		}
+	}
+	return
+}
+
 // PackageRef 包引用节点 代表一个源代码文件中引用的其他包
 type PackageRef struct {
 	BaseNode
@@ -0,0 +1,574 @@
+// @file 	cache.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	cache
+
+package gslang
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang/ast"
+)
+
+// cacheVersion 缓存格式版本 缓存格式或者解析规则变化时修改 旧版本的缓存全部失效
+const cacheVersion = 1
+
+// 缓存节点的类型
+const (
+	cacheTable = iota + 1
+	cacheField
+	cacheEnum
+	cacheEnumVal
+	cacheContract
+	cacheMethod
+	cacheParam
+	cacheTypeRef
+	cacheList
+	cacheArray
+	cacheMap
+	cacheAttr
+	cacheArgs
+	cacheNamedArgs
+	cacheInt
+	cacheFloat
+	cacheString
+	cacheBool
+	cacheBinaryOp
+	cacheImport
+)
+
+// cacheComment 缓存的注释
+type cacheComment struct {
+	Text string
+	Pos  Position
+}
+
+// cacheNode 缓存的语法树节点 只保存解析结果 不包含连接信息
+type cacheNode struct {
+	Kind     int
+	Name     string
+	Pos      *Position      // 没有位置信息时为空
+	Comments []cacheComment // 注释
+	Attrs    []*cacheNode   // 属性
+	Implicit bool           // 编译器自动引入的包引用
+	Path     []string       // 类型引用的名字路径 包引用的包路径
+	Nodes    []*cacheNode   // 域 枚举值 函数 输入参数 父协议 参数列表
+	Returns  []*cacheNode   // 返回参数
+	Methods  []*cacheNode   // 协议的函数 按编号顺序
+	Labels   []string       // 命名参数列表的参数名 与Nodes一一对应
+	Type     *cacheNode     // 域及参数的类型 属性的类型 数组及切片的元素 字典的key 二元运算的左操作数
+	Value    *cacheNode     // 字典的value 属性的参数列表 二元运算的右操作数
+	Int      int64          // 枚举值
+	Big      string         // 整数字面量
+	Float    float64        // 浮点数字面量
+	String   string         // 字符串字面量
+	Bool     bool           // 布尔字面量 枚举是否有符号
+	Length   uint           // 枚举长度 数组长度
+}
+
+// cacheScript 缓存的代码节点
+type cacheScript struct {
+	Name     string
+	Hash     string // 源文件内容的哈希
+	Imports  []*cacheNode
+	Types    []*cacheNode
+	Attrs    []*cacheNode
+	Comments []cacheComment
+}
+
+// cachePackage 缓存的包 每个包保存为缓存目录下的一个文件
+type cachePackage struct {
+	Version     int
+	Name        string
+	Fingerprint string
+	Scripts     []*cacheScript
+}
+
+// UseCache 设置缓存目录 编译时源文件没有变化的代码节点直接使用缓存的解析结果
+//
+// 缓存只保存解析结果 不保存连接结果: 连接后的语法树引用了其他包的节点 无法单独保存
+// 因此每次编译都会重新连接所有的包 包括没有变化的包 连接结果总是与引用的包一致
+// 节省的只是读取及解析源文件的时间 需要跳过没有变化的包的调用者应该使用Changed
+func (cs *CompileS) UseCache(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return gserrors.Newf(err, "create cache dir %s error: %s", dir, err)
+	}
+	cs.cache = dir
+	return nil
+}
+
+// Fingerprint 返回已编译的包的指纹 指纹由包内源文件的内容及引用的包的指纹计算
+// 任何直接或者间接引用的包发生变化时指纹都会变化
+func (cs *CompileS) Fingerprint(packageName string) (string, bool) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	fingerprint, ok := cs.fingerprints[packageName]
+	return fingerprint, ok
+}
+
+// Changed 返回已编译的包中指纹与缓存中上一次编译结果不同的包名 按名字排序
+// 没有使用缓存时返回所有已编译的包 代码生成器可以跳过没有变化的包
+func (cs *CompileS) Changed() []string {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	var names []string
+	for name := range cs.changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// cacheFile 返回包的缓存文件路径
+func (cs *CompileS) cacheFile(packageName string) string {
+	sum := sha256.Sum256([]byte(packageName))
+	return filepath.Join(cs.cache, hex.EncodeToString(sum[:16])+".gob")
+}
+
+// loadCache 读取包的缓存 没有缓存或者缓存无效时返回nil
+func (cs *CompileS) loadCache(packageName string) *cachePackage {
+	if cs.cache == "" {
+		return nil
+	}
+	file, err := os.Open(cs.cacheFile(packageName))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	cached := &cachePackage{}
+	if err := gob.NewDecoder(file).Decode(cached); err != nil {
+		cs.W("ignore invalid cache of package %s: %s", packageName, err)
+		return nil
+	}
+	if cached.Version != cacheVersion || cached.Name != packageName {
+		return nil
+	}
+	return cached
+}
+
+// saveCache 保存包的缓存 先写入临时文件再重命名 保存失败只输出警告
+func (cs *CompileS) saveCache(cached *cachePackage) {
+	file, err := os.CreateTemp(cs.cache, "*.tmp")
+	if err != nil {
+		cs.W("save cache of package %s error: %s", cached.Name, err)
+		return
+	}
+	err = gob.NewEncoder(file).Encode(cached)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), cs.cacheFile(cached.Name))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		cs.W("save cache of package %s error: %s", cached.Name, err)
+	}
+}
+
+// hashSource 返回源文件内容的哈希
+func hashSource(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// record 记录连接完成的包的指纹 指纹变化时标记为已变化并更新缓存
+func (cs *CompileS) record(pkg *ast.Package, cached *cachePackage, scripts []*cacheScript) {
+	// 引用的包已经编译完成 按名字排序参与计算
+	imported := make(map[string]bool)
+	for _, script := range pkg.Scripts {
+		for _, ref := range script.Imports {
+			if ref.Ref != nil && ref.Ref != pkg {
+				imported[ref.Ref.Name()] = true
+			}
+		}
+	}
+	var names []string
+	for name := range imported {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d\n%s\n", cacheVersion, pkg.Name())
+	for _, script := range scripts {
+		fmt.Fprintf(hash, "script %s %s\n", script.Name, script.Hash)
+	}
+	cs.mutex.Lock()
+	for _, name := range names {
+		fmt.Fprintf(hash, "import %s %s\n", name, cs.fingerprints[name])
+	}
+	fingerprint := hex.EncodeToString(hash.Sum(nil))
+	cs.fingerprints[pkg.Name()] = fingerprint
+	changed := cached == nil || cached.Fingerprint != fingerprint
+	if changed {
+		cs.changed[pkg.Name()] = true
+	}
+	cs.mutex.Unlock()
+	if changed && cs.cache != "" {
+		cs.saveCache(&cachePackage{
+			Version:     cacheVersion,
+			Name:        pkg.Name(),
+			Fingerprint: fingerprint,
+			Scripts:     scripts,
+		})
+	}
+}
+
+// encodeComments 编码节点的注释
+func encodeComments(node ast.Node) []cacheComment {
+	var comments []cacheComment
+	for _, token := range Comments(node) {
+		text, _ := token.Value.(string)
+		comments = append(comments, cacheComment{Text: text, Pos: token.Pos})
+	}
+	return comments
+}
+
+// encodeScript 编码刚解析完成的代码节点 必须在连接之前调用
+func encodeScript(script *ast.Script, hash string) *cacheScript {
+	cached := &cacheScript{
+		Name:     script.Name(),
+		Hash:     hash,
+		Comments: encodeComments(script),
+	}
+	var names []string
+	for name := range script.Imports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ref := script.Imports[name]
+		node := encodeNode(ref)
+		node.Implicit = IsImplicit(ref)
+		if ref.Ref != nil {
+			node.Path = []string{ref.Ref.Name()}
+		}
+		cached.Imports = append(cached.Imports, node)
+	}
+	for _, expr := range script.Types {
+		cached.Types = append(cached.Types, encodeNode(expr))
+	}
+	cached.Attrs = encodeNodes(script.Attrs())
+	return cached
+}
+
+// encodeNodes 编码属性列表
+func encodeNodes(attrs []*ast.Attr) []*cacheNode {
+	var nodes []*cacheNode
+	for _, attr := range attrs {
+		nodes = append(nodes, encodeNode(attr))
+	}
+	return nodes
+}
+
+// encodeParams 编码参数列表
+func encodeParams(params []*ast.Param) []*cacheNode {
+	var nodes []*cacheNode
+	for _, param := range params {
+		nodes = append(nodes, encodeNode(param))
+	}
+	return nodes
+}
+
+// encodeNode 编码语法树节点 空节点编码为nil
+func encodeNode(node ast.Node) *cacheNode {
+	if node == nil {
+		return nil
+	}
+	cached := &cacheNode{
+		Name:     node.Name(),
+		Comments: encodeComments(node),
+		Attrs:    encodeNodes(node.Attrs()),
+	}
+	if pos, ok := node.Extra(posExtra); ok {
+		position := pos.(Position)
+		cached.Pos = &position
+	}
+	switch expr := node.(type) {
+	case *ast.PackageRef:
+		cached.Kind = cacheImport
+	case *ast.Table:
+		cached.Kind = cacheTable
+		for _, field := range expr.Fields {
+			cached.Nodes = append(cached.Nodes, encodeNode(field))
+		}
+	case *ast.Field:
+		cached.Kind = cacheField
+		cached.Type = encodeExpr(expr.Type)
+	case *ast.Enum:
+		cached.Kind = cacheEnum
+		cached.Length = expr.Length
+		cached.Bool = expr.Signed
+		// 第一个枚举值为默认值 其余按名字排序
+		var names []string
+		for name, val := range expr.Values {
+			if val != expr.Default {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		if expr.Default != nil {
+			cached.Nodes = append(cached.Nodes, encodeNode(expr.Default))
+		}
+		for _, name := range names {
+			cached.Nodes = append(cached.Nodes, encodeNode(expr.Values[name]))
+		}
+	case *ast.EnumVal:
+		cached.Kind = cacheEnumVal
+		cached.Int = expr.Value
+	case *ast.Contract:
+		cached.Kind = cacheContract
+		for _, base := range expr.Bases {
+			cached.Nodes = append(cached.Nodes, encodeNode(base))
+		}
+		// 函数按编号顺序 解码时重新按顺序编号
+		for _, method := range Methods(expr) {
+			cached.Methods = append(cached.Methods, encodeNode(method))
+		}
+	case *ast.Method:
+		cached.Kind = cacheMethod
+		cached.Nodes = encodeParams(expr.Params)
+		cached.Returns = encodeParams(expr.Return)
+	case *ast.Param:
+		cached.Kind = cacheParam
+		cached.Type = encodeExpr(expr.Type)
+	case *ast.TypeRef:
+		cached.Kind = cacheTypeRef
+		cached.Path = expr.NamePath
+	case *ast.List:
+		cached.Kind = cacheList
+		cached.Type = encodeExpr(expr.Element)
+	case *ast.Array:
+		cached.Kind = cacheArray
+		cached.Length = uint(expr.Length)
+		cached.Type = encodeExpr(expr.Element)
+	case *ast.Map:
+		cached.Kind = cacheMap
+		cached.Type = encodeExpr(expr.Key)
+		cached.Value = encodeExpr(expr.Value)
+	case *ast.Attr:
+		cached.Kind = cacheAttr
+		cached.Type = encodeNode(expr.Type)
+		cached.Value = encodeExpr(expr.Args)
+	case *ast.Args:
+		cached.Kind = cacheArgs
+		for _, item := range expr.Items {
+			cached.Nodes = append(cached.Nodes, encodeNode(item))
+		}
+	case *ast.NamedArgs:
+		cached.Kind = cacheNamedArgs
+		for label := range expr.Items {
+			cached.Labels = append(cached.Labels, label)
+		}
+		sort.Strings(cached.Labels)
+		for _, label := range cached.Labels {
+			cached.Nodes = append(cached.Nodes, encodeNode(expr.Items[label]))
+		}
+	case *ast.Int:
+		cached.Kind = cacheInt
+		cached.Big = expr.Big.String()
+	case *ast.Float:
+		cached.Kind = cacheFloat
+		cached.Float = expr.Value
+	case *ast.String:
+		cached.Kind = cacheString
+		cached.String = expr.Value
+	case *ast.Bool:
+		cached.Kind = cacheBool
+		cached.Bool = expr.Value
+	case *ast.BinaryOp:
+		cached.Kind = cacheBinaryOp
+		cached.Type = encodeExpr(expr.Left)
+		cached.Value = encodeExpr(expr.Right)
+	default:
+		gserrors.Panicf(ErrCompileS, "inner error: can't cache node %s(%T)", node, node)
+	}
+	return cached
+}
+
+// encodeExpr 编码表达式 避免空接口包装的nil指针
+func encodeExpr(expr ast.Expr) *cacheNode {
+	if expr == nil {
+		return nil
+	}
+	return encodeNode(expr)
+}
+
+// decoder 将缓存节点解码到代码节点中 与解析器构造节点的方式一致
+type decoder struct {
+	script *ast.Script
+}
+
+// decodeScript 在pkg中解码缓存的代码节点 并编译引用的包
+func (cs *CompileS) decodeScript(pkg *ast.Package, cached *cacheScript) (script *ast.Script, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = recoverError(e)
+		}
+	}()
+	if script, err = pkg.NewScript(cached.Name); err != nil {
+		return nil, err
+	}
+	d := &decoder{script: script}
+	var refs []*ast.PackageRef
+	var paths []string
+	for _, node := range cached.Imports {
+		ref, _ := script.NewPackageRef(node.Name, nil)
+		d.restore(ref, node)
+		if node.Implicit {
+			markAsImplicit(ref)
+		}
+		if len(node.Path) > 0 {
+			refs = append(refs, ref)
+			paths = append(paths, node.Path[0])
+		}
+	}
+	pkgs, err := cs.compileImports(pkg.Name(), paths)
+	if err != nil {
+		return nil, err
+	}
+	for i, ref := range refs {
+		ref.Ref = pkgs[i]
+	}
+	for _, node := range cached.Types {
+		if old, ok := script.NewType(d.expr(node)); !ok {
+			return nil, gserrors.Newf(ErrCompileS, "invalid cache: duplicate type %s", old)
+		}
+	}
+	script.AddAttrs(d.attrs(cached.Attrs))
+	attachComments(script, decodeComments(cached.Comments))
+	return script, nil
+}
+
+// decodeComments 解码注释
+func decodeComments(comments []cacheComment) []*Token {
+	var tokens []*Token
+	for _, comment := range comments {
+		token := NewToken(TokenCOMMENT, comment.Text)
+		token.Pos = comment.Pos
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// restore 恢复节点的位置 注释及属性
+func (d *decoder) restore(node ast.Node, cached *cacheNode) {
+	if cached.Pos != nil {
+		attachPos(node, *cached.Pos)
+	}
+	if len(cached.Comments) > 0 {
+		attachComments(node, decodeComments(cached.Comments))
+	}
+	node.AddAttrs(d.attrs(cached.Attrs))
+}
+
+// attrs 解码属性列表
+func (d *decoder) attrs(nodes []*cacheNode) []*ast.Attr {
+	var attrs []*ast.Attr
+	for _, node := range nodes {
+		attrs = append(attrs, d.expr(node).(*ast.Attr))
+	}
+	return attrs
+}
+
+// expr 解码表达式 空节点解码为nil
+func (d *decoder) expr(cached *cacheNode) ast.Expr {
+	if cached == nil {
+		return nil
+	}
+	script := d.script
+	var expr ast.Expr
+	switch cached.Kind {
+	case cacheTable:
+		table := script.NewTable(cached.Name)
+		for _, node := range cached.Nodes {
+			field, ok := table.NewField(node.Name)
+			if !ok {
+				gserrors.Panicf(ErrCompileS, "invalid cache: duplicate field %s", field)
+			}
+			field.Type = d.expr(node.Type)
+			d.restore(field, node)
+		}
+		expr = table
+	case cacheEnum:
+		enum := script.NewEnum(cached.Name, cached.Length, cached.Bool)
+		for _, node := range cached.Nodes {
+			val, ok := enum.NewVal(node.Name, node.Int)
+			if !ok {
+				gserrors.Panicf(ErrCompileS, "invalid cache: duplicate enum value %s", val)
+			}
+			d.restore(val, node)
+		}
+		expr = enum
+	case cacheContract:
+		contract := script.NewContract(cached.Name)
+		for _, node := range cached.Nodes {
+			contract.NewBase(d.expr(node).(*ast.TypeRef))
+		}
+		for _, node := range cached.Methods {
+			method, ok := contract.NewMethod(node.Name)
+			if !ok {
+				gserrors.Panicf(ErrCompileS, "invalid cache: duplicate method %s", method)
+			}
+			for _, param := range node.Nodes {
+				d.restore(method.NewParam(d.expr(param.Type)), param)
+			}
+			for _, param := range node.Returns {
+				d.restore(method.NewReturn(d.expr(param.Type)), param)
+			}
+			d.restore(method, node)
+		}
+		expr = contract
+	case cacheTypeRef:
+		expr = script.NewTypeRef(cached.Path)
+	case cacheList:
+		expr = script.NewList(d.expr(cached.Type))
+	case cacheArray:
+		expr = script.NewArray(uint16(cached.Length), d.expr(cached.Type))
+	case cacheMap:
+		expr = script.NewMap(d.expr(cached.Type), d.expr(cached.Value))
+	case cacheAttr:
+		attr := script.NewAttr(d.expr(cached.Type).(*ast.TypeRef))
+		attr.Args = d.expr(cached.Value)
+		expr = attr
+	case cacheArgs:
+		args := script.NewArgs()
+		for _, node := range cached.Nodes {
+			args.NewArg(d.expr(node))
+		}
+		expr = args
+	case cacheNamedArgs:
+		args := script.NewNamedArgs()
+		for i, node := range cached.Nodes {
+			args.NewArg(cached.Labels[i], d.expr(node))
+		}
+		expr = args
+	case cacheInt:
+		val, ok := new(big.Int).SetString(cached.Big, 10)
+		if !ok {
+			gserrors.Panicf(ErrCompileS, "invalid cache: integer %q", cached.Big)
+		}
+		expr = script.NewBigInt(val)
+	case cacheFloat:
+		expr = script.NewFloat(cached.Float)
+	case cacheString:
+		expr = script.NewString(cached.String)
+	case cacheBool:
+		expr = script.NewBool(cached.Bool)
+	case cacheBinaryOp:
+		expr = script.NewBinaryOp(cached.Name, d.expr(cached.Type), d.expr(cached.Value))
+	default:
+		gserrors.Panicf(ErrCompileS, "invalid cache: unknown node kind %d", cached.Kind)
+	}
+	d.restore(expr, cached)
+	return expr
+}
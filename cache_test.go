@@ -0,0 +1,224 @@
+// @file 	cache_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	cache_test
+
+package gslang_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// cacheFiles 测试缓存的代码 覆盖缓存的所有节点类型
+var cacheFiles = map[string]string{
+	"demo/base/base.gs": `
+// 坐标
+struct Point {
+    X int32; // 横坐标
+    Y int32;
+}
+
+@gslang.AttrUsage(gslang.AttrTarget.Table|gslang.AttrTarget.Field)
+table Meta {
+    Name string;
+    Weight float64;
+    Hidden bool;
+    Level int32;
+}
+`,
+	"demo/keys/keys.gs": `
+import "demo/base"
+
+enum Key(int16) {
+    Low(-1), High(0x7f)
+}
+
+@base.Meta(Name: "entry", Weight: 1.5, Hidden: true, Level: -2)
+table Entry {
+    Pos base.Point;
+}
+`,
+	"demo/shop/shop.gs": `
+import (
+    "demo/base"
+    "demo/keys"
+)
+
+// 物品
+@base.Meta(Name: "item", Level: 1)
+table Item {
+    Pos [2]base.Point;
+    Tags []string;
+    Counts map[keys.Key]keys.Entry;
+}
+
+@gslang.Error
+enum ShopError(uint32) {
+    NotFound(1), Full(2)
+}
+
+contract Base {
+    Ping();
+}
+
+// 商店
+contract Shop(Base) {
+    Get(id uint64) -> (Item, ShopError);
+    Put(item Item);
+}
+`,
+	"demo/other/other.gs": `
+table Other {}
+`,
+}
+
+// compileCached 使用缓存目录编译指定的包
+func compileCached(t *testing.T, gopath, cache string, packages ...string) *gslang.Result {
+	t.Helper()
+	result, err := gslang.Compile(context.Background(), gslang.Options{
+		Packages: packages,
+		GOPATH:   []string{gopath},
+		CacheDir: cache,
+	})
+	if err != nil {
+		t.Fatalf("compile %v: %s", packages, err)
+	}
+	return result
+}
+
+// fingerprints 返回所有已编译的包的指纹
+func fingerprints(cs *gslang.CompileS) map[string]string {
+	result := make(map[string]string)
+	for name := range cs.Loaded {
+		result[name], _ = cs.Fingerprint(name)
+	}
+	return result
+}
+
+// TestCacheHit 源文件没有变化时使用缓存 结果与重新解析相同 没有包发生变化
+func TestCacheHit(t *testing.T) {
+	gopath := gstest.GOPATH(t, cacheFiles)
+	cache := filepath.Join(t.TempDir(), "cache")
+	cold := compileCached(t, gopath, cache, "demo/shop", "demo/other")
+	want := "demo/base demo/keys demo/other demo/shop skea3344/gslang"
+	if got := strings.Join(cold.Changed, " "); got != want {
+		t.Fatalf("cold Changed = %s want %s", got, want)
+	}
+	entries, err := os.ReadDir(cache)
+	if err != nil || len(entries) != 5 {
+		t.Fatalf("cache entries = %v, %v", entries, err)
+	}
+	warm := compileCached(t, gopath, cache, "demo/shop", "demo/other")
+	if len(warm.Changed) != 0 {
+		t.Fatalf("warm Changed = %v", warm.Changed)
+	}
+	if got, want := describe(t, warm.CompileS), describe(t, cold.CompileS); got != want {
+		t.Fatalf("cached result differs:\n%s\nwant\n%s", got, want)
+	}
+	coldPrints, warmPrints := fingerprints(cold.CompileS), fingerprints(warm.CompileS)
+	for name, fingerprint := range coldPrints {
+		if fingerprint == "" || warmPrints[name] != fingerprint {
+			t.Errorf("%s fingerprint %s, cached %s", name, fingerprint, warmPrints[name])
+		}
+	}
+	// 缓存的解析结果保留位置信息 并重新连接
+	for _, name := range []string{"Item", "Shop", "ShopError"} {
+		coldType, warmType := gstest.Type(t, cold.CompileS, "demo/shop", name), gstest.Type(t, warm.CompileS, "demo/shop", name)
+		if gslang.Pos(warmType) != gslang.Pos(coldType) || len(warmType.Attrs()) != len(coldType.Attrs()) {
+			t.Errorf("cached %s at %s with %d attrs, want %s with %d", name,
+				gslang.Pos(warmType), len(warmType.Attrs()), gslang.Pos(coldType), len(coldType.Attrs()))
+		}
+	}
+}
+
+// TestCacheInvalidation 源文件变化时只有该包及直接或者间接引用它的包发生变化
+func TestCacheInvalidation(t *testing.T) {
+	gopath := gstest.GOPATH(t, cacheFiles)
+	cache := t.TempDir()
+	before := fingerprints(compileCached(t, gopath, cache, "demo/shop", "demo/other").CompileS)
+	tests := []struct {
+		name    string
+		files   map[string]string
+		changed string
+	}{
+		{"base changed", map[string]string{"demo/base/base.gs": cacheFiles["demo/base/base.gs"] + "\ntable Extra {}\n"},
+			"demo/base demo/keys demo/shop"},
+		{"comment only", map[string]string{"demo/keys/keys.gs": "// 注释\n" + cacheFiles["demo/keys/keys.gs"]},
+			"demo/keys demo/shop"},
+		{"file added", map[string]string{"demo/shop/more.gs": "table More {}\n"}, "demo/shop"},
+		{"file removed", map[string]string{"demo/shop/more.gs": ""}, "demo/shop"},
+		{"leaf changed", map[string]string{"demo/other/other.gs": "table Other { A int32; }\n"}, "demo/other"},
+		{"unchanged", nil, ""},
+	}
+	for _, tc := range tests {
+		gstest.Write(t, gopath, tc.files)
+		result := compileCached(t, gopath, cache, "demo/shop", "demo/other")
+		if got := strings.Join(result.Changed, " "); got != tc.changed {
+			t.Fatalf("%s: Changed = %s want %s", tc.name, got, tc.changed)
+		}
+		after := fingerprints(result.CompileS)
+		for name, fingerprint := range after {
+			if changed := strings.Contains(" "+tc.changed+" ", " "+name+" "); changed == (fingerprint == before[name]) {
+				t.Errorf("%s: %s fingerprint changed %v", tc.name, name, fingerprint != before[name])
+			}
+		}
+		before = after
+	}
+	// 修改后的代码被重新解析
+	result := compileCached(t, gopath, cache, "demo/shop")
+	gstest.Type(t, result.CompileS, "demo/base", "Extra")
+	if _, err := result.CompileS.Type("demo/shop", "More"); err == nil {
+		t.Error("removed type More is still compiled")
+	}
+}
+
+// TestCacheCorrupt 无效的缓存文件被忽略并重新生成
+func TestCacheCorrupt(t *testing.T) {
+	gopath := gstest.GOPATH(t, cacheFiles)
+	cache := t.TempDir()
+	want := describe(t, compileCached(t, gopath, cache, "demo/shop").CompileS)
+	entries, err := os.ReadDir(cache)
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("cache entries = %v, %v", entries, err)
+	}
+	for i, entry := range entries {
+		path := filepath.Join(cache, entry.Name())
+		content := []byte("not a gob")
+		if i%2 == 1 {
+			// 截断的缓存
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content = data[:len(data)/2]
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result := compileCached(t, gopath, cache, "demo/shop")
+	if len(result.Changed) != len(entries) {
+		t.Errorf("Changed with corrupt cache = %v", result.Changed)
+	}
+	if got := describe(t, result.CompileS); got != want {
+		t.Fatalf("result with corrupt cache differs:\n%s\nwant\n%s", got, want)
+	}
+	if changed := compileCached(t, gopath, cache, "demo/shop").Changed; len(changed) != 0 {
+		t.Errorf("cache was not rewritten, Changed = %v", changed)
+	}
+	// 缓存目录不可用时报错
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gslang.Compile(context.Background(), gslang.Options{Packages: []string{"demo/shop"}, GOPATH: []string{gopath}, CacheDir: file}); err == nil {
+		t.Error("Compile with a file as cache dir succeeded")
+	}
+}
@@ -0,0 +1,67 @@
+// @file 	check.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	check
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/skea3344/gslang/compat"
+)
+
+// checkFlags check命令选项
+var checkFlags = flag.NewFlagSet("check", flag.ExitOnError)
+
+var (
+	checkFormat   = checkFlags.String("format", "text", "output format: text or json")
+	checkWarnings = checkFlags.Bool("warnings", false, "also print compatible changes")
+)
+
+func init() {
+	register(&command{
+		name:    "check",
+		usage:   "[-format text|json] [-warnings] <package> <old-dir> <new-dir>",
+		summary: "report wire-breaking changes between two versions of a package, exit 1 if any",
+		flags:   checkFlags,
+		run:     runCheck,
+	})
+}
+
+// runCheck 执行check命令
+func runCheck(args []string) int {
+	if len(args) != 3 {
+		checkFlags.Usage()
+		return 2
+	}
+	report, err := compat.CheckDirs(args[0], args[1], args[2])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gslangc check: %s\n", err)
+		return 2
+	}
+	if !*checkWarnings {
+		report.Changes = report.Breaking()
+	}
+	switch *checkFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "gslangc check: %s\n", err)
+			return 2
+		}
+	case "text":
+		fmt.Print(report)
+	default:
+		fmt.Fprintf(os.Stderr, "gslangc check: unknown format %s\n", *checkFormat)
+		return 2
+	}
+	if !report.Compatible() {
+		return 1
+	}
+	return 0
+}
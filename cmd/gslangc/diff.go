@@ -0,0 +1,60 @@
+// @file 	diff.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	diff
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/skea3344/gslang/diff"
+)
+
+// diffFlags diff命令选项
+var diffFlags = flag.NewFlagSet("diff", flag.ExitOnError)
+
+var (
+	diffFormat = diffFlags.String("format", "text", "output format: text, json or markdown")
+)
+
+func init() {
+	register(&command{
+		name:    "diff",
+		usage:   "[-format text|json|markdown] <package> <old-dir> <new-dir>",
+		summary: "print a semantic diff between two versions of a package",
+		flags:   diffFlags,
+		run:     runDiff,
+	})
+}
+
+// runDiff 执行diff命令
+func runDiff(args []string) int {
+	if len(args) != 3 {
+		diffFlags.Usage()
+		return 2
+	}
+	result, err := diff.CompareDirs(args[0], args[1], args[2])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gslangc diff: %s\n", err)
+		return 2
+	}
+	switch *diffFormat {
+	case "text":
+		err = result.WriteText(os.Stdout)
+	case "json":
+		err = result.WriteJSON(os.Stdout)
+	case "markdown", "md":
+		err = result.WriteMarkdown(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "gslangc diff: unknown format %s\n", *diffFormat)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gslangc diff: %s\n", err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+// @file 	lint.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	lint
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/lint"
+)
+
+// lintFlags lint命令选项
+var lintFlags = flag.NewFlagSet("lint", flag.ExitOnError)
+
+var (
+	lintConfig = lintFlags.String("config", "", "JSON config file enabling or disabling rules per package")
+	lintFormat = lintFlags.String("format", "text", "output format: text or json")
+	lintRules  = lintFlags.Bool("rules", false, "list the registered rules and exit")
+	lintTypes  = lintFlags.Bool("unused-types", false, "also report types no other loaded type, contract or attribute references")
+)
+
+func init() {
+	register(&command{
+		name:    "lint",
+		usage:   "[-config file] [-format text|json] [-rules] [-unused-types] <package>...",
+		summary: "check packages against the lint rules, exit 1 if any issue is found",
+		flags:   lintFlags,
+		run:     runLint,
+	})
+}
+
+// runLint 执行lint命令
+func runLint(args []string) int {
+	if *lintRules {
+		for _, rule := range lint.Rules() {
+			fmt.Printf("%-14s %-8v %s\n", rule.Name, rule.Enabled, rule.Doc)
+		}
+		return 0
+	}
+	if len(args) == 0 {
+		lintFlags.Usage()
+		return 2
+	}
+	var config *lint.Config
+	if *lintConfig != "" {
+		var err error
+		if config, err = lint.LoadConfig(*lintConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "gslangc lint: %s\n", err)
+			return 2
+		}
+	}
+	linter := lint.New(config)
+	issues := []*lint.Issue{}
+	cs := gslang.NewCompileS()
+	for _, name := range args {
+		pkg, err := cs.Compile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gslangc lint: %s\n", err)
+			return 2
+		}
+		issues = append(issues, linter.Lint(pkg)...)
+	}
+	if *lintTypes {
+		for _, expr := range cs.UnusedTypes() {
+			issues = append(issues, &lint.Issue{
+				Rule:    "unused-type",
+				Pos:     gslang.Pos(expr),
+				Message: fmt.Sprintf("type %s is never referenced", gslang.TypeName(expr)),
+			})
+		}
+	}
+	switch *lintFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(issues); err != nil {
+			fmt.Fprintf(os.Stderr, "gslangc lint: %s\n", err)
+			return 2
+		}
+	case "text":
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "gslangc lint: unknown format %s\n", *lintFormat)
+		return 2
+	}
+	if len(issues) != 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+// @file 	main.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	main
+
+// gslangc gslang命令行工具
+//
+//	gslangc <command> [arguments]
+//
+// 运行 gslangc help 查看支持的命令
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// command 子命令
+type command struct {
+	name    string                  // 命令名字
+	usage   string                  // 参数说明
+	summary string                  // 简要描述
+	flags   *flag.FlagSet           // 命令选项
+	run     func(args []string) int // 执行命令 返回进程退出码
+}
+
+// commands 已注册的子命令
+var commands = make(map[string]*command)
+
+// register 注册子命令 在各命令文件的init中调用
+func register(cmd *command) {
+	cmd.flags.Usage = func() {
+		fmt.Fprintf(cmd.flags.Output(), "usage: gslangc %s %s\n\n%s\n", cmd.name, cmd.usage, cmd.summary)
+		cmd.flags.PrintDefaults()
+	}
+	commands[cmd.name] = cmd
+}
+
+// usage 打印总的使用说明
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gslangc <command> [arguments]\n\ncommands:\n")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\t%-10s %s\n", name, commands[name].summary)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" {
+		if len(os.Args) > 2 {
+			if cmd, ok := commands[os.Args[2]]; ok {
+				cmd.flags.SetOutput(os.Stdout)
+				cmd.flags.Usage()
+				return
+			}
+		}
+		usage()
+		if len(os.Args) < 2 {
+			os.Exit(2)
+		}
+		return
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gslangc: unknown command %s\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.flags.Parse(os.Args[2:]); err != nil {
+		os.Exit(2)
+	}
+	os.Exit(cmd.run(cmd.flags.Args()))
+}
@@ -0,0 +1,262 @@
+// @file 	watch.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	watch
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/gen"
+	_ "github.com/skea3344/gslang/gen/doc"        // 内置文档生成器
+	_ "github.com/skea3344/gslang/gen/jsonschema" // 内置JSON Schema生成器
+	_ "github.com/skea3344/gslang/gen/proto"      // 内置protobuf生成器
+	_ "github.com/skea3344/gslang/gen/tmpl"       // 内置模板生成器
+)
+
+// watchFlags watch命令选项
+var watchFlags = flag.NewFlagSet("watch", flag.ExitOnError)
+
+// options 可以重复指定的 key=value 形式的选项
+type options map[string]string
+
+// String 实现flag.Value接口
+func (opts options) String() string {
+	var items []string
+	for key, val := range opts {
+		items = append(items, key+"="+val)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// Set 实现flag.Value接口
+func (opts options) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("option must be key=value, got %q", value)
+	}
+	opts[key] = val
+	return nil
+}
+
+var (
+	watchGen      = watchFlags.String("gen", "", "comma separated generators to rerun after each successful build")
+	watchOut      = watchFlags.String("out", ".", "output directory of the generators")
+	watchCache    = watchFlags.String("cache", "", "cache directory for parse results, empty for none")
+	watchInterval = watchFlags.Duration("interval", time.Second, "polling interval")
+	watchOptions  = make(options)
+)
+
+func init() {
+	watchFlags.Var(watchOptions, "opt", "generator option key=value, can be repeated")
+	register(&command{
+		name:    "watch",
+		usage:   "[-gen names] [-out dir] [-opt key=value]... [-cache dir] [-interval d] <package>...",
+		summary: "recompile packages and rerun generators whenever their sources change",
+		flags:   watchFlags,
+		run:     runWatch,
+	})
+}
+
+// watcher 轮询包目录 只重新编译变化的包及引用它们的包
+type watcher struct {
+	cs         *gslang.CompileS
+	packages   []string                     // 监视的包
+	generators []string                     // 每次编译后执行的生成器
+	modified   map[string]map[string]string // 包名 -> 源文件 -> 修改时间及大小
+	reported   map[string]bool              // 上一次编译输出过的诊断信息
+	source     func() []string              // 返回源文件有变化的包 默认为scan
+	stdout     io.Writer
+	stderr     io.Writer
+}
+
+// newWatcher 新建监视指定包的watcher 输出到标准输出及标准错误
+func newWatcher(cs *gslang.CompileS, packages []string) *watcher {
+	w := &watcher{
+		cs:       cs,
+		packages: packages,
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
+	}
+	w.source = w.scan
+	return w
+}
+
+// runWatch 执行watch命令 收到中断信号时退出
+func runWatch(args []string) int {
+	if len(args) == 0 {
+		watchFlags.Usage()
+		return 2
+	}
+	if os.Getenv("GOPATH") == "" {
+		fmt.Fprintf(os.Stderr, "gslangc watch: must set GOPATH first\n")
+		return 2
+	}
+	w := newWatcher(gslang.NewCompileS(), args)
+	if *watchGen != "" {
+		w.generators = strings.Split(*watchGen, ",")
+	}
+	for _, name := range w.generators {
+		if _, err := gen.Find(name); err != nil {
+			fmt.Fprintf(os.Stderr, "gslangc watch: %s\n", err)
+			return 2
+		}
+	}
+	if *watchCache != "" {
+		if err := w.cs.UseCache(*watchCache); err != nil {
+			fmt.Fprintf(os.Stderr, "gslangc watch: %s\n", err)
+			return 2
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	w.build(ctx, nil)
+	ticker := time.NewTicker(*watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-ticker.C:
+			w.poll(ctx)
+		}
+	}
+}
+
+// poll 执行一次轮询 有包发生变化时重新编译 返回是否重新编译
+func (w *watcher) poll(ctx context.Context) bool {
+	changed := w.source()
+	if len(changed) == 0 {
+		return false
+	}
+	w.build(ctx, changed)
+	return true
+}
+
+// stamps 返回所有已知包目录下源文件的修改时间及大小 按包名分组
+func (w *watcher) stamps() map[string]map[string]string {
+	stamps := make(map[string]map[string]string)
+	for name, dir := range w.cs.Dirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		files := make(map[string]string)
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".gs" {
+				continue
+			}
+			if info, err := entry.Info(); err == nil {
+				files[entry.Name()] = fmt.Sprintf("%d %d", info.ModTime().UnixNano(), info.Size())
+			}
+		}
+		stamps[name] = files
+	}
+	return stamps
+}
+
+// scan 返回源文件有增加 删除或者修改的包 按名字排序
+func (w *watcher) scan() []string {
+	var changed []string
+	for name, files := range w.stamps() {
+		last, ok := w.modified[name]
+		if !ok {
+			continue
+		}
+		dirty := len(files) != len(last)
+		for file, stamp := range files {
+			if last[file] != stamp {
+				dirty = true
+			}
+		}
+		if dirty {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// build 使changed中的包失效并重新编译所有监视的包 输出诊断信息 为指纹变化的包重新执行生成器
+func (w *watcher) build(ctx context.Context, changed []string) {
+	start := time.Now()
+	// 编译之前记录源文件状态 编译过程中的修改在下一次扫描时发现
+	modified := w.stamps()
+	previous := make(map[string]string)
+	for _, name := range w.packages {
+		if fingerprint, ok := w.cs.Fingerprint(name); ok {
+			previous[name] = fingerprint
+		}
+	}
+	if len(changed) > 0 {
+		fmt.Fprintf(w.stdout, "changed: %s\n", strings.Join(changed, " "))
+		if dropped := w.cs.Invalidate(changed...); len(dropped) > 0 {
+			fmt.Fprintf(w.stdout, "rebuilding: %s\n", strings.Join(dropped, " "))
+		}
+	}
+	var pkgs []*ast.Package
+	failed := false
+	for _, name := range w.packages {
+		pkg, err := w.cs.Compile(name)
+		if err != nil {
+			fmt.Fprintf(w.stderr, "%s\n", err)
+			failed = true
+			continue
+		}
+		if fingerprint, _ := w.cs.Fingerprint(name); fingerprint != previous[name] {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	// 只输出新增或者变化的诊断信息 没有变化的包重新编译时不重复输出
+	reported := make(map[string]bool)
+	for _, diagnostic := range w.cs.Diagnostics {
+		text := diagnostic.String()
+		if !w.reported[text] {
+			fmt.Fprintln(w.stdout, text)
+		}
+		reported[text] = true
+	}
+	w.reported = reported
+	// 补充编译过程中新发现的包目录
+	for name, files := range w.stamps() {
+		if _, ok := modified[name]; !ok {
+			modified[name] = files
+		}
+	}
+	w.modified = modified
+	if len(pkgs) > 0 {
+		for _, name := range w.generators {
+			files, err := gen.Run(ctx, name, &gen.Request{
+				CompileS: w.cs,
+				Packages: pkgs,
+				Options:  watchOptions,
+			})
+			if err == nil {
+				err = gen.WriteFiles(*watchOut, files)
+			}
+			if err != nil {
+				fmt.Fprintf(w.stderr, "gslangc watch: %s: %s\n", name, err)
+				continue
+			}
+			fmt.Fprintf(w.stdout, "%s: wrote %d files\n", name, len(files))
+		}
+	}
+	status := "ok"
+	if failed {
+		status = "failed"
+	}
+	fmt.Fprintf(w.stdout, "build %s in %s, watching for changes...\n", status, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,124 @@
+// @file 	watch_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	watch_test
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/gen"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// watchGenerator 记录每次执行时的包 每个包输出一个文件
+type watchGenerator struct {
+	runs [][]string
+}
+
+// Name 实现gen.Generator接口
+func (generator *watchGenerator) Name() string {
+	return "watchtest"
+}
+
+// Options 实现gen.Generator接口
+func (generator *watchGenerator) Options() []*gen.Option {
+	return nil
+}
+
+// Generate 实现gen.Generator接口
+func (generator *watchGenerator) Generate(ctx context.Context, req *gen.Request) ([]*gen.File, error) {
+	var names []string
+	var files []*gen.File
+	for _, pkg := range req.Packages {
+		names = append(names, pkg.Name())
+		files = append(files, gen.NewFile(pkg.Name()+"/out.txt", []byte(pkg.Name())))
+	}
+	generator.runs = append(generator.runs, names)
+	return files, nil
+}
+
+var testGenerator = &watchGenerator{}
+
+func init() {
+	gen.Register(testGenerator)
+}
+
+// watchFiles 测试使用的代码 demo/shop引用了没有使用的demo/other
+var watchFiles = map[string]string{
+	"demo/base/base.gs":   "table Point { X int32; }\n",
+	"demo/other/other.gs": "table Other {}\n",
+	"demo/shop/shop.gs":   "import \"demo/base\"\nimport \"demo/other\"\n\ntable Item { Pos base.Point; }\n",
+}
+
+// TestWatchPoll 每次轮询只重新编译变化的包 只输出新增或者变化的诊断信息
+func TestWatchPoll(t *testing.T) {
+	gopath := gstest.GOPATH(t, watchFiles)
+	t.Setenv("GOPATH", gopath)
+	out := *watchOut
+	*watchOut = t.TempDir()
+	defer func() { *watchOut = out }()
+	testGenerator.runs = nil
+	var stdout, stderr bytes.Buffer
+	w := newWatcher(gslang.NewCompileS(), []string{"demo/shop"})
+	w.stdout, w.stderr = &stdout, &stderr
+	w.generators = []string{"watchtest"}
+	var changes []string
+	w.source = func() []string { return changes }
+	ctx := context.Background()
+	unused := `imported and not used: "demo/other"`
+	// step 执行一次轮询 返回输出
+	step := func(name string, files map[string]string, changed ...string) string {
+		t.Helper()
+		stdout.Reset()
+		gstest.Write(t, gopath, files)
+		changes = changed
+		if polled := w.poll(ctx); polled != (len(changed) > 0) {
+			t.Fatalf("%s: poll = %v", name, polled)
+		}
+		if stderr.Len() != 0 {
+			t.Fatalf("%s: stderr = %s", name, stderr.String())
+		}
+		return stdout.String()
+	}
+
+	w.build(ctx, nil)
+	if got := stdout.String(); strings.Count(got, unused) != 1 || !strings.Contains(got, "shop.gs(2:8)") ||
+		!strings.Contains(got, "watchtest: wrote 1 files") || !strings.Contains(got, "build ok") {
+		t.Fatalf("first build:\n%s", got)
+	}
+	if got := step("unchanged", nil); got != "" {
+		t.Errorf("unchanged poll printed:\n%s", got)
+	}
+	// 引用的包变化 诊断信息没有变化时不再输出
+	got := step("base changed", map[string]string{"demo/base/base.gs": "table Point { X int32; Y int32; }\n"}, "demo/base")
+	if strings.Contains(got, unused) || !strings.Contains(got, "changed: demo/base\n") ||
+		!strings.Contains(got, "rebuilding: ") || !strings.Contains(got, "build ok") {
+		t.Errorf("base changed:\n%s", got)
+	}
+	// 诊断信息的位置变化时输出新的诊断信息
+	got = step("warning moved", map[string]string{
+		"demo/shop/shop.gs": "import \"demo/base\"\n\nimport \"demo/other\"\n\ntable Item { Pos base.Point; }\n",
+	}, "demo/shop")
+	if strings.Count(got, unused) != 1 || !strings.Contains(got, "shop.gs(3:8)") {
+		t.Errorf("warning moved:\n%s", got)
+	}
+	// 修复后不再输出 再次出现时重新输出
+	if got := step("warning fixed", map[string]string{
+		"demo/shop/shop.gs": "import \"demo/base\"\n\ntable Item { Pos base.Point; }\n",
+	}, "demo/shop"); strings.Contains(got, unused) {
+		t.Errorf("warning fixed:\n%s", got)
+	}
+	if got := step("warning back", map[string]string{"demo/shop/shop.gs": watchFiles["demo/shop/shop.gs"]}, "demo/shop"); strings.Count(got, unused) != 1 {
+		t.Errorf("warning back:\n%s", got)
+	}
+	// 每次编译后demo/shop的指纹都发生了变化 生成器都重新执行
+	if len(testGenerator.runs) != 5 {
+		t.Errorf("generator runs = %v", testGenerator.runs)
+	}
+}
@@ -0,0 +1,190 @@
+// @file 	codec.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	codec
+
+// Package codec 根据已连接的语法树在运行时编解码二进制数据 不需要生成代码
+// 编码格式见 FORMAT.md
+//
+// Go值与gslang类型的对应关系:
+//
+//	byte sbyte int16 uint16 int32 uint32 int64 uint64 -> uint8 int8 int16 uint16 int32 uint32 int64 uint64
+//	float32 float64 bool string                       -> float32 float64 bool string
+//	enum                                              -> 有符号枚举为int64 无符号枚举为uint64
+//	table struct                                      -> map[string]interface{} 以域名字为key
+//	[]T [N]T                                          -> []interface{}
+//	map[K]V                                           -> map[interface{}]interface{}
+//
+// 编码时整数类型可以是任意Go整数类型 只要值在目标类型的范围之内 nil编码为对应类型的零值
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// 错误码
+var (
+	ErrCodec = errors.New("codec error")
+)
+
+// Marshal 将值按类型表达式编码为二进制数据
+func Marshal(expr ast.Expr, value interface{}) ([]byte, error) {
+	encoder := NewEncoder()
+	if err := encoder.Encode(expr, value); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
+}
+
+// Unmarshal 按类型表达式解码二进制数据 数据必须恰好被完全解码
+func Unmarshal(expr ast.Expr, data []byte) (interface{}, error) {
+	decoder := NewDecoder(data)
+	value, err := decoder.Decode(expr)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.Len() != 0 {
+		return nil, gserrors.Newf(ErrCodec, "%d trailing bytes after %s", decoder.Len(), gslang.TypeName(expr))
+	}
+	return value, nil
+}
+
+// kind 编码时类型表达式的分类
+type kind int
+
+const (
+	kindBuiltin kind = iota
+	kindTable
+	kindStruct
+	kindEnum
+	kindList
+	kindArray
+	kindMap
+)
+
+// resolve 返回类型表达式的分类 类型引用解析为被引用的类型
+func resolve(expr ast.Expr) (kind, ast.Expr, rune, error) {
+	if key, ok := gslang.Builtin(expr); ok {
+		return kindBuiltin, expr, key, nil
+	}
+	if ref, ok := expr.(*ast.TypeRef); ok {
+		if ref.Ref == nil {
+			return 0, nil, 0, fmt.Errorf("unlinked type %s", ref)
+		}
+		expr = ref.Ref
+	}
+	switch node := expr.(type) {
+	case *ast.Table:
+		if gslang.IsStruct(node) {
+			return kindStruct, node, 0, nil
+		}
+		return kindTable, node, 0, nil
+	case *ast.Enum:
+		return kindEnum, node, 0, nil
+	case *ast.List:
+		return kindList, node, 0, nil
+	case *ast.Array:
+		return kindArray, node, 0, nil
+	case *ast.Map:
+		return kindMap, node, 0, nil
+	}
+	return 0, nil, 0, fmt.Errorf("type %s can not be encoded", gslang.TypeName(expr))
+}
+
+// path 编解码时的值路径 用于错误信息 如 Item.Corners[2].X
+type path []string
+
+// String 实现fmt.Stringer接口
+func (p path) String() string {
+	return strings.Join(p, "")
+}
+
+// errorf 生成带值路径的错误
+func (p path) errorf(format string, args ...interface{}) error {
+	if len(p) == 0 {
+		return gserrors.Newf(ErrCodec, format, args...)
+	}
+	return gserrors.Newf(ErrCodec, "%s: %s", p, fmt.Sprintf(format, args...))
+}
+
+// enumRange 返回枚举值的取值范围 由gslang.IntRange给出
+func enumRange(enum *ast.Enum) (int64, uint64) {
+	min, max, _ := gslang.IntRange(enum)
+	return min.Int64(), max.Uint64()
+}
+
+// bigInt 将任意Go整数转换为任意精度整数 nil为0
+func bigInt(value interface{}) (*big.Int, bool) {
+	if value == nil {
+		return new(big.Int), true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Int).SetUint64(v.Uint()), true
+	}
+	return nil, false
+}
+
+// enumGo 将枚举的数值转换为codec值 有符号枚举为int64 无符号枚举为uint64 数值必须在枚举的取值范围内
+func enumGo(enum *ast.Enum, n *big.Int) interface{} {
+	if enum.Signed {
+		return n.Int64()
+	}
+	return n.Uint64()
+}
+
+// enumName 返回枚举数值对应的名字
+func enumName(enum *ast.Enum, n *big.Int) (string, bool) {
+	for _, val := range gslang.EnumVals(enum) {
+		if gslang.EnumValue(val).Cmp(n) == 0 {
+			return val.Name(), true
+		}
+	}
+	return "", false
+}
+
+// enumByName 返回枚举值名字对应的codec值
+func enumByName(enum *ast.Enum, name string) (interface{}, bool) {
+	val, ok := enum.Values[name]
+	if !ok {
+		return nil, false
+	}
+	return enumGo(enum, gslang.EnumValue(val)), true
+}
+
+// intRange 整数内置类型的取值范围及字节数
+type intRange struct {
+	min  int64
+	max  uint64
+	size int
+}
+
+// builtinRanges 整数内置类型的取值范围 由gslang.BuiltinRange预先计算 避免每次编解码时分配任意精度整数
+var builtinRanges = func() map[rune]intRange {
+	ranges := make(map[rune]intRange)
+	for _, key := range []rune{
+		gslang.KeyByte, gslang.KeySByte, gslang.KeyInt16, gslang.KeyUInt16,
+		gslang.KeyInt32, gslang.KeyUInt32, gslang.KeyInt64, gslang.KeyUInt64,
+	} {
+		min, max, _ := gslang.BuiltinRange(key)
+		ranges[key] = intRange{min: min.Int64(), max: max.Uint64(), size: (max.BitLen() + 7) / 8}
+	}
+	return ranges
+}()
+
+// builtinRange 返回整数内置类型的取值范围 及字节数
+func builtinRange(key rune) (min int64, max uint64, size int, ok bool) {
+	r, ok := builtinRanges[key]
+	return r.min, r.max, r.size, ok
+}
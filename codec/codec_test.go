@@ -0,0 +1,356 @@
+// @file 	codec_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	codec_test
+
+package codec
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// testFiles 测试使用的代码 demo/base与FORMAT.md中的示例一致
+var testFiles = map[string]string{
+	"demo/base/base.gs": `
+struct Point {
+    X int32;
+    Y int32;
+}
+
+enum Color(byte) {
+    Red(1), Green(2), Blue(3)
+}
+
+enum Delta(int16) {
+    Down(-1), Up(1)
+}
+
+enum Big(uint64) {
+    Zero(0), Max(0xFFFFFFFFFFFFFFFF)
+}
+
+enum Wide(int64) {
+    Min(-0x8000000000000000), One(1)
+}
+`,
+	"demo/shop/shop.gs": `
+import "demo/base"
+
+table Item {
+    ID uint64;
+    Name string;
+    Tags []string;
+    Level byte;
+    Pos base.Point;
+    Color base.Color;
+    Corners [4]base.Point;
+    Props map[string]float64;
+}
+
+table Scalars {
+    B byte;
+    SB sbyte;
+    I16 int16;
+    U16 uint16;
+    I32 int32;
+    U32 uint32;
+    I64 int64;
+    U64 uint64;
+    F32 float32;
+    F64 float64;
+    Ok bool;
+    S string;
+}
+
+table Order {
+    Item Item;
+    Items []Item;
+    Path [2]base.Point;
+    Counts map[base.Color]uint32;
+    Deltas map[int32]base.Delta;
+    Groups map[string]Item;
+    Flags map[bool]string;
+}
+`,
+}
+
+// compile 编译测试代码 返回编译器
+func compile(t *testing.T) *gslang.CompileS {
+	return gstest.Compile(t, testFiles, "demo/shop")
+}
+
+// point 构造Point的codec值
+func point(x, y int32) map[string]interface{} {
+	return map[string]interface{}{"X": x, "Y": y}
+}
+
+// roundTripCase 往返编解码的测试用例 value使用解码结果的Go类型 以便直接比较
+type roundTripCase struct {
+	name  string
+	expr  ast.Expr
+	value interface{}
+}
+
+// roundTripCases 覆盖每种内置类型 枚举 结构体 表 切片 数组 字典及嵌套
+func roundTripCases(t *testing.T, cs *gslang.CompileS) []roundTripCase {
+	item := map[string]interface{}{
+		"ID":      uint64(1 << 40),
+		"Name":    "sword",
+		"Tags":    []interface{}{"a", "bb"},
+		"Level":   uint8(7),
+		"Pos":     point(1, -2),
+		"Color":   uint64(2),
+		"Corners": []interface{}{point(0, 0), point(1, 0), point(1, 1), point(0, 1)},
+		"Props":   map[interface{}]interface{}{"atk": 1.5, "def": 2.25},
+	}
+	return []roundTripCase{
+		{"struct", gstest.Type(t, cs, "demo/base", "Point"), point(math.MinInt32, math.MaxInt32)},
+		{"enum", gstest.Type(t, cs, "demo/base", "Color"), uint64(3)},
+		{"undeclared enum value", gstest.Type(t, cs, "demo/base", "Color"), uint64(200)},
+		{"signed enum", gstest.Type(t, cs, "demo/base", "Delta"), int64(-1)},
+		{"uint64 enum", gstest.Type(t, cs, "demo/base", "Big"), uint64(math.MaxUint64)},
+		{"undeclared uint64 enum value", gstest.Type(t, cs, "demo/base", "Big"), uint64(1 << 63)},
+		{"int64 enum", gstest.Type(t, cs, "demo/base", "Wide"), int64(math.MinInt64)},
+		{"empty table", gstest.Type(t, cs, "demo/shop", "Item"), map[string]interface{}{}},
+		{"table", gstest.Type(t, cs, "demo/shop", "Item"), item},
+		{"scalars", gstest.Type(t, cs, "demo/shop", "Scalars"), map[string]interface{}{
+			"B":   uint8(math.MaxUint8),
+			"SB":  int8(math.MinInt8),
+			"I16": int16(math.MinInt16),
+			"U16": uint16(math.MaxUint16),
+			"I32": int32(math.MinInt32),
+			"U32": uint32(math.MaxUint32),
+			"I64": int64(math.MinInt64),
+			"U64": uint64(math.MaxUint64),
+			"F32": float32(-1.5),
+			"F64": math.MaxFloat64,
+			"Ok":  true,
+			"S":   "中文\n\"quoted\"",
+		}},
+		{"zero scalars", gstest.Type(t, cs, "demo/shop", "Scalars"), map[string]interface{}{
+			"B": uint8(0), "I64": int64(0), "U64": uint64(0), "F64": float64(0), "Ok": false, "S": "",
+		}},
+		{"nested", gstest.Type(t, cs, "demo/shop", "Order"), map[string]interface{}{
+			"Item":  item,
+			"Items": []interface{}{item, map[string]interface{}{"Name": "shield"}},
+			"Path":  []interface{}{point(1, 2), point(3, 4)},
+			"Counts": map[interface{}]interface{}{
+				uint64(1): uint32(10),
+				uint64(3): uint32(0),
+			},
+			"Deltas": map[interface{}]interface{}{
+				int32(-5): int64(-1),
+				int32(5):  int64(1),
+			},
+			"Groups": map[interface{}]interface{}{
+				"weapons": item,
+				"empty":   map[string]interface{}{},
+			},
+			"Flags": map[interface{}]interface{}{true: "yes", false: "no"},
+		}},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	cs := compile(t)
+	for _, tc := range roundTripCases(t, cs) {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := Marshal(tc.expr, tc.value)
+			if err != nil {
+				t.Fatalf("Marshal: %s", err)
+			}
+			got, err := Unmarshal(tc.expr, data)
+			if err != nil {
+				t.Fatalf("Unmarshal: %s", err)
+			}
+			if !reflect.DeepEqual(got, tc.value) {
+				t.Fatalf("round trip mismatch:\n got %#v\nwant %#v", got, tc.value)
+			}
+			// 编码结果唯一
+			again, err := Marshal(tc.expr, got)
+			if err != nil {
+				t.Fatalf("Marshal decoded value: %s", err)
+			}
+			if !bytes.Equal(again, data) {
+				t.Fatalf("encoding is not canonical:\n% x\n% x", data, again)
+			}
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	cs := compile(t)
+	for _, tc := range roundTripCases(t, cs) {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := MarshalJSON(tc.expr, tc.value)
+			if err != nil {
+				t.Fatalf("MarshalJSON: %s", err)
+			}
+			got, err := UnmarshalJSON(tc.expr, data)
+			if err != nil {
+				t.Fatalf("UnmarshalJSON %s: %s", data, err)
+			}
+			if !reflect.DeepEqual(got, tc.value) {
+				t.Fatalf("round trip mismatch for %s:\n got %#v\nwant %#v", data, got, tc.value)
+			}
+			again, err := MarshalJSON(tc.expr, got)
+			if err != nil {
+				t.Fatalf("MarshalJSON decoded value: %s", err)
+			}
+			if !bytes.Equal(again, data) {
+				t.Fatalf("json is not canonical:\n%s\n%s", data, again)
+			}
+		})
+	}
+}
+
+// TestFormatExamples 检查FORMAT.md中的示例
+func TestFormatExamples(t *testing.T) {
+	cs := compile(t)
+	tests := []struct {
+		expr  ast.Expr
+		value interface{}
+		want  []byte
+	}{
+		{
+			gstest.Type(t, cs, "demo/base", "Point"),
+			point(1, -2),
+			[]byte{0x01, 0x00, 0x00, 0x00, 0xfe, 0xff, 0xff, 0xff},
+		},
+		{
+			gstest.Type(t, cs, "demo/shop", "Item"),
+			map[string]interface{}{"Name": "ab", "Color": 3},
+			[]byte{0x02, 0x01, 0x03, 0x02, 0x61, 0x62, 0x05, 0x01, 0x03},
+		},
+		{
+			gstest.Type(t, cs, "demo/shop", "Item"),
+			nil,
+			[]byte{0x00},
+		},
+	}
+	for _, tc := range tests {
+		got, err := Marshal(tc.expr, tc.value)
+		if err != nil {
+			t.Fatalf("Marshal(%v): %s", tc.value, err)
+		}
+		if !bytes.Equal(got, tc.want) {
+			t.Errorf("Marshal(%v) = % x, want % x", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	cs := compile(t)
+	item := gstest.Type(t, cs, "demo/shop", "Item")
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{map[string]interface{}{"Level": 300}, "Item.Level: value 300 out of range"},
+		{map[string]interface{}{"Color": -1}, "Item.Color: value -1 out of range"},
+		{map[string]interface{}{"Color": uint64(256)}, "Item.Color: value 256 out of range"},
+		{map[string]interface{}{"Nope": 1}, "unknown field Nope"},
+		{map[string]interface{}{"Corners": []interface{}{point(0, 0)}}, "Item.Corners: expect 4 elements got 1"},
+		{map[string]interface{}{"Corners": []interface{}{point(0, 0), map[string]interface{}{"X": "bad"}, nil, nil}}, "Item.Corners[1].X: expect integer got string"},
+		{map[string]interface{}{"Name": 1}, "Item.Name: expect string got int"},
+	}
+	for _, tc := range tests {
+		_, err := Marshal(item, tc.value)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Marshal(%v) error = %v, want %q", tc.value, err, tc.want)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	cs := compile(t)
+	item := gstest.Type(t, cs, "demo/shop", "Item")
+	data, err := Marshal(item, map[string]interface{}{
+		"Name":    "sword",
+		"Tags":    []interface{}{"a"},
+		"Corners": []interface{}{point(0, 0), point(1, 0), point(1, 1), point(0, 1)},
+		"Props":   map[interface{}]interface{}{"atk": 1.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 任何截断的数据都不能被成功解码
+	for i := range data {
+		if _, err := Unmarshal(item, data[:i]); err == nil {
+			t.Errorf("Unmarshal of %d/%d bytes succeeded", i, len(data))
+		}
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"trailing", append(append([]byte(nil), data...), 0), "1 trailing bytes"},
+		{"duplicate field", []byte{0x02, 0x03, 0x01, 0x01, 0x03, 0x01, 0x01}, "duplicate field id 3"},
+		{"invalid utf8", []byte{0x01, 0x01, 0x02, 0x01, 0xff}, "invalid utf8 string"},
+		{"huge count", []byte{0x01, 0x02, 0x02, 0xff, 0x01}, "exceeds remaining data"},
+	}
+	for _, tc := range tests {
+		_, err := Unmarshal(item, tc.data)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+	// 未知ID的域被跳过
+	got, err := Unmarshal(item, []byte{0x01, 0x63, 0x02, 0xaa, 0xbb})
+	if err != nil || !reflect.DeepEqual(got, map[string]interface{}{}) {
+		t.Errorf("unknown field: got %v, %v", got, err)
+	}
+}
+
+// TestRange 取值范围与gslang.IntRange一致
+func TestRange(t *testing.T) {
+	tests := []struct {
+		key  rune
+		min  int64
+		max  uint64
+		size int
+	}{
+		{gslang.KeyByte, 0, math.MaxUint8, 1},
+		{gslang.KeySByte, math.MinInt8, math.MaxInt8, 1},
+		{gslang.KeyInt16, math.MinInt16, math.MaxInt16, 2},
+		{gslang.KeyUInt16, 0, math.MaxUint16, 2},
+		{gslang.KeyInt32, math.MinInt32, math.MaxInt32, 4},
+		{gslang.KeyUInt32, 0, math.MaxUint32, 4},
+		{gslang.KeyInt64, math.MinInt64, math.MaxInt64, 8},
+		{gslang.KeyUInt64, 0, math.MaxUint64, 8},
+	}
+	for _, tc := range tests {
+		min, max, size, ok := builtinRange(tc.key)
+		if !ok || min != tc.min || max != tc.max || size != tc.size {
+			t.Errorf("builtinRange(%s) = %d, %d, %d, %v", gslang.TokenName(tc.key), min, max, size, ok)
+		}
+	}
+	if _, _, _, ok := builtinRange(gslang.KeyFloat64); ok {
+		t.Error("builtinRange(float64) succeeded")
+	}
+	cs := compile(t)
+	enums := []struct {
+		name string
+		min  int64
+		max  uint64
+	}{
+		{"Color", 0, math.MaxUint8},
+		{"Delta", math.MinInt16, math.MaxInt16},
+		{"Big", 0, math.MaxUint64},
+		{"Wide", math.MinInt64, math.MaxInt64},
+	}
+	for _, tc := range enums {
+		if min, max := enumRange(gstest.Type(t, cs, "demo/base", tc.name).(*ast.Enum)); min != tc.min || max != tc.max {
+			t.Errorf("enumRange(%s) = %d, %d", tc.name, min, max)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+// @file 	decode.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	decode
+
+package codec
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// maxZeroSizeCount 元素编码长度可能为0时 允许的最大元素个数
+const maxZeroSizeCount = 1 << 16
+
+// Decoder 二进制解码器 可以连续解码多个值
+type Decoder struct {
+	data   []byte // 待解码数据
+	offset int    // 当前解码位置
+	path   path   // 当前解码的值路径
+}
+
+// NewDecoder 新建解码器
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{
+		data: data,
+	}
+}
+
+// Len 返回未解码的字节数
+func (decoder *Decoder) Len() int {
+	return len(decoder.data) - decoder.offset
+}
+
+// Decode 按类型表达式解码一个值
+func (decoder *Decoder) Decode(expr ast.Expr) (interface{}, error) {
+	decoder.path = path{gslang.TypeName(expr)}
+	return decoder.decode(expr)
+}
+
+// read 读取n个字节
+func (decoder *Decoder) read(n int) ([]byte, error) {
+	if n < 0 || n > decoder.Len() {
+		return nil, decoder.path.errorf("unexpected end of data at offset %d", decoder.offset)
+	}
+	buff := decoder.data[decoder.offset : decoder.offset+n]
+	decoder.offset += n
+	return buff, nil
+}
+
+// uvarint 读取无符号变长整数
+func (decoder *Decoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(decoder.data[decoder.offset:])
+	if n <= 0 {
+		return 0, decoder.path.errorf("invalid varint at offset %d", decoder.offset)
+	}
+	decoder.offset += n
+	return v, nil
+}
+
+// fixed 以小端序读取size个字节的整数
+func (decoder *Decoder) fixed(size int) (uint64, error) {
+	buff, err := decoder.read(size)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for i, b := range buff {
+		v |= uint64(b) << (8 * uint(i))
+	}
+	return v, nil
+}
+
+// count 读取元素个数 元素个数不能超过剩余数据可以容纳的个数
+func (decoder *Decoder) count(element ast.Expr) (int, error) {
+	n, err := decoder.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	limit := uint64(maxZeroSizeCount)
+	if size := minSize(element, nil); size > 0 {
+		limit = uint64(decoder.Len() / size)
+	}
+	if n > limit {
+		return 0, decoder.path.errorf("element count %d exceeds remaining data", n)
+	}
+	return int(n), nil
+}
+
+// decode 解码单个值
+func (decoder *Decoder) decode(expr ast.Expr) (interface{}, error) {
+	k, target, key, err := resolve(expr)
+	if err != nil {
+		return nil, decoder.path.errorf("%s", err)
+	}
+	switch k {
+	case kindBuiltin:
+		return decoder.builtin(key)
+	case kindEnum:
+		return decoder.enum(target.(*ast.Enum))
+	case kindStruct:
+		return decoder.structValue(target.(*ast.Table))
+	case kindTable:
+		return decoder.table(target.(*ast.Table))
+	case kindList:
+		element := target.(*ast.List).Element
+		n, err := decoder.count(element)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.list(element, n)
+	case kindArray:
+		array := target.(*ast.Array)
+		n, err := decoder.count(array.Element)
+		if err != nil {
+			return nil, err
+		}
+		if n != int(array.Length) {
+			return nil, decoder.path.errorf("expect %d elements got %d", array.Length, n)
+		}
+		return decoder.list(array.Element, n)
+	default:
+		return decoder.mapValue(target.(*ast.Map))
+	}
+}
+
+// builtin 解码内置类型
+func (decoder *Decoder) builtin(key rune) (interface{}, error) {
+	switch key {
+	case gslang.KeyBool:
+		buff, err := decoder.read(1)
+		if err != nil {
+			return nil, err
+		}
+		if buff[0] > 1 {
+			return nil, decoder.path.errorf("invalid bool value %d", buff[0])
+		}
+		return buff[0] == 1, nil
+	case gslang.KeyString:
+		n, err := decoder.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(decoder.Len()) {
+			return nil, decoder.path.errorf("string length %d exceeds remaining data", n)
+		}
+		buff, _ := decoder.read(int(n))
+		if !utf8.Valid(buff) {
+			return nil, decoder.path.errorf("invalid utf8 string")
+		}
+		return string(buff), nil
+	case gslang.KeyFloat32:
+		v, err := decoder.fixed(4)
+		return math.Float32frombits(uint32(v)), err
+	case gslang.KeyFloat64:
+		v, err := decoder.fixed(8)
+		return math.Float64frombits(v), err
+	}
+	_, _, size, _ := builtinRange(key)
+	v, err := decoder.fixed(size)
+	if err != nil {
+		return nil, err
+	}
+	switch key {
+	case gslang.KeyByte:
+		return uint8(v), nil
+	case gslang.KeySByte:
+		return int8(v), nil
+	case gslang.KeyInt16:
+		return int16(v), nil
+	case gslang.KeyUInt16:
+		return uint16(v), nil
+	case gslang.KeyInt32:
+		return int32(v), nil
+	case gslang.KeyUInt32:
+		return uint32(v), nil
+	case gslang.KeyInt64:
+		return int64(v), nil
+	}
+	return v, nil
+}
+
+// enum 解码枚举 有符号枚举做符号扩展后为int64 无符号枚举为uint64
+func (decoder *Decoder) enum(enum *ast.Enum) (interface{}, error) {
+	v, err := decoder.fixed(int(enum.Length))
+	if err != nil {
+		return nil, err
+	}
+	if !enum.Signed {
+		return v, nil
+	}
+	shift := 64 - enum.Length*8
+	return int64(v<<shift) >> shift, nil
+}
+
+// structValue 解码结构体
+func (decoder *Decoder) structValue(table *ast.Table) (interface{}, error) {
+	fields := make(map[string]interface{}, len(table.Fields))
+	for _, field := range table.Fields {
+		decoder.path = append(decoder.path, "."+field.Name())
+		v, err := decoder.decode(field.Type)
+		if err != nil {
+			return nil, err
+		}
+		decoder.path = decoder.path[:len(decoder.path)-1]
+		fields[field.Name()] = v
+	}
+	return fields, nil
+}
+
+// table 解码表 跳过未知ID的域 以兼容新版本的表定义
+func (decoder *Decoder) table(table *ast.Table) (interface{}, error) {
+	n, err := decoder.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	// 每个域至少占用两个字节
+	if n > uint64(decoder.Len()/2) {
+		return nil, decoder.path.errorf("field count %d exceeds remaining data", n)
+	}
+	fields := make(map[string]interface{}, n)
+	seen := make(map[uint64]bool, n)
+	for i := uint64(0); i < n; i++ {
+		id, err := decoder.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if seen[id] {
+			return nil, decoder.path.errorf("duplicate field id %d", id)
+		}
+		seen[id] = true
+		length, err := decoder.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if length > uint64(decoder.Len()) {
+			return nil, decoder.path.errorf("field %d length %d exceeds remaining data", id, length)
+		}
+		payload, _ := decoder.read(int(length))
+		field := fieldByID(table, id)
+		if field == nil {
+			continue
+		}
+		sub := &Decoder{
+			data: payload,
+			path: append(decoder.path, "."+field.Name()),
+		}
+		v, err := sub.decode(field.Type)
+		if err != nil {
+			return nil, err
+		}
+		if sub.Len() != 0 {
+			return nil, sub.path.errorf("%d trailing bytes in field data", sub.Len())
+		}
+		fields[field.Name()] = v
+	}
+	return fields, nil
+}
+
+// fieldByID 按ID查找表的域
+func fieldByID(table *ast.Table, id uint64) *ast.Field {
+	for _, field := range table.Fields {
+		if uint64(field.ID) == id {
+			return field
+		}
+	}
+	return nil
+}
+
+// list 解码n个元素
+func (decoder *Decoder) list(element ast.Expr, n int) (interface{}, error) {
+	items := make([]interface{}, n)
+	for i := range items {
+		decoder.path = append(decoder.path, "["+strconv.Itoa(i)+"]")
+		v, err := decoder.decode(element)
+		if err != nil {
+			return nil, err
+		}
+		decoder.path = decoder.path[:len(decoder.path)-1]
+		items[i] = v
+	}
+	return items, nil
+}
+
+// mapValue 解码字典 key不能重复
+func (decoder *Decoder) mapValue(node *ast.Map) (interface{}, error) {
+	if err := checkKey(node.Key); err != nil {
+		return nil, decoder.path.errorf("%s", err)
+	}
+	n, err := decoder.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if size := minSize(node.Key, nil) + minSize(node.Value, nil); size > 0 && n > uint64(decoder.Len()/size) {
+		return nil, decoder.path.errorf("entry count %d exceeds remaining data", n)
+	}
+	result := make(map[interface{}]interface{}, n)
+	for i := uint64(0); i < n; i++ {
+		key, err := decoder.decode(node.Key)
+		if err != nil {
+			return nil, err
+		}
+		label := "[" + formatKey(key) + "]"
+		if _, ok := result[key]; ok {
+			return nil, decoder.path.errorf("duplicate map key %s", label)
+		}
+		decoder.path = append(decoder.path, label)
+		value, err := decoder.decode(node.Value)
+		if err != nil {
+			return nil, err
+		}
+		decoder.path = decoder.path[:len(decoder.path)-1]
+		result[key] = value
+	}
+	return result, nil
+}
+
+// minSize 返回类型编码后的最小字节数 visiting用于避免结构体的循环引用
+func minSize(expr ast.Expr, visiting map[*ast.Table]bool) int {
+	k, target, key, err := resolve(expr)
+	if err != nil {
+		return 0
+	}
+	switch k {
+	case kindBuiltin:
+		switch key {
+		case gslang.KeyBool, gslang.KeyString:
+			return 1
+		case gslang.KeyFloat32:
+			return 4
+		case gslang.KeyFloat64:
+			return 8
+		}
+		_, _, size, _ := builtinRange(key)
+		return size
+	case kindEnum:
+		return int(target.(*ast.Enum).Length)
+	case kindStruct:
+		table := target.(*ast.Table)
+		if visiting[table] {
+			return 0
+		}
+		if visiting == nil {
+			visiting = make(map[*ast.Table]bool)
+		}
+		visiting[table] = true
+		size := 0
+		for _, field := range table.Fields {
+			size += minSize(field.Type, visiting)
+		}
+		delete(visiting, table)
+		return size
+	}
+	return 1
+}
@@ -0,0 +1,355 @@
+// @file 	encode.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	encode
+
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// Encoder 二进制编码器 可以连续编码多个值
+type Encoder struct {
+	buff []byte // 已编码数据
+	path path   // 当前编码的值路径
+}
+
+// NewEncoder 新建编码器
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// Bytes 返回已编码的数据
+func (encoder *Encoder) Bytes() []byte {
+	return encoder.buff
+}
+
+// Reset 清空已编码的数据
+func (encoder *Encoder) Reset() {
+	encoder.buff = encoder.buff[:0]
+}
+
+// Encode 将值按类型表达式编码 追加到已编码数据之后 出错时已编码数据保持不变
+func (encoder *Encoder) Encode(expr ast.Expr, value interface{}) error {
+	start := len(encoder.buff)
+	encoder.path = path{gslang.TypeName(expr)}
+	if err := encoder.encode(expr, value); err != nil {
+		encoder.buff = encoder.buff[:start]
+		return err
+	}
+	return nil
+}
+
+// uvarint 写入无符号变长整数
+func (encoder *Encoder) uvarint(v uint64) {
+	var buff [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buff[:], v)
+	encoder.buff = append(encoder.buff, buff[:n]...)
+}
+
+// fixed 以小端序写入整数的低size个字节
+func (encoder *Encoder) fixed(v uint64, size int) {
+	for i := 0; i < size; i++ {
+		encoder.buff = append(encoder.buff, byte(v>>(8*uint(i))))
+	}
+}
+
+// encode 编码单个值
+func (encoder *Encoder) encode(expr ast.Expr, value interface{}) error {
+	k, target, key, err := resolve(expr)
+	if err != nil {
+		return encoder.path.errorf("%s", err)
+	}
+	switch k {
+	case kindBuiltin:
+		return encoder.builtin(key, value)
+	case kindEnum:
+		return encoder.enum(target.(*ast.Enum), value)
+	case kindStruct:
+		return encoder.structValue(target.(*ast.Table), value)
+	case kindTable:
+		return encoder.table(target.(*ast.Table), value)
+	case kindList:
+		items, err := encoder.items(value)
+		if err != nil {
+			return err
+		}
+		return encoder.list(target.(*ast.List).Element, items)
+	case kindArray:
+		array := target.(*ast.Array)
+		items, err := encoder.items(value)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			items = make([]interface{}, array.Length)
+		}
+		if len(items) != int(array.Length) {
+			return encoder.path.errorf("expect %d elements got %d", array.Length, len(items))
+		}
+		return encoder.list(array.Element, items)
+	default:
+		return encoder.mapValue(target.(*ast.Map), value)
+	}
+}
+
+// builtin 编码内置类型 整数及浮点数使用小端序定长编码 bool为一个字节 字符串以长度为前缀
+func (encoder *Encoder) builtin(key rune, value interface{}) error {
+	switch key {
+	case gslang.KeyBool:
+		v, ok := value.(bool)
+		if !ok && value != nil {
+			return encoder.path.errorf("expect bool got %T", value)
+		}
+		if v {
+			encoder.buff = append(encoder.buff, 1)
+		} else {
+			encoder.buff = append(encoder.buff, 0)
+		}
+		return nil
+	case gslang.KeyString:
+		v, ok := value.(string)
+		if !ok && value != nil {
+			return encoder.path.errorf("expect string got %T", value)
+		}
+		encoder.uvarint(uint64(len(v)))
+		encoder.buff = append(encoder.buff, v...)
+		return nil
+	case gslang.KeyFloat32, gslang.KeyFloat64:
+		var v float64
+		switch n := value.(type) {
+		case nil:
+		case float32:
+			v = float64(n)
+		case float64:
+			v = n
+		default:
+			return encoder.path.errorf("expect float got %T", value)
+		}
+		if key == gslang.KeyFloat32 {
+			encoder.fixed(uint64(math.Float32bits(float32(v))), 4)
+		} else {
+			encoder.fixed(math.Float64bits(v), 8)
+		}
+		return nil
+	}
+	min, max, size, _ := builtinRange(key)
+	v, err := encoder.integer(value, min, max)
+	if err != nil {
+		return err
+	}
+	encoder.fixed(v, size)
+	return nil
+}
+
+// integer 将任意Go整数转换为补码形式的uint64 并检查取值范围
+func (encoder *Encoder) integer(value interface{}, min int64, max uint64) (uint64, error) {
+	if value == nil {
+		return 0, nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		if n < min || (n >= 0 && uint64(n) > max) {
+			return 0, encoder.path.errorf("value %d out of range [%d, %d]", n, min, max)
+		}
+		return uint64(n), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := v.Uint()
+		if n > max {
+			return 0, encoder.path.errorf("value %d out of range [%d, %d]", n, min, max)
+		}
+		return n, nil
+	}
+	return 0, encoder.path.errorf("expect integer got %T", value)
+}
+
+// enum 编码枚举 按枚举长度使用小端序定长编码
+func (encoder *Encoder) enum(enum *ast.Enum, value interface{}) error {
+	min, max := enumRange(enum)
+	v, err := encoder.integer(value, min, max)
+	if err != nil {
+		return err
+	}
+	encoder.fixed(v, int(enum.Length))
+	return nil
+}
+
+// fields 将表或结构体的值转换为域名字到值的字典 检查未声明的域
+func (encoder *Encoder) fields(table *ast.Table, value interface{}) (map[string]interface{}, error) {
+	fields, ok := value.(map[string]interface{})
+	if !ok && value != nil {
+		return nil, encoder.path.errorf("expect map[string]interface{} got %T", value)
+	}
+	for name := range fields {
+		if _, ok := table.Field(name); !ok {
+			return nil, encoder.path.errorf("unknown field %s of %s", name, gslang.TypeName(table))
+		}
+	}
+	return fields, nil
+}
+
+// structValue 编码结构体 所有域按声明顺序依次编码 没有标签
+func (encoder *Encoder) structValue(table *ast.Table, value interface{}) error {
+	fields, err := encoder.fields(table, value)
+	if err != nil {
+		return err
+	}
+	for _, field := range table.Fields {
+		encoder.path = append(encoder.path, "."+field.Name())
+		if err := encoder.encode(field.Type, fields[field.Name()]); err != nil {
+			return err
+		}
+		encoder.path = encoder.path[:len(encoder.path)-1]
+	}
+	return nil
+}
+
+// table 编码表 以域个数为前缀 每个非nil的域编码为 ID 长度 数据
+func (encoder *Encoder) table(table *ast.Table, value interface{}) error {
+	fields, err := encoder.fields(table, value)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, field := range table.Fields {
+		if fields[field.Name()] != nil {
+			count++
+		}
+	}
+	encoder.uvarint(uint64(count))
+	for _, field := range table.Fields {
+		v := fields[field.Name()]
+		if v == nil {
+			continue
+		}
+		encoder.uvarint(uint64(field.ID))
+		encoder.path = append(encoder.path, "."+field.Name())
+		start := len(encoder.buff)
+		if err := encoder.encode(field.Type, v); err != nil {
+			return err
+		}
+		encoder.path = encoder.path[:len(encoder.path)-1]
+		// 数据编码完成后才知道长度 将数据移到长度前缀之后
+		payload := append([]byte(nil), encoder.buff[start:]...)
+		encoder.buff = encoder.buff[:start]
+		encoder.uvarint(uint64(len(payload)))
+		encoder.buff = append(encoder.buff, payload...)
+	}
+	return nil
+}
+
+// items 将切片或者数组的值转换为[]interface{}
+func (encoder *Encoder) items(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		return v, nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, encoder.path.errorf("expect []interface{} got %T", value)
+	}
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+	return items, nil
+}
+
+// list 编码切片或者数组 以元素个数为前缀
+func (encoder *Encoder) list(element ast.Expr, items []interface{}) error {
+	encoder.uvarint(uint64(len(items)))
+	for i, item := range items {
+		encoder.path = append(encoder.path, "["+strconv.Itoa(i)+"]")
+		if err := encoder.encode(element, item); err != nil {
+			return err
+		}
+		encoder.path = encoder.path[:len(encoder.path)-1]
+	}
+	return nil
+}
+
+// mapValue 编码字典 以键值对个数为前缀 键值对按key的编码结果排序 以保证编码结果唯一
+func (encoder *Encoder) mapValue(node *ast.Map, value interface{}) error {
+	if err := checkKey(node.Key); err != nil {
+		return encoder.path.errorf("%s", err)
+	}
+	if value == nil {
+		encoder.uvarint(0)
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map {
+		return encoder.path.errorf("expect map[interface{}]interface{} got %T", value)
+	}
+	type entry struct {
+		key   []byte
+		value interface{}
+		label string
+	}
+	var entries []*entry
+	for _, k := range v.MapKeys() {
+		label := "[" + formatKey(k.Interface()) + "]"
+		encoder.path = append(encoder.path, label)
+		start := len(encoder.buff)
+		if err := encoder.encode(node.Key, k.Interface()); err != nil {
+			return err
+		}
+		encoder.path = encoder.path[:len(encoder.path)-1]
+		entries = append(entries, &entry{
+			key:   append([]byte(nil), encoder.buff[start:]...),
+			value: v.MapIndex(k).Interface(),
+			label: label,
+		})
+		encoder.buff = encoder.buff[:start]
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+	encoder.uvarint(uint64(len(entries)))
+	for i, e := range entries {
+		if i > 0 && bytes.Equal(entries[i-1].key, e.key) {
+			return encoder.path.errorf("duplicate map key %s", e.label)
+		}
+		encoder.buff = append(encoder.buff, e.key...)
+		encoder.path = append(encoder.path, e.label)
+		if err := encoder.encode(node.Value, e.value); err != nil {
+			return err
+		}
+		encoder.path = encoder.path[:len(encoder.path)-1]
+	}
+	return nil
+}
+
+// checkKey 字典的key只能是内置类型或者枚举
+func checkKey(expr ast.Expr) error {
+	k, _, _, err := resolve(expr)
+	if err != nil {
+		return err
+	}
+	if k != kindBuiltin && k != kindEnum {
+		return fmt.Errorf("map key %s must be builtin type or enum", gslang.TypeName(expr))
+	}
+	return nil
+}
+
+// formatKey 格式化字典key 用于值路径
+func formatKey(key interface{}) string {
+	if s, ok := key.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(key)
+}
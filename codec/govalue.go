@@ -0,0 +1,403 @@
+// @file 	govalue.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	govalue
+
+package codec
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// TagName Go结构体字段对应gslang域名字的标签 如 `gslang:"Name"` 标签为-时忽略该字段
+// 没有标签时使用字段名 字段名与域名字大小写不同时也可以匹配
+const TagName = "gslang"
+
+// FromGo 将Go值按类型表达式转换为codec值
+// 表及结构体可以是Go结构体或者其指针 枚举可以是整数或者枚举值名字 codec值原样转换
+func FromGo(expr ast.Expr, value interface{}) (interface{}, error) {
+	return fromGo(expr, reflect.ValueOf(value), path{gslang.TypeName(expr)})
+}
+
+// ToGo 将codec值按类型表达式写入target指向的Go值
+// target可以指向Go结构体 切片 字典 基本类型 或者interface{}
+func ToGo(expr ast.Expr, value interface{}, target interface{}) error {
+	dst := reflect.ValueOf(target)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() {
+		return path{gslang.TypeName(expr)}.errorf("target must be a non-nil pointer got %T", target)
+	}
+	return toGo(expr, value, dst.Elem(), path{gslang.TypeName(expr)})
+}
+
+// indirect 解开指针及接口 nil返回无效值
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// goFields 返回Go结构体类型的字段与表的域的对应关系 域ID -> 字段下标
+func goFields(t reflect.Type, table *ast.Table, p path) (map[uint16]int, error) {
+	result := make(map[uint16]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup(TagName); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		field, ok := table.Field(name)
+		if !ok {
+			for _, candidate := range table.Fields {
+				if strings.EqualFold(candidate.Name(), name) {
+					field, ok = candidate, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return nil, p.errorf("go field %s.%s has no corresponding field in %s", t, f.Name, gslang.TypeName(table))
+		}
+		result[field.ID] = i
+	}
+	return result, nil
+}
+
+// fromGo 转换单个值
+func fromGo(expr ast.Expr, v reflect.Value, p path) (interface{}, error) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil, nil
+	}
+	k, target, key, err := resolve(expr)
+	if err != nil {
+		return nil, p.errorf("%s", err)
+	}
+	switch k {
+	case kindBuiltin:
+		switch v.Kind() {
+		case reflect.Bool:
+			return v.Bool(), nil
+		case reflect.String:
+			return v.String(), nil
+		case reflect.Float32, reflect.Float64:
+			if key == gslang.KeyFloat32 {
+				return float32(v.Float()), nil
+			}
+			return v.Float(), nil
+		}
+		return v.Interface(), nil
+	case kindEnum:
+		if v.Kind() == reflect.String {
+			if n, ok := enumByName(target.(*ast.Enum), v.String()); ok {
+				return n, nil
+			}
+			return nil, p.errorf("%s has no value named %s", gslang.TypeName(target), v.String())
+		}
+		return v.Interface(), nil
+	case kindStruct, kindTable:
+		table := target.(*ast.Table)
+		result := make(map[string]interface{})
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, p.errorf("expect map with string keys got %s", v.Type())
+			}
+			for _, name := range v.MapKeys() {
+				field, ok := table.Field(name.String())
+				if !ok {
+					return nil, p.errorf("unknown field %s of %s", name.String(), gslang.TypeName(table))
+				}
+				item, err := fromGo(field.Type, v.MapIndex(name), append(p, "."+field.Name()))
+				if err != nil {
+					return nil, err
+				}
+				if item != nil {
+					result[field.Name()] = item
+				}
+			}
+		case reflect.Struct:
+			fields, err := goFields(v.Type(), table, p)
+			if err != nil {
+				return nil, err
+			}
+			for _, field := range table.Fields {
+				i, ok := fields[field.ID]
+				if !ok {
+					continue
+				}
+				item, err := fromGo(field.Type, v.Field(i), append(p, "."+field.Name()))
+				if err != nil {
+					return nil, err
+				}
+				if item != nil {
+					result[field.Name()] = item
+				}
+			}
+		default:
+			return nil, p.errorf("expect struct or map got %s", v.Type())
+		}
+		return result, nil
+	case kindList, kindArray:
+		var element ast.Expr
+		if list, ok := target.(*ast.List); ok {
+			element = list.Element
+		} else {
+			element = target.(*ast.Array).Element
+		}
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, p.errorf("expect slice or array got %s", v.Type())
+		}
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			item, err := fromGo(element, v.Index(i), append(p, "["+strconv.Itoa(i)+"]"))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		node := target.(*ast.Map)
+		if v.Kind() != reflect.Map {
+			return nil, p.errorf("expect map got %s", v.Type())
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		result := make(map[interface{}]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			label := "[" + formatKey(k.Interface()) + "]"
+			key, err := fromGo(node.Key, k, append(p, label))
+			if err != nil {
+				return nil, err
+			}
+			item, err := fromGo(node.Value, v.MapIndex(k), append(p, label))
+			if err != nil {
+				return nil, err
+			}
+			result[key] = item
+		}
+		return result, nil
+	}
+}
+
+// toGo 将codec值写入Go值
+func toGo(expr ast.Expr, value interface{}, dst reflect.Value, p path) error {
+	if dst.Kind() == reflect.Ptr {
+		if value == nil {
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return toGo(expr, value, dst.Elem(), p)
+	}
+	if dst.Kind() == reflect.Interface && dst.NumMethod() == 0 {
+		if value == nil {
+			dst.Set(reflect.Zero(dst.Type()))
+		} else {
+			dst.Set(reflect.ValueOf(value))
+		}
+		return nil
+	}
+	if value == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	k, target, _, err := resolve(expr)
+	if err != nil {
+		return p.errorf("%s", err)
+	}
+	switch k {
+	case kindBuiltin, kindEnum:
+		return scalarToGo(target, value, dst, p)
+	case kindStruct, kindTable:
+		table := target.(*ast.Table)
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return p.errorf("expect map[string]interface{} got %T", value)
+		}
+		switch dst.Kind() {
+		case reflect.Map:
+			if dst.Type().Key().Kind() != reflect.String {
+				return p.errorf("can not store %s into %s", gslang.TypeName(table), dst.Type())
+			}
+			result := reflect.MakeMapWithSize(dst.Type(), len(fields))
+			for name, item := range fields {
+				field, ok := table.Field(name)
+				if !ok {
+					return p.errorf("unknown field %s of %s", name, gslang.TypeName(table))
+				}
+				elem := reflect.New(dst.Type().Elem()).Elem()
+				if err := toGo(field.Type, item, elem, append(p, "."+name)); err != nil {
+					return err
+				}
+				result.SetMapIndex(reflect.ValueOf(name).Convert(dst.Type().Key()), elem)
+			}
+			dst.Set(result)
+			return nil
+		case reflect.Struct:
+			index, err := goFields(dst.Type(), table, p)
+			if err != nil {
+				return err
+			}
+			dst.Set(reflect.Zero(dst.Type()))
+			for name, item := range fields {
+				field, ok := table.Field(name)
+				if !ok {
+					return p.errorf("unknown field %s of %s", name, gslang.TypeName(table))
+				}
+				i, ok := index[field.ID]
+				if !ok {
+					continue
+				}
+				if err := toGo(field.Type, item, dst.Field(i), append(p, "."+name)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return p.errorf("can not store %s into %s", gslang.TypeName(table), dst.Type())
+	case kindList, kindArray:
+		var element ast.Expr
+		if list, ok := target.(*ast.List); ok {
+			element = list.Element
+		} else {
+			element = target.(*ast.Array).Element
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return p.errorf("expect []interface{} got %T", value)
+		}
+		switch dst.Kind() {
+		case reflect.Slice:
+			dst.Set(reflect.MakeSlice(dst.Type(), len(items), len(items)))
+		case reflect.Array:
+			if dst.Len() != len(items) {
+				return p.errorf("can not store %d elements into %s", len(items), dst.Type())
+			}
+		default:
+			return p.errorf("can not store list into %s", dst.Type())
+		}
+		for i, item := range items {
+			if err := toGo(element, item, dst.Index(i), append(p, "["+strconv.Itoa(i)+"]")); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		node := target.(*ast.Map)
+		entries, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return p.errorf("expect map[interface{}]interface{} got %T", value)
+		}
+		if dst.Kind() != reflect.Map {
+			return p.errorf("can not store map into %s", dst.Type())
+		}
+		result := reflect.MakeMapWithSize(dst.Type(), len(entries))
+		for k, item := range entries {
+			label := "[" + formatKey(k) + "]"
+			key := reflect.New(dst.Type().Key()).Elem()
+			if err := toGo(node.Key, k, key, append(p, label)); err != nil {
+				return err
+			}
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := toGo(node.Value, item, elem, append(p, label)); err != nil {
+				return err
+			}
+			result.SetMapIndex(key, elem)
+		}
+		dst.Set(result)
+		return nil
+	}
+}
+
+// scalarToGo 将内置类型或者枚举的值写入Go值 枚举写入字符串时使用枚举值名字
+func scalarToGo(target ast.Expr, value interface{}, dst reflect.Value, p path) error {
+	v := reflect.ValueOf(value)
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = v.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if v.Uint() > 1<<63-1 {
+				return p.errorf("value %d overflows %s", v.Uint(), dst.Type())
+			}
+			n = int64(v.Uint())
+		default:
+			return p.errorf("can not store %T into %s", value, dst.Type())
+		}
+		if dst.OverflowInt(n) {
+			return p.errorf("value %d overflows %s", n, dst.Type())
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.Int() < 0 {
+				return p.errorf("value %d overflows %s", v.Int(), dst.Type())
+			}
+			n = uint64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n = v.Uint()
+		default:
+			return p.errorf("can not store %T into %s", value, dst.Type())
+		}
+		if dst.OverflowUint(n) {
+			return p.errorf("value %d overflows %s", n, dst.Type())
+		}
+		dst.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+			return p.errorf("can not store %T into %s", value, dst.Type())
+		}
+		dst.SetFloat(v.Float())
+	case reflect.Bool:
+		if v.Kind() != reflect.Bool {
+			return p.errorf("can not store %T into %s", value, dst.Type())
+		}
+		dst.SetBool(v.Bool())
+	case reflect.String:
+		if enum, ok := target.(*ast.Enum); ok {
+			n, ok := bigInt(value)
+			if !ok {
+				return p.errorf("can not store %T into %s", value, dst.Type())
+			}
+			name, ok := enumName(enum, n)
+			if !ok {
+				return p.errorf("%s has no name for value %s", gslang.TypeName(enum), n)
+			}
+			dst.SetString(name)
+			return nil
+		}
+		if v.Kind() != reflect.String {
+			return p.errorf("can not store %T into %s", value, dst.Type())
+		}
+		dst.SetString(v.String())
+	default:
+		return p.errorf("can not store %T into %s", value, dst.Type())
+	}
+	return nil
+}
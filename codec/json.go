@@ -0,0 +1,686 @@
+// @file 	json.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	json
+
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// JSON规范映射:
+//
+//	table                  -> 对象 以域名字为key 只输出非nil的域 按声明顺序输出
+//	struct                 -> 对象 输出所有的域
+//	byte ... int32 uint32  -> 数字
+//	int64 uint64           -> 十进制字符串 解码时也接受数字
+//	float32 float64        -> 数字 NaN及正负无穷为字符串 "NaN" "Infinity" "-Infinity"
+//	bool string            -> true false 及字符串
+//	enum                   -> 枚举值名字 没有对应名字的值输出为数字 解码时接受名字或者数字
+//	[]T [N]T               -> 数组 [N]T的元素个数必须为N
+//	map[K]V                -> 对象 key转换为字符串 枚举key使用名字 按key排序输出
+//
+// null等价于没有设置 表的域为null时跳过 其他位置的null解码为零值
+
+// jsonPath 编解码JSON时的路径 记录路径上的域 用于在错误信息中给出域的声明位置
+type jsonPath struct {
+	parts  []string
+	fields []*ast.Field
+}
+
+// push 进入下一层
+func (p *jsonPath) push(part string, field *ast.Field) {
+	p.parts = append(p.parts, part)
+	p.fields = append(p.fields, field)
+}
+
+// pop 返回上一层
+func (p *jsonPath) pop() {
+	p.parts = p.parts[:len(p.parts)-1]
+	p.fields = p.fields[:len(p.fields)-1]
+}
+
+// String 实现fmt.Stringer接口 如 $.Corners[2].X
+func (p *jsonPath) String() string {
+	return "$" + strings.Join(p.parts, "")
+}
+
+// errorf 生成带JSON路径及域声明位置的错误
+func (p *jsonPath) errorf(format string, args ...interface{}) error {
+	msg := fmt.Sprintf("%s: %s", p, fmt.Sprintf(format, args...))
+	for i := len(p.fields) - 1; i >= 0; i-- {
+		if field := p.fields[i]; field != nil {
+			table := field.Parent()
+			msg += fmt.Sprintf("\n\tsee field %s.%s: %s", table.Name(), field.Name(), gslang.Pos(field))
+			break
+		}
+	}
+	return gserrors.Newf(ErrCodec, "%s", msg)
+}
+
+// MarshalJSON 将codec值或者Go值按类型表达式编码为规范JSON
+func MarshalJSON(expr ast.Expr, value interface{}) ([]byte, error) {
+	value, err := FromGo(expr, value)
+	if err != nil {
+		return nil, err
+	}
+	encoder := &jsonEncoder{}
+	if err := encoder.encode(expr, value); err != nil {
+		return nil, err
+	}
+	return encoder.buff.Bytes(), nil
+}
+
+// UnmarshalJSON 按类型表达式解码规范JSON 返回codec值
+func UnmarshalJSON(expr ast.Expr, data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, gserrors.Newf(ErrCodec, "invalid json: %s", err)
+	}
+	// 值之后只能是空白
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, gserrors.Newf(ErrCodec, "invalid json: trailing data after value")
+	}
+	return (&jsonDecoder{}).decode(expr, raw)
+}
+
+// UnmarshalJSONInto 按类型表达式解码规范JSON 结果写入target指向的Go值
+func UnmarshalJSONInto(expr ast.Expr, data []byte, target interface{}) error {
+	value, err := UnmarshalJSON(expr, data)
+	if err != nil {
+		return err
+	}
+	return ToGo(expr, value, target)
+}
+
+// jsonEncoder JSON编码器
+type jsonEncoder struct {
+	buff bytes.Buffer
+	path jsonPath
+}
+
+// quote 输出JSON字符串 不转义HTML字符
+func (encoder *jsonEncoder) quote(s string) {
+	var buff bytes.Buffer
+	e := json.NewEncoder(&buff)
+	e.SetEscapeHTML(false)
+	e.Encode(s)
+	encoder.buff.Write(bytes.TrimRight(buff.Bytes(), "\n"))
+}
+
+// encode 编码单个值
+func (encoder *jsonEncoder) encode(expr ast.Expr, value interface{}) error {
+	k, target, key, err := resolve(expr)
+	if err != nil {
+		return encoder.path.errorf("%s", err)
+	}
+	switch k {
+	case kindBuiltin:
+		return encoder.builtin(key, value)
+	case kindEnum:
+		return encoder.enum(target.(*ast.Enum), value)
+	case kindStruct, kindTable:
+		return encoder.table(target.(*ast.Table), k == kindStruct, value)
+	case kindList, kindArray:
+		element := ast.Expr(nil)
+		length := -1
+		if list, ok := target.(*ast.List); ok {
+			element = list.Element
+		} else {
+			element = target.(*ast.Array).Element
+			length = int(target.(*ast.Array).Length)
+		}
+		items, ok := value.([]interface{})
+		if !ok && value != nil {
+			return encoder.path.errorf("expect []interface{} got %T", value)
+		}
+		if value == nil && length > 0 {
+			items = make([]interface{}, length)
+		}
+		if length >= 0 && len(items) != length {
+			return encoder.path.errorf("expect %d elements got %d", length, len(items))
+		}
+		encoder.buff.WriteByte('[')
+		for i, item := range items {
+			if i > 0 {
+				encoder.buff.WriteByte(',')
+			}
+			encoder.path.push("["+strconv.Itoa(i)+"]", nil)
+			if err := encoder.encode(element, item); err != nil {
+				return err
+			}
+			encoder.path.pop()
+		}
+		encoder.buff.WriteByte(']')
+		return nil
+	default:
+		return encoder.mapValue(target.(*ast.Map), value)
+	}
+}
+
+// builtin 编码内置类型
+func (encoder *jsonEncoder) builtin(key rune, value interface{}) error {
+	switch key {
+	case gslang.KeyBool:
+		v, ok := value.(bool)
+		if !ok && value != nil {
+			return encoder.path.errorf("expect bool got %T", value)
+		}
+		encoder.buff.WriteString(strconv.FormatBool(v))
+		return nil
+	case gslang.KeyString:
+		v, ok := value.(string)
+		if !ok && value != nil {
+			return encoder.path.errorf("expect string got %T", value)
+		}
+		encoder.quote(v)
+		return nil
+	case gslang.KeyFloat32, gslang.KeyFloat64:
+		var v float64
+		bits := 64
+		switch n := value.(type) {
+		case nil:
+		case float32:
+			v = float64(n)
+		case float64:
+			v = n
+		default:
+			return encoder.path.errorf("expect float got %T", value)
+		}
+		if key == gslang.KeyFloat32 {
+			bits = 32
+		}
+		switch {
+		case math.IsNaN(v):
+			encoder.buff.WriteString(`"NaN"`)
+		case math.IsInf(v, 1):
+			encoder.buff.WriteString(`"Infinity"`)
+		case math.IsInf(v, -1):
+			encoder.buff.WriteString(`"-Infinity"`)
+		default:
+			encoder.buff.WriteString(strconv.FormatFloat(v, 'g', -1, bits))
+		}
+		return nil
+	}
+	text, err := encoder.integer(key, value)
+	if err != nil {
+		return err
+	}
+	if key == gslang.KeyInt64 || key == gslang.KeyUInt64 {
+		encoder.quote(text)
+	} else {
+		encoder.buff.WriteString(text)
+	}
+	return nil
+}
+
+// integer 检查整数范围 返回十进制文本
+func (encoder *jsonEncoder) integer(key rune, value interface{}) (string, error) {
+	min, max, _, _ := builtinRange(key)
+	if value == nil {
+		return "0", nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		if n < min || (n >= 0 && uint64(n) > max) {
+			return "", encoder.path.errorf("value %d out of %s range", n, gslang.TokenName(key))
+		}
+		return strconv.FormatInt(n, 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := v.Uint()
+		if n > max {
+			return "", encoder.path.errorf("value %d out of %s range", n, gslang.TokenName(key))
+		}
+		return strconv.FormatUint(n, 10), nil
+	}
+	return "", encoder.path.errorf("expect integer got %T", value)
+}
+
+// enumInt 将枚举值转换为任意精度整数 并检查是否在枚举的取值范围内
+func (encoder *jsonEncoder) enumInt(enum *ast.Enum, value interface{}) (*big.Int, error) {
+	n, ok := bigInt(value)
+	if !ok {
+		return nil, encoder.path.errorf("expect integer got %T", value)
+	}
+	if err := gslang.CheckInt(n, enum); err != nil {
+		return nil, encoder.path.errorf("%s", err)
+	}
+	return n, nil
+}
+
+// enum 编码枚举 使用枚举值名字 没有对应名字时使用数字
+func (encoder *jsonEncoder) enum(enum *ast.Enum, value interface{}) error {
+	n, err := encoder.enumInt(enum, value)
+	if err != nil {
+		return err
+	}
+	if name, ok := enumName(enum, n); ok {
+		encoder.quote(name)
+	} else {
+		encoder.buff.WriteString(n.String())
+	}
+	return nil
+}
+
+// table 编码表或结构体
+func (encoder *jsonEncoder) table(table *ast.Table, isStruct bool, value interface{}) error {
+	fields, ok := value.(map[string]interface{})
+	if !ok && value != nil {
+		return encoder.path.errorf("expect map[string]interface{} got %T", value)
+	}
+	for name := range fields {
+		if _, ok := table.Field(name); !ok {
+			return encoder.path.errorf("unknown field %s of %s", name, gslang.TypeName(table))
+		}
+	}
+	encoder.buff.WriteByte('{')
+	first := true
+	for _, field := range table.Fields {
+		v := fields[field.Name()]
+		if v == nil && !isStruct {
+			continue
+		}
+		if !first {
+			encoder.buff.WriteByte(',')
+		}
+		first = false
+		encoder.quote(field.Name())
+		encoder.buff.WriteByte(':')
+		encoder.path.push("."+field.Name(), field)
+		if err := encoder.encode(field.Type, v); err != nil {
+			return err
+		}
+		encoder.path.pop()
+	}
+	encoder.buff.WriteByte('}')
+	return nil
+}
+
+// mapKey 将字典key转换为JSON对象的key
+func (encoder *jsonEncoder) mapKey(expr ast.Expr, key interface{}) (string, error) {
+	k, target, builtin, err := resolve(expr)
+	if err != nil {
+		return "", encoder.path.errorf("%s", err)
+	}
+	switch k {
+	case kindEnum:
+		n, err := encoder.enumInt(target.(*ast.Enum), key)
+		if err != nil {
+			return "", err
+		}
+		if name, ok := enumName(target.(*ast.Enum), n); ok {
+			return name, nil
+		}
+		return n.String(), nil
+	case kindBuiltin:
+		switch builtin {
+		case gslang.KeyString:
+			if s, ok := key.(string); ok {
+				return s, nil
+			}
+			return "", encoder.path.errorf("expect string map key got %T", key)
+		case gslang.KeyBool:
+			if b, ok := key.(bool); ok {
+				return strconv.FormatBool(b), nil
+			}
+			return "", encoder.path.errorf("expect bool map key got %T", key)
+		case gslang.KeyFloat32, gslang.KeyFloat64:
+			return "", encoder.path.errorf("float map key is not supported by json")
+		}
+		return encoder.integer(builtin, key)
+	}
+	return "", encoder.path.errorf("map key %s must be builtin type or enum", gslang.TypeName(expr))
+}
+
+// mapValue 编码字典 按key排序输出
+func (encoder *jsonEncoder) mapValue(node *ast.Map, value interface{}) error {
+	encoder.buff.WriteByte('{')
+	defer encoder.buff.WriteByte('}')
+	if value == nil {
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map {
+		return encoder.path.errorf("expect map[interface{}]interface{} got %T", value)
+	}
+	values := make(map[string]interface{}, v.Len())
+	var keys []string
+	for _, k := range v.MapKeys() {
+		key, err := encoder.mapKey(node.Key, k.Interface())
+		if err != nil {
+			return err
+		}
+		if _, ok := values[key]; ok {
+			return encoder.path.errorf("duplicate map key %s", key)
+		}
+		values[key] = v.MapIndex(k).Interface()
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i > 0 {
+			encoder.buff.WriteByte(',')
+		}
+		encoder.quote(key)
+		encoder.buff.WriteByte(':')
+		encoder.path.push("["+strconv.Quote(key)+"]", nil)
+		if err := encoder.encode(node.Value, values[key]); err != nil {
+			return err
+		}
+		encoder.path.pop()
+	}
+	return nil
+}
+
+// jsonDecoder JSON解码器 解码encoding/json使用UseNumber解析的结果
+type jsonDecoder struct {
+	path jsonPath
+}
+
+// kindOf 返回JSON值的类型名 用于错误信息
+func kindOf(raw interface{}) string {
+	switch raw.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// decode 解码单个值
+func (decoder *jsonDecoder) decode(expr ast.Expr, raw interface{}) (interface{}, error) {
+	k, target, key, err := resolve(expr)
+	if err != nil {
+		return nil, decoder.path.errorf("%s", err)
+	}
+	if raw == nil && k != kindStruct && k != kindArray {
+		return decoder.zero(k, target, key)
+	}
+	switch k {
+	case kindBuiltin:
+		return decoder.builtin(key, raw)
+	case kindEnum:
+		return decoder.enum(target.(*ast.Enum), raw)
+	case kindStruct, kindTable:
+		return decoder.table(target.(*ast.Table), k == kindStruct, raw)
+	case kindList, kindArray:
+		element := ast.Expr(nil)
+		length := -1
+		if list, ok := target.(*ast.List); ok {
+			element = list.Element
+		} else {
+			element = target.(*ast.Array).Element
+			length = int(target.(*ast.Array).Length)
+		}
+		items, ok := raw.([]interface{})
+		if raw == nil {
+			items, ok = make([]interface{}, length), true
+		}
+		if !ok {
+			return nil, decoder.path.errorf("expect array got %s", kindOf(raw))
+		}
+		if length >= 0 && len(items) != length {
+			return nil, decoder.path.errorf("expect %d elements got %d", length, len(items))
+		}
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			decoder.path.push("["+strconv.Itoa(i)+"]", nil)
+			v, err := decoder.decode(element, item)
+			if err != nil {
+				return nil, err
+			}
+			decoder.path.pop()
+			result[i] = v
+		}
+		return result, nil
+	default:
+		return decoder.mapValue(target.(*ast.Map), raw)
+	}
+}
+
+// zero 返回null对应的值 内置类型及枚举为零值 其他为nil
+func (decoder *jsonDecoder) zero(k kind, target ast.Expr, key rune) (interface{}, error) {
+	switch k {
+	case kindBuiltin:
+		return decoder.builtin(key, zeroJSON(key))
+	case kindEnum:
+		return enumGo(target.(*ast.Enum), new(big.Int)), nil
+	}
+	return nil, nil
+}
+
+// zeroJSON 返回内置类型零值对应的JSON值
+func zeroJSON(key rune) interface{} {
+	switch key {
+	case gslang.KeyBool:
+		return false
+	case gslang.KeyString:
+		return ""
+	}
+	return json.Number("0")
+}
+
+// builtin 解码内置类型
+func (decoder *jsonDecoder) builtin(key rune, raw interface{}) (interface{}, error) {
+	switch key {
+	case gslang.KeyBool:
+		if v, ok := raw.(bool); ok {
+			return v, nil
+		}
+		return nil, decoder.path.errorf("expect boolean got %s", kindOf(raw))
+	case gslang.KeyString:
+		if v, ok := raw.(string); ok {
+			return v, nil
+		}
+		return nil, decoder.path.errorf("expect string got %s", kindOf(raw))
+	case gslang.KeyFloat32, gslang.KeyFloat64:
+		var v float64
+		switch n := raw.(type) {
+		case json.Number:
+			f, err := strconv.ParseFloat(string(n), 64)
+			if err != nil {
+				return nil, decoder.path.errorf("invalid number %s", n)
+			}
+			v = f
+		case string:
+			switch n {
+			case "NaN":
+				v = math.NaN()
+			case "Infinity":
+				v = math.Inf(1)
+			case "-Infinity":
+				v = math.Inf(-1)
+			default:
+				return nil, decoder.path.errorf("invalid float %q", n)
+			}
+		default:
+			return nil, decoder.path.errorf("expect number got %s", kindOf(raw))
+		}
+		if key == gslang.KeyFloat32 {
+			if !math.IsInf(v, 0) && !math.IsNaN(v) && math.Abs(v) > math.MaxFloat32 {
+				return nil, decoder.path.errorf("value %s out of float32 range", raw)
+			}
+			return float32(v), nil
+		}
+		return v, nil
+	}
+	var text string
+	switch n := raw.(type) {
+	case json.Number:
+		text = string(n)
+	case string:
+		if key != gslang.KeyInt64 && key != gslang.KeyUInt64 {
+			return nil, decoder.path.errorf("expect number got string")
+		}
+		text = n
+	default:
+		return nil, decoder.path.errorf("expect integer got %s", kindOf(raw))
+	}
+	return decoder.parseInteger(key, text)
+}
+
+// parseInteger 解析十进制整数文本 并检查范围
+func (decoder *jsonDecoder) parseInteger(key rune, text string) (interface{}, error) {
+	min, max, _, _ := builtinRange(key)
+	if min < 0 {
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || n < min || n > int64(max) {
+			return nil, decoder.path.errorf("invalid %s value %s", gslang.TokenName(key), text)
+		}
+		switch key {
+		case gslang.KeySByte:
+			return int8(n), nil
+		case gslang.KeyInt16:
+			return int16(n), nil
+		case gslang.KeyInt32:
+			return int32(n), nil
+		}
+		return n, nil
+	}
+	n, err := strconv.ParseUint(text, 10, 64)
+	if err != nil || n > max {
+		return nil, decoder.path.errorf("invalid %s value %s", gslang.TokenName(key), text)
+	}
+	switch key {
+	case gslang.KeyByte:
+		return uint8(n), nil
+	case gslang.KeyUInt16:
+		return uint16(n), nil
+	case gslang.KeyUInt32:
+		return uint32(n), nil
+	}
+	return n, nil
+}
+
+// enumText 将枚举值名字或者十进制文本转换为枚举值
+func (decoder *jsonDecoder) enumText(enum *ast.Enum, text string, name bool) (interface{}, error) {
+	if name {
+		if v, ok := enumByName(enum, text); ok {
+			return v, nil
+		}
+	}
+	n, ok := new(big.Int).SetString(text, 10)
+	if !ok {
+		if name {
+			return nil, decoder.path.errorf("%s has no value named %s", gslang.TypeName(enum), text)
+		}
+		return nil, decoder.path.errorf("invalid %s value %s", gslang.TypeName(enum), text)
+	}
+	if err := gslang.CheckInt(n, enum); err != nil {
+		return nil, decoder.path.errorf("%s", err)
+	}
+	return enumGo(enum, n), nil
+}
+
+// enum 解码枚举 接受名字或者数字
+func (decoder *jsonDecoder) enum(enum *ast.Enum, raw interface{}) (interface{}, error) {
+	switch v := raw.(type) {
+	case string:
+		return decoder.enumText(enum, v, true)
+	case json.Number:
+		return decoder.enumText(enum, string(v), false)
+	}
+	return nil, decoder.path.errorf("expect enum name or number got %s", kindOf(raw))
+}
+
+// table 解码表或结构体 未知的域为错误 结构体缺少的域为零值
+func (decoder *jsonDecoder) table(table *ast.Table, isStruct bool, raw interface{}) (interface{}, error) {
+	object, ok := raw.(map[string]interface{})
+	if raw == nil {
+		object, ok = nil, true
+	}
+	if !ok {
+		return nil, decoder.path.errorf("expect object got %s", kindOf(raw))
+	}
+	for name := range object {
+		if _, ok := table.Field(name); !ok {
+			decoder.path.push("."+name, nil)
+			return nil, decoder.path.errorf("unknown field %s of %s", name, gslang.TypeName(table))
+		}
+	}
+	result := make(map[string]interface{}, len(object))
+	for _, field := range table.Fields {
+		item, ok := object[field.Name()]
+		if (!ok || item == nil) && !isStruct {
+			continue
+		}
+		decoder.path.push("."+field.Name(), field)
+		v, err := decoder.decode(field.Type, item)
+		if err != nil {
+			return nil, err
+		}
+		decoder.path.pop()
+		result[field.Name()] = v
+	}
+	return result, nil
+}
+
+// mapValue 解码字典 key按类型从字符串转换
+func (decoder *jsonDecoder) mapValue(node *ast.Map, raw interface{}) (interface{}, error) {
+	object, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, decoder.path.errorf("expect object got %s", kindOf(raw))
+	}
+	k, target, builtin, err := resolve(node.Key)
+	if err != nil {
+		return nil, decoder.path.errorf("%s", err)
+	}
+	result := make(map[interface{}]interface{}, len(object))
+	for text, item := range object {
+		decoder.path.push("["+strconv.Quote(text)+"]", nil)
+		var key interface{}
+		switch {
+		case k == kindEnum:
+			key, err = decoder.enumText(target.(*ast.Enum), text, true)
+		case k == kindBuiltin && builtin == gslang.KeyString:
+			key = text
+		case k == kindBuiltin && builtin == gslang.KeyBool:
+			key, err = strconv.ParseBool(text)
+			if err != nil || (text != "true" && text != "false") {
+				err = decoder.path.errorf("invalid bool map key %q", text)
+			}
+		case k == kindBuiltin && builtin != gslang.KeyFloat32 && builtin != gslang.KeyFloat64:
+			key, err = decoder.parseInteger(builtin, text)
+		default:
+			err = decoder.path.errorf("map key %s is not supported by json", gslang.TypeName(node.Key))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := result[key]; ok {
+			return nil, decoder.path.errorf("duplicate map key %q", text)
+		}
+		v, err := decoder.decode(node.Value, item)
+		if err != nil {
+			return nil, err
+		}
+		decoder.path.pop()
+		result[key] = v
+	}
+	return result, nil
+}
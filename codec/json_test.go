@@ -0,0 +1,227 @@
+// @file 	json_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	json_test
+
+package codec
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+func TestMarshalJSONCanonical(t *testing.T) {
+	cs := compile(t)
+	scalars := gstest.Type(t, cs, "demo/shop", "Scalars")
+	order := gstest.Type(t, cs, "demo/shop", "Order")
+	tests := []struct {
+		expr  ast.Expr
+		value interface{}
+		want  string
+	}{
+		{scalars, map[string]interface{}{"I64": int64(-1), "U64": uint64(math.MaxUint64), "I32": int32(-1)},
+			`{"I32":-1,"I64":"-1","U64":"18446744073709551615"}`},
+		{scalars, map[string]interface{}{"F32": float32(math.Inf(1)), "F64": math.NaN()},
+			`{"F32":"Infinity","F64":"NaN"}`},
+		{scalars, map[string]interface{}{"S": "<a&b>"}, `{"S":"<a&b>"}`},
+		{order, map[string]interface{}{"Counts": map[interface{}]interface{}{uint64(3): uint32(1), uint64(9): uint32(2)}},
+			`{"Counts":{"9":2,"Blue":1}}`},
+		{order, map[string]interface{}{"Path": nil}, `{}`},
+		{order, map[string]interface{}{"Item": map[string]interface{}{"Color": uint64(200), "Pos": nil}},
+			`{"Item":{"Color":200}}`},
+	}
+	for _, tc := range tests {
+		got, err := MarshalJSON(tc.expr, tc.value)
+		if err != nil {
+			t.Errorf("MarshalJSON(%v): %s", tc.value, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("MarshalJSON(%v) =\n%s\nwant\n%s", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	cs := compile(t)
+	item := gstest.Type(t, cs, "demo/shop", "Item")
+	tests := []struct {
+		data string
+		want map[string]interface{}
+	}{
+		// 64位整数接受数字及字符串 枚举接受名字及数字
+		{`{"ID": 7, "Color": 2}`, map[string]interface{}{"ID": uint64(7), "Color": uint64(2)}},
+		{`{"ID": "7", "Color": "Green"}`, map[string]interface{}{"ID": uint64(7), "Color": uint64(2)}},
+		// 表的null域跳过 结构体缺少的域为零值
+		{`{"Name": null, "Pos": {"X": 1}}`, map[string]interface{}{"Pos": point(1, 0)}},
+		{"  {}\n\t", map[string]interface{}{}},
+	}
+	for _, tc := range tests {
+		got, err := UnmarshalJSON(item, []byte(tc.data))
+		if err != nil {
+			t.Errorf("UnmarshalJSON(%s): %s", tc.data, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("UnmarshalJSON(%s) = %#v want %#v", tc.data, got, tc.want)
+		}
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	cs := compile(t)
+	item := gstest.Type(t, cs, "demo/shop", "Item")
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`{"Nope": 1}`, "$.Nope: unknown field Nope of demo/shop.Item"},
+		{`{"Color": "Purple"}`, "$.Color: demo/base.Color has no value named Purple\n\tsee field Item.Color: shop.gs(10:5)"},
+		{`{"Color": 256}`, "$.Color: 256 overflows demo/base.Color, range is [0, 255]"},
+		{`{"Level": 256}`, "$.Level: invalid byte value 256"},
+		{`{"Level": "1"}`, "$.Level: expect number got string"},
+		{`{"Corners": [{"X": 1}]}`, "$.Corners: expect 4 elements got 1"},
+		{`{"Corners": [null, {"X": "a"}, null, null]}`, "$.Corners[1].X: expect number got string\n\tsee field Point.X: base.gs(3:5)"},
+		{`{"Props": {"atk": true}}`, `$.Props["atk"]: expect number got boolean`},
+		{`{"Tags": "a"}`, "$.Tags: expect array got string"},
+		{`[]`, "$: expect object got array"},
+		{`{`, "invalid json"},
+		{`{} {}`, "trailing data"},
+		{`{}}`, "trailing data"},
+		{`{}]`, "trailing data"},
+		{`{} x`, "trailing data"},
+	}
+	for _, tc := range tests {
+		_, err := UnmarshalJSON(item, []byte(tc.data))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("UnmarshalJSON(%s) error = %v, want %q", tc.data, err, tc.want)
+		}
+	}
+}
+
+// TestJSONEnum64 64位枚举的取值超出int64时按无符号整数处理
+func TestJSONEnum64(t *testing.T) {
+	cs := compile(t)
+	big := gstest.Type(t, cs, "demo/base", "Big")
+	wide := gstest.Type(t, cs, "demo/base", "Wide")
+	tests := []struct {
+		expr ast.Expr
+		data string
+		want interface{}
+	}{
+		{big, `18446744073709551615`, uint64(math.MaxUint64)},
+		{big, `"Max"`, uint64(math.MaxUint64)},
+		{big, `"18446744073709551615"`, uint64(math.MaxUint64)},
+		{big, `9223372036854775808`, uint64(1 << 63)},
+		{wide, `-9223372036854775808`, int64(math.MinInt64)},
+	}
+	for _, tc := range tests {
+		got, err := UnmarshalJSON(tc.expr, []byte(tc.data))
+		if err != nil {
+			t.Errorf("UnmarshalJSON(%s): %s", tc.data, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("UnmarshalJSON(%s) = %#v want %#v", tc.data, got, tc.want)
+		}
+	}
+	if data, err := MarshalJSON(big, uint64(math.MaxUint64)); err != nil || string(data) != `"Max"` {
+		t.Errorf("MarshalJSON(MaxUint64) = %s, %v", data, err)
+	}
+	if data, err := MarshalJSON(big, uint64(1<<63)); err != nil || string(data) != `9223372036854775808` {
+		t.Errorf("MarshalJSON(1<<63) = %s, %v", data, err)
+	}
+	errors := []struct {
+		expr ast.Expr
+		data string
+		want string
+	}{
+		{big, `18446744073709551616`, "18446744073709551616 overflows demo/base.Big"},
+		{big, `-1`, "-1 overflows demo/base.Big"},
+		{big, `"Purple"`, "demo/base.Big has no value named Purple"},
+		{wide, `9223372036854775808`, "9223372036854775808 overflows demo/base.Wide"},
+	}
+	for _, tc := range errors {
+		_, err := UnmarshalJSON(tc.expr, []byte(tc.data))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("UnmarshalJSON(%s) error = %v, want %q", tc.data, err, tc.want)
+		}
+	}
+}
+
+// goItem 与Item对应的Go结构体
+type goItem struct {
+	ID      uint64 `gslang:"ID"`
+	Name    string
+	Tags    []string
+	Level   int
+	Pos     *goPoint
+	Color   string
+	Corners [4]goPoint
+	Props   map[string]float64
+	Ignored string `gslang:"-"`
+}
+
+// goPoint 与Point对应的Go结构体
+type goPoint struct {
+	X, Y int32
+}
+
+func TestJSONGoStruct(t *testing.T) {
+	cs := compile(t)
+	item := gstest.Type(t, cs, "demo/shop", "Item")
+	value := goItem{
+		ID:      1 << 40,
+		Name:    "sword",
+		Tags:    []string{"a"},
+		Level:   7,
+		Pos:     &goPoint{1, -2},
+		Color:   "Blue",
+		Corners: [4]goPoint{{0, 0}, {1, 0}, {1, 1}, {0, 1}},
+		Props:   map[string]float64{"atk": 1.5},
+		Ignored: "x",
+	}
+	data, err := MarshalJSON(item, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"ID":"1099511627776","Name":"sword","Tags":["a"],"Level":7,"Pos":{"X":1,"Y":-2},"Color":"Blue",` +
+		`"Corners":[{"X":0,"Y":0},{"X":1,"Y":0},{"X":1,"Y":1},{"X":0,"Y":1}],"Props":{"atk":1.5}}`
+	if string(data) != want {
+		t.Fatalf("MarshalJSON =\n%s\nwant\n%s", data, want)
+	}
+	var got goItem
+	if err := UnmarshalJSONInto(item, data, &got); err != nil {
+		t.Fatal(err)
+	}
+	value.Ignored = ""
+	if !reflect.DeepEqual(got, value) {
+		t.Fatalf("UnmarshalJSONInto = %+v want %+v", got, value)
+	}
+	// Go值与二进制编码结果一致
+	fromGo, err := FromGo(item, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary, err := Marshal(item, fromGo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Unmarshal(item, binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back goItem
+	if err := ToGo(item, decoded, &back); err != nil || !reflect.DeepEqual(back, value) {
+		t.Fatalf("ToGo = %+v, %v want %+v", back, err, value)
+	}
+	if err := UnmarshalJSONInto(item, []byte(`{"Level": 200}`), &struct{ Level int8 }{}); err == nil ||
+		!strings.Contains(err.Error(), "overflows int8") {
+		t.Errorf("UnmarshalJSONInto overflow error = %v", err)
+	}
+}
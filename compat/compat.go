@@ -0,0 +1,346 @@
+// @file 	compat.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	compat
+
+// Package compat 检查同一个包的两个版本之间的线上兼容性
+//
+// 兼容性以codec包的二进制格式及rpc包的调用分发为准:
+// 表的域按ID编码 未知的域被跳过 结构体按位置编码 枚举按Length字节编码
+// 函数按 (协议全名, Method.ID) 分发 参数及返回值按位置编码
+package compat
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// Kind 变更种类
+type Kind string
+
+// 变更种类
+const (
+	TypeRemoved      Kind = "type-removed"       // 类型被删除
+	TypeKindChanged  Kind = "type-kind-changed"  // 类型种类改变 如表改为枚举
+	FieldIDReused    Kind = "field-id-reused"    // 域ID被另一个名字及类型的域使用
+	FieldRenamed     Kind = "field-renamed"      // 表的域改名 ID及类型不变
+	FieldTypeChanged Kind = "field-type-changed" // 域的类型改变
+	FieldRemoved     Kind = "field-removed"      // 表的域被删除
+	FieldAdded       Kind = "field-added"        // 表新增了域
+	StructLayout     Kind = "struct-layout"      // 结构体的布局改变
+	EnumValueRemoved Kind = "enum-value-removed" // 枚举值被删除
+	EnumValueChanged Kind = "enum-value-changed" // 枚举值的值改变
+	EnumValueAdded   Kind = "enum-value-added"   // 枚举新增了值
+	EnumWidthChanged Kind = "enum-width-changed" // 枚举的Length或者Signed改变
+	MethodRemoved    Kind = "method-removed"     // 函数被删除
+	MethodIDShifted  Kind = "method-id-shifted"  // 函数ID改变
+	MethodAdded      Kind = "method-added"       // 协议新增了函数
+	ParamsChanged    Kind = "params-changed"     // 函数参数列表改变
+	ReturnChanged    Kind = "return-changed"     // 函数返回值列表改变
+	ContractBases    Kind = "contract-bases"     // 协议的父协议列表改变
+	TypeAdded        Kind = "type-added"         // 新增了类型
+	ErrorEnumChanged Kind = "error-enum-changed" // 枚举的@Error标记改变
+)
+
+// Change 两个版本之间的一处变更
+type Change struct {
+	Kind     Kind            `json:"kind"`     // 变更种类
+	Breaking bool            `json:"breaking"` // 是否破坏兼容性
+	Path     string          `json:"path"`     // 变更的位置 如 Item.Name Shop.Get
+	Message  string          `json:"message"`  // 变更描述
+	Old      gslang.Position `json:"old"`      // 旧版本中的位置 不存在时无效
+	New      gslang.Position `json:"new"`      // 新版本中的位置 不存在时无效
+}
+
+// String 实现fmt.Stringer接口
+func (change *Change) String() string {
+	var buff bytes.Buffer
+	level := "warning"
+	if change.Breaking {
+		level = "breaking"
+	}
+	buff.WriteString(fmt.Sprintf("%s: %s: %s [%s]", level, change.Path, change.Message, change.Kind))
+	if change.Old.Valid() {
+		buff.WriteString(fmt.Sprintf("\n\told: %s", change.Old))
+	}
+	if change.New.Valid() {
+		buff.WriteString(fmt.Sprintf("\n\tnew: %s", change.New))
+	}
+	return buff.String()
+}
+
+// Report 检查结果
+type Report struct {
+	Package string    `json:"package"` // 包名
+	Changes []*Change `json:"changes"` // 按位置排序的变更列表
+}
+
+// Breaking 返回破坏兼容性的变更
+func (report *Report) Breaking() []*Change {
+	var changes []*Change
+	for _, change := range report.Changes {
+		if change.Breaking {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// Compatible 检查新版本是否与旧版本兼容
+func (report *Report) Compatible() bool {
+	return len(report.Breaking()) == 0
+}
+
+// String 实现fmt.Stringer接口
+func (report *Report) String() string {
+	var buff bytes.Buffer
+	for _, change := range report.Changes {
+		buff.WriteString(change.String())
+		buff.WriteByte('\n')
+	}
+	breaking := len(report.Breaking())
+	buff.WriteString(fmt.Sprintf("%s: %d changes, %d breaking\n", report.Package, len(report.Changes), breaking))
+	return buff.String()
+}
+
+// checker 兼容性检查器
+type checker struct {
+	changes []*Change
+}
+
+// report 记录一处变更
+func (checker *checker) report(kind Kind, breaking bool, path string, old, new ast.Node, format string, args ...interface{}) {
+	change := &Change{
+		Kind:     kind,
+		Breaking: breaking,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if old != nil {
+		change.Old = gslang.FilePos(old)
+	}
+	if new != nil {
+		change.New = gslang.FilePos(new)
+	}
+	checker.changes = append(checker.changes, change)
+}
+
+// CheckDirs 分别从两个目录编译同一个包的旧版本及新版本并比较
+func CheckDirs(packageName string, oldDir string, newDir string) (*Report, error) {
+	old, new, err := gslang.CompileVersions(packageName, oldDir, newDir)
+	if err != nil {
+		return nil, err
+	}
+	return Check(old, new), nil
+}
+
+// Check 比较同一个包的旧版本及新版本 两个版本都必须已经连接
+func Check(old, new *ast.Package) *Report {
+	checker := &checker{}
+	olds := types(old)
+	news := types(new)
+	for _, expr := range gslang.Types(old) {
+		name := expr.Name()
+		target, ok := news[name]
+		if !ok {
+			checker.report(TypeRemoved, true, name, expr, nil, "%s %s removed", gslang.KindName(expr), name)
+			continue
+		}
+		if gslang.KindName(expr) != gslang.KindName(target) {
+			checker.report(TypeKindChanged, true, name, expr, target, "%s changed to %s", gslang.KindName(expr), gslang.KindName(target))
+			continue
+		}
+		switch node := expr.(type) {
+		case *ast.Table:
+			checker.table(node, target.(*ast.Table))
+		case *ast.Enum:
+			checker.enum(node, target.(*ast.Enum))
+		case *ast.Contract:
+			checker.contract(node, target.(*ast.Contract))
+		}
+	}
+	for _, expr := range gslang.Types(new) {
+		if _, ok := olds[expr.Name()]; !ok {
+			checker.report(TypeAdded, false, expr.Name(), nil, expr, "%s %s added", gslang.KindName(expr), expr.Name())
+		}
+	}
+	sort.SliceStable(checker.changes, func(i, j int) bool {
+		return checker.changes[i].Path < checker.changes[j].Path
+	})
+	return &Report{Package: new.Name(), Changes: checker.changes}
+}
+
+// types 返回包内的类型 名字 -> 类型
+func types(pkg *ast.Package) map[string]ast.Expr {
+	result := make(map[string]ast.Expr)
+	for _, expr := range gslang.Types(pkg) {
+		result[expr.Name()] = expr
+	}
+	return result
+}
+
+// table 比较表或者结构体
+func (checker *checker) table(old, new *ast.Table) {
+	if gslang.IsStruct(old) {
+		checker.structLayout(old, new)
+		return
+	}
+	news := make(map[uint16]*ast.Field)
+	for _, field := range new.Fields {
+		news[field.ID] = field
+	}
+	olds := make(map[uint16]*ast.Field)
+	for _, field := range old.Fields {
+		olds[field.ID] = field
+		path := old.Name() + "." + field.Name()
+		target, ok := news[field.ID]
+		if !ok {
+			checker.report(FieldRemoved, false, path, field, nil, "field %s(id %d) removed", field.Name(), field.ID)
+			continue
+		}
+		if target.Name() != field.Name() {
+			// 表的域按ID编码 只改名不影响二进制兼容性
+			if gslang.TypeName(field.Type) == gslang.TypeName(target.Type) {
+				checker.report(FieldRenamed, false, path, field, target,
+					"field id %d renamed from %s to %s", field.ID, field.Name(), target.Name())
+				continue
+			}
+			checker.report(FieldIDReused, true, path, field, target,
+				"field id %d reused by %s %s, was %s %s", field.ID,
+				target.Name(), gslang.TypeName(target.Type), field.Name(), gslang.TypeName(field.Type))
+			continue
+		}
+		if gslang.TypeName(field.Type) != gslang.TypeName(target.Type) {
+			checker.report(FieldTypeChanged, true, path, field, target,
+				"field %s type changed from %s to %s", field.Name(), gslang.TypeName(field.Type), gslang.TypeName(target.Type))
+		}
+	}
+	for _, field := range new.Fields {
+		if _, ok := olds[field.ID]; !ok {
+			checker.report(FieldAdded, false, new.Name()+"."+field.Name(), nil, field,
+				"field %s(id %d) added", field.Name(), field.ID)
+		}
+	}
+}
+
+// structLayout 比较结构体的布局 结构体按位置编码 任何域的增删改都破坏兼容性
+func (checker *checker) structLayout(old, new *ast.Table) {
+	length := len(old.Fields)
+	if len(new.Fields) > length {
+		length = len(new.Fields)
+	}
+	for i := 0; i < length; i++ {
+		switch {
+		case i >= len(new.Fields):
+			field := old.Fields[i]
+			checker.report(StructLayout, true, old.Name()+"."+field.Name(), field, new,
+				"struct field %d %s %s removed", i, field.Name(), gslang.TypeName(field.Type))
+		case i >= len(old.Fields):
+			field := new.Fields[i]
+			checker.report(StructLayout, true, new.Name()+"."+field.Name(), old, field,
+				"struct field %d %s %s added", i, field.Name(), gslang.TypeName(field.Type))
+		default:
+			field, target := old.Fields[i], new.Fields[i]
+			if gslang.TypeName(field.Type) != gslang.TypeName(target.Type) {
+				checker.report(StructLayout, true, old.Name()+"."+field.Name(), field, target,
+					"struct field %d changed from %s %s to %s %s", i,
+					field.Name(), gslang.TypeName(field.Type), target.Name(), gslang.TypeName(target.Type))
+			} else if field.Name() != target.Name() {
+				checker.report(StructLayout, false, old.Name()+"."+field.Name(), field, target,
+					"struct field %d renamed from %s to %s", i, field.Name(), target.Name())
+			}
+		}
+	}
+}
+
+// enum 比较枚举
+func (checker *checker) enum(old, new *ast.Enum) {
+	if old.Length != new.Length || old.Signed != new.Signed {
+		checker.report(EnumWidthChanged, true, old.Name(), old, new,
+			"enum width changed from %s to %s", gslang.EnumBaseName(old), gslang.EnumBaseName(new))
+	}
+	if gslang.IsError(old) != gslang.IsError(new) {
+		checker.report(ErrorEnumChanged, true, old.Name(), old, new,
+			"@Error attribute changed from %v to %v", gslang.IsError(old), gslang.IsError(new))
+	}
+	for _, val := range gslang.EnumVals(old) {
+		path := old.Name() + "." + val.Name()
+		target, ok := new.Values[val.Name()]
+		if !ok {
+			checker.report(EnumValueRemoved, true, path, val, nil, "enum value %s(%s) removed", val.Name(), gslang.EnumValue(val))
+			continue
+		}
+		if target.Value != val.Value {
+			checker.report(EnumValueChanged, true, path, val, target,
+				"enum value %s changed from %s to %s", val.Name(), gslang.EnumValue(val), gslang.EnumValue(target))
+		}
+	}
+	for _, val := range gslang.EnumVals(new) {
+		if _, ok := old.Values[val.Name()]; !ok {
+			checker.report(EnumValueAdded, false, new.Name()+"."+val.Name(), nil, val,
+				"enum value %s(%s) added", val.Name(), gslang.EnumValue(val))
+		}
+	}
+}
+
+// contract 比较协议
+func (checker *checker) contract(old, new *ast.Contract) {
+	if bases(old) != bases(new) {
+		checker.report(ContractBases, false, old.Name(), old, new,
+			"bases changed from (%s) to (%s)", bases(old), bases(new))
+	}
+	for _, method := range gslang.Methods(old) {
+		path := old.Name() + "." + method.Name()
+		target, ok := new.Methods[method.Name()]
+		if !ok {
+			checker.report(MethodRemoved, true, path, method, nil, "method %s(id %d) removed", method.Name(), method.ID)
+			continue
+		}
+		if target.ID != method.ID {
+			checker.report(MethodIDShifted, true, path, method, target,
+				"method %s id shifted from %d to %d", method.Name(), method.ID, target.ID)
+		}
+		if params(method.Params) != params(target.Params) {
+			checker.report(ParamsChanged, true, path, method, target,
+				"params changed from (%s) to (%s)", params(method.Params), params(target.Params))
+		}
+		if params(method.Return) != params(target.Return) {
+			checker.report(ReturnChanged, true, path, method, target,
+				"return changed from (%s) to (%s)", params(method.Return), params(target.Return))
+		}
+	}
+	for _, method := range gslang.Methods(new) {
+		if _, ok := old.Methods[method.Name()]; !ok {
+			checker.report(MethodAdded, false, new.Name()+"."+method.Name(), nil, method,
+				"method %s(id %d) added", method.Name(), method.ID)
+		}
+	}
+}
+
+// bases 返回父协议列表的描述
+func bases(contract *ast.Contract) string {
+	var buff bytes.Buffer
+	for i, base := range contract.Bases {
+		if i != 0 {
+			buff.WriteString(", ")
+		}
+		buff.WriteString(gslang.TypeName(base))
+	}
+	return buff.String()
+}
+
+// params 返回参数列表的描述
+func params(list []*ast.Param) string {
+	var buff bytes.Buffer
+	for i, param := range list {
+		if i != 0 {
+			buff.WriteString(", ")
+		}
+		buff.WriteString(gslang.TypeName(param.Type))
+	}
+	return buff.String()
+}
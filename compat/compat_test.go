@@ -0,0 +1,219 @@
+// @file 	compat_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	compat_test
+
+package compat
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// oldShop 旧版本
+const oldShop = `
+struct Point {
+    X int32;
+    Y int32;
+}
+
+table Item {
+    ID uint64;
+    Name string;
+    Level byte;
+    Grade byte;
+    Price uint32;
+    Note string;
+}
+
+table Bag {
+    Size int32;
+}
+
+enum Color(byte) {
+    Red(1), Green(2), Blue(3)
+}
+
+@gslang.Error
+enum ShopError(int32) {
+    NotFound(1)
+}
+
+table Removed {}
+
+contract Shop {
+    Get(id uint64) -> (Item);
+    Put(Item);
+    Count() -> (uint32);
+    Drop(id uint64);
+}
+`
+
+// newShop 新版本
+const newShop = `
+struct Point {
+    Left int32;
+    Y int64;
+}
+
+table Item {
+    ID uint64;
+    Title string;
+    Level int32;
+    Rank int32;
+    Cost uint32;
+}
+
+table Bag {
+    Size int32;
+    Tags []string;
+}
+
+enum Color(uint16) {
+    Red(1), Green(4), Purple(5)
+}
+
+enum ShopError(int32) {
+    NotFound(1)
+}
+
+table Added {}
+
+contract Shop {
+    Get(id uint64) -> (Item);
+    Put(Item, bool);
+    Count() -> (uint64);
+    Find(name string) -> (Item);
+}
+`
+
+// check 在临时GOPATH下比较同一个包的两个版本
+func check(t *testing.T, old, new string) *Report {
+	gopath := gstest.GOPATH(t, map[string]string{
+		"v1/shop.gs": old,
+		"v2/shop.gs": new,
+	})
+	t.Setenv("GOPATH", gopath)
+	report, err := CheckDirs("demo/shop", filepath.Join(gopath, "src", "v1"), filepath.Join(gopath, "src", "v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestCheck(t *testing.T) {
+	report := check(t, oldShop, newShop)
+	got := make(map[string]*Change)
+	for _, change := range report.Changes {
+		key := string(change.Kind) + " " + change.Path
+		if _, ok := got[key]; ok {
+			t.Errorf("duplicate change %s", key)
+		}
+		got[key] = change
+	}
+	want := map[string]bool{
+		"struct-layout Point.X":          false, // 只改名
+		"struct-layout Point.Y":          true,
+		"field-renamed Item.Name":        false,
+		"field-type-changed Item.Level":  true,
+		"field-id-reused Item.Grade":     true, // 改名且改类型
+		"field-renamed Item.Price":       false,
+		"field-removed Item.Note":        false,
+		"field-added Bag.Tags":           false,
+		"enum-width-changed Color":       true,
+		"enum-value-changed Color.Green": true,
+		"enum-value-removed Color.Blue":  true,
+		"enum-value-added Color.Purple":  false,
+		"error-enum-changed ShopError":   true,
+		"type-removed Removed":           true,
+		"type-added Added":               false,
+		"params-changed Shop.Put":        true,
+		"return-changed Shop.Count":      true,
+		"method-removed Shop.Drop":       true,
+		"method-added Shop.Find":         false,
+	}
+	for key, breaking := range want {
+		change, ok := got[key]
+		if !ok {
+			t.Errorf("missing change %s", key)
+			continue
+		}
+		if change.Breaking != breaking {
+			t.Errorf("%s breaking = %v want %v", key, change.Breaking, breaking)
+		}
+		if !change.Old.Valid() && !change.New.Valid() {
+			t.Errorf("%s has no position", key)
+		}
+	}
+	for key := range got {
+		if _, ok := want[key]; !ok {
+			t.Errorf("unexpected change %s: %s", key, got[key])
+		}
+	}
+	if report.Compatible() {
+		t.Error("report is compatible")
+	}
+	if !sort.SliceIsSorted(report.Changes, func(i, j int) bool { return report.Changes[i].Path < report.Changes[j].Path }) {
+		t.Error("changes are not sorted by path")
+	}
+	if renamed := got["field-renamed Item.Name"]; renamed.Message != "field id 1 renamed from Name to Title" {
+		t.Errorf("rename message = %q", renamed.Message)
+	}
+	if reused := got["field-id-reused Item.Grade"]; reused.Message != "field id 3 reused by Rank int32, was Grade byte" {
+		t.Errorf("reuse message = %q", reused.Message)
+	}
+	if width := got["enum-width-changed Color"]; width.Message != "enum width changed from byte to uint16" {
+		t.Errorf("width message = %q", width.Message)
+	}
+	// 文件名使用绝对路径 以区分两个版本中的同名文件
+	if change := got["field-renamed Item.Name"]; !strings.Contains(change.Old.Filename, "v1") || !strings.Contains(change.New.Filename, "v2") {
+		t.Errorf("positions %s %s", change.Old, change.New)
+	}
+	text := report.String()
+	if !strings.Contains(text, "\nbreaking: Item.Grade: ") || !strings.HasSuffix(text, "demo/shop: 19 changes, 11 breaking\n") {
+		t.Errorf("String() =\n%s", text)
+	}
+}
+
+// TestCompatible 只有非破坏性变更时兼容
+func TestCompatible(t *testing.T) {
+	report := check(t, `
+table Item {
+    ID uint64;
+    Name string;
+}
+contract Shop {
+    Get(id uint64) -> (Item);
+}
+`, `
+table Item {
+    Key uint64;
+    Name string;
+    Tags []string;
+}
+table Tag {}
+contract Shop {
+    Get(id uint64) -> (Item);
+    Find(name string) -> (Item);
+}
+`)
+	if !report.Compatible() || len(report.Breaking()) != 0 {
+		t.Fatalf("report is not compatible:\n%s", report)
+	}
+	var kinds []string
+	for _, change := range report.Changes {
+		kinds = append(kinds, string(change.Kind))
+	}
+	sort.Strings(kinds)
+	want := "field-added field-renamed method-added type-added"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Fatalf("changes %s want %s", got, want)
+	}
+	if same := check(t, oldShop, oldShop); len(same.Changes) != 0 {
+		t.Fatalf("identical versions report changes:\n%s", same)
+	}
+}
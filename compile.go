@@ -0,0 +1,99 @@
+// @file 	compile.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	compile
+
+package gslang
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang/ast"
+)
+
+// Options 编译选项
+type Options struct {
+	Packages []string // 需要编译的包名 引用的包会一起编译
+	GOPATH   []string // 包的查找路径 为空时使用环境变量GOPATH
+	Workers  int      // 并发解析及编译的协程数 小于等于0时为GOMAXPROCS
+	CacheDir string   // 解析结果的缓存目录 为空时不使用缓存 连接总是重新进行 见CompileS.UseCache
+}
+
+// Timings 编译各阶段的耗时 Parse及Link为所有协程的累计耗时 并发时可能大于Total
+type Timings struct {
+	Parse time.Duration // 读取及解析源文件的耗时 不包括等待引用的包编译的时间
+	Link  time.Duration // 连接耗时
+	Total time.Duration // 总耗时
+}
+
+// Result 编译结果
+type Result struct {
+	CompileS    *CompileS      // 编译使用的编译器 可用于查询类型或者继续编译其他包
+	Packages    []*ast.Package // 按Options.Packages顺序编译得到的包 不包括引用的包
+	Diagnostics []*Diagnostic  // 编译过程中产生的警告
+	Changed     []string       // 与缓存中上一次编译结果相比发生变化的包 包括引用的包 没有使用缓存时为所有包
+	Timings     Timings        // 各阶段耗时
+}
+
+// Compile 按选项编译指定的包 ctx取消或者超时时中断目录遍历及连接并返回ctx.Err()
+// 任何内部错误都以error返回 不会panic 出错时Result中保留已经编译完成的包及诊断信息
+// ctx为nil时等同于context.Background()
+func Compile(ctx context.Context, opts Options) (result *Result, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	start := time.Now()
+	result = &Result{}
+	defer func() {
+		if e := recover(); e != nil {
+			err = recoverError(e)
+		}
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if cs := result.CompileS; cs != nil {
+			// 编译结束后继续使用编译器时不再受ctx影响
+			cs.ctx = nil
+			result.Diagnostics = cs.Diagnostics
+			result.Changed = cs.Changed()
+			result.Timings.Parse = cs.parseTime
+			result.Timings.Link = cs.linkTime
+		}
+		result.Timings.Total = time.Since(start)
+	}()
+	goPath := opts.GOPATH
+	if len(goPath) == 0 {
+		if GOPATH := os.Getenv("GOPATH"); GOPATH != "" {
+			goPath = filepath.SplitList(GOPATH)
+		}
+	}
+	if len(goPath) == 0 {
+		return result, gserrors.Newf(ErrCompileS, "must set GOPATH first")
+	}
+	cs := newCompileS(goPath)
+	cs.ctx = ctx
+	if opts.Workers > 0 {
+		cs.workers = make(chan struct{}, opts.Workers)
+	}
+	if opts.CacheDir != "" {
+		if err = cs.UseCache(opts.CacheDir); err != nil {
+			return
+		}
+	}
+	result.CompileS = cs
+	for _, name := range opts.Packages {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		var pkg *ast.Package
+		if pkg, err = cs.Compile(name); err != nil {
+			return
+		}
+		result.Packages = append(result.Packages, pkg)
+	}
+	return
+}
@@ -0,0 +1,206 @@
+// @file 	compile_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	compile_test
+
+package gslang_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// compileFiles 测试编译入口的代码 demo/shop引用demo/base及demo/keys
+var compileFiles = map[string]string{
+	"demo/base/base.gs": `
+struct Point {
+    X int32;
+    Y int32;
+}
+`,
+	"demo/base/color.gs": `
+enum Color(byte) {
+    Red(1), Green(2)
+}
+`,
+	"demo/keys/keys.gs": `
+import "demo/base"
+
+enum Key(int32) {
+    A(1)
+}
+
+table Entry {
+    Color base.Color;
+}
+`,
+	"demo/shop/shop.gs": `
+import "demo/base"
+import "demo/keys"
+import "demo/other"
+
+table Item {
+    Pos base.Point;
+    Entry keys.Entry;
+}
+`,
+	"demo/other/other.gs": `
+table Other {}
+`,
+}
+
+// countdown 前n次调用Err返回nil 之后返回context.Canceled 用于在编译的任意阶段取消
+type countdown struct {
+	context.Context
+	mutex sync.Mutex
+	n     int
+}
+
+// Err 实现context.Context接口
+func (ctx *countdown) Err() error {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	if ctx.n <= 0 {
+		return context.Canceled
+	}
+	ctx.n--
+	return nil
+}
+
+func TestCompile(t *testing.T) {
+	gopath := gstest.GOPATH(t, compileFiles)
+	result, err := gslang.Compile(context.Background(), gslang.Options{
+		Packages: []string{"demo/shop", "demo/base"},
+		GOPATH:   []string{gopath},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, pkg := range result.Packages {
+		names = append(names, pkg.Name())
+	}
+	if got := strings.Join(names, " "); got != "demo/shop demo/base" {
+		t.Errorf("Packages = %s", got)
+	}
+	if len(result.Diagnostics) != 1 || !strings.Contains(result.Diagnostics[0].String(), `imported and not used: "demo/other"`) {
+		t.Errorf("Diagnostics = %v", result.Diagnostics)
+	}
+	// 没有使用缓存时所有编译的包都是变化的
+	want := "demo/base demo/keys demo/other demo/shop skea3344/gslang"
+	if got := strings.Join(result.Changed, " "); got != want {
+		t.Errorf("Changed = %s want %s", got, want)
+	}
+	if result.Timings.Total <= 0 || result.Timings.Parse <= 0 || result.Timings.Link <= 0 {
+		t.Errorf("Timings = %+v", result.Timings)
+	}
+	// 编译结束后编译器可以继续使用
+	if _, err := result.CompileS.Compile("demo/keys"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	gopath := gstest.GOPATH(t, map[string]string{
+		"demo/bad/bad.gs":   "table T {",
+		"demo/uses/uses.gs": "import \"demo/bad\"\n\ntable U {}\n",
+		"demo/ok/ok.gs":     "table T {}",
+	})
+	tests := []struct {
+		packages []string
+		want     string
+	}{
+		{[]string{"demo/missing"}, "demo/missing"},
+		{[]string{"demo/bad"}, "bad.gs"},
+		{[]string{"demo/ok", "demo/uses"}, "bad.gs"},
+	}
+	for _, tc := range tests {
+		result, err := gslang.Compile(context.Background(), gslang.Options{Packages: tc.packages, GOPATH: []string{gopath}})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Compile(%v) error = %v, want %q", tc.packages, err, tc.want)
+			continue
+		}
+		if result == nil || result.Timings.Total <= 0 {
+			t.Errorf("Compile(%v) result = %+v", tc.packages, result)
+		}
+	}
+	// 出错时保留已经编译完成的包
+	result, _ := gslang.Compile(context.Background(), gslang.Options{
+		Packages: []string{"demo/ok", "demo/uses"},
+		GOPATH:   []string{gopath},
+	})
+	if len(result.Packages) != 1 || result.Packages[0].Name() != "demo/ok" {
+		t.Errorf("Packages after error = %v", result.Packages)
+	}
+	t.Setenv("GOPATH", "")
+	if _, err := gslang.Compile(context.Background(), gslang.Options{Packages: []string{"demo/ok"}}); err == nil ||
+		!strings.Contains(err.Error(), "must set GOPATH first") {
+		t.Errorf("Compile without GOPATH error = %v", err)
+	}
+}
+
+// TestCompileNilContext ctx为nil时等同于context.Background() 出错时也不会panic
+func TestCompileNilContext(t *testing.T) {
+	gopath := gstest.GOPATH(t, map[string]string{
+		"demo/bad/bad.gs": "table T {",
+		"demo/ok/ok.gs":   "table T {}",
+	})
+	result, err := gslang.Compile(nil, gslang.Options{Packages: []string{"demo/ok"}, GOPATH: []string{gopath}})
+	if err != nil || len(result.Packages) != 1 {
+		t.Fatalf("Compile(nil) = %v, %v", result, err)
+	}
+	if _, err := gslang.Compile(nil, gslang.Options{Packages: []string{"demo/bad"}, GOPATH: []string{gopath}}); err == nil ||
+		!strings.Contains(err.Error(), "bad.gs") {
+		t.Errorf("Compile(nil) error = %v", err)
+	}
+}
+
+// TestCompileCancel 在编译的任意阶段取消都返回ctx.Err() 不会panic
+func TestCompileCancel(t *testing.T) {
+	gopath := gstest.GOPATH(t, compileFiles)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := gslang.Compile(ctx, gslang.Options{Packages: []string{"demo/shop"}, GOPATH: []string{gopath}}); err != context.Canceled {
+		t.Fatalf("canceled Compile error = %v", err)
+	}
+	for n := 0; ; n++ {
+		ctx := &countdown{Context: context.Background(), n: n}
+		result, err := gslang.Compile(ctx, gslang.Options{
+			Packages: []string{"demo/shop"},
+			GOPATH:   []string{gopath},
+			Workers:  1,
+		})
+		if err == nil {
+			if n == 0 || len(result.Packages) != 1 {
+				t.Fatalf("Compile succeeded after %d checks with %v", n, result.Packages)
+			}
+			break
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Compile canceled after %d checks error = %v", n, err)
+		}
+		if n > 1000 {
+			t.Fatal("Compile never finished")
+		}
+	}
+}
+
+// TestCompileTypes 编译得到的包可以查询类型 引用的类型已连接
+func TestCompileTypes(t *testing.T) {
+	cs := gstest.Compile(t, compileFiles, "demo/shop")
+	item := gstest.Type(t, cs, "demo/shop", "Item").(*ast.Table)
+	ref := item.Fields[1].Type.(*ast.TypeRef)
+	if ref.Ref == nil || gslang.TypeName(ref.Ref) != "demo/keys.Entry" {
+		t.Fatalf("Item.Entry refers to %v", ref.Ref)
+	}
+	if _, err := cs.Type("demo/shop", "Nope"); err == nil {
+		t.Error("Type(Nope) succeeded")
+	}
+}
@@ -7,11 +7,16 @@ package gslang
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/skea3344/gserrors"
 	"github.com/skea3344/gslang/ast"
@@ -39,10 +44,21 @@ func FilePath(script *ast.Script) (string, bool) {
 
 // CompileS 编译器
 type CompileS struct {
-	logger.ILog                         // 内嵌通用日志接口
-	Loaded      map[string]*ast.Package // 已加载包节点字典
-	loading     []*ast.Package          // 正在加载的包节点列表
-	goPath      []string                // 系统golang路径
+	logger.ILog                            // 内嵌通用日志接口
+	Loaded       map[string]*ast.Package   // 已加载包节点字典
+	Diagnostics  []*Diagnostic             // 编译过程中产生的警告 如未使用的包引用
+	goPath       []string                  // 系统golang路径
+	cache        string                    // 缓存目录 为空时不使用缓存
+	ctx          context.Context           // 编译的上下文 取消时中断编译 可以为空
+	workers      chan struct{}             // 限制并发解析及编译的协程数
+	mutex        sync.Mutex                // 保护Loaded Diagnostics及以下字段
+	building     map[string]*buildTask     // 正在编译的包
+	imports      map[string]map[string]int // 正在编译的包在等待的包 用于检测循环引用
+	dirs         map[string]string         // 已查找到的包目录 包括编译失败的包
+	fingerprints map[string]string         // 已编译的包的指纹
+	changed      map[string]bool           // 指纹与缓存不同的包
+	parseTime    time.Duration             // 解析累计耗时
+	linkTime     time.Duration             // 连接累计耗时
 }
 
 // NewCompileS 新建一个编译器
@@ -51,10 +67,49 @@ func NewCompileS() *CompileS {
 	if GOPATH == "" {
 		gserrors.Panicf(ErrCompileS, "must set GOPATH first")
 	}
+	return newCompileS(strings.Split(GOPATH, string(os.PathListSeparator)))
+}
+
+// newCompileS 用指定的包查找路径新建一个编译器
+func newCompileS(goPath []string) *CompileS {
 	return &CompileS{
-		ILog:   logger.Get("gslang"),
-		Loaded: make(map[string]*ast.Package),
-		goPath: strings.Split(GOPATH, string(os.PathListSeparator)),
+		ILog:         logger.Get("gslang"),
+		Loaded:       make(map[string]*ast.Package),
+		goPath:       goPath,
+		workers:      make(chan struct{}, runtime.GOMAXPROCS(0)),
+		building:     make(map[string]*buildTask),
+		imports:      make(map[string]map[string]int),
+		dirs:         make(map[string]string),
+		fingerprints: make(map[string]string),
+		changed:      make(map[string]bool),
+	}
+}
+
+// buildTask 正在编译的包 编译完成后关闭done
+type buildTask struct {
+	done chan struct{}
+	pkg  *ast.Package
+	err  error
+}
+
+// recoverError 将recover得到的任意值转换为错误 panic的值可能不是error
+func recoverError(e interface{}) error {
+	switch val := e.(type) {
+	case gserrors.GSError:
+		return val
+	case error:
+		return gserrors.New(val)
+	}
+	return gserrors.Newf(ErrCompileS, "%v", e)
+}
+
+// checkContext 编译被取消或者超时时中断编译
+func (cs *CompileS) checkContext() {
+	if cs.ctx == nil {
+		return
+	}
+	if err := cs.ctx.Err(); err != nil {
+		gserrors.Panic(err)
 	}
 }
 
@@ -84,20 +139,77 @@ func (cs *CompileS) searchPackage(packageName string) string {
 	return found[0]
 }
 
-// circularRefCheck 循环引用检查 指定名字的包
-func (cs *CompileS) circularRefCheck(packageName string) {
+// circularRefCheck 循环引用检查 importer引用packageName时 packageName已经直接或者间接引用了importer 调用时必须持有锁
+func (cs *CompileS) circularRefCheck(importer, packageName string) error {
+	path := cs.importPath(packageName, importer, make(map[string]bool))
+	if path == nil {
+		return nil
+	}
+	// 并发编译时检测到循环的协程不确定 从名字最小的包开始输出 保证错误信息一致
+	start := 0
+	for i, name := range path {
+		if name < path[start] {
+			start = i
+		}
+	}
+	path = append(path[start:], path[:start]...)
 	var buff bytes.Buffer
-	// 如果当前正在loading的包中包含对应的包名 那么就认为循环引用
-	for _, pkg := range cs.loading {
-		if pkg.Name() == packageName || buff.Len() != 0 {
-			buff.WriteString(fmt.Sprintf("\t%s import\n", pkg.Name()))
+	for _, name := range path {
+		buff.WriteString(fmt.Sprintf("\t%s import\n", name))
+	}
+	return fmt.Errorf("circular package import :\n%s\t%s", buff.String(), path[0])
+}
+
+// importPath 返回正在编译的包之间从from到to的引用路径 不存在时返回nil 调用时必须持有锁
+func (cs *CompileS) importPath(from, to string, visited map[string]bool) []string {
+	if from == to {
+		return []string{to}
+	}
+	if visited[from] {
+		return nil
+	}
+	visited[from] = true
+	var names []string
+	for name := range cs.imports[from] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if path := cs.importPath(name, to, visited); path != nil {
+			return append([]string{from}, path...)
 		}
 	}
-	if buff.Len() != 0 {
-		panic(fmt.Errorf("circular package import :\n%s\t%s", buff.String(), packageName))
+	return nil
+}
+
+// addImport 记录正在编译的包importer等待包packageName编译完成 调用时必须持有锁
+func (cs *CompileS) addImport(importer, packageName string) {
+	if cs.imports[importer] == nil {
+		cs.imports[importer] = make(map[string]int)
+	}
+	cs.imports[importer][packageName]++
+}
+
+// removeImport 删除addImport记录的引用关系
+func (cs *CompileS) removeImport(importer, packageName string) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	if cs.imports[importer][packageName]--; cs.imports[importer][packageName] == 0 {
+		delete(cs.imports[importer], packageName)
+	}
+	if len(cs.imports[importer]) == 0 {
+		delete(cs.imports, importer)
 	}
 }
 
+// loaded 返回已加载的指定名字的包 编译过程中可以并发调用
+func (cs *CompileS) loaded(packageName string) (*ast.Package, bool) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	pkg, ok := cs.Loaded[packageName]
+	return pkg, ok
+}
+
 // errorf 编译器报错
 func (cs *CompileS) errorf(position Position, fmtstring string, args ...interface{}) {
 	gserrors.Panicf(
@@ -114,11 +226,7 @@ func (cs *CompileS) errorf(position Position, fmtstring string, args ...interfac
 func (cs *CompileS) Accept(visitor ast.Visitor) (err error) {
 	defer func() {
 		if e := recover(); e != nil {
-			if _, ok := e.(gserrors.GSError); ok {
-				err = e.(error)
-			} else {
-				err = gserrors.New(e.(error))
-			}
+			err = recoverError(e)
 		}
 	}()
 	// 使用访问者对编译器已加载的包轮流进行访问
@@ -131,71 +239,286 @@ func (cs *CompileS) Accept(visitor ast.Visitor) (err error) {
 
 // Compile 对指定包名进行编译
 func (cs *CompileS) Compile(packageName string) (pkg *ast.Package, err error) {
-	defer func() {
-		if e := recover(); e != nil {
-			if _, ok := e.(gserrors.GSError); ok {
-				err = e.(error)
-			} else {
-				err = gserrors.New(e.(error))
-			}
-		}
-	}()
+	return cs.CompileDir(packageName, "")
+}
+
+// CompileDir 从指定目录编译指定包名的包 dir为空时在$GOPATH/src下查找
+// 包引用的其他包仍然在$GOPATH/src下查找 用于编译同一个包的不同版本
+func (cs *CompileS) CompileDir(packageName string, dir string) (pkg *ast.Package, err error) {
+	return cs.compile("", packageName, dir)
+}
+
+// CompileVersions 使用两个独立的编译器分别从两个目录编译同一个包的旧版本及新版本
+// 没有设置GOPATH时返回错误而不是panic
+func CompileVersions(packageName string, oldDir string, newDir string) (old, new *ast.Package, err error) {
+	GOPATH := os.Getenv("GOPATH")
+	if GOPATH == "" {
+		return nil, nil, gserrors.Newf(ErrCompileS, "must set GOPATH first")
+	}
+	goPath := strings.Split(GOPATH, string(os.PathListSeparator))
+	old, err = newCompileS(goPath).CompileDir(packageName, oldDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	new, err = newCompileS(goPath).CompileDir(packageName, newDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return old, new, nil
+}
+
+// compile 编译指定包 importer为引用此包的正在编译的包 直接编译时为空
+// 同一个包只编译一次 其他协程同时请求该包时等待编译完成
+func (cs *CompileS) compile(importer, packageName, dir string) (pkg *ast.Package, err error) {
 	defer gserrors.Ensure(func() bool {
 		if err == nil {
 			return pkg != nil
 		}
 		return true
 	}, "if err == nil the return param pkg can not be nil")
+	// 先于Ensure执行 将panic转换为err
+	defer func() {
+		if e := recover(); e != nil {
+			err = recoverError(e)
+		}
+	}()
+	cs.mutex.Lock()
 	if loaded, ok := cs.Loaded[packageName]; ok {
-		pkg = loaded
-		return
+		cs.mutex.Unlock()
+		return loaded, nil
+	}
+	if importer != "" {
+		// 循环应用检测 packageName正在等待importer时 再等待packageName会死锁
+		if err = cs.circularRefCheck(importer, packageName); err != nil {
+			cs.mutex.Unlock()
+			return nil, gserrors.New(err)
+		}
+		cs.addImport(importer, packageName)
+		defer cs.removeImport(importer, packageName)
+	}
+	// 其他协程正在编译该包 等待其完成
+	if task, ok := cs.building[packageName]; ok {
+		cs.mutex.Unlock()
+		<-task.done
+		return task.pkg, task.err
 	}
-	// 循环应用检测 在当前loading的包中已存在同名包 则报错
-	cs.circularRefCheck(packageName)
+	task := &buildTask{done: make(chan struct{})}
+	cs.building[packageName] = task
+	cs.mutex.Unlock()
+	// 出错的包不会加入Loaded 再次编译时重新加载
+	task.pkg, task.err = cs.build(packageName, dir)
+	cs.mutex.Lock()
+	delete(cs.building, packageName)
+	if task.err == nil {
+		cs.Loaded[packageName] = task.pkg
+	}
+	cs.mutex.Unlock()
+	close(task.done)
+	return task.pkg, task.err
+}
+
+// build 加载并连接指定包
+func (cs *CompileS) build(packageName, dir string) (pkg *ast.Package, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			pkg, err = nil, recoverError(e)
+		}
+	}()
+	cs.checkContext()
 	// 在系统中查找对应的包路径
 	cs.D("%s", packageName)
-	fullPath := cs.searchPackage(packageName)
-	// 生成一个抽象包节点
-	pkg = ast.NewPackage(packageName)
-	// 将包添加到loading列表
-	cs.loading = append(cs.loading, pkg)
-	// 遍历目标包目录下的每一个文件
+	fullPath := dir
+	if fullPath == "" {
+		fullPath = cs.searchPackage(packageName)
+	}
+	cs.mutex.Lock()
+	cs.dirs[packageName] = fullPath
+	cs.mutex.Unlock()
+	// 遍历目标包目录下的每一个gs文件
+	var files []string
 	err = filepath.Walk(fullPath, func(path string, info os.FileInfo, err error) error {
 		// 系统遍历时报错则直接返回该错误
 		if err != nil {
 			return err
 		}
+		// 编译被取消时停止遍历
+		if cs.ctx != nil && cs.ctx.Err() != nil {
+			return cs.ctx.Err()
+		}
 		// 如果该文件是一个不同于fullPath的文件夹 则略过
 		if info.IsDir() && path != fullPath {
 			return filepath.SkipDir
 		}
 		// 如果不是gs文件 则忽略
-		if filepath.Ext(path) != ".gs" {
-			return nil
-		}
-		// 解析该gs文件 生成代码节点
-		script, err := cs.parse(pkg, path)
-		if err == nil { // 没有错误的话 则将路径保存为代码节点的额外信息
-			setFilePath(script, path)
+		if filepath.Ext(path) == ".gs" {
+			files = append(files, path)
 		}
-		return err
+		return nil
 	})
-	// 如果有错误发生 则将刚才加入loading的包去掉
 	if err != nil {
-		cs.loading = cs.loading[:len(cs.loading)-1]
-		return
+		return nil, err
+	}
+	cached := cs.loadCache(packageName)
+	scripts, encoded, errs := cs.parseFiles(packageName, files, cached)
+	// 按文件顺序合并到包节点 报错的文件及重名的类型与逐个解析时相同
+	pkg = ast.NewPackage(packageName)
+	for i, script := range scripts {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		old, dup, err := pkg.AddScript(script)
+		if err != nil {
+			return nil, err
+		}
+		if dup != nil {
+			return nil, gserrors.Newf(ErrParse, "parse %s error: duplicate type name:\n\tsee: %s", Pos(dup), Pos(old))
+		}
+		// 将路径保存为代码节点的额外信息
+		setFilePath(script, files[i])
 	}
 	cs.link(pkg)
-	// 加载完成 将loading中的pkg移到Loaded字典
-	cs.loading = cs.loading[:len(cs.loading)-1]
-	cs.Loaded[packageName] = pkg
+	cs.record(pkg, cached, encoded)
+	return pkg, nil
+}
 
-	return
+// parseFiles 并发解析包内的源文件 每个文件解析到单独的包节点 返回结果与文件顺序一致
+// 内容与缓存一致的源文件直接解码缓存的解析结果 同时返回每个文件用于缓存的解析结果
+func (cs *CompileS) parseFiles(packageName string, files []string, cached *cachePackage) ([]*ast.Script, []*cacheScript, []error) {
+	hits := make(map[string]*cacheScript)
+	if cached != nil {
+		for _, script := range cached.Scripts {
+			hits[script.Name] = script
+		}
+	}
+	scripts := make([]*ast.Script, len(files))
+	encoded := make([]*cacheScript, len(files))
+	errs := make([]error, len(files))
+	cs.parallel(len(files), func(i int) {
+		content, err := os.ReadFile(files[i])
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		hash := hashSource(content)
+		pkg := ast.NewPackage(packageName)
+		if hit, ok := hits[filepath.Base(files[i])]; ok && hit.Hash == hash {
+			scripts[i], errs[i] = cs.decodeScript(pkg, hit)
+			encoded[i] = hit
+			return
+		}
+		scripts[i], errs[i] = cs.parse(pkg, files[i], content)
+		// 必须在连接之前编码 没有使用缓存时只记录哈希
+		if errs[i] == nil && cs.cache != "" {
+			encoded[i] = encodeScript(scripts[i], hash)
+		} else {
+			encoded[i] = &cacheScript{Name: filepath.Base(files[i]), Hash: hash}
+		}
+	})
+	return scripts, encoded, errs
+}
+
+// compileImports 并发编译importer引用的包 返回结果与paths顺序一致 有错误时返回第一个错误
+func (cs *CompileS) compileImports(importer string, paths []string) ([]*ast.Package, error) {
+	pkgs := make([]*ast.Package, len(paths))
+	errs := make([]error, len(paths))
+	cs.parallel(len(paths), func(i int) {
+		pkgs[i], errs[i] = cs.compile(importer, paths[i], "")
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pkgs, nil
+}
+
+// parallel 并发执行n次fn 并发数由workers限制 没有空闲位置时在当前协程执行
+// 当前协程不会为等待位置而阻塞 嵌套编译时不会死锁
+func (cs *CompileS) parallel(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		// 最后一个任务总是在当前协程执行
+		if i < n-1 {
+			select {
+			case cs.workers <- struct{}{}:
+				wg.Add(1)
+				go func(i int) {
+					defer func() {
+						<-cs.workers
+						wg.Done()
+					}()
+					fn(i)
+				}(i)
+				continue
+			default:
+			}
+		}
+		fn(i)
+	}
+	wg.Wait()
+}
+
+// Dirs 返回编译过程中查找到的包名及其目录 包括编译失败的包 用于监视源文件变化
+func (cs *CompileS) Dirs() map[string]string {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	dirs := make(map[string]string, len(cs.dirs))
+	for name, dir := range cs.dirs {
+		dirs[name] = dir
+	}
+	return dirs
+}
+
+// Invalidate 从Loaded中删除指定的包以及所有直接或者间接引用它们的包 同时删除其指纹及诊断信息
+// 返回删除的包名 按名字排序 再次编译时这些包重新加载 其余的包继续复用 不能在编译过程中调用
+func (cs *CompileS) Invalidate(packageNames ...string) []string {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	// 反向引用关系 包名 -> 引用它的包
+	importers := make(map[string][]string)
+	for name, pkg := range cs.Loaded {
+		for _, script := range pkg.Scripts {
+			for _, ref := range script.Imports {
+				if ref.Ref != nil && ref.Ref != pkg {
+					importers[ref.Ref.Name()] = append(importers[ref.Ref.Name()], name)
+				}
+			}
+		}
+	}
+	stale := make(map[string]bool)
+	queue := append([]string(nil), packageNames...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if stale[name] {
+			continue
+		}
+		stale[name] = true
+		queue = append(queue, importers[name]...)
+	}
+	var dropped []string
+	for name := range stale {
+		if _, ok := cs.Loaded[name]; ok {
+			dropped = append(dropped, name)
+			delete(cs.Loaded, name)
+		}
+		delete(cs.fingerprints, name)
+		delete(cs.changed, name)
+	}
+	sort.Strings(dropped)
+	var diagnostics []*Diagnostic
+	for _, diagnostic := range cs.Diagnostics {
+		if !stale[diagnostic.Package] {
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	cs.Diagnostics = diagnostics
+	return dropped
 }
 
 // Type 在当前编译器已加载的指定名字包中查找指定名字的类型表达式
 func (cs *CompileS) Type(packageName string, typeName string) (ast.Expr, error) {
-	if pkg, ok := cs.Loaded[packageName]; ok {
+	if pkg, ok := cs.loaded(packageName); ok {
 		if target, ok := pkg.Types[typeName]; ok {
 			return target, nil
 		}
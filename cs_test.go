@@ -0,0 +1,277 @@
+// @file 	cs_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	cs_test
+
+package gslang_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// diamondFiles 生成菱形引用的包 demo/top引用demo/left及demo/right 两者都引用demo/bottom
+// 每个包包含多个源文件 以便并发解析
+func diamondFiles() map[string]string {
+	files := map[string]string{}
+	imports := map[string][]string{
+		"demo/top":    {"demo/left", "demo/right"},
+		"demo/left":   {"demo/bottom"},
+		"demo/right":  {"demo/bottom"},
+		"demo/bottom": nil,
+	}
+	for pkg, refs := range imports {
+		short := pkg[strings.LastIndex(pkg, "/")+1:]
+		for i := 0; i < 8; i++ {
+			var code strings.Builder
+			for _, ref := range refs {
+				fmt.Fprintf(&code, "import %q\n", ref)
+			}
+			fmt.Fprintf(&code, "\n// T%d 类型\ntable T%d {\n    ID int32;\n", i, i)
+			for j, ref := range refs {
+				fmt.Fprintf(&code, "    R%d %s.T%d;\n", j, ref[strings.LastIndex(ref, "/")+1:], i)
+			}
+			if i > 0 {
+				fmt.Fprintf(&code, "    Prev T%d;\n", i-1)
+			}
+			fmt.Fprintf(&code, "}\n\nenum E%d(byte) {\n    A(%d)\n}\n", i, i)
+			files[fmt.Sprintf("%s/%s%d.gs", pkg, short, i)] = code.String()
+		}
+	}
+	return files
+}
+
+// describe 输出编译器中所有包的格式化代码及诊断信息 用于比较编译结果
+func describe(t *testing.T, cs *gslang.CompileS) string {
+	var names []string
+	for name := range cs.Loaded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buff bytes.Buffer
+	for _, name := range names {
+		pkg := cs.Loaded[name]
+		var scripts []string
+		for script := range pkg.Scripts {
+			scripts = append(scripts, script)
+		}
+		sort.Strings(scripts)
+		for _, script := range scripts {
+			fmt.Fprintf(&buff, "== %s/%s\n", name, script)
+			if err := gslang.Format(&buff, pkg.Scripts[script]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, diagnostic := range cs.Diagnostics {
+		fmt.Fprintf(&buff, "%s\n", diagnostic)
+	}
+	return buff.String()
+}
+
+// TestParallelCompile 不同的并发数多次编译 结果完全相同
+func TestParallelCompile(t *testing.T) {
+	gopath := gstest.GOPATH(t, diamondFiles())
+	var want string
+	for _, workers := range []int{1, 2, 8, 1, 8, 8} {
+		result, err := gslang.Compile(context.Background(), gslang.Options{
+			Packages: []string{"demo/top", "demo/left"},
+			GOPATH:   []string{gopath},
+			Workers:  workers,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.CompileS.Loaded) != 5 {
+			t.Fatalf("Loaded %d packages", len(result.CompileS.Loaded))
+		}
+		got := describe(t, result.CompileS)
+		if want == "" {
+			want = got
+			continue
+		}
+		if got != want {
+			t.Fatalf("workers %d result differs:\n%s\nwant\n%s", workers, got, want)
+		}
+	}
+	// 引用同一个包的包共享同一个包节点
+	result, err := gslang.Compile(context.Background(), gslang.Options{Packages: []string{"demo/top"}, GOPATH: []string{gopath}, Workers: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bottom := result.CompileS.Loaded["demo/bottom"]
+	for _, name := range []string{"demo/left", "demo/right"} {
+		for _, script := range result.CompileS.Loaded[name].Scripts {
+			if ref, ok := script.Imports["bottom"]; !ok || ref.Ref != bottom {
+				t.Fatalf("%s/%s does not share demo/bottom", name, script.Name())
+			}
+		}
+	}
+}
+
+// TestParallelDuplicateType 并发解析时重名类型的报错与逐个解析时相同
+func TestParallelDuplicateType(t *testing.T) {
+	gopath := gstest.GOPATH(t, map[string]string{
+		"demo/shop/a.gs": "table Item {}\n",
+		"demo/shop/b.gs": "table Other {}\n",
+		"demo/shop/c.gs": "\ntable Item {}\n",
+	})
+	for i := 0; i < 10; i++ {
+		_, err := gslang.Compile(context.Background(), gslang.Options{Packages: []string{"demo/shop"}, GOPATH: []string{gopath}, Workers: 4})
+		if err == nil || !strings.Contains(err.Error(), "c.gs(2:7) error: duplicate type name") || !strings.Contains(err.Error(), "see: ") ||
+			!strings.Contains(err.Error(), "a.gs(1:7)") {
+			t.Fatalf("duplicate type error = %v", err)
+		}
+	}
+}
+
+// TestImportCycle 循环引用的包报错 并发编译时不会死锁
+func TestImportCycle(t *testing.T) {
+	gopath := gstest.GOPATH(t, map[string]string{
+		"demo/a/a.gs": "import \"demo/b\"\n\ntable A { B b.B; }\n",
+		"demo/b/b.gs": "import \"demo/c\"\n\ntable B { C c.C; }\n",
+		"demo/c/c.gs": "import \"demo/a\"\nimport \"demo/d\"\n\ntable C { A a.A; D d.D; }\n",
+		"demo/d/d.gs": "table D {}\n",
+		"demo/e/e.gs": "import \"demo/e\"\n\ntable E {}\n",
+	})
+	tests := []struct {
+		packages []string
+		want     string
+	}{
+		{[]string{"demo/a"}, "circular package import"},
+		{[]string{"demo/d", "demo/b"}, "circular package import"},
+		{[]string{"demo/e"}, "demo/e"},
+	}
+	for _, workers := range []int{1, 4} {
+		for _, tc := range tests {
+			done := make(chan error, 1)
+			go func() {
+				_, err := gslang.Compile(context.Background(), gslang.Options{Packages: tc.packages, GOPATH: []string{gopath}, Workers: workers})
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				if err == nil || !strings.Contains(err.Error(), tc.want) {
+					t.Errorf("workers %d Compile(%v) error = %v, want %q", workers, tc.packages, err, tc.want)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("workers %d Compile(%v) deadlocked", workers, tc.packages)
+			}
+		}
+	}
+}
+
+// TestInvalidate 删除过期的包及引用它们的包 重新编译时只加载这些包
+func TestInvalidate(t *testing.T) {
+	gopath := gstest.GOPATH(t, compileFiles)
+	result, err := gslang.Compile(context.Background(), gslang.Options{
+		Packages: []string{"demo/shop"},
+		GOPATH:   []string{gopath},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := result.CompileS
+	other := cs.Loaded["demo/other"]
+	keys := cs.Loaded["demo/keys"]
+	if len(cs.Diagnostics) != 1 {
+		t.Fatalf("Diagnostics = %v", cs.Diagnostics)
+	}
+	if dropped := cs.Invalidate("demo/nope"); len(dropped) != 0 {
+		t.Errorf("Invalidate(demo/nope) = %v", dropped)
+	}
+	dropped := cs.Invalidate("demo/base")
+	if got := strings.Join(dropped, " "); got != "demo/base demo/keys demo/shop" {
+		t.Fatalf("Invalidate(demo/base) = %s", got)
+	}
+	for _, name := range dropped {
+		if _, ok := cs.Loaded[name]; ok {
+			t.Errorf("%s is still loaded", name)
+		}
+		if _, ok := cs.Fingerprint(name); ok {
+			t.Errorf("%s still has a fingerprint", name)
+		}
+	}
+	if len(cs.Diagnostics) != 0 {
+		t.Errorf("Diagnostics of invalidated packages = %v", cs.Diagnostics)
+	}
+	// 重新编译时加载修改后的代码 没有失效的包继续复用
+	gstest.Write(t, gopath, map[string]string{"demo/base/extra.gs": "table Extra {}\n"})
+	if _, err := cs.Compile("demo/shop"); err != nil {
+		t.Fatal(err)
+	}
+	gstest.Type(t, cs, "demo/base", "Extra")
+	if cs.Loaded["demo/other"] != other {
+		t.Error("demo/other was reloaded")
+	}
+	if cs.Loaded["demo/keys"] == keys {
+		t.Error("demo/keys was not reloaded")
+	}
+	if len(cs.Diagnostics) != 1 {
+		t.Errorf("Diagnostics after recompile = %v", cs.Diagnostics)
+	}
+}
+
+// TestDirs 编译过程中查找到的包目录 包括编译失败的包
+func TestDirs(t *testing.T) {
+	files := map[string]string{"demo/broken/broken.gs": "table T {"}
+	for name, content := range compileFiles {
+		files[name] = content
+	}
+	gopath := gstest.GOPATH(t, files)
+	result, err := gslang.Compile(context.Background(), gslang.Options{
+		Packages: []string{"demo/shop", "demo/broken"},
+		GOPATH:   []string{gopath},
+	})
+	if err == nil {
+		t.Fatal("compile demo/broken succeeded")
+	}
+	dirs := result.CompileS.Dirs()
+	for _, name := range []string{"demo/base", "demo/keys", "demo/other", "demo/shop", "demo/broken", "skea3344/gslang"} {
+		if want := filepath.Join(gopath, "src", filepath.FromSlash(name)); dirs[name] != want {
+			t.Errorf("Dirs[%s] = %s want %s", name, dirs[name], want)
+		}
+	}
+	if _, ok := result.CompileS.Loaded["demo/broken"]; ok || len(dirs) != 6 {
+		t.Errorf("Dirs = %v", dirs)
+	}
+	// 返回的是副本
+	delete(dirs, "demo/shop")
+	if _, ok := result.CompileS.Dirs()["demo/shop"]; !ok {
+		t.Error("Dirs returned the internal map")
+	}
+}
+
+// TestCompileVersions 两个版本使用独立的编译器 没有设置GOPATH时返回错误
+func TestCompileVersions(t *testing.T) {
+	gopath := gstest.GOPATH(t, map[string]string{
+		"v1/shop.gs": "table Item { ID uint32; }\n",
+		"v2/shop.gs": "table Item { ID uint32; Name string; }\n",
+	})
+	t.Setenv("GOPATH", gopath)
+	old, new, err := gslang.CompileVersions("demo/shop", filepath.Join(gopath, "src", "v1"), filepath.Join(gopath, "src", "v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old == new || len(old.Types["Item"].(*ast.Table).Fields) != 1 || len(new.Types["Item"].(*ast.Table).Fields) != 2 {
+		t.Errorf("CompileVersions = %v, %v", old.Types, new.Types)
+	}
+	if _, _, err := gslang.CompileVersions("demo/shop", filepath.Join(gopath, "src", "v1"), filepath.Join(gopath, "src", "v3")); err == nil {
+		t.Error("CompileVersions of missing dir succeeded")
+	}
+	t.Setenv("GOPATH", "")
+	if _, _, err := gslang.CompileVersions("demo/shop", filepath.Join(gopath, "src", "v1"), filepath.Join(gopath, "src", "v2")); err == nil ||
+		!strings.Contains(err.Error(), "must set GOPATH first") {
+		t.Errorf("CompileVersions without GOPATH error = %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+// @file 	diagnostic.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	diagnostic
+
+package gslang
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/skea3344/gslang/ast"
+)
+
+// Severity 诊断信息的严重程度
+type Severity int
+
+// 严重程度
+const (
+	SeverityWarning Severity = iota // 警告 不影响编译结果
+	SeverityError                   // 错误
+)
+
+// String 实现fmt.Stringer接口
+func (severity Severity) String() string {
+	if severity == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// MarshalText 实现encoding.TextMarshaler接口 JSON中输出为字符串
+func (severity Severity) MarshalText() ([]byte, error) {
+	return []byte(severity.String()), nil
+}
+
+// Diagnostic 编译过程中产生的诊断信息
+type Diagnostic struct {
+	Severity Severity `json:"severity"`          // 严重程度
+	Package  string   `json:"package,omitempty"` // 所属的包 包失效时删除其诊断信息
+	Pos      Position `json:"pos"`               // 位置
+	Message  string   `json:"message"`           // 描述
+}
+
+// String 实现fmt.Stringer接口
+func (diagnostic *Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", diagnostic.Pos, diagnostic.Severity, diagnostic.Message)
+}
+
+// less 按文件名 行号 列号及描述比较诊断信息
+func (diagnostic *Diagnostic) less(other *Diagnostic) bool {
+	a, b := diagnostic.Pos, other.Pos
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	if a.Column != b.Column {
+		return a.Column < b.Column
+	}
+	return diagnostic.Message < other.Message
+}
+
+// warnf 记录包内的一条警告 并输出到日志
+func (cs *CompileS) warnf(pkg *ast.Package, position Position, fmtstring string, args ...interface{}) {
+	diagnostic := &Diagnostic{
+		Severity: SeverityWarning,
+		Package:  pkg.Name(),
+		Pos:      position,
+		Message:  fmt.Sprintf(fmtstring, args...),
+	}
+	cs.mutex.Lock()
+	// 包可能并发连接 按位置插入保证诊断信息的顺序确定
+	i := sort.Search(len(cs.Diagnostics), func(i int) bool {
+		return diagnostic.less(cs.Diagnostics[i])
+	})
+	cs.Diagnostics = append(cs.Diagnostics, nil)
+	copy(cs.Diagnostics[i+1:], cs.Diagnostics[i:])
+	cs.Diagnostics[i] = diagnostic
+	cs.mutex.Unlock()
+	cs.W("%s", diagnostic)
+}
@@ -0,0 +1,346 @@
+// @file 	diff.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	diff
+
+// Package diff 比较同一个包的两个已编译版本 生成便于阅读的语义差异
+//
+// 类型按名字匹配 表及结构体的域按ID匹配 因此可以发现域的改名
+// 枚举值按名字匹配 删除及新增的枚举值数值相同时视为改名
+// 函数按名字匹配 删除及新增的函数ID及签名都相同时视为改名
+// 类型 域 枚举值 函数的属性及注释的变化也会被列出
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// Op 差异操作
+type Op string
+
+// 差异操作
+const (
+	Added   Op = "added"   // 新增
+	Removed Op = "removed" // 删除
+	Changed Op = "changed" // 修改
+	Renamed Op = "renamed" // 改名
+)
+
+// Entry 一条差异
+type Entry struct {
+	Op     Op              `json:"op"`            // 差异操作
+	Kind   string          `json:"kind"`          // 对象种类 table struct enum contract field value method attr comment
+	Path   string          `json:"path"`          // 对象路径 如 Item.Name 新增的对象为新版本中的路径
+	Old    string          `json:"old,omitempty"` // 旧版本中的描述
+	New    string          `json:"new,omitempty"` // 新版本中的描述
+	OldPos gslang.Position `json:"oldPos"`        // 旧版本中的位置 不存在时无效
+	NewPos gslang.Position `json:"newPos"`        // 新版本中的位置 不存在时无效
+}
+
+// Diff 两个版本之间的语义差异
+type Diff struct {
+	Package string   `json:"package"` // 包名
+	Entries []*Entry `json:"entries"` // 差异列表 按旧版本的声明顺序排列 新增的类型在最后
+}
+
+// Empty 检查两个版本是否没有差异
+func (diff *Diff) Empty() bool {
+	return len(diff.Entries) == 0
+}
+
+// CompareDirs 分别从两个目录编译同一个包的旧版本及新版本并比较
+func CompareDirs(packageName string, oldDir string, newDir string) (*Diff, error) {
+	old, new, err := gslang.CompileVersions(packageName, oldDir, newDir)
+	if err != nil {
+		return nil, err
+	}
+	return Compare(old, new), nil
+}
+
+// Compare 比较同一个包的旧版本及新版本 两个版本都必须已经连接
+func Compare(old, new *ast.Package) *Diff {
+	differ := &differ{}
+	news := make(map[string]ast.Expr)
+	for _, expr := range gslang.Types(new) {
+		news[expr.Name()] = expr
+	}
+	olds := make(map[string]bool)
+	for _, expr := range gslang.Types(old) {
+		olds[expr.Name()] = true
+		target, ok := news[expr.Name()]
+		if !ok {
+			differ.add(Removed, gslang.KindName(expr), expr.Name(), expr, nil, describe(expr), "")
+			continue
+		}
+		differ.typeDecl(expr, target)
+	}
+	for _, expr := range gslang.Types(new) {
+		if !olds[expr.Name()] {
+			differ.add(Added, gslang.KindName(expr), expr.Name(), nil, expr, "", describe(expr))
+		}
+	}
+	return &Diff{Package: new.Name(), Entries: differ.entries}
+}
+
+// differ 差异比较器
+type differ struct {
+	entries []*Entry
+}
+
+// add 记录一条差异
+func (differ *differ) add(op Op, kind string, path string, old, new ast.Node, oldText, newText string) {
+	entry := &Entry{Op: op, Kind: kind, Path: path, Old: oldText, New: newText}
+	if old != nil {
+		entry.OldPos = gslang.FilePos(old)
+	}
+	if new != nil {
+		entry.NewPos = gslang.FilePos(new)
+	}
+	differ.entries = append(differ.entries, entry)
+}
+
+// describe 返回类型声明的描述 如 enum Color(byte) contract Admin(Shop)
+func describe(expr ast.Expr) string {
+	switch node := expr.(type) {
+	case *ast.Enum:
+		return fmt.Sprintf("enum %s(%s)", node.Name(), gslang.EnumBaseName(node))
+	case *ast.Contract:
+		if len(node.Bases) == 0 {
+			return "contract " + node.Name()
+		}
+		var bases []string
+		for _, base := range node.Bases {
+			bases = append(bases, gslang.TypeName(base))
+		}
+		return fmt.Sprintf("contract %s(%s)", node.Name(), strings.Join(bases, ", "))
+	}
+	return gslang.KindName(expr) + " " + expr.Name()
+}
+
+// typeDecl 比较同名类型
+func (differ *differ) typeDecl(old, new ast.Expr) {
+	path := old.Name()
+	if describe(old) != describe(new) {
+		differ.add(Changed, gslang.KindName(new), path, old, new, describe(old), describe(new))
+	}
+	differ.meta(path, old, new)
+	switch node := old.(type) {
+	case *ast.Table:
+		if target, ok := new.(*ast.Table); ok {
+			differ.table(node, target)
+		}
+	case *ast.Enum:
+		if target, ok := new.(*ast.Enum); ok {
+			differ.enum(node, target)
+		}
+	case *ast.Contract:
+		if target, ok := new.(*ast.Contract); ok {
+			differ.contract(node, target)
+		}
+	}
+}
+
+// meta 比较节点的属性及注释
+func (differ *differ) meta(path string, old, new ast.Node) {
+	oldAttrs, newAttrs := attrs(old), attrs(new)
+	for _, attr := range oldAttrs {
+		if !contains(newAttrs, attr) {
+			differ.add(Removed, "attr", path, old, new, attr, "")
+		}
+	}
+	for _, attr := range newAttrs {
+		if !contains(oldAttrs, attr) {
+			differ.add(Added, "attr", path, old, new, "", attr)
+		}
+	}
+	if oldText, newText := comments(old), comments(new); oldText != newText {
+		differ.add(Changed, "comment", path, old, new, oldText, newText)
+	}
+}
+
+// attrs 返回节点属性的源码形式
+func attrs(node ast.Node) []string {
+	var result []string
+	for _, attr := range node.Attrs() {
+		result = append(result, gslang.FormatAttr(attr))
+	}
+	return result
+}
+
+// contains 检查字符串列表是否包含指定字符串
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// comments 返回节点的注释文本
+func comments(node ast.Node) string {
+	var lines []string
+	for _, token := range gslang.Comments(node) {
+		if text, ok := token.Value.(string); ok {
+			lines = append(lines, strings.TrimSpace(text))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// field 返回域的描述 如 Name string
+func field(field *ast.Field) string {
+	return field.Name() + " " + gslang.TypeName(field.Type)
+}
+
+// table 比较表或者结构体的域 域按ID匹配
+func (differ *differ) table(old, new *ast.Table) {
+	news := make(map[uint16]*ast.Field)
+	for _, f := range new.Fields {
+		news[f.ID] = f
+	}
+	olds := make(map[uint16]bool)
+	for _, f := range old.Fields {
+		olds[f.ID] = true
+		path := old.Name() + "." + f.Name()
+		target, ok := news[f.ID]
+		if !ok {
+			differ.add(Removed, "field", path, f, nil, field(f), "")
+			continue
+		}
+		if target.Name() != f.Name() {
+			differ.add(Renamed, "field", path, f, target, field(f), field(target))
+		} else if field(target) != field(f) {
+			differ.add(Changed, "field", path, f, target, field(f), field(target))
+		}
+		differ.meta(path, f, target)
+	}
+	for _, f := range new.Fields {
+		if !olds[f.ID] {
+			differ.add(Added, "field", new.Name()+"."+f.Name(), nil, f, "", field(f))
+		}
+	}
+}
+
+// value 返回枚举值的描述 如 Red(1)
+func value(val *ast.EnumVal) string {
+	return fmt.Sprintf("%s(%s)", val.Name(), gslang.EnumValue(val))
+}
+
+// enum 比较枚举值 枚举值按名字匹配 数值相同的删除及新增视为改名
+func (differ *differ) enum(old, new *ast.Enum) {
+	var removed, added []*ast.EnumVal
+	for _, val := range gslang.EnumVals(old) {
+		target, ok := new.Values[val.Name()]
+		if !ok {
+			removed = append(removed, val)
+			continue
+		}
+		path := old.Name() + "." + val.Name()
+		if target.Value != val.Value {
+			differ.add(Changed, "value", path, val, target, value(val), value(target))
+		}
+		differ.meta(path, val, target)
+	}
+	for _, val := range gslang.EnumVals(new) {
+		if _, ok := old.Values[val.Name()]; !ok {
+			added = append(added, val)
+		}
+	}
+	for _, val := range removed {
+		path := old.Name() + "." + val.Name()
+		if i := indexValue(added, val.Value); i >= 0 {
+			target := added[i]
+			added = append(added[:i], added[i+1:]...)
+			differ.add(Renamed, "value", path, val, target, value(val), value(target))
+			differ.meta(path, val, target)
+			continue
+		}
+		differ.add(Removed, "value", path, val, nil, value(val), "")
+	}
+	for _, val := range added {
+		differ.add(Added, "value", new.Name()+"."+val.Name(), nil, val, "", value(val))
+	}
+}
+
+// indexValue 返回枚举值列表中指定数值的下标 没有时返回-1
+func indexValue(vals []*ast.EnumVal, v int64) int {
+	for i, val := range vals {
+		if val.Value == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// signature 返回函数的签名 如 Get(uint64) -> (Item)
+func signature(method *ast.Method) string {
+	text := fmt.Sprintf("%s(%s)", method.Name(), params(method.Params))
+	if len(method.Return) > 0 {
+		text += fmt.Sprintf(" -> (%s)", params(method.Return))
+	}
+	return text
+}
+
+// params 返回参数列表的描述
+func params(list []*ast.Param) string {
+	var items []string
+	for _, param := range list {
+		items = append(items, gslang.TypeName(param.Type))
+	}
+	return strings.Join(items, ", ")
+}
+
+// shape 返回去掉名字的函数签名 用于改名检测
+func shape(method *ast.Method) string {
+	return fmt.Sprintf("%d(%s)(%s)", method.ID, params(method.Params), params(method.Return))
+}
+
+// contract 比较协议的函数 函数按名字匹配 ID及签名相同的删除及新增视为改名
+func (differ *differ) contract(old, new *ast.Contract) {
+	var removed, added []*ast.Method
+	for _, method := range gslang.Methods(old) {
+		target, ok := new.Methods[method.Name()]
+		if !ok {
+			removed = append(removed, method)
+			continue
+		}
+		path := old.Name() + "." + method.Name()
+		if signature(method) != signature(target) {
+			differ.add(Changed, "method", path, method, target, signature(method), signature(target))
+		}
+		if method.ID != target.ID {
+			differ.add(Changed, "method", path, method, target,
+				fmt.Sprintf("id %d", method.ID), fmt.Sprintf("id %d", target.ID))
+		}
+		differ.meta(path, method, target)
+	}
+	for _, method := range gslang.Methods(new) {
+		if _, ok := old.Methods[method.Name()]; !ok {
+			added = append(added, method)
+		}
+	}
+	for _, method := range removed {
+		path := old.Name() + "." + method.Name()
+		matched := false
+		for i, target := range added {
+			if shape(target) == shape(method) {
+				added = append(added[:i], added[i+1:]...)
+				differ.add(Renamed, "method", path, method, target, signature(method), signature(target))
+				differ.meta(path, method, target)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			differ.add(Removed, "method", path, method, nil, signature(method), "")
+		}
+	}
+	for _, method := range added {
+		differ.add(Added, "method", new.Name()+"."+method.Name(), nil, method, "", signature(method))
+	}
+}
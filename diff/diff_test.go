@@ -0,0 +1,197 @@
+// @file 	diff_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	diff_test
+
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// oldShop 旧版本
+const oldShop = `
+// 坐标
+struct Point {
+    X int32;
+    Y int32;
+}
+
+table Item {
+    ID uint64;
+    Name string;
+    Level byte;
+    Note string;
+}
+
+enum Color(byte) {
+    Red(1), Green(2), Blue(3), Black(4)
+}
+
+enum ShopError(int32) {
+    NotFound(1)
+}
+
+table Removed {}
+
+contract Shop {
+    Get(id uint64) -> (Item);
+    Put(Item);
+    Count() -> (uint32);
+    Drop(id uint64);
+}
+`
+
+// newShop 新版本
+const newShop = `
+// 二维坐标
+struct Point {
+    X int32;
+    Y int32;
+}
+
+table Item {
+    ID uint64;
+    Title string;
+    Level int32;
+}
+
+enum Color(uint16) {
+    Red(1), Green(5), Blue(3), Dark(4), White(6)
+}
+
+@gslang.Error
+enum ShopError(int32) {
+    NotFound(1)
+}
+
+contract Shop {
+    Get(id uint64) -> (Item);
+    Put(Item, bool);
+    Total() -> (uint32);
+    Find(name string) -> (Item);
+}
+
+table Added {}
+`
+
+// compare 在临时GOPATH下比较同一个包的两个版本
+func compare(t *testing.T, old, new string) *Diff {
+	gopath := gstest.GOPATH(t, map[string]string{
+		"v1/shop.gs": old,
+		"v2/shop.gs": new,
+	})
+	t.Setenv("GOPATH", gopath)
+	diff, err := CompareDirs("demo/shop", filepath.Join(gopath, "src", "v1"), filepath.Join(gopath, "src", "v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return diff
+}
+
+func TestCompare(t *testing.T) {
+	diff := compare(t, oldShop, newShop)
+	var got []string
+	for _, entry := range diff.Entries {
+		got = append(got, fmt.Sprintf("%s %s %s: %q -> %q", entry.Op, entry.Kind, entry.Path, entry.Old, entry.New))
+		if !entry.OldPos.Valid() && !entry.NewPos.Valid() {
+			t.Errorf("%s %s has no position", entry.Op, entry.Path)
+		}
+		if entry.OldPos.Valid() && !strings.Contains(entry.OldPos.Filename, "v1") ||
+			entry.NewPos.Valid() && !strings.Contains(entry.NewPos.Filename, "v2") {
+			t.Errorf("%s %s positions %s %s", entry.Op, entry.Path, entry.OldPos, entry.NewPos)
+		}
+	}
+	want := []string{
+		`changed comment Point: "坐标" -> "二维坐标"`,
+		`renamed field Item.Name: "Name string" -> "Title string"`,
+		`changed field Item.Level: "Level byte" -> "Level int32"`,
+		`removed field Item.Note: "Note string" -> ""`,
+		`changed enum Color: "enum Color(byte)" -> "enum Color(uint16)"`,
+		`changed value Color.Green: "Green(2)" -> "Green(5)"`,
+		`renamed value Color.Black: "Black(4)" -> "Dark(4)"`,
+		`added value Color.White: "" -> "White(6)"`,
+		`added attr ShopError: "" -> "@gslang.Error"`,
+		`removed table Removed: "table Removed" -> ""`,
+		`changed method Shop.Put: "Put(demo/shop.Item)" -> "Put(demo/shop.Item, bool)"`,
+		`renamed method Shop.Count: "Count() -> (uint32)" -> "Total() -> (uint32)"`,
+		`removed method Shop.Drop: "Drop(uint64)" -> ""`,
+		`added method Shop.Find: "" -> "Find(string) -> (demo/shop.Item)"`,
+		`added table Added: "" -> "table Added"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("entries:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if diff.Empty() {
+		t.Fatal("diff is empty")
+	}
+	if same := compare(t, oldShop, oldShop); !same.Empty() {
+		t.Fatalf("identical versions differ:\n%s", same)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	diff := compare(t, `
+enum Color(byte) {
+    Red(1)
+}
+`, `
+// 颜色
+// 多行
+enum Color(sbyte) {
+    Red(1)
+}
+`)
+	text := diff.String()
+	wantText := []string{
+		"demo/shop: 2 changed\n",
+		"~ enum Color: enum Color(byte) -> enum Color(sbyte)\n\told: ",
+		"~ comment Color: 颜色 多行\n",
+	}
+	for _, want := range wantText {
+		if !strings.Contains(text, want) {
+			t.Errorf("text output does not contain %q:\n%s", want, text)
+		}
+	}
+	var buff bytes.Buffer
+	if err := diff.WriteMarkdown(&buff); err != nil {
+		t.Fatal(err)
+	}
+	wantMarkdown := "### Schema diff `demo/shop`\n\n2 changed\n\n" +
+		"| | Kind | Path | Old | New |\n|---|---|---|---|---|\n" +
+		"| changed | enum | `Color` | `enum Color(byte)` | `enum Color(sbyte)` |\n" +
+		"| changed | comment | `Color` |  | `颜色`<br>`多行` |\n"
+	if buff.String() != wantMarkdown {
+		t.Errorf("markdown =\n%s\nwant\n%s", buff.String(), wantMarkdown)
+	}
+	buff.Reset()
+	if err := diff.WriteJSON(&buff); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Diff
+	if err := json.Unmarshal(buff.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Package != "demo/shop" || len(decoded.Entries) != 2 || decoded.Entries[0].Op != Changed {
+		t.Errorf("json = %s", buff.String())
+	}
+	// 没有差异时entries为空数组
+	buff.Reset()
+	(&Diff{Package: "demo/shop"}).WriteJSON(&buff)
+	if !strings.Contains(buff.String(), `"entries": []`) {
+		t.Errorf("empty json = %s", buff.String())
+	}
+	if got := (&Diff{Package: "demo/shop"}).String(); got != "demo/shop: no changes\n" {
+		t.Errorf("empty text = %q", got)
+	}
+	if got := cell("a|b\nc`d"); got != "`a\\|b`<br>`` c`d ``" {
+		t.Errorf("cell = %q", got)
+	}
+}
@@ -0,0 +1,120 @@
+// @file 	output.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	output
+
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// opMarks 差异操作在文本输出中的标记
+var opMarks = map[Op]string{
+	Added:   "+",
+	Removed: "-",
+	Changed: "~",
+	Renamed: ">",
+}
+
+// summary 返回差异统计 如 3 added, 1 removed
+func (diff *Diff) summary() string {
+	counts := make(map[Op]int)
+	for _, entry := range diff.Entries {
+		counts[entry.Op]++
+	}
+	var items []string
+	for _, op := range []Op{Added, Removed, Changed, Renamed} {
+		if counts[op] != 0 {
+			items = append(items, fmt.Sprintf("%d %s", counts[op], op))
+		}
+	}
+	if len(items) == 0 {
+		return "no changes"
+	}
+	return strings.Join(items, ", ")
+}
+
+// change 返回单条差异的变化描述 多行文本合并为一行
+func (entry *Entry) change() string {
+	oldText := strings.Replace(entry.Old, "\n", " ", -1)
+	newText := strings.Replace(entry.New, "\n", " ", -1)
+	switch {
+	case entry.Old == "":
+		return newText
+	case entry.New == "":
+		return oldText
+	}
+	return oldText + " -> " + newText
+}
+
+// String 实现fmt.Stringer接口 返回文本格式
+func (diff *Diff) String() string {
+	var buff bytes.Buffer
+	diff.WriteText(&buff)
+	return buff.String()
+}
+
+// WriteText 以文本格式输出 每条差异一行 后跟位置
+func (diff *Diff) WriteText(w io.Writer) error {
+	var buff bytes.Buffer
+	buff.WriteString(fmt.Sprintf("%s: %s\n", diff.Package, diff.summary()))
+	for _, entry := range diff.Entries {
+		buff.WriteString(fmt.Sprintf("%s %s %s: %s\n", opMarks[entry.Op], entry.Kind, entry.Path, entry.change()))
+		if entry.OldPos.Valid() {
+			buff.WriteString(fmt.Sprintf("\told: %s\n", entry.OldPos))
+		}
+		if entry.NewPos.Valid() {
+			buff.WriteString(fmt.Sprintf("\tnew: %s\n", entry.NewPos))
+		}
+	}
+	_, err := w.Write(buff.Bytes())
+	return err
+}
+
+// WriteJSON 以JSON格式输出
+func (diff *Diff) WriteJSON(w io.Writer) error {
+	entries := diff.Entries
+	if entries == nil {
+		entries = []*Entry{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&Diff{Package: diff.Package, Entries: entries})
+}
+
+// WriteMarkdown 以Markdown表格格式输出 便于贴到代码评审中
+func (diff *Diff) WriteMarkdown(w io.Writer) error {
+	var buff bytes.Buffer
+	buff.WriteString(fmt.Sprintf("### Schema diff `%s`\n\n%s\n", diff.Package, diff.summary()))
+	if len(diff.Entries) != 0 {
+		buff.WriteString("\n| | Kind | Path | Old | New |\n|---|---|---|---|---|\n")
+		for _, entry := range diff.Entries {
+			buff.WriteString(fmt.Sprintf("| %s | %s | `%s` | %s | %s |\n",
+				entry.Op, entry.Kind, entry.Path, cell(entry.Old), cell(entry.New)))
+		}
+	}
+	_, err := w.Write(buff.Bytes())
+	return err
+}
+
+// cell 将文本转换为Markdown表格单元格 非空文本使用代码格式 换行转为<br>
+func cell(text string) string {
+	if text == "" {
+		return ""
+	}
+	var items []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Replace(line, "|", "\\|", -1)
+		if strings.Contains(line, "`") {
+			items = append(items, "``"+" "+line+" "+"``")
+		} else {
+			items = append(items, "`"+line+"`")
+		}
+	}
+	return strings.Join(items, "<br>")
+}
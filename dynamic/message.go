@@ -0,0 +1,275 @@
+// @file 	message.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	message
+
+// Package dynamic 运行时根据表定义构造及访问消息 不需要生成代码
+//
+// 域的值使用以下Go类型表示:
+//
+//	byte sbyte int16 uint16 int32 uint32 int64 uint64 -> uint8 int8 int16 uint16 int32 uint32 int64 uint64
+//	float32 float64 bool string                       -> float32 float64 bool string
+//	enum                                              -> 有符号枚举为int64 无符号枚举为uint64
+//	table struct                                      -> *Message
+//	[]T [N]T                                          -> []interface{}
+//	map[K]V                                           -> map[interface{}]interface{}
+//
+// 设置域的值时会按域类型检查并转换 整数可以是任意Go整数类型 枚举可以使用枚举值名字
+// 表及结构体可以使用map[string]interface{}
+package dynamic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/codec"
+)
+
+// 错误码
+var (
+	ErrDynamic = errors.New("dynamic message error")
+)
+
+// Message 动态消息 由表或结构体定义
+type Message struct {
+	table  *ast.Table             // 消息定义
+	values map[uint16]interface{} // 已设置的域 域ID -> 值
+}
+
+// New 新建表或结构体对应的空消息
+func New(table *ast.Table) *Message {
+	return &Message{
+		table:  table,
+		values: make(map[uint16]interface{}),
+	}
+}
+
+// Table 返回消息定义
+func (message *Message) Table() *ast.Table {
+	return message.table
+}
+
+// IsStruct 检查消息是否由结构体定义
+func (message *Message) IsStruct() bool {
+	return gslang.IsStruct(message.table)
+}
+
+// String 实现fmt.Stringer接口
+func (message *Message) String() string {
+	return fmt.Sprintf("%s %v", gslang.TypeName(message.table), message.Map())
+}
+
+// Field 按名字查找域
+func (message *Message) Field(name string) (*ast.Field, error) {
+	if field, ok := message.table.Field(name); ok {
+		return field, nil
+	}
+	return nil, gserrors.Newf(ErrDynamic, "%s has no field named %s", gslang.TypeName(message.table), name)
+}
+
+// FieldByID 按ID查找域
+func (message *Message) FieldByID(id uint16) (*ast.Field, error) {
+	for _, field := range message.table.Fields {
+		if field.ID == id {
+			return field, nil
+		}
+	}
+	return nil, gserrors.Newf(ErrDynamic, "%s has no field with id %d", gslang.TypeName(message.table), id)
+}
+
+// Has 检查域是否已设置
+func (message *Message) Has(name string) bool {
+	field, ok := message.table.Field(name)
+	if !ok {
+		return false
+	}
+	_, ok = message.values[field.ID]
+	return ok
+}
+
+// Get 按名字返回域的值 未设置的域返回对应类型的零值
+func (message *Message) Get(name string) (interface{}, error) {
+	field, err := message.Field(name)
+	if err != nil {
+		return nil, err
+	}
+	return message.get(field), nil
+}
+
+// GetByID 按ID返回域的值 未设置的域返回对应类型的零值
+func (message *Message) GetByID(id uint16) (interface{}, error) {
+	field, err := message.FieldByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return message.get(field), nil
+}
+
+// get 返回域的值
+func (message *Message) get(field *ast.Field) interface{} {
+	if value, ok := message.values[field.ID]; ok {
+		return value
+	}
+	return Zero(field.Type)
+}
+
+// Set 按名字设置域的值 值按域类型检查并转换 nil清除域
+func (message *Message) Set(name string, value interface{}) error {
+	field, err := message.Field(name)
+	if err != nil {
+		return err
+	}
+	return message.set(field, value)
+}
+
+// SetByID 按ID设置域的值 值按域类型检查并转换 nil清除域
+func (message *Message) SetByID(id uint16, value interface{}) error {
+	field, err := message.FieldByID(id)
+	if err != nil {
+		return err
+	}
+	return message.set(field, value)
+}
+
+// set 设置域的值
+func (message *Message) set(field *ast.Field, value interface{}) error {
+	if value == nil {
+		delete(message.values, field.ID)
+		return nil
+	}
+	v, err := convert(field.Type, value, path{gslang.TypeName(message.table), "." + field.Name()})
+	if err != nil {
+		return err
+	}
+	message.values[field.ID] = v
+	return nil
+}
+
+// Clear 清除域的值
+func (message *Message) Clear(name string) error {
+	return message.Set(name, nil)
+}
+
+// Mutable 返回表或结构体类型的域对应的消息 域未设置时新建空消息并设置
+func (message *Message) Mutable(name string) (*Message, error) {
+	field, err := message.Field(name)
+	if err != nil {
+		return nil, err
+	}
+	table, ok := tableOf(field.Type)
+	if !ok {
+		return nil, gserrors.Newf(ErrDynamic, "%s.%s is not a table or struct", gslang.TypeName(message.table), name)
+	}
+	if value, ok := message.values[field.ID].(*Message); ok {
+		return value, nil
+	}
+	value := New(table)
+	message.values[field.ID] = value
+	return value, nil
+}
+
+// Range 按域ID顺序遍历已设置的域 f返回false时停止遍历
+func (message *Message) Range(f func(field *ast.Field, value interface{}) bool) {
+	var ids []int
+	for id := range message.values {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		field, _ := message.FieldByID(uint16(id))
+		if !f(field, message.values[uint16(id)]) {
+			return
+		}
+	}
+}
+
+// Map 返回消息对应的codec值 结构体包含所有的域 表只包含已设置的域
+func (message *Message) Map() map[string]interface{} {
+	result := make(map[string]interface{}, len(message.values))
+	for _, field := range message.table.Fields {
+		value, ok := message.values[field.ID]
+		if !ok {
+			if !message.IsStruct() {
+				continue
+			}
+			value = Zero(field.Type)
+		}
+		result[field.Name()] = toCodec(value)
+	}
+	return result
+}
+
+// SetMap 使用codec值设置消息的域 先清除所有已设置的域
+func (message *Message) SetMap(fields map[string]interface{}) error {
+	values := make(map[uint16]interface{}, len(fields))
+	for name, value := range fields {
+		field, err := message.Field(name)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			continue
+		}
+		v, err := convert(field.Type, value, path{gslang.TypeName(message.table), "." + name})
+		if err != nil {
+			return err
+		}
+		values[field.ID] = v
+	}
+	message.values = values
+	return nil
+}
+
+// Marshal 将消息编码为二进制数据
+func (message *Message) Marshal() ([]byte, error) {
+	return codec.Marshal(message.table, message.Map())
+}
+
+// Unmarshal 解码二进制数据 替换消息的所有域
+func (message *Message) Unmarshal(data []byte) error {
+	value, err := codec.Unmarshal(message.table, data)
+	if err != nil {
+		return err
+	}
+	return message.SetMap(value.(map[string]interface{}))
+}
+
+// MarshalJSON 实现json.Marshaler接口 按规范JSON映射编码消息
+func (message *Message) MarshalJSON() ([]byte, error) {
+	return codec.MarshalJSON(message.table, message.Map())
+}
+
+// UnmarshalJSON 实现json.Unmarshaler接口 按规范JSON映射解码 替换消息的所有域
+func (message *Message) UnmarshalJSON(data []byte) error {
+	value, err := codec.UnmarshalJSON(message.table, data)
+	if err != nil {
+		return err
+	}
+	fields, _ := value.(map[string]interface{})
+	return message.SetMap(fields)
+}
+
+// toCodec 将消息的值转换为codec值
+func toCodec(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *Message:
+		return v.Map()
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = toCodec(item)
+		}
+		return result
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			result[key] = toCodec(item)
+		}
+		return result
+	}
+	return value
+}
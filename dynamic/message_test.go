@@ -0,0 +1,286 @@
+// @file 	message_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	message_test
+
+package dynamic
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// testFiles 测试使用的代码
+var testFiles = map[string]string{
+	"demo/shop/shop.gs": `
+struct Point {
+    X int32;
+    Y int32;
+}
+
+enum Color(byte) {
+    Red(1), Green(2), Blue(3)
+}
+
+enum Big(uint64) {
+    Zero(0), Max(0xFFFFFFFFFFFFFFFF)
+}
+
+table Flags {
+    Big Big;
+}
+
+table Item {
+    ID uint64;
+    Name string;
+    Tags []string;
+    Level byte;
+    Pos Point;
+    Color Color;
+    Corners [2]Point;
+    Props map[string]float64;
+    Parts []Item;
+    Counts map[Color]int32;
+}
+`,
+}
+
+// tables 编译测试代码 返回Item及Point的定义
+func tables(t *testing.T) (item, point *ast.Table) {
+	cs := gstest.Compile(t, testFiles, "demo/shop")
+	item = gstest.Type(t, cs, "demo/shop", "Item").(*ast.Table)
+	point = gstest.Type(t, cs, "demo/shop", "Point").(*ast.Table)
+	return item, point
+}
+
+func TestGetSet(t *testing.T) {
+	item, _ := tables(t)
+	message := New(item)
+	if message.Has("Name") {
+		t.Fatal("new message has Name")
+	}
+	// 未设置的域返回零值
+	zeros := map[string]interface{}{
+		"ID":    uint64(0),
+		"Name":  "",
+		"Level": uint8(0),
+		"Color": uint64(0),
+		"Tags":  nil,
+	}
+	for name, want := range zeros {
+		got, err := message.Get(name)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Get(%s) = %#v, %v want %#v", name, got, err, want)
+		}
+	}
+	pos, err := message.Get("Pos")
+	if err != nil || !reflect.DeepEqual(pos.(*Message).Map(), map[string]interface{}{"X": int32(0), "Y": int32(0)}) {
+		t.Errorf("Get(Pos) = %v, %v", pos, err)
+	}
+	corners, _ := message.Get("Corners")
+	if items, ok := corners.([]interface{}); !ok || len(items) != 2 {
+		t.Errorf("Get(Corners) = %v", corners)
+	}
+	// 设置时按域类型转换
+	sets := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{"ID", 42, uint64(42)},
+		{"Level", int64(255), uint8(255)},
+		{"Color", "Blue", uint64(3)},
+		{"Color", 200, uint64(200)},
+		{"Tags", []string{"a", "b"}, []interface{}{"a", "b"}},
+		{"Props", map[string]float64{"atk": 1.5}, map[interface{}]interface{}{"atk": 1.5}},
+		{"Counts", map[interface{}]interface{}{"Red": 1}, map[interface{}]interface{}{uint64(1): int32(1)}},
+	}
+	for _, tc := range sets {
+		if err := message.Set(tc.name, tc.value); err != nil {
+			t.Fatalf("Set(%s, %v): %s", tc.name, tc.value, err)
+		}
+		got, _ := message.Get(tc.name)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Set(%s, %v) stored %#v want %#v", tc.name, tc.value, got, tc.want)
+		}
+	}
+	// 按ID访问
+	field, _ := message.Field("Name")
+	if err := message.SetByID(field.ID, "sword"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := message.Get("Name"); got != "sword" {
+		t.Errorf("SetByID stored %v", got)
+	}
+	if got, _ := message.GetByID(field.ID); got != "sword" {
+		t.Errorf("GetByID = %v", got)
+	}
+	// nil清除域
+	if err := message.Clear("Name"); err != nil || message.Has("Name") {
+		t.Errorf("Clear(Name): %v, has %v", err, message.Has("Name"))
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	item, point := tables(t)
+	message := New(item)
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"Nope", 1, "Item has no field named Nope"},
+		{"Level", 256, "Item.Level: value 256 out of byte range"},
+		{"ID", -1, "Item.ID: value -1 out of uint64 range"},
+		{"Name", 1, "Item.Name: expect string got int"},
+		{"Color", "Purple", "Item.Color: demo/shop.Color has no value named Purple"},
+		{"Color", 256, "Item.Color: value 256 out of demo/shop.Color range"},
+		{"Corners", []interface{}{nil}, "Item.Corners: expect 2 elements got 1"},
+		{"Pos", New(item), "Item.Pos: expect message demo/shop.Point got demo/shop.Item"},
+		{"Pos", map[string]interface{}{"Z": 1}, "Point has no field named Z"},
+		{"Parts", []interface{}{map[string]interface{}{"Level": "x"}}, "Item.Parts[0].Level: expect integer got string"},
+	}
+	for _, tc := range tests {
+		err := message.Set(tc.name, tc.value)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Set(%s, %v) error = %v, want %q", tc.name, tc.value, err, tc.want)
+		}
+	}
+	if len(message.Map()) != 0 {
+		t.Errorf("failed Set changed the message: %v", message.Map())
+	}
+	if _, err := message.Mutable("Name"); err == nil {
+		t.Error("Mutable(Name) succeeded on a string field")
+	}
+	if err := message.Set("Pos", New(point)); err != nil {
+		t.Errorf("Set(Pos, Point message): %s", err)
+	}
+}
+
+// sample 构造包含嵌套消息 切片及字典的消息
+func sample(t *testing.T, item *ast.Table) *Message {
+	message := New(item)
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(message.Set("ID", uint64(1<<40)))
+	must(message.Set("Name", "sword"))
+	must(message.Set("Tags", []interface{}{"a", "bb"}))
+	must(message.Set("Color", "Green"))
+	pos, err := message.Mutable("Pos")
+	must(err)
+	must(pos.Set("X", 1))
+	must(pos.Set("Y", -2))
+	must(message.Set("Props", map[interface{}]interface{}{"atk": 1.5}))
+	must(message.Set("Parts", []interface{}{map[string]interface{}{"Name": "blade", "Level": 3}}))
+	must(message.Set("Counts", map[interface{}]interface{}{uint64(3): int32(7)}))
+	return message
+}
+
+func TestCodec(t *testing.T) {
+	item, _ := tables(t)
+	message := sample(t, item)
+	data, err := message.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := New(item)
+	if err := decoded.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Map(), message.Map()) {
+		t.Fatalf("binary round trip mismatch:\n got %v\nwant %v", decoded, message)
+	}
+	text, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"ID":"1099511627776","Name":"sword","Tags":["a","bb"],"Pos":{"X":1,"Y":-2},"Color":"Green",` +
+		`"Props":{"atk":1.5},"Parts":[{"Name":"blade","Level":3}],"Counts":{"Blue":7}}`
+	if string(text) != want {
+		t.Fatalf("json.Marshal =\n%s\nwant\n%s", text, want)
+	}
+	fromJSON := New(item)
+	if err := json.Unmarshal(text, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON.Map(), message.Map()) {
+		t.Fatalf("json round trip mismatch:\n got %v\nwant %v", fromJSON, message)
+	}
+	// 结构体的Map包含所有的域
+	pos, _ := message.Get("Pos")
+	if got := New(pos.(*Message).Table()).Map(); len(got) != 2 {
+		t.Errorf("struct Map = %v", got)
+	}
+}
+
+func TestRange(t *testing.T) {
+	item, _ := tables(t)
+	message := sample(t, item)
+	var ids []uint16
+	message.Range(func(field *ast.Field, value interface{}) bool {
+		ids = append(ids, field.ID)
+		return true
+	})
+	for i := 1; i < len(ids); i++ {
+		if ids[i-1] >= ids[i] {
+			t.Fatalf("Range order %v", ids)
+		}
+	}
+	if len(ids) != len(message.Map()) {
+		t.Fatalf("Range visited %d fields, message has %d", len(ids), len(message.Map()))
+	}
+	count := 0
+	message.Range(func(*ast.Field, interface{}) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("Range did not stop, visited %d", count)
+	}
+}
+
+// TestEnum64 无符号64位枚举的取值可以超出int64
+func TestEnum64(t *testing.T) {
+	cs := gstest.Compile(t, testFiles, "demo/shop")
+	flags := gstest.Type(t, cs, "demo/shop", "Flags").(*ast.Table)
+	message := New(flags)
+	if got, _ := message.Get("Big"); got != uint64(0) {
+		t.Errorf("zero Big = %#v", got)
+	}
+	for _, value := range []interface{}{"Max", uint64(math.MaxUint64)} {
+		if err := message.Set("Big", value); err != nil {
+			t.Fatalf("Set(Big, %v): %s", value, err)
+		}
+		if got, _ := message.Get("Big"); got != uint64(math.MaxUint64) {
+			t.Errorf("Set(Big, %v) stored %#v", value, got)
+		}
+	}
+	data, err := message.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := New(flags)
+	if err := decoded.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := decoded.Get("Big"); got != uint64(math.MaxUint64) {
+		t.Errorf("decoded Big = %#v", got)
+	}
+	text, err := json.Marshal(decoded)
+	if err != nil || string(text) != `{"Big":"Max"}` {
+		t.Errorf("json.Marshal = %s, %v", text, err)
+	}
+	if err := message.Set("Big", -1); err == nil || !strings.Contains(err.Error(), "value -1 out of demo/shop.Big range") {
+		t.Errorf("Set(Big, -1) error = %v", err)
+	}
+}
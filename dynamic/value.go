@@ -0,0 +1,324 @@
+// @file 	value.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	value
+
+package dynamic
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// path 值路径 用于错误信息 如 Item.Corners[2].X
+type path []string
+
+// errorf 生成带值路径的错误
+func (p path) errorf(format string, args ...interface{}) error {
+	return gserrors.Newf(ErrDynamic, "%s: %s", strings.Join(p, ""), fmt.Sprintf(format, args...))
+}
+
+// push 返回追加了一段的新路径
+func (p path) push(part string) path {
+	return append(append(path(nil), p...), part)
+}
+
+// target 返回类型引用指向的类型 内置类型及其他类型表达式返回自身
+func target(expr ast.Expr) ast.Expr {
+	if _, ok := gslang.Builtin(expr); ok {
+		return expr
+	}
+	if ref, ok := expr.(*ast.TypeRef); ok && ref.Ref != nil {
+		return ref.Ref
+	}
+	return expr
+}
+
+// tableOf 返回类型表达式对应的表或结构体
+func tableOf(expr ast.Expr) (*ast.Table, bool) {
+	if _, ok := gslang.Builtin(expr); ok {
+		return nil, false
+	}
+	table, ok := target(expr).(*ast.Table)
+	return table, ok
+}
+
+// Zero 返回类型的零值 结构体返回所有域为零值的消息 表 切片 字典返回nil
+func Zero(expr ast.Expr) interface{} {
+	if key, ok := gslang.Builtin(expr); ok {
+		switch key {
+		case gslang.KeyByte:
+			return uint8(0)
+		case gslang.KeySByte:
+			return int8(0)
+		case gslang.KeyInt16:
+			return int16(0)
+		case gslang.KeyUInt16:
+			return uint16(0)
+		case gslang.KeyInt32:
+			return int32(0)
+		case gslang.KeyUInt32:
+			return uint32(0)
+		case gslang.KeyInt64:
+			return int64(0)
+		case gslang.KeyUInt64:
+			return uint64(0)
+		case gslang.KeyFloat32:
+			return float32(0)
+		case gslang.KeyFloat64:
+			return float64(0)
+		case gslang.KeyBool:
+			return false
+		case gslang.KeyString:
+			return ""
+		}
+	}
+	switch node := target(expr).(type) {
+	case *ast.Enum:
+		if !node.Signed {
+			return uint64(0)
+		}
+		return int64(0)
+	case *ast.Table:
+		if gslang.IsStruct(node) {
+			return New(node)
+		}
+	case *ast.Array:
+		items := make([]interface{}, node.Length)
+		for i := range items {
+			items[i] = Zero(node.Element)
+		}
+		return items
+	}
+	return nil
+}
+
+// convert 按类型检查值并转换为消息使用的Go类型
+func convert(expr ast.Expr, value interface{}, p path) (interface{}, error) {
+	if key, ok := gslang.Builtin(expr); ok {
+		return builtin(key, value, p)
+	}
+	switch node := target(expr).(type) {
+	case *ast.Enum:
+		return enum(node, value, p)
+	case *ast.Table:
+		return message(node, value, p)
+	case *ast.List:
+		return list(node.Element, -1, value, p)
+	case *ast.Array:
+		return list(node.Element, int(node.Length), value, p)
+	case *ast.Map:
+		return mapValue(node, value, p)
+	case *ast.TypeRef:
+		return nil, p.errorf("unlinked type %s", node)
+	}
+	return nil, p.errorf("type %s can not be used as field type", gslang.TypeName(expr))
+}
+
+// integerBits 整数内置类型的位数
+var integerBits = map[rune]uint{
+	gslang.KeyByte: 8, gslang.KeySByte: 8,
+	gslang.KeyInt16: 16, gslang.KeyUInt16: 16,
+	gslang.KeyInt32: 32, gslang.KeyUInt32: 32,
+	gslang.KeyInt64: 64, gslang.KeyUInt64: 64,
+}
+
+// builtin 检查并转换内置类型的值
+func builtin(key rune, value interface{}, p path) (interface{}, error) {
+	switch key {
+	case gslang.KeyBool:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		return nil, p.errorf("expect bool got %T", value)
+	case gslang.KeyString:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+		return nil, p.errorf("expect string got %T", value)
+	case gslang.KeyFloat32:
+		switch v := value.(type) {
+		case float32:
+			return v, nil
+		case float64:
+			return float32(v), nil
+		}
+		return nil, p.errorf("expect float32 got %T", value)
+	case gslang.KeyFloat64:
+		switch v := value.(type) {
+		case float32:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+		return nil, p.errorf("expect float64 got %T", value)
+	}
+	n, negative, err := integer(value, p)
+	if err != nil {
+		return nil, err
+	}
+	bits := integerBits[key]
+	signed := key == gslang.KeySByte || key == gslang.KeyInt16 || key == gslang.KeyInt32 || key == gslang.KeyInt64
+	if !fits(n, negative, bits, signed) {
+		return nil, p.errorf("value %s out of %s range", formatInteger(n, negative), gslang.TokenName(key))
+	}
+	switch key {
+	case gslang.KeyByte:
+		return uint8(n), nil
+	case gslang.KeySByte:
+		return int8(n), nil
+	case gslang.KeyInt16:
+		return int16(n), nil
+	case gslang.KeyUInt16:
+		return uint16(n), nil
+	case gslang.KeyInt32:
+		return int32(n), nil
+	case gslang.KeyUInt32:
+		return uint32(n), nil
+	case gslang.KeyInt64:
+		return int64(n), nil
+	}
+	return n, nil
+}
+
+// integer 将任意Go整数转换为补码形式的uint64 negative表示是否为负数
+func integer(value interface{}, p path) (uint64, bool, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(v.Int()), v.Int() < 0, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), false, nil
+	}
+	return 0, false, p.errorf("expect integer got %T", value)
+}
+
+// fits 检查整数是否在指定位数的整数范围内
+func fits(n uint64, negative bool, bits uint, signed bool) bool {
+	if negative {
+		return signed && int64(n) >= -1<<(bits-1)
+	}
+	if signed {
+		return n <= 1<<(bits-1)-1
+	}
+	return bits == 64 || n <= 1<<bits-1
+}
+
+// formatInteger 格式化整数
+func formatInteger(n uint64, negative bool) string {
+	if negative {
+		return strconv.FormatInt(int64(n), 10)
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+// enum 检查并转换枚举值 可以使用整数或者枚举值名字 有符号枚举转换为int64 无符号枚举转换为uint64
+func enum(node *ast.Enum, value interface{}, p path) (interface{}, error) {
+	if name, ok := value.(string); ok {
+		val, ok := node.Values[name]
+		if !ok {
+			return nil, p.errorf("%s has no value named %s", gslang.TypeName(node), name)
+		}
+		// 无符号64位枚举的EnumVal.Value为补码
+		if !node.Signed {
+			return uint64(val.Value), nil
+		}
+		return val.Value, nil
+	}
+	n, negative, err := integer(value, p)
+	if err != nil {
+		return nil, err
+	}
+	if !fits(n, negative, node.Length*8, node.Signed) {
+		return nil, p.errorf("value %s out of %s range", formatInteger(n, negative), gslang.TypeName(node))
+	}
+	if !node.Signed {
+		return n, nil
+	}
+	return int64(n), nil
+}
+
+// message 检查并转换表或结构体的值 可以使用同一定义的消息或者map[string]interface{}
+func message(table *ast.Table, value interface{}, p path) (interface{}, error) {
+	switch v := value.(type) {
+	case *Message:
+		if v.table != table {
+			return nil, p.errorf("expect message %s got %s", gslang.TypeName(table), gslang.TypeName(v.table))
+		}
+		return v, nil
+	case map[string]interface{}:
+		result := New(table)
+		for name, item := range v {
+			field, ok := table.Field(name)
+			if !ok {
+				return nil, p.errorf("%s has no field named %s", gslang.TypeName(table), name)
+			}
+			if item == nil {
+				continue
+			}
+			converted, err := convert(field.Type, item, p.push("."+name))
+			if err != nil {
+				return nil, err
+			}
+			result.values[field.ID] = converted
+		}
+		return result, nil
+	}
+	return nil, p.errorf("expect message %s got %T", gslang.TypeName(table), value)
+}
+
+// list 检查并转换切片或者数组的值 length小于0表示切片
+func list(element ast.Expr, length int, value interface{}, p path) (interface{}, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, p.errorf("expect []interface{} got %T", value)
+	}
+	if length >= 0 && v.Len() != length {
+		return nil, p.errorf("expect %d elements got %d", length, v.Len())
+	}
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		item, err := convert(element, v.Index(i).Interface(), p.push("["+strconv.Itoa(i)+"]"))
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// mapValue 检查并转换字典的值 key只能是内置类型或者枚举
+func mapValue(node *ast.Map, value interface{}, p path) (interface{}, error) {
+	if _, ok := gslang.Builtin(node.Key); !ok {
+		if _, ok := target(node.Key).(*ast.Enum); !ok {
+			return nil, p.errorf("map key %s must be builtin type or enum", gslang.TypeName(node.Key))
+		}
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map {
+		return nil, p.errorf("expect map[interface{}]interface{} got %T", value)
+	}
+	result := make(map[interface{}]interface{}, v.Len())
+	for _, k := range v.MapKeys() {
+		label := fmt.Sprintf("[%v]", k.Interface())
+		key, err := convert(node.Key, k.Interface(), p.push(label))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := result[key]; ok {
+			return nil, p.errorf("duplicate map key %s", label)
+		}
+		item, err := convert(node.Value, v.MapIndex(k).Interface(), p.push(label))
+		if err != nil {
+			return nil, err
+		}
+		result[key] = item
+	}
+	return result, nil
+}
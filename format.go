@@ -0,0 +1,390 @@
+// @file 	format.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	format
+
+package gslang
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/skea3344/gslang/ast"
+)
+
+// AddComments 为节点追加注释 用于在代码中构造语法树时保留注释
+func AddComments(node ast.Node, comments ...string) {
+	tokens := Comments(node)
+	for _, comment := range comments {
+		token := NewToken(TokenCOMMENT, " "+comment)
+		token.Pos = Pos(node)
+		tokens = append(tokens, token)
+	}
+	attachComments(node, tokens)
+}
+
+// formatter 将代码节点格式化输出为gslang源码
+type formatter struct {
+	writer *bufio.Writer
+	indent string
+}
+
+// Format 将代码节点格式化输出为gslang源码
+func Format(writer io.Writer, script *ast.Script) error {
+	f := &formatter{
+		writer: bufio.NewWriter(writer),
+		indent: "    ",
+	}
+	f.script(script)
+	return f.writer.Flush()
+}
+
+// printf 格式化输出
+func (f *formatter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(f.writer, format, args...)
+}
+
+// comments 输出节点的注释 单行注释使用// 多行注释使用/**/
+func (f *formatter) comments(node ast.Node, indent string) {
+	for _, token := range Comments(node) {
+		text, _ := token.Value.(string)
+		if strings.Contains(text, "\n") {
+			f.printf("%s/*%s*/\n", indent, text)
+			continue
+		}
+		f.printf("%s//%s\n", indent, text)
+	}
+}
+
+// attrs 输出节点的属性列表 每个属性一行 struct的内置Struct属性不输出
+func (f *formatter) attrs(node ast.Node, indent string) {
+	for _, attr := range node.Attrs() {
+		if isStructAttr(attr) {
+			continue
+		}
+		f.comments(attr, indent)
+		f.printf("%s%s\n", indent, formatAttr(attr))
+	}
+}
+
+// isStructAttr 检查是否为struct关键字生成的内置Struct属性
+func isStructAttr(attr *ast.Attr) bool {
+	path := attr.Type.NamePath
+	if path[len(path)-1] != GSLangAttrStruct {
+		return false
+	}
+	if attr.Type.Ref != nil {
+		return attr.Type.Ref.Package() != nil && attr.Type.Ref.Package().Name() == GSLangPackage
+	}
+	return len(path) == 1 || path[0] == "gslang"
+}
+
+// isStructTable 检查表节点是否为结构体 未连接的节点通过属性判断
+func isStructTable(table *ast.Table) bool {
+	if IsStruct(table) {
+		return true
+	}
+	for _, attr := range table.Attrs() {
+		if isStructAttr(attr) {
+			return true
+		}
+	}
+	return false
+}
+
+// script 输出代码节点
+func (f *formatter) script(script *ast.Script) {
+	imported := f.imports(script)
+	for i, expr := range script.Types {
+		if i > 0 || imported {
+			f.printf("\n")
+		}
+		f.comments(expr, "")
+		f.attrs(expr, "")
+		switch node := expr.(type) {
+		case *ast.Table:
+			f.table(node)
+		case *ast.Enum:
+			f.enum(node)
+		case *ast.Contract:
+			f.contract(node)
+		}
+	}
+	// 代码节点的注释及属性 在解析时为文件末尾剩余的注释及属性
+	if len(script.Attrs()) > 0 || len(Comments(script)) > 0 {
+		f.printf("\n")
+		f.comments(script, "")
+		f.attrs(script, "")
+	}
+}
+
+// imports 输出代码引用的包 不输出自动引用的gslang包 返回是否有输出
+func (f *formatter) imports(script *ast.Script) bool {
+	var names []string
+	for name, ref := range script.Imports {
+		if name == "gslang" && (ref.Ref == nil || ref.Ref.Name() == GSLangPackage) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	line := func(name string) string {
+		ref := script.Imports[name]
+		path := name
+		if ref.Ref != nil {
+			path = ref.Ref.Name()
+		} else if val, ok := ref.Extra(importPathExtra); ok {
+			path = val.(string)
+		}
+		if filepath.Base(path) == name {
+			return strconv.Quote(path)
+		}
+		return name + " " + strconv.Quote(path)
+	}
+	switch len(names) {
+	case 0:
+		return false
+	case 1:
+		f.comments(script.Imports[names[0]], "")
+		f.printf("import %s\n", line(names[0]))
+	default:
+		f.printf("import (\n")
+		for _, name := range names {
+			f.comments(script.Imports[name], f.indent)
+			f.printf("%s%s\n", f.indent, line(name))
+		}
+		f.printf(")\n")
+	}
+	return true
+}
+
+// table 输出表或结构体
+func (f *formatter) table(table *ast.Table) {
+	keyword := "table"
+	if isStructTable(table) {
+		keyword = "struct"
+	}
+	if len(table.Fields) == 0 {
+		f.printf("%s %s {}\n", keyword, table.Name())
+		return
+	}
+	f.printf("%s %s {\n", keyword, table.Name())
+	for _, field := range table.Fields {
+		f.comments(field, f.indent)
+		f.attrs(field, f.indent)
+		f.printf("%s%s %s;\n", f.indent, field.Name(), formatType(field.Type))
+	}
+	f.printf("}\n")
+}
+
+// enum 输出枚举 枚举值按声明顺序输出
+func (f *formatter) enum(enum *ast.Enum) {
+	f.printf("enum %s", enum.Name())
+	if base := enumBase(enum); base != KeyByte {
+		f.printf("(%s)", TokenName(base))
+	}
+	f.printf(" {\n")
+	vals := declaredVals(enum)
+	for i, val := range vals {
+		f.comments(val, f.indent)
+		f.attrs(val, f.indent)
+		f.printf("%s%s(%s)", f.indent, val.Name(), EnumValue(val))
+		if i < len(vals)-1 {
+			f.printf(",")
+		}
+		f.printf("\n")
+	}
+	f.printf("}\n")
+}
+
+// declaredVals 返回按声明顺序排列的枚举值 第一个声明的枚举值为默认值 必须保持在第一个
+// 解析得到的枚举值按源码位置排序 在代码中构造的枚举值没有位置 默认值之后按值排序
+func declaredVals(enum *ast.Enum) []*ast.EnumVal {
+	vals := EnumVals(enum)
+	positioned := true
+	for _, val := range vals {
+		positioned = positioned && Pos(val).Valid()
+	}
+	sort.SliceStable(vals, func(i, j int) bool {
+		if vals[i] == enum.Default || vals[j] == enum.Default {
+			return vals[i] == enum.Default
+		}
+		if !positioned {
+			return false
+		}
+		left, right := Pos(vals[i]), Pos(vals[j])
+		if left.Line != right.Line {
+			return left.Line < right.Line
+		}
+		return left.Column < right.Column
+	})
+	return vals
+}
+
+// enumBase 返回枚举长度及符号对应的内置类型关键字
+func enumBase(enum *ast.Enum) rune {
+	switch {
+	case enum.Length == 1 && enum.Signed:
+		return KeySByte
+	case enum.Length == 2 && enum.Signed:
+		return KeyInt16
+	case enum.Length == 2:
+		return KeyUInt16
+	case enum.Length == 4 && enum.Signed:
+		return KeyInt32
+	case enum.Length == 4:
+		return KeyUInt32
+	case enum.Length == 8 && enum.Signed:
+		return KeyInt64
+	case enum.Length == 8:
+		return KeyUInt64
+	}
+	return KeyByte
+}
+
+// ownMethods 返回协议自身声明的函数 不包括展开时从父协议复制的函数
+func ownMethods(contract *ast.Contract) []*ast.Method {
+	var methods []*ast.Method
+	for _, method := range Methods(contract) {
+		inherited := false
+		for _, base := range contract.Bases {
+			if parent, ok := base.Ref.(*ast.Contract); ok {
+				if _, ok := parent.Methods[method.Name()]; ok {
+					inherited = true
+				}
+			}
+		}
+		if !inherited {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// contract 输出协议
+func (f *formatter) contract(contract *ast.Contract) {
+	f.printf("contract %s", contract.Name())
+	if len(contract.Bases) > 0 {
+		var bases []string
+		for _, base := range contract.Bases {
+			bases = append(bases, formatType(base))
+		}
+		f.printf("(%s)", strings.Join(bases, ", "))
+	}
+	f.printf(" {\n")
+	for _, method := range ownMethods(contract) {
+		f.comments(method, f.indent)
+		f.attrs(method, f.indent)
+		f.printf("%s%s(%s)", f.indent, method.Name(), formatParams(method.Params))
+		if len(method.Return) > 0 {
+			f.printf(" -> (%s)", formatParams(method.Return))
+		}
+		f.printf(";\n")
+	}
+	f.printf("}\n")
+}
+
+// formatParams 格式化参数列表 参数的属性输出在类型之前
+func formatParams(params []*ast.Param) string {
+	var items []string
+	for _, param := range params {
+		var buff strings.Builder
+		for _, attr := range param.Attrs() {
+			buff.WriteString(formatAttr(attr))
+			buff.WriteString(" ")
+		}
+		buff.WriteString(formatType(param.Type))
+		items = append(items, buff.String())
+	}
+	return strings.Join(items, ", ")
+}
+
+// formatType 格式化类型表达式 内置类型输出为关键字
+func formatType(expr ast.Expr) string {
+	if key, ok := Builtin(expr); ok {
+		return TokenName(key)
+	}
+	switch node := expr.(type) {
+	case *ast.TypeRef:
+		return strings.Join(node.NamePath, ".")
+	case *ast.List:
+		return "[]" + formatType(node.Element)
+	case *ast.Array:
+		return fmt.Sprintf("[%d]%s", node.Length, formatType(node.Element))
+	case *ast.Map:
+		return fmt.Sprintf("map[%s]%s", formatType(node.Key), formatType(node.Value))
+	}
+	return expr.Name()
+}
+
+// FormatAttr 返回属性的gslang源码形式 如 @gslang.AttrUsage(AttrTarget.Table)
+func FormatAttr(attr *ast.Attr) string {
+	return formatAttr(attr)
+}
+
+// formatAttr 格式化属性
+func formatAttr(attr *ast.Attr) string {
+	name := "@" + strings.Join(attr.Type.NamePath, ".")
+	switch args := attr.Args.(type) {
+	case *ast.Args:
+		var items []string
+		for _, arg := range args.Items {
+			items = append(items, formatArg(arg))
+		}
+		return name + "(" + strings.Join(items, ", ") + ")"
+	case *ast.NamedArgs:
+		// 已连接的属性按属性类型的域顺序输出 否则按名字排序
+		var labels []string
+		if table, ok := attr.Type.Ref.(*ast.Table); ok {
+			for _, field := range table.Fields {
+				if _, ok := args.Items[field.Name()]; ok {
+					labels = append(labels, field.Name())
+				}
+			}
+		}
+		if len(labels) != len(args.Items) {
+			labels = labels[:0]
+			for label := range args.Items {
+				labels = append(labels, label)
+			}
+			sort.Strings(labels)
+		}
+		var items []string
+		for _, label := range labels {
+			items = append(items, label+": "+formatArg(args.Items[label]))
+		}
+		return name + "(" + strings.Join(items, ", ") + ")"
+	}
+	return name
+}
+
+// formatArg 格式化属性参数
+func formatArg(expr ast.Expr) string {
+	switch node := expr.(type) {
+	case *ast.Int:
+		if node.Big != nil {
+			return node.Big.String()
+		}
+		return strconv.FormatInt(node.Value, 10)
+	case *ast.Float:
+		text := strconv.FormatFloat(node.Value, 'g', -1, 64)
+		if !strings.ContainsAny(text, ".eEnN") {
+			text += ".0"
+		}
+		return text
+	case *ast.String:
+		return strconv.Quote(node.Value)
+	case *ast.Bool:
+		return strconv.FormatBool(node.Value)
+	case *ast.TypeRef:
+		return strings.Join(node.NamePath, ".")
+	case *ast.BinaryOp:
+		return formatArg(node.Left) + " " + node.Name() + " " + formatArg(node.Right)
+	}
+	return expr.Name()
+}
@@ -0,0 +1,197 @@
+// @file 	format_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	format_test
+
+package gslang_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// formatSource 格式化测试使用的代码
+const formatSource = `import b2 "demo/other"
+import "demo/base"
+
+// 颜色
+@Flag
+enum Color(int32) {
+    Red(0),
+    // 绿
+    Green(1),
+    Blue(-5) // 蓝
+}
+
+struct Point { X int32; Y int32; }
+
+@gslang.AttrUsage(gslang.AttrTarget.Table|gslang.AttrTarget.Field)
+table Tag { Name string; Weight float64; }
+
+table Empty {}
+
+// 物品
+table Item {
+    // 编号
+    @Tag(Weight: 1, Name: "id")
+    ID uint64; // 行尾
+    Pos [2]base.Point;
+    Other b2.T;
+    Tags []string;
+    Counts map[string]int32;
+}
+
+contract Base { Ping(); }
+
+// 商店
+contract Shop(Base, base.Other) {
+    @Tag(Name: "get")
+    // 查询
+    Get(@Tag id uint64, name string) -> (Item, Color);
+    Put(Item);
+}
+
+// 结尾
+`
+
+// wantFormat formatSource格式化后的代码
+const wantFormat = `import (
+    b2 "demo/other"
+    "demo/base"
+)
+
+// 颜色
+@Flag
+enum Color(int32) {
+    Red(0),
+    // 绿
+    Green(1),
+    // 蓝
+    Blue(-5)
+}
+
+struct Point {
+    X int32;
+    Y int32;
+}
+
+@gslang.AttrUsage(gslang.AttrTarget.Table | gslang.AttrTarget.Field)
+table Tag {
+    Name string;
+    Weight float64;
+}
+
+table Empty {}
+
+// 物品
+table Item {
+    // 行尾
+    // 编号
+    @Tag(Name: "id", Weight: 1)
+    ID uint64;
+    Pos [2]base.Point;
+    Other b2.T;
+    Tags []string;
+    Counts map[string]int32;
+}
+
+contract Base {
+    Ping();
+}
+
+// 商店
+contract Shop(Base, base.Other) {
+    // 查询
+    @Tag(Name: "get")
+    Get(@Tag uint64, string) -> (Item, Color);
+    Put(Item);
+}
+
+// 结尾
+`
+
+// format 解析并格式化代码
+func format(t *testing.T, source string) string {
+	script, diagnostics := gslang.ParseSource("demo/a.gs", []byte(source))
+	if len(diagnostics) != 0 {
+		t.Fatalf("ParseSource: %v\n%s", diagnostics, source)
+	}
+	var buff bytes.Buffer
+	if err := gslang.Format(&buff, script); err != nil {
+		t.Fatal(err)
+	}
+	return buff.String()
+}
+
+// TestFormat 格式化的结果再次解析格式化后不变
+func TestFormat(t *testing.T) {
+	got := format(t, formatSource)
+	if got != wantFormat {
+		t.Fatalf("Format:\n%s\nwant\n%s", got, wantFormat)
+	}
+	if again := format(t, got); again != got {
+		t.Errorf("Format is not idempotent:\n%s\nwant\n%s", again, got)
+	}
+}
+
+// TestFormatEnumOrder 枚举值按声明顺序输出 重新解析后默认值不变
+func TestFormatEnumOrder(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"enum Color(int32) { Red(0), Green(1), Blue(-5) }", "enum Color(int32) {\n    Red(0),\n    Green(1),\n    Blue(-5)\n}\n"},
+		{"enum E { B(2), A(1), C(3) }", "enum E {\n    B(2),\n    A(1),\n    C(3)\n}\n"},
+		{"enum E(uint64) { Max(0xFFFFFFFFFFFFFFFF), Zero(0) }", "enum E(uint64) {\n    Max(18446744073709551615),\n    Zero(0)\n}\n"},
+	}
+	for _, tc := range tests {
+		got := format(t, tc.source)
+		if got != tc.want {
+			t.Errorf("Format(%q) =\n%s\nwant\n%s", tc.source, got, tc.want)
+			continue
+		}
+		before, _ := gslang.ParseSource("demo/a.gs", []byte(tc.source))
+		after, _ := gslang.ParseSource("demo/a.gs", []byte(got))
+		if want, got := before.Types[0].(*ast.Enum).Default.Name(), after.Types[0].(*ast.Enum).Default.Name(); got != want {
+			t.Errorf("Format(%q) changed default %s to %s", tc.source, want, got)
+		}
+	}
+	// 已连接的代码
+	cs := gstest.Compile(t, map[string]string{"demo/shop/shop.gs": tests[0].source}, "demo/shop")
+	var buff bytes.Buffer
+	if err := gslang.Format(&buff, cs.Loaded["demo/shop"].Scripts["shop.gs"]); err != nil {
+		t.Fatal(err)
+	}
+	if buff.String() != tests[0].want {
+		t.Errorf("Format linked:\n%s\nwant\n%s", buff.String(), tests[0].want)
+	}
+}
+
+// TestFormatConstructed 代码中构造的枚举值没有位置 默认值在前 其余按值排序
+func TestFormatConstructed(t *testing.T) {
+	script, err := ast.NewPackage("demo/gen").NewScript("gen.gs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enum := script.NewEnum("Level", 2, true)
+	script.NewType(enum)
+	for _, val := range []struct {
+		name  string
+		value int64
+	}{{"Mid", 5}, {"High", 9}, {"Low", -1}} {
+		enumVal, _ := enum.NewVal(val.name, val.value)
+		gslang.AddComments(enumVal, val.name)
+	}
+	var buff bytes.Buffer
+	if err := gslang.Format(&buff, script); err != nil {
+		t.Fatal(err)
+	}
+	want := "enum Level(int16) {\n    // Mid\n    Mid(5),\n    // Low\n    Low(-1),\n    // High\n    High(9)\n}\n"
+	if buff.String() != want {
+		t.Errorf("Format:\n%s\nwant\n%s", buff.String(), want)
+	}
+}
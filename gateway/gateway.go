@@ -0,0 +1,230 @@
+// @file 	gateway.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	gateway
+
+// Package gateway 将协议的函数以HTTP/JSON的形式暴露出来 调用转发给rpc.Invoker
+//
+// 每个函数对应 POST /<package>/<Contract>/<Method> 如 POST /demo/shop/Shop/Get
+// 请求体为参数的JSON数组 按参数顺序排列 没有参数时可以为空
+// 参数及返回值使用codec包的规范JSON映射
+//
+// 调用成功时返回200 响应体为 {"result": [返回值...]}
+// 调用失败时响应体为 {"error": {"enum": 枚举全名, "name": 枚举值名字, "code": 枚举值, "message": 错误描述}}
+// 只有@Error枚举定义的错误才有enum name code 其余错误只有message
+//
+//	请求方法不是POST         405
+//	协议或者函数不存在       404
+//	请求体不合法             400
+//	请求体超过MaxBodySize    413
+//	@Error枚举定义的错误     422
+//	调用超时 包括服务端超时    504
+//	其他错误                 502
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/codec"
+	"github.com/skea3344/gslang/rpc"
+)
+
+// 错误码
+var (
+	ErrGateway = errors.New("gateway error")
+)
+
+// MaxBodySize 请求体的最大长度
+const MaxBodySize = 4 << 20
+
+// Gateway HTTP网关 实现http.Handler接口
+type Gateway struct {
+	invoker   rpc.Invoker              // 调用转发的目标
+	contracts map[string]*ast.Contract // 路径 <package>/<Contract> -> 协议
+	Timeout   time.Duration            // 单次调用的超时时间 0表示只使用请求的context
+}
+
+// New 新建网关 暴露给定协议的所有函数 包括从父协议继承的函数
+func New(invoker rpc.Invoker, contracts ...*ast.Contract) *Gateway {
+	gateway := &Gateway{
+		invoker:   invoker,
+		contracts: make(map[string]*ast.Contract),
+	}
+	for _, contract := range contracts {
+		gateway.Expose(contract)
+	}
+	return gateway
+}
+
+// Expose 暴露协议的所有函数 返回协议对应的路径前缀
+func (gateway *Gateway) Expose(contract *ast.Contract) string {
+	name := contract.Package().Name() + "/" + contract.Name()
+	gateway.contracts[name] = contract
+	return "/" + name + "/"
+}
+
+// Routes 返回所有函数的路径 按协议及函数ID排序
+func (gateway *Gateway) Routes() []string {
+	var names []string
+	for name := range gateway.contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var routes []string
+	for _, name := range names {
+		for _, method := range gslang.Methods(gateway.contracts[name]) {
+			routes = append(routes, "/"+name+"/"+method.Name())
+		}
+	}
+	return routes
+}
+
+// errorBody 错误响应体
+type errorBody struct {
+	Enum    string `json:"enum,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Code    *int64 `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// ServeHTTP 实现http.Handler接口
+func (gateway *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		gateway.fail(w, http.StatusMethodNotAllowed, nil, "method %s not allowed", r.Method)
+		return
+	}
+	contract, method, err := gateway.route(r.URL.Path)
+	if err != nil {
+		gateway.fail(w, http.StatusNotFound, nil, "%s", err)
+		return
+	}
+	// 多读一个字节用于判断请求体是否超过最大长度
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxBodySize+1))
+	if err != nil {
+		gateway.fail(w, http.StatusBadRequest, nil, "read body error: %s", err)
+		return
+	}
+	if len(data) > MaxBodySize {
+		gateway.fail(w, http.StatusRequestEntityTooLarge, nil, "request body exceeds %d bytes", MaxBodySize)
+		return
+	}
+	params, err := gateway.params(method, data)
+	if err != nil {
+		gateway.fail(w, http.StatusBadRequest, nil, "%s", err)
+		return
+	}
+	ctx := r.Context()
+	if gateway.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, gateway.Timeout)
+		defer cancel()
+	}
+	results, err := gateway.invoker.Invoke(ctx, contract, method, params)
+	if err != nil {
+		var target *rpc.Error
+		switch {
+		case errors.As(err, &target):
+			gateway.fail(w, http.StatusUnprocessableEntity, target, "%s", target.Message)
+		case errors.Is(err, context.DeadlineExceeded):
+			gateway.fail(w, http.StatusGatewayTimeout, nil, "%s", err)
+		default:
+			gateway.fail(w, http.StatusBadGateway, nil, "%s", err)
+		}
+		return
+	}
+	body, err := gateway.results(method, results)
+	if err != nil {
+		gateway.fail(w, http.StatusBadGateway, nil, "%s", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// route 按路径查找协议及函数 路径为 /<package>/<Contract>/<Method>
+func (gateway *Gateway) route(path string) (*ast.Contract, *ast.Method, error) {
+	path = strings.Trim(path, "/")
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return nil, nil, gserrors.Newf(ErrGateway, "invalid path /%s", path)
+	}
+	contract, ok := gateway.contracts[path[:i]]
+	if !ok {
+		return nil, nil, gserrors.Newf(ErrGateway, "contract %s not found", path[:i])
+	}
+	method, err := rpc.Method(contract, path[i+1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return contract, method, nil
+}
+
+// params 解码请求体为参数
+func (gateway *Gateway) params(method *ast.Method, data []byte) ([]interface{}, error) {
+	var raws []json.RawMessage
+	if len(bytes.TrimSpace(data)) != 0 {
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return nil, gserrors.Newf(ErrGateway, "body must be a json array of params: %s", err)
+		}
+	}
+	if len(raws) != len(method.Params) {
+		return nil, gserrors.Newf(ErrGateway, "%s expect %d params got %d", method.Name(), len(method.Params), len(raws))
+	}
+	params := make([]interface{}, len(raws))
+	for i, param := range method.Params {
+		value, err := codec.UnmarshalJSON(param.Type, raws[i])
+		if err != nil {
+			return nil, gserrors.Newf(ErrGateway, "param %d: %s", i, err)
+		}
+		params[i] = value
+	}
+	return params, nil
+}
+
+// results 编码返回值为响应体
+func (gateway *Gateway) results(method *ast.Method, results []interface{}) ([]byte, error) {
+	if len(results) != len(method.Return) {
+		return nil, gserrors.Newf(ErrGateway, "%s expect %d results got %d", method.Name(), len(method.Return), len(results))
+	}
+	var buff bytes.Buffer
+	buff.WriteString(`{"result":[`)
+	for i, param := range method.Return {
+		if i != 0 {
+			buff.WriteByte(',')
+		}
+		data, err := codec.MarshalJSON(param.Type, results[i])
+		if err != nil {
+			return nil, gserrors.Newf(ErrGateway, "result %d: %s", i, err)
+		}
+		buff.Write(data)
+	}
+	buff.WriteString("]}\n")
+	return buff.Bytes(), nil
+}
+
+// fail 写入错误响应
+func (gateway *Gateway) fail(w http.ResponseWriter, status int, target *rpc.Error, format string, args ...interface{}) {
+	body := errorBody{Message: fmt.Sprintf(format, args...)}
+	if target != nil {
+		code := target.Code
+		body.Enum, body.Name, body.Code = target.Enum, target.Name, &code
+	}
+	data, _ := json.Marshal(map[string]interface{}{"error": body})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
@@ -0,0 +1,217 @@
+// @file 	gateway_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	gateway_test
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+	"github.com/skea3344/gslang/rpc"
+)
+
+// testFiles 测试使用的代码
+var testFiles = map[string]string{
+	"demo/shop/shop.gs": `
+table Item {
+    ID uint64;
+    Name string;
+}
+
+@gslang.Error
+enum ShopError(int32) {
+    NotFound(1),
+    Denied(2)
+}
+
+contract Shop {
+    Get(id uint64) -> (Item);
+    Count() -> (uint32, bool);
+    Fail();
+    Slow();
+}
+
+contract AdminShop(Shop) {
+    Clear();
+}
+`,
+}
+
+// invokerFunc 函数形式的rpc.Invoker
+type invokerFunc func(ctx context.Context, contract *ast.Contract, method *ast.Method, params []interface{}) ([]interface{}, error)
+
+// Invoke 实现rpc.Invoker接口
+func (f invokerFunc) Invoke(ctx context.Context, contract *ast.Contract, method *ast.Method, params []interface{}) ([]interface{}, error) {
+	return f(ctx, contract, method, params)
+}
+
+// newTestGateway 编译测试代码 通过进程内连接把AdminShop的实现暴露为HTTP服务
+func newTestGateway(t *testing.T) (*Gateway, *httptest.Server) {
+	cs := gstest.Compile(t, testFiles, "demo/shop")
+	contract := gstest.Type(t, cs, "demo/shop", "AdminShop").(*ast.Contract)
+	shopErrors := gstest.Type(t, cs, "demo/shop", "ShopError").(*ast.Enum)
+	server := rpc.NewServer()
+	err := server.Register(contract, map[string]rpc.Handler{
+		"Get": func(ctx context.Context, params []interface{}) ([]interface{}, error) {
+			id := params[0].(uint64)
+			if id != 1 {
+				return nil, rpc.NewError(shopErrors, "NotFound", "item %d not found", id)
+			}
+			return []interface{}{map[string]interface{}{"ID": id, "Name": "sword"}}, nil
+		},
+		"Count": func(ctx context.Context, params []interface{}) ([]interface{}, error) {
+			return []interface{}{uint32(1), true}, nil
+		},
+		"Fail": func(ctx context.Context, params []interface{}) ([]interface{}, error) {
+			return nil, errors.New("disk on fire")
+		},
+		// 服务端自己的截止时间到期
+		"Slow": func(ctx context.Context, params []interface{}) ([]interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		"Clear": func(ctx context.Context, params []interface{}) ([]interface{}, error) {
+			return nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.Pipe(server)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	gateway := New(client, contract)
+	web := httptest.NewServer(gateway)
+	t.Cleanup(web.Close)
+	return gateway, web
+}
+
+// post 发送请求 返回状态码及响应体
+func post(t *testing.T, method, url, body string) (int, string) {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("%s %s Content-Type = %q", method, url, contentType)
+	}
+	if !json.Valid(data) {
+		t.Errorf("%s %s body is not json: %s", method, url, data)
+	}
+	return response.StatusCode, strings.TrimSpace(string(data))
+}
+
+func TestServeHTTP(t *testing.T) {
+	_, server := newTestGateway(t)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		want   string
+	}{
+		{"ok", "POST", "/demo/shop/AdminShop/Get", `[1]`, 200, `{"result":[{"ID":"1","Name":"sword"}]}`},
+		{"string uint64", "POST", "/demo/shop/AdminShop/Get", `["1"]`, 200, `{"result":[{"ID":"1","Name":"sword"}]}`},
+		{"multiple results", "POST", "/demo/shop/AdminShop/Count", ``, 200, `{"result":[1,true]}`},
+		{"no results", "POST", "/demo/shop/AdminShop/Clear", `[]`, 200, `{"result":[]}`},
+		{"error enum", "POST", "/demo/shop/AdminShop/Get", `[2]`, 422,
+			`{"error":{"enum":"demo/shop.ShopError","name":"NotFound","code":1,"message":"item 2 not found"}}`},
+		{"remote failure", "POST", "/demo/shop/AdminShop/Fail", ``, 502, `{"error":{"message":"disk on fire"}}`},
+		{"server deadline", "POST", "/demo/shop/AdminShop/Slow", ``, 504, ``},
+		{"method not allowed", "GET", "/demo/shop/AdminShop/Get", ``, 405, `{"error":{"message":"method GET not allowed"}}`},
+		{"unknown contract", "POST", "/demo/shop/Nope/Get", `[1]`, 404, ``},
+		{"unknown method", "POST", "/demo/shop/AdminShop/Nope", `[1]`, 404, ``},
+		{"invalid path", "POST", "/Get", `[1]`, 404, ``},
+		{"bad json", "POST", "/demo/shop/AdminShop/Get", `[1`, 400, ``},
+		{"not an array", "POST", "/demo/shop/AdminShop/Get", `{"id":1}`, 400, ``},
+		{"param count", "POST", "/demo/shop/AdminShop/Get", `[1, 2]`, 400, ``},
+		{"missing params", "POST", "/demo/shop/AdminShop/Get", ``, 400, ``},
+		{"bad param", "POST", "/demo/shop/AdminShop/Get", `[true]`, 400, ``},
+		{"body at limit", "POST", "/demo/shop/AdminShop/Get", strings.Repeat(" ", MaxBodySize-3) + `[1]`, 200, ``},
+		{"body too large", "POST", "/demo/shop/AdminShop/Get", strings.Repeat(" ", MaxBodySize-2) + `[1]`, 413,
+			`{"error":{"message":"request body exceeds 4194304 bytes"}}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			status, body := post(t, tc.method, server.URL+tc.path, tc.body)
+			if status != tc.status {
+				t.Fatalf("status = %d want %d, body %s", status, tc.status, body)
+			}
+			if tc.want != "" && body != tc.want {
+				t.Fatalf("body =\n%s\nwant\n%s", body, tc.want)
+			}
+			if tc.status != 200 && !strings.HasPrefix(body, `{"error":{`) {
+				t.Fatalf("error body = %s", body)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	cs := gstest.Compile(t, testFiles, "demo/shop")
+	contract := gstest.Type(t, cs, "demo/shop", "Shop").(*ast.Contract)
+	gateway := New(invokerFunc(func(ctx context.Context, contract *ast.Contract, method *ast.Method, params []interface{}) ([]interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}), contract)
+	gateway.Timeout = 10 * time.Millisecond
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+	if status, body := post(t, "POST", server.URL+"/demo/shop/Shop/Count", ``); status != 504 {
+		t.Fatalf("status = %d want 504, body %s", status, body)
+	}
+}
+
+// TestShortResults 自定义的Invoker返回的返回值个数不足时返回错误而不是panic
+func TestShortResults(t *testing.T) {
+	cs := gstest.Compile(t, testFiles, "demo/shop")
+	contract := gstest.Type(t, cs, "demo/shop", "Shop").(*ast.Contract)
+	gateway := New(invokerFunc(func(ctx context.Context, contract *ast.Contract, method *ast.Method, params []interface{}) ([]interface{}, error) {
+		return []interface{}{uint32(1)}, nil
+	}), contract)
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+	status, body := post(t, "POST", server.URL+"/demo/shop/Shop/Count", ``)
+	if status != 502 || !strings.Contains(body, "Count expect 2 results got 1") {
+		t.Fatalf("status = %d body %s", status, body)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	gateway, _ := newTestGateway(t)
+	want := []string{
+		"/demo/shop/AdminShop/Get",
+		"/demo/shop/AdminShop/Count",
+		"/demo/shop/AdminShop/Fail",
+		"/demo/shop/AdminShop/Slow",
+		"/demo/shop/AdminShop/Clear",
+	}
+	if got := gateway.Routes(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("Routes = %v want %v", got, want)
+	}
+}
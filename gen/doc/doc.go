@@ -0,0 +1,240 @@
+// @file 	doc.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	doc
+
+package doc
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang/gen"
+)
+
+func init() {
+	gen.Register(New())
+}
+
+// 文档格式
+const (
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+)
+
+// Generator API文档生成器 每个包生成一个页面 另外生成一个带搜索的索引页面
+type Generator struct{}
+
+// New 新建API文档生成器
+func New() *Generator {
+	return &Generator{}
+}
+
+// Name 实现gen.Generator接口
+func (generator *Generator) Name() string {
+	return "doc"
+}
+
+// Options 实现gen.Generator接口
+func (generator *Generator) Options() []*gen.Option {
+	return []*gen.Option{
+		{Name: "format", Default: FormatHTML, Usage: "output format: html or markdown"},
+		{Name: "title", Default: "API Reference", Usage: "title of the index page"},
+	}
+}
+
+// Generate 实现gen.Generator接口
+func (generator *Generator) Generate(ctx context.Context, req *gen.Request) ([]*gen.File, error) {
+	var r renderer
+	switch format := req.Option("format", FormatHTML); format {
+	case FormatHTML:
+		r = &htmlRenderer{}
+	case FormatMarkdown:
+		r = &markdownRenderer{}
+	default:
+		return nil, gserrors.Newf(gen.ErrGen, "unknown doc format(%s), expect html or markdown", format)
+	}
+	s := newSite(gen.NewSchema(req.Packages), req.Option("title", "API Reference"), r.ext())
+	var files []*gen.File
+	for _, pkg := range s.schema.Packages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		files = append(files, gen.NewFile(s.page(pkg.Name), r.pkg(s, pkg)))
+	}
+	files = append(files, gen.NewFile(s.index(), r.index(s)))
+	return files, nil
+}
+
+// renderer 文档格式
+type renderer interface {
+	ext() string                          // 页面文件扩展名
+	pkg(s *site, pkg *gen.Package) []byte // 生成包页面
+	index(s *site) []byte                 // 生成索引页面
+}
+
+// entry 索引条目
+type entry struct {
+	Name    string `json:"name"`    // 类型名 或者 类型名.成员名
+	Kind    string `json:"kind"`    // 类型种类 field value method
+	Package string `json:"package"` // 所属包
+	URL     string `json:"url"`     // 相对索引页面的链接
+	Summary string `json:"summary"` // 注释的第一行
+}
+
+// site 文档站点 记录所有页面及索引条目
+type site struct {
+	title   string
+	ext     string
+	schema  *gen.Schema
+	entries []*entry
+}
+
+// newSite 新建文档站点并生成索引条目
+func newSite(schema *gen.Schema, title string, ext string) *site {
+	s := &site{
+		title:  title,
+		ext:    ext,
+		schema: schema,
+	}
+	for _, pkg := range schema.Packages {
+		for _, typ := range pkg.Types {
+			s.add(pkg.Name, typ.Name, typ.Kind, typ.Comments)
+			for _, field := range typ.Fields {
+				s.add(pkg.Name, typ.Name+"."+field.Name, "field", field.Comments)
+			}
+			for _, val := range typ.Values {
+				s.add(pkg.Name, typ.Name+"."+val.Name, "value", val.Comments)
+			}
+			for _, method := range typ.Methods {
+				s.add(pkg.Name, typ.Name+"."+method.Name, "method", method.Comments)
+			}
+		}
+	}
+	sort.SliceStable(s.entries, func(i, j int) bool {
+		return strings.ToLower(s.entries[i].Name) < strings.ToLower(s.entries[j].Name)
+	})
+	return s
+}
+
+// add 添加索引条目 锚点名字即条目名字
+func (s *site) add(pkg string, name string, kind string, comments []string) {
+	summary := ""
+	if len(comments) > 0 {
+		summary = comments[0]
+	}
+	s.entries = append(s.entries, &entry{
+		Name:    name,
+		Kind:    kind,
+		Package: pkg,
+		URL:     s.page(pkg) + "#" + name,
+		Summary: summary,
+	})
+}
+
+// index 索引页面的文件名
+func (s *site) index() string {
+	return "index" + s.ext
+}
+
+// page 包页面的文件名 如 skea3344/foo -> skea3344/foo/index.html
+func (s *site) page(pkg string) string {
+	return pkg + "/index" + s.ext
+}
+
+// documented 检查包是否生成了页面
+func (s *site) documented(pkg string) bool {
+	_, ok := s.schema.Package(pkg)
+	return ok
+}
+
+// link 返回从from页面到指定包页面锚点的相对链接 包没有生成页面时返回false
+func (s *site) link(from string, pkg string, anchor string) (string, bool) {
+	if !s.documented(pkg) {
+		return "", false
+	}
+	target := s.page(pkg)
+	url := ""
+	if target != from {
+		rel, err := filepath.Rel(filepath.FromSlash(path.Dir(from)), filepath.FromSlash(target))
+		if err != nil {
+			return "", false
+		}
+		url = filepath.ToSlash(rel)
+	}
+	if anchor != "" {
+		url += "#" + anchor
+	}
+	return url, true
+}
+
+// attrLink 返回属性类型的链接 属性类型的规范名字为 包名.类型名
+func (s *site) attrLink(from string, attr *gen.Attr) (string, bool) {
+	i := strings.LastIndex(attr.Type, ".")
+	if i < 0 {
+		return "", false
+	}
+	return s.link(from, attr.Type[:i], attr.Type[i+1:])
+}
+
+// typeLabel 返回被引用类型在当前包中显示的名字 其他包的类型加上包名最后一段
+func typeLabel(current string, expr *gen.TypeExpr) string {
+	if expr.Package == "" || expr.Package == current {
+		return expr.Name
+	}
+	return path.Base(expr.Package) + "." + expr.Name
+}
+
+// baseExpr 将父协议的规范名字转换为类型表达式描述
+func baseExpr(base string) *gen.TypeExpr {
+	i := strings.LastIndex(base, ".")
+	if i < 0 {
+		return &gen.TypeExpr{Name: base}
+	}
+	return &gen.TypeExpr{Kind: gen.KindContract, Name: base[i+1:], Package: base[:i]}
+}
+
+// isRef 检查类型表达式是否为类型引用
+func isRef(expr *gen.TypeExpr) bool {
+	switch expr.Kind {
+	case gen.KindTable, gen.KindStruct, gen.KindEnum, gen.KindContract:
+		return true
+	}
+	return false
+}
+
+// kindTitle 类型种类的标题
+var kindTitle = map[string]string{
+	gen.KindTable:    "Tables",
+	gen.KindStruct:   "Structs",
+	gen.KindEnum:     "Enums",
+	gen.KindContract: "Contracts",
+}
+
+// kinds 包页面中类型种类的排列顺序
+var kinds = []string{gen.KindTable, gen.KindStruct, gen.KindEnum, gen.KindContract}
+
+// byKind 按种类返回包内的类型
+func byKind(pkg *gen.Package, kind string) []*gen.Type {
+	var types []*gen.Type
+	for _, typ := range pkg.Types {
+		if typ.Kind == kind {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
+
+// argNames 返回排序后的属性参数名字
+func argNames(attr *gen.Attr) []string {
+	var names []string
+	for name := range attr.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,370 @@
+// @file 	doc_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	doc_test
+
+package doc
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/gen"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// testFiles 测试使用的代码 demo/shop引用demo/base
+var testFiles = map[string]string{
+	"demo/base/base.gs": `
+// 坐标
+struct Point {
+    X int32;
+    Y int32;
+}
+
+@gslang.AttrUsage(gslang.AttrTarget.Table|gslang.AttrTarget.Method)
+table Meta {
+    Name string;
+}
+`,
+	"demo/shop/shop.gs": `
+import "demo/base"
+
+// 物品
+// 第二行
+table Item {
+    // 编号|唯一
+    ID uint64;
+    Pos [2]base.Point;
+    Counts map[Color]string;
+    Tags []base.Point;
+}
+
+// 颜色
+enum Color(byte) {
+    Red(1),
+    // 绿
+    Green(2)
+}
+
+@gslang.Error
+enum ShopError(int32) {
+    NotFound(-1)
+}
+
+contract Base {
+    Ping();
+}
+
+// 商店
+contract Shop(Base) {
+    @base.Meta(Name: "get")
+    // 查询
+    Get(id uint64) -> (Item, ShopError);
+}
+`,
+}
+
+// generate 编译测试代码并生成指定包的文档 返回文件名到内容的映射
+func generate(t *testing.T, options map[string]string, pkgs ...string) map[string]string {
+	cs := gstest.Compile(t, testFiles, "demo/shop")
+	var loaded []*ast.Package
+	for _, name := range pkgs {
+		loaded = append(loaded, cs.Loaded[name])
+	}
+	files, err := New().Generate(context.Background(), &gen.Request{Packages: loaded, Options: options})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := make(map[string]string)
+	for _, file := range files {
+		pages[file.Name] = string(file.Content)
+	}
+	return pages
+}
+
+// wantShopMarkdown demo/shop包的Markdown页面 其中的'代替Markdown的反引号
+const wantShopMarkdown = `[API Reference](../../index.md)
+
+# package demo/shop
+
+## Imports
+
+- [demo/base](../base/index.md)
+
+## Tables
+
+- [Item](#Item)
+
+## Enums
+
+- [Color](#Color)
+- [ShopError](#ShopError)
+
+## Contracts
+
+- [Base](#Base)
+- [Shop](#Shop)
+
+<a id="Item"></a>
+
+### table Item
+
+*shop.gs:6*
+
+物品
+第二行
+
+| ID | Field | Type | Description |
+| --- | --- | --- | --- |
+| 0 | <a id="Item.ID"></a>'ID' | 'uint64' | 编号\|唯一 |
+| 1 | <a id="Item.Pos"></a>'Pos' | \[2\][base.Point](../base/index.md#Point) |  |
+| 2 | <a id="Item.Counts"></a>'Counts' | map\[[Color](#Color)\]string |  |
+| 3 | <a id="Item.Tags"></a>'Tags' | \[\][base.Point](../base/index.md#Point) |  |
+
+<a id="Color"></a>
+
+### enum Color
+
+*shop.gs:15*
+
+颜色
+
+underlying length 1 bytes
+
+| Value | Name | Description |
+| --- | --- | --- |
+| 1 | <a id="Color.Red"></a>'Red' |  |
+| 2 | <a id="Color.Green"></a>'Green' | 绿 |
+
+<a id="ShopError"></a>
+
+### enum ShopError
+
+*shop.gs:22*
+
+'@Error'
+
+underlying length 4 bytes, signed, error codes
+
+| Value | Name | Description |
+| --- | --- | --- |
+| -1 | <a id="ShopError.NotFound"></a>'NotFound' |  |
+
+<a id="Base"></a>
+
+### contract Base
+
+*shop.gs:26*
+
+| ID | Method | Description |
+| --- | --- | --- |
+| 0 | <a id="Base.Ping"></a>'Ping()' |  |
+
+<a id="Shop"></a>
+
+### contract Shop
+
+*shop.gs:31*
+
+商店
+
+extends [Base](#Base)
+
+| ID | Method | Description |
+| --- | --- | --- |
+| 0 | <a id="Shop.Ping"></a>'Ping()' |  |
+| 1 | <a id="Shop.Get"></a>Get(uint64) -> ([Item](#Item), [ShopError](#ShopError)) | [@Meta(Name: "get")](../base/index.md#Meta)<br>查询 |
+`
+
+// wantIndexMarkdown Markdown索引页面
+const wantIndexMarkdown = `# API Reference
+
+## Packages
+
+- [demo/shop](demo/shop/index.md) (5 types)
+- [demo/base](demo/base/index.md) (2 types)
+
+## Index
+
+| Name | Kind | Package | Summary |
+| --- | --- | --- | --- |
+| [Base](demo/shop/index.md#Base) | contract | demo/shop |  |
+| [Base.Ping](demo/shop/index.md#Base.Ping) | method | demo/shop |  |
+| [Color](demo/shop/index.md#Color) | enum | demo/shop | 颜色 |
+| [Color.Green](demo/shop/index.md#Color.Green) | value | demo/shop | 绿 |
+| [Color.Red](demo/shop/index.md#Color.Red) | value | demo/shop |  |
+| [Item](demo/shop/index.md#Item) | table | demo/shop | 物品 |
+| [Item.Counts](demo/shop/index.md#Item.Counts) | field | demo/shop |  |
+| [Item.ID](demo/shop/index.md#Item.ID) | field | demo/shop | 编号\|唯一 |
+| [Item.Pos](demo/shop/index.md#Item.Pos) | field | demo/shop |  |
+| [Item.Tags](demo/shop/index.md#Item.Tags) | field | demo/shop |  |
+| [Meta](demo/base/index.md#Meta) | table | demo/base |  |
+| [Meta.Name](demo/base/index.md#Meta.Name) | field | demo/base |  |
+| [Point](demo/base/index.md#Point) | struct | demo/base | 坐标 |
+| [Point.X](demo/base/index.md#Point.X) | field | demo/base |  |
+| [Point.Y](demo/base/index.md#Point.Y) | field | demo/base |  |
+| [Shop](demo/shop/index.md#Shop) | contract | demo/shop | 商店 |
+| [Shop.Get](demo/shop/index.md#Shop.Get) | method | demo/shop | 查询 |
+| [Shop.Ping](demo/shop/index.md#Shop.Ping) | method | demo/shop |  |
+| [ShopError](demo/shop/index.md#ShopError) | enum | demo/shop |  |
+| [ShopError.NotFound](demo/shop/index.md#ShopError.NotFound) | value | demo/shop |  |
+`
+
+func TestMarkdown(t *testing.T) {
+	pages := generate(t, map[string]string{"format": FormatMarkdown}, "demo/shop", "demo/base")
+	if len(pages) != 3 || pages["demo/base/index.md"] == "" {
+		t.Fatalf("pages = %d", len(pages))
+	}
+	if got, want := pages["demo/shop/index.md"], strings.Replace(wantShopMarkdown, "'", "`", -1); got != want {
+		t.Errorf("demo/shop/index.md:\n%s\nwant\n%s", got, want)
+	}
+	if got := pages["index.md"]; got != wantIndexMarkdown {
+		t.Errorf("index.md:\n%s\nwant\n%s", got, wantIndexMarkdown)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	pages := generate(t, map[string]string{"title": "Shop <API>"}, "demo/shop", "demo/base")
+	shop := pages["demo/shop/index.html"]
+	for _, want := range []string{
+		"<title>Shop &lt;API&gt;</title>",
+		`<header><a href="../../index.html">Shop &lt;API&gt;</a></header>`,
+		`<li><a href="../base/index.html">demo/base</a></li>`,
+		`<tr id="Item.Pos"><td>1</td><td><code>Pos</code></td><td class="sig">[2]<a href="../base/index.html#Point">base.Point</a></td>`,
+		`<td class="sig">map[<a href="#Color">Color</a>]string</td>`,
+		`<p class="comment">编号|唯一</p>`,
+		`<p>underlying length 4 bytes, signed, error codes</p>`,
+		`<p>extends <a href="#Base">Base</a></p>`,
+		`<td class="sig">Get(uint64) -&gt; (<a href="#Item">Item</a>, <a href="#ShopError">ShopError</a>)</td>`,
+		`<a href="../base/index.html#Meta">@Meta</a>(Name: &#34;get&#34;)`,
+	} {
+		if !strings.Contains(shop, want) {
+			t.Errorf("demo/shop/index.html missing %s", want)
+		}
+	}
+	// 页面不引用外部资源
+	for name, page := range pages {
+		if strings.Contains(page, "http://") || strings.Contains(page, "https://") || strings.Contains(page, " src=") {
+			t.Errorf("%s references external resources", name)
+		}
+	}
+}
+
+// TestSearchIndex 索引页面内嵌的条目按名字排序 链接指向包页面中的锚点
+func TestSearchIndex(t *testing.T) {
+	pages := generate(t, nil, "demo/shop", "demo/base")
+	index := pages["index.html"]
+	begin := strings.Index(index, `<script id="entries" type="application/json">`)
+	if begin < 0 {
+		t.Fatalf("index.html without entries:\n%s", index)
+	}
+	data := index[begin+len(`<script id="entries" type="application/json">`):]
+	data = data[:strings.Index(data, "</script>")]
+	var entries []*entry
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 20 {
+		t.Fatalf("entries = %d", len(entries))
+	}
+	for i := 1; i < len(entries); i++ {
+		if strings.ToLower(entries[i-1].Name) > strings.ToLower(entries[i].Name) {
+			t.Errorf("entries not sorted: %s before %s", entries[i-1].Name, entries[i].Name)
+		}
+	}
+	want := map[string]entry{
+		"Item":        {Name: "Item", Kind: "table", Package: "demo/shop", URL: "demo/shop/index.html#Item", Summary: "物品"},
+		"Color.Green": {Name: "Color.Green", Kind: "value", Package: "demo/shop", URL: "demo/shop/index.html#Color.Green", Summary: "绿"},
+		"Shop.Get":    {Name: "Shop.Get", Kind: "method", Package: "demo/shop", URL: "demo/shop/index.html#Shop.Get", Summary: "查询"},
+		"Point.X":     {Name: "Point.X", Kind: "field", Package: "demo/base", URL: "demo/base/index.html#Point.X"},
+		"ShopError":   {Name: "ShopError", Kind: "enum", Package: "demo/shop", URL: "demo/shop/index.html#ShopError"},
+	}
+	for _, e := range entries {
+		if w, ok := want[e.Name]; ok {
+			if *e != w {
+				t.Errorf("entry %s = %+v want %+v", e.Name, *e, w)
+			}
+			delete(want, e.Name)
+		}
+		// 每个条目的锚点都存在于包页面中
+		page := pages[e.URL[:strings.Index(e.URL, "#")]]
+		if anchor := e.URL[strings.Index(e.URL, "#")+1:]; !strings.Contains(page, `id="`+html.EscapeString(anchor)+`"`) {
+			t.Errorf("anchor of %s not found", e.URL)
+		}
+	}
+	for name := range want {
+		t.Errorf("entry %s not found", name)
+	}
+	if !strings.Contains(index, `<tr><td><a href="demo/shop/index.html">demo/shop</a></td><td>5</td></tr>`) {
+		t.Errorf("index.html packages:\n%s", index)
+	}
+}
+
+// TestUndocumented 没有生成页面的包不输出链接
+func TestUndocumented(t *testing.T) {
+	pages := generate(t, map[string]string{"format": FormatMarkdown}, "demo/shop")
+	if len(pages) != 2 {
+		t.Fatalf("pages = %d", len(pages))
+	}
+	shop := pages["demo/shop/index.md"]
+	for _, want := range []string{
+		"- `demo/base`\n",
+		"| 1 | <a id=\"Item.Pos\"></a>`Pos` | `[2]base.Point` |  |",
+		"`@Meta(Name: \"get\")`<br>查询",
+		"map\\[[Color](#Color)\\]string",
+	} {
+		if !strings.Contains(shop, want) {
+			t.Errorf("demo/shop/index.md missing %s:\n%s", want, shop)
+		}
+	}
+	if strings.Contains(shop, "../base/") {
+		t.Errorf("demo/shop/index.md links to demo/base:\n%s", shop)
+	}
+}
+
+func TestLink(t *testing.T) {
+	s := newSite(&gen.Schema{Packages: []*gen.Package{{Name: "a/b"}, {Name: "a/c/d"}, {Name: "e"}}}, "API", ".html")
+	tests := []struct {
+		from   string
+		pkg    string
+		anchor string
+		want   string
+	}{
+		{"a/b/index.html", "a/b", "T", "#T"},
+		{"a/b/index.html", "a/b", "", ""},
+		{"a/b/index.html", "a/c/d", "T.F", "../c/d/index.html#T.F"},
+		{"a/c/d/index.html", "e", "", "../../../e/index.html"},
+		{"e/index.html", "a/b", "T", "../a/b/index.html#T"},
+		{"index.html", "a/c/d", "T", "a/c/d/index.html#T"},
+	}
+	for _, tc := range tests {
+		if got, ok := s.link(tc.from, tc.pkg, tc.anchor); !ok || got != tc.want {
+			t.Errorf("link(%s, %s, %s) = %s, %v want %s", tc.from, tc.pkg, tc.anchor, got, ok, tc.want)
+		}
+	}
+	if _, ok := s.link("a/b/index.html", "x", "T"); ok {
+		t.Error("link to undocumented package")
+	}
+	if got := relIndex("a/c/d/index.html", s); got != "../../../index.html" {
+		t.Errorf("relIndex = %s", got)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	cs := gstest.Compile(t, testFiles, "demo/shop")
+	req := &gen.Request{Packages: []*ast.Package{cs.Loaded["demo/shop"]}, Options: map[string]string{"format": "pdf"}}
+	if _, err := New().Generate(context.Background(), req); err == nil || !strings.Contains(err.Error(), "unknown doc format(pdf)") {
+		t.Errorf("error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req.Options = nil
+	if _, err := New().Generate(ctx, req); err != context.Canceled {
+		t.Errorf("canceled error = %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+// @file 	html.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	html
+
+package doc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/skea3344/gslang/gen"
+)
+
+// style 页面内联样式 页面不引用任何外部资源
+const style = `
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0; color: #24292e; }
+header { background: #24292e; padding: 12px 24px; }
+header a { color: #fff; text-decoration: none; font-weight: bold; }
+main { max-width: 960px; margin: 0 auto; padding: 8px 24px 48px; }
+a { color: #0366d6; }
+code, .sig { font-family: SFMono-Regular, Consolas, Menlo, monospace; font-size: 90%; }
+table { border-collapse: collapse; width: 100%; margin: 8px 0 16px; }
+th, td { border: 1px solid #dfe2e5; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+.type { border-top: 1px solid #eaecef; margin-top: 24px; }
+.kind { color: #6a737d; font-weight: normal; font-size: 80%; }
+.attr { color: #6f42c1; }
+.pos { color: #6a737d; font-size: 80%; }
+.comment { white-space: pre-wrap; }
+#search { width: 100%; padding: 8px; font-size: 16px; box-sizing: border-box; }
+#results li span { color: #6a737d; margin-left: 8px; }
+`
+
+// script 索引页面的搜索脚本 条目数据由页面内嵌的JSON提供
+const script = `
+(function () {
+  var entries = JSON.parse(document.getElementById("entries").textContent);
+  var input = document.getElementById("search");
+  var results = document.getElementById("results");
+  input.addEventListener("input", function () {
+    var q = input.value.trim().toLowerCase();
+    results.innerHTML = "";
+    if (!q) { return; }
+    var count = 0;
+    for (var i = 0; i < entries.length && count < 100; i++) {
+      var e = entries[i];
+      if (e.name.toLowerCase().indexOf(q) < 0 && e.package.toLowerCase().indexOf(q) < 0) { continue; }
+      count++;
+      var li = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = e.url;
+      a.textContent = e.name;
+      li.appendChild(a);
+      var info = document.createElement("span");
+      info.textContent = e.kind + " in " + e.package + (e.summary ? " - " + e.summary : "");
+      li.appendChild(info);
+      results.appendChild(li);
+    }
+  });
+})();
+`
+
+// htmlRenderer 生成不依赖外部资源的静态HTML页面
+type htmlRenderer struct {
+	buff bytes.Buffer
+}
+
+// ext 实现renderer接口
+func (r *htmlRenderer) ext() string {
+	return ".html"
+}
+
+// printf 写入格式化文本
+func (r *htmlRenderer) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&r.buff, format, args...)
+}
+
+// begin 输出页面头部 home为到索引页面的相对链接
+func (r *htmlRenderer) begin(title string, home string) {
+	r.buff.Reset()
+	r.printf("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	r.printf("<title>%s</title>\n<style>%s</style>\n</head>\n<body>\n", html.EscapeString(title), style)
+	r.printf("<header><a href=\"%s\">%s</a></header>\n<main>\n", html.EscapeString(home), html.EscapeString(title))
+}
+
+// end 输出页面尾部并返回页面内容
+func (r *htmlRenderer) end() []byte {
+	r.printf("</main>\n</body>\n</html>\n")
+	return append([]byte(nil), r.buff.Bytes()...)
+}
+
+// comments 输出注释段落
+func (r *htmlRenderer) comments(comments []string) {
+	if len(comments) > 0 {
+		r.printf("<p class=\"comment\">%s</p>\n", html.EscapeString(gen.CommentText(comments)))
+	}
+}
+
+// index 实现renderer接口
+func (r *htmlRenderer) index(s *site) []byte {
+	r.begin(s.title, s.index())
+	r.printf("<h1>%s</h1>\n", html.EscapeString(s.title))
+	r.printf("<input id=\"search\" type=\"search\" placeholder=\"Search types, fields, values and methods\" autofocus>\n")
+	r.printf("<ul id=\"results\"></ul>\n")
+	r.printf("<h2>Packages</h2>\n<table>\n<tr><th>Package</th><th>Types</th></tr>\n")
+	for _, pkg := range s.schema.Packages {
+		r.printf("<tr><td><a href=\"%s\">%s</a></td><td>%d</td></tr>\n",
+			html.EscapeString(s.page(pkg.Name)), html.EscapeString(pkg.Name), len(pkg.Types))
+	}
+	r.printf("</table>\n")
+	entries, _ := json.Marshal(s.entries)
+	r.printf("<script id=\"entries\" type=\"application/json\">%s</script>\n", entries)
+	r.printf("<script>%s</script>\n", script)
+	return r.end()
+}
+
+// pkg 实现renderer接口
+func (r *htmlRenderer) pkg(s *site, pkg *gen.Package) []byte {
+	from := s.page(pkg.Name)
+	r.begin(s.title, relIndex(from, s))
+	r.printf("<h1>package %s</h1>\n", html.EscapeString(pkg.Name))
+	r.attrs(s, from, pkg.Attrs)
+	if len(pkg.Imports) > 0 {
+		r.printf("<h2>Imports</h2>\n<ul>\n")
+		for _, name := range pkg.Imports {
+			if url, ok := s.link(from, name, ""); ok {
+				r.printf("<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(url), html.EscapeString(name))
+			} else {
+				r.printf("<li><code>%s</code></li>\n", html.EscapeString(name))
+			}
+		}
+		r.printf("</ul>\n")
+	}
+	// 目录
+	for _, kind := range kinds {
+		types := byKind(pkg, kind)
+		if len(types) == 0 {
+			continue
+		}
+		r.printf("<h2>%s</h2>\n<ul>\n", kindTitle[kind])
+		for _, typ := range types {
+			r.printf("<li><a href=\"#%s\">%s</a></li>\n", html.EscapeString(typ.Name), html.EscapeString(typ.Name))
+		}
+		r.printf("</ul>\n")
+	}
+	for _, typ := range pkg.Types {
+		r.typ(s, from, pkg, typ)
+	}
+	return r.end()
+}
+
+// relIndex 返回从包页面到索引页面的相对链接
+func relIndex(from string, s *site) string {
+	return strings.Repeat("../", strings.Count(from, "/")) + s.index()
+}
+
+// typ 输出类型
+func (r *htmlRenderer) typ(s *site, from string, pkg *gen.Package, typ *gen.Type) {
+	name := html.EscapeString(typ.Name)
+	r.printf("<section class=\"type\">\n<h3 id=\"%s\"><span class=\"kind\">%s</span> %s</h3>\n", name, typ.Kind, name)
+	r.printf("<div class=\"pos\">%s:%d</div>\n", html.EscapeString(typ.Script), typ.Pos.Line)
+	r.attrs(s, from, typ.Attrs)
+	r.comments(typ.Comments)
+	switch typ.Kind {
+	case gen.KindTable, gen.KindStruct:
+		if len(typ.Fields) == 0 {
+			break
+		}
+		r.printf("<table>\n<tr><th>ID</th><th>Field</th><th>Type</th><th>Description</th></tr>\n")
+		for _, field := range typ.Fields {
+			anchor := html.EscapeString(typ.Name + "." + field.Name)
+			r.printf("<tr id=\"%s\"><td>%d</td><td><code>%s</code></td><td class=\"sig\">%s</td><td>",
+				anchor, field.ID, html.EscapeString(field.Name), r.typeExpr(s, from, pkg.Name, field.Type))
+			r.attrs(s, from, field.Attrs)
+			r.comments(field.Comments)
+			r.printf("</td></tr>\n")
+		}
+		r.printf("</table>\n")
+	case gen.KindEnum:
+		r.printf("<p>underlying length %d bytes", typ.Length)
+		if typ.Signed {
+			r.printf(", signed")
+		}
+		if typ.Error {
+			r.printf(", error codes")
+		}
+		r.printf("</p>\n<table>\n<tr><th>Value</th><th>Name</th><th>Description</th></tr>\n")
+		for _, val := range typ.Values {
+			anchor := html.EscapeString(typ.Name + "." + val.Name)
+			r.printf("<tr id=\"%s\"><td>%d</td><td><code>%s</code></td><td>", anchor, val.Value, html.EscapeString(val.Name))
+			r.attrs(s, from, val.Attrs)
+			r.comments(val.Comments)
+			r.printf("</td></tr>\n")
+		}
+		r.printf("</table>\n")
+	case gen.KindContract:
+		if len(typ.Bases) > 0 {
+			r.printf("<p>extends")
+			for _, base := range typ.Bases {
+				r.printf(" %s", r.base(s, from, pkg.Name, base))
+			}
+			r.printf("</p>\n")
+		}
+		if len(typ.Methods) == 0 {
+			break
+		}
+		r.printf("<table>\n<tr><th>ID</th><th>Method</th><th>Description</th></tr>\n")
+		for _, method := range typ.Methods {
+			anchor := html.EscapeString(typ.Name + "." + method.Name)
+			r.printf("<tr id=\"%s\"><td>%d</td><td class=\"sig\">%s(%s)", anchor, method.ID,
+				html.EscapeString(method.Name), r.params(s, from, pkg.Name, method.Params))
+			if len(method.Return) > 0 {
+				r.printf(" -&gt; (%s)", r.params(s, from, pkg.Name, method.Return))
+			}
+			r.printf("</td><td>")
+			r.attrs(s, from, method.Attrs)
+			r.comments(method.Comments)
+			r.printf("</td></tr>\n")
+		}
+		r.printf("</table>\n")
+	}
+	r.printf("</section>\n")
+}
+
+// params 格式化参数列表
+func (r *htmlRenderer) params(s *site, from string, current string, params []*gen.Param) string {
+	var items []string
+	for _, param := range params {
+		var buff strings.Builder
+		for _, attr := range param.Attrs {
+			buff.WriteString(r.attr(s, from, attr))
+			buff.WriteString(" ")
+		}
+		buff.WriteString(r.typeExpr(s, from, current, param.Type))
+		items = append(items, buff.String())
+	}
+	return strings.Join(items, ", ")
+}
+
+// base 格式化父协议 父协议为 包名.类型名 形式的规范名字
+func (r *htmlRenderer) base(s *site, from string, current string, base string) string {
+	return r.typeExpr(s, from, current, baseExpr(base))
+}
+
+// typeExpr 格式化类型表达式 被引用类型链接到其声明位置
+func (r *htmlRenderer) typeExpr(s *site, from string, current string, expr *gen.TypeExpr) string {
+	if expr == nil {
+		return ""
+	}
+	switch expr.Kind {
+	case gen.KindList:
+		return "[]" + r.typeExpr(s, from, current, expr.Element)
+	case gen.KindArray:
+		return fmt.Sprintf("[%d]", expr.Length) + r.typeExpr(s, from, current, expr.Element)
+	case gen.KindMap:
+		return "map[" + r.typeExpr(s, from, current, expr.Key) + "]" + r.typeExpr(s, from, current, expr.Value)
+	}
+	if !isRef(expr) {
+		return html.EscapeString(expr.Name)
+	}
+	label := html.EscapeString(typeLabel(current, expr))
+	if url, ok := s.link(from, expr.Package, expr.Name); ok {
+		return fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(url), label)
+	}
+	return label
+}
+
+// attrs 输出属性列表
+func (r *htmlRenderer) attrs(s *site, from string, attrs []*gen.Attr) {
+	for _, attr := range attrs {
+		r.printf("<div class=\"attr sig\">%s</div>\n", r.attr(s, from, attr))
+	}
+}
+
+// attr 格式化属性 参数按名字排序
+func (r *htmlRenderer) attr(s *site, from string, attr *gen.Attr) string {
+	name := "@" + html.EscapeString(attr.Name)
+	if url, ok := s.attrLink(from, attr); ok {
+		name = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(url), name)
+	}
+	if len(attr.Args) == 0 {
+		return name
+	}
+	var items []string
+	for _, arg := range argNames(attr) {
+		items = append(items, html.EscapeString(arg+": "+formatValue(attr.Args[arg])))
+	}
+	return name + "(" + strings.Join(items, ", ") + ")"
+}
+
+// formatValue 格式化属性参数值
+func formatValue(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return strconv.Quote(text)
+	}
+	return fmt.Sprint(value)
+}
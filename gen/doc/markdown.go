@@ -0,0 +1,268 @@
+// @file 	markdown.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	markdown
+
+package doc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/skea3344/gslang/gen"
+)
+
+// markdownRenderer 生成Markdown页面 锚点使用内嵌的<a id>标签 以兼容各种wiki
+type markdownRenderer struct {
+	buff bytes.Buffer
+}
+
+// ext 实现renderer接口
+func (r *markdownRenderer) ext() string {
+	return ".md"
+}
+
+// printf 写入格式化文本
+func (r *markdownRenderer) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&r.buff, format, args...)
+}
+
+// bytes 返回页面内容
+func (r *markdownRenderer) bytes() []byte {
+	content := append([]byte(nil), bytes.TrimRight(r.buff.Bytes(), "\n")...)
+	return append(content, '\n')
+}
+
+// cell 转义表格单元格内容
+func cell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", "<br>").Replace(text)
+}
+
+// code 生成行内代码
+func code(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "`" + text + "`"
+}
+
+// index 实现renderer接口 Markdown没有脚本 索引为按名字排序的条目表格
+func (r *markdownRenderer) index(s *site) []byte {
+	r.buff.Reset()
+	r.printf("# %s\n\n## Packages\n\n", s.title)
+	for _, pkg := range s.schema.Packages {
+		r.printf("- [%s](%s) (%d types)\n", pkg.Name, s.page(pkg.Name), len(pkg.Types))
+	}
+	r.printf("\n## Index\n\n| Name | Kind | Package | Summary |\n| --- | --- | --- | --- |\n")
+	for _, e := range s.entries {
+		r.printf("| [%s](%s) | %s | %s | %s |\n", e.Name, e.URL, e.Kind, e.Package, cell(e.Summary))
+	}
+	return r.bytes()
+}
+
+// pkg 实现renderer接口
+func (r *markdownRenderer) pkg(s *site, pkg *gen.Package) []byte {
+	r.buff.Reset()
+	from := s.page(pkg.Name)
+	r.printf("[%s](%s)\n\n# package %s\n\n", s.title, relIndex(from, s), pkg.Name)
+	r.attrs(s, from, pkg.Attrs)
+	if len(pkg.Imports) > 0 {
+		r.printf("## Imports\n\n")
+		for _, name := range pkg.Imports {
+			if url, ok := s.link(from, name, ""); ok {
+				r.printf("- [%s](%s)\n", name, url)
+			} else {
+				r.printf("- %s\n", code(name))
+			}
+		}
+		r.printf("\n")
+	}
+	for _, kind := range kinds {
+		types := byKind(pkg, kind)
+		if len(types) == 0 {
+			continue
+		}
+		r.printf("## %s\n\n", kindTitle[kind])
+		for _, typ := range types {
+			r.printf("- [%s](#%s)\n", typ.Name, typ.Name)
+		}
+		r.printf("\n")
+	}
+	for _, typ := range pkg.Types {
+		r.typ(s, from, pkg, typ)
+	}
+	return r.bytes()
+}
+
+// typ 输出类型
+func (r *markdownRenderer) typ(s *site, from string, pkg *gen.Package, typ *gen.Type) {
+	r.printf("<a id=\"%s\"></a>\n\n### %s %s\n\n", typ.Name, typ.Kind, typ.Name)
+	r.printf("*%s:%d*\n\n", typ.Script, typ.Pos.Line)
+	r.attrs(s, from, typ.Attrs)
+	if len(typ.Comments) > 0 {
+		r.printf("%s\n\n", gen.CommentText(typ.Comments))
+	}
+	switch typ.Kind {
+	case gen.KindTable, gen.KindStruct:
+		if len(typ.Fields) == 0 {
+			break
+		}
+		r.printf("| ID | Field | Type | Description |\n| --- | --- | --- | --- |\n")
+		for _, field := range typ.Fields {
+			r.printf("| %d | <a id=\"%s.%s\"></a>%s | %s | %s |\n", field.ID, typ.Name, field.Name,
+				code(field.Name), cell(r.typeSpan(s, from, pkg.Name, field.Type)), r.description(s, from, field.Attrs, field.Comments))
+		}
+		r.printf("\n")
+	case gen.KindEnum:
+		r.printf("underlying length %d bytes", typ.Length)
+		if typ.Signed {
+			r.printf(", signed")
+		}
+		if typ.Error {
+			r.printf(", error codes")
+		}
+		r.printf("\n\n| Value | Name | Description |\n| --- | --- | --- |\n")
+		for _, val := range typ.Values {
+			r.printf("| %d | <a id=\"%s.%s\"></a>%s | %s |\n", val.Value, typ.Name, val.Name,
+				code(val.Name), r.description(s, from, val.Attrs, val.Comments))
+		}
+		r.printf("\n")
+	case gen.KindContract:
+		if len(typ.Bases) > 0 {
+			var bases []string
+			for _, base := range typ.Bases {
+				bases = append(bases, r.typeSpan(s, from, pkg.Name, baseExpr(base)))
+			}
+			r.printf("extends %s\n\n", strings.Join(bases, ", "))
+		}
+		if len(typ.Methods) == 0 {
+			break
+		}
+		r.printf("| ID | Method | Description |\n| --- | --- | --- |\n")
+		for _, method := range typ.Methods {
+			r.printf("| %d | <a id=\"%s.%s\"></a>%s | %s |\n", method.ID, typ.Name, method.Name,
+				cell(r.signature(s, from, pkg.Name, method)), r.description(s, from, method.Attrs, method.Comments))
+		}
+		r.printf("\n")
+	}
+}
+
+// description 生成表格中的说明单元格 包括属性及注释
+func (r *markdownRenderer) description(s *site, from string, attrs []*gen.Attr, comments []string) string {
+	var lines []string
+	for _, attr := range attrs {
+		lines = append(lines, r.attr(s, from, attr))
+	}
+	lines = append(lines, comments...)
+	return cell(strings.Join(lines, "\n"))
+}
+
+// params 格式化参数列表 link为false时输出不带链接的纯文本
+func (r *markdownRenderer) params(s *site, from string, current string, params []*gen.Param, link bool) string {
+	var items []string
+	for _, param := range params {
+		var buff strings.Builder
+		for _, attr := range param.Attrs {
+			buff.WriteString(r.attrText(attr, link))
+			buff.WriteString(" ")
+		}
+		buff.WriteString(r.typeExpr(s, from, current, param.Type, link))
+		items = append(items, buff.String())
+	}
+	return strings.Join(items, ", ")
+}
+
+// typeExpr 格式化类型表达式 link为true时被引用类型链接到其声明位置 其余文本转义
+func (r *markdownRenderer) typeExpr(s *site, from string, current string, expr *gen.TypeExpr, link bool) string {
+	if expr == nil {
+		return ""
+	}
+	text := func(plain string) string {
+		if link {
+			return escape(plain)
+		}
+		return plain
+	}
+	switch expr.Kind {
+	case gen.KindList:
+		return text("[]") + r.typeExpr(s, from, current, expr.Element, link)
+	case gen.KindArray:
+		return text(fmt.Sprintf("[%d]", expr.Length)) + r.typeExpr(s, from, current, expr.Element, link)
+	case gen.KindMap:
+		return text("map[") + r.typeExpr(s, from, current, expr.Key, link) + text("]") + r.typeExpr(s, from, current, expr.Value, link)
+	}
+	if !isRef(expr) {
+		return text(expr.Name)
+	}
+	label := typeLabel(current, expr)
+	if url, ok := s.link(from, expr.Package, expr.Name); ok && link {
+		return fmt.Sprintf("[%s](%s)", escape(label), url)
+	}
+	return text(label)
+}
+
+// escape 转义Markdown中有特殊含义的字符
+func escape(text string) string {
+	return strings.NewReplacer("[", "\\[", "]", "\\]", "*", "\\*", "_", "\\_", "`", "\\`").Replace(text)
+}
+
+// span 没有链接的文本输出为行内代码 否则输出带链接的文本
+func span(rich string, plain string) string {
+	if rich == escape(plain) {
+		return code(plain)
+	}
+	return rich
+}
+
+// typeSpan 格式化类型表达式
+func (r *markdownRenderer) typeSpan(s *site, from string, current string, expr *gen.TypeExpr) string {
+	return span(r.typeExpr(s, from, current, expr, true), r.typeExpr(s, from, current, expr, false))
+}
+
+// signature 格式化函数签名
+func (r *markdownRenderer) signature(s *site, from string, current string, method *gen.Method) string {
+	format := func(link bool) string {
+		name := method.Name
+		if link {
+			name = escape(name)
+		}
+		text := name + "(" + r.params(s, from, current, method.Params, link) + ")"
+		if len(method.Return) > 0 {
+			text += " -> (" + r.params(s, from, current, method.Return, link) + ")"
+		}
+		return text
+	}
+	return span(format(true), format(false))
+}
+
+// attrs 输出属性列表
+func (r *markdownRenderer) attrs(s *site, from string, attrs []*gen.Attr) {
+	for _, attr := range attrs {
+		r.printf("%s\n\n", r.attr(s, from, attr))
+	}
+}
+
+// attr 格式化属性 属性类型可以链接时输出链接
+func (r *markdownRenderer) attr(s *site, from string, attr *gen.Attr) string {
+	if url, ok := s.attrLink(from, attr); ok {
+		return fmt.Sprintf("[%s](%s)", escape(r.attrText(attr, false)), url)
+	}
+	return code(r.attrText(attr, false))
+}
+
+// attrText 格式化属性文本 参数按名字排序 link为true时转义特殊字符
+func (r *markdownRenderer) attrText(attr *gen.Attr, link bool) string {
+	text := "@" + attr.Name
+	if len(attr.Args) > 0 {
+		var items []string
+		for _, arg := range argNames(attr) {
+			items = append(items, arg+": "+formatValue(attr.Args[arg]))
+		}
+		text += "(" + strings.Join(items, ", ") + ")"
+	}
+	if link {
+		return escape(text)
+	}
+	return text
+}
@@ -0,0 +1,58 @@
+// @file 	file.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	file
+
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/skea3344/gserrors"
+)
+
+// File 代码生成器输出的单个文件
+type File struct {
+	Name    string `json:"name"`    // 相对输出目录的文件名 使用/分隔
+	Content []byte `json:"content"` // 文件内容
+}
+
+// NewFile 新建输出文件
+func NewFile(name string, content []byte) *File {
+	return &File{
+		Name:    name,
+		Content: content,
+	}
+}
+
+// path 检查文件名 并返回在输出目录下的完整路径 文件名不能是绝对路径 也不能跳出输出目录
+func (file *File) path(dir string) (string, error) {
+	name := filepath.FromSlash(file.Name)
+	if name == "" || filepath.IsAbs(name) {
+		return "", gserrors.Newf(ErrGen, "illegal output file name(%s)", file.Name)
+	}
+	name = filepath.Clean(name)
+	if name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", gserrors.Newf(ErrGen, "output file(%s) out of output directory", file.Name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// WriteFiles 将输出文件列表写入指定目录 自动创建所需的子目录
+func WriteFiles(dir string, files []*File) error {
+	for _, file := range files {
+		path, err := file.path(dir)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, file.Content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
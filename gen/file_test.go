@@ -0,0 +1,68 @@
+// @file 	file_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	file_test
+
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilePath(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		want string // 为空时期望报错
+	}{
+		{"a.txt", "a.txt"},
+		{"sub/dir/a.txt", "sub/dir/a.txt"},
+		{"sub/../a.txt", "a.txt"},
+		{"./a.txt", "a.txt"},
+		{"..", ""},
+		{"../a.txt", ""},
+		{"sub/../../a.txt", ""},
+		{"", ""},
+		{"/etc/passwd", ""},
+	}
+	for _, tc := range tests {
+		path, err := NewFile(tc.name, nil).path(dir)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("path(%q) = %s, want error", tc.name, path)
+			}
+			continue
+		}
+		if err != nil || path != filepath.Join(dir, filepath.FromSlash(tc.want)) {
+			t.Errorf("path(%q) = %s, %v", tc.name, path, err)
+		}
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	dir := t.TempDir()
+	err := WriteFiles(dir, []*File{
+		NewFile("a.txt", []byte("a")),
+		NewFile("sub/dir/b.txt", []byte("b")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"a.txt": "a", "sub/dir/b.txt": "b"} {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v", name, data, err)
+		}
+	}
+	// 跳出输出目录的文件不会写入
+	err = WriteFiles(dir, []*File{NewFile("../escape.txt", []byte("x"))})
+	if err == nil || !strings.Contains(err.Error(), "out of output directory") {
+		t.Fatalf("escape error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt")); err == nil {
+		t.Error("escape.txt was written")
+	}
+}
@@ -0,0 +1,114 @@
+// @file 	gen.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	gen
+
+package gen
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"sort"
+	"sync"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+var (
+	// ErrGen 代码生成器错误
+	ErrGen = errors.New("gen error")
+)
+
+// PluginPrefix 外部插件生成器可执行文件名前缀 如 gslang-gen-java
+const PluginPrefix = "gslang-gen-"
+
+// Option 代码生成器支持的选项说明
+type Option struct {
+	Name    string `json:"name"`    // 选项名
+	Default string `json:"default"` // 默认值
+	Usage   string `json:"usage"`   // 选项说明
+}
+
+// Request 一次代码生成请求
+type Request struct {
+	CompileS *gslang.CompileS  // 完成编译的编译器 外部插件生成器不可用
+	Packages []*ast.Package    // 需要生成代码的包
+	Options  map[string]string // 生成器选项
+}
+
+// Option 取指定名字的选项值 未设置则返回默认值
+func (req *Request) Option(name string, defaultVal string) string {
+	if val, ok := req.Options[name]; ok {
+		return val
+	}
+	return defaultVal
+}
+
+// Generator 代码生成器接口
+type Generator interface {
+	Name() string                                                // 生成器名字
+	Options() []*Option                                          // 生成器支持的选项
+	Generate(ctx context.Context, req *Request) ([]*File, error) // 生成输出文件列表
+}
+
+// registry 已注册的代码生成器
+var registry = struct {
+	sync.RWMutex
+	generators map[string]Generator
+}{
+	generators: make(map[string]Generator),
+}
+
+// Register 注册代码生成器 不能重复注册同名生成器
+func Register(generator Generator) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.generators[generator.Name()]; ok {
+		gserrors.Panicf(ErrGen, "duplicate register generator(%s)", generator.Name())
+	}
+	registry.generators[generator.Name()] = generator
+}
+
+// Lookup 查找已注册的代码生成器
+func Lookup(name string) (Generator, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	generator, ok := registry.generators[name]
+	return generator, ok
+}
+
+// Generators 返回已注册的代码生成器名字列表
+func Generators() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	var names []string
+	for name := range registry.generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Find 查找代码生成器 先查找已注册的生成器 再在PATH中查找名为 gslang-gen-<name> 的外部插件
+func Find(name string) (Generator, error) {
+	if generator, ok := Lookup(name); ok {
+		return generator, nil
+	}
+	path, err := exec.LookPath(PluginPrefix + name)
+	if err != nil {
+		return nil, gserrors.Newf(ErrGen, "can not found generator(%s) : %s", name, err)
+	}
+	return NewPlugin(name, path), nil
+}
+
+// Run 使用指定名字的代码生成器生成代码
+func Run(ctx context.Context, name string, req *Request) ([]*File, error) {
+	generator, err := Find(name)
+	if err != nil {
+		return nil, err
+	}
+	return generator.Generate(ctx, req)
+}
@@ -0,0 +1,104 @@
+// @file 	gen_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	gen_test
+
+package gen
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeGenerator 测试用的代码生成器 为每个包输出一个文件
+type fakeGenerator struct {
+	name string
+}
+
+// Name 实现Generator接口
+func (generator *fakeGenerator) Name() string {
+	return generator.name
+}
+
+// Options 实现Generator接口
+func (generator *fakeGenerator) Options() []*Option {
+	return []*Option{{Name: "suffix", Default: ".txt", Usage: "file suffix"}}
+}
+
+// Generate 实现Generator接口
+func (generator *fakeGenerator) Generate(ctx context.Context, req *Request) ([]*File, error) {
+	if len(req.Packages) == 0 {
+		return nil, errors.New("no packages")
+	}
+	var files []*File
+	for _, pkg := range req.Packages {
+		files = append(files, NewFile(pkg.Name()+req.Option("suffix", ".txt"), []byte(pkg.Name())))
+	}
+	return files, nil
+}
+
+func TestRegistry(t *testing.T) {
+	generator := &fakeGenerator{name: "test-registry"}
+	Register(generator)
+	if found, ok := Lookup("test-registry"); !ok || found != generator {
+		t.Fatalf("Lookup = %v, %v", found, ok)
+	}
+	if _, ok := Lookup("test-none"); ok {
+		t.Error("Lookup(test-none) succeeded")
+	}
+	names := Generators()
+	found := false
+	for i, name := range names {
+		found = found || name == "test-registry"
+		if i > 0 && names[i-1] >= name {
+			t.Errorf("Generators not sorted: %v", names)
+		}
+	}
+	if !found {
+		t.Errorf("Generators = %v", names)
+	}
+	if found, err := Find("test-registry"); err != nil || found != generator {
+		t.Errorf("Find = %v, %v", found, err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("duplicate Register did not panic")
+			}
+		}()
+		Register(&fakeGenerator{name: "test-registry"})
+	}()
+	// 已注册的生成器优先于PATH中的插件
+	t.Setenv("PATH", t.TempDir())
+	if _, err := Find("test-none"); err == nil || !strings.Contains(err.Error(), "can not found generator(test-none)") {
+		t.Errorf("Find(test-none) error = %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	Register(&fakeGenerator{name: "test-run"})
+	files, err := Run(context.Background(), "test-run", &Request{
+		Packages: packages(t),
+		Options:  map[string]string{"suffix": ".md"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"demo/shop.md", "demo/base.md"}) {
+		t.Errorf("files = %v", names)
+	}
+	if _, err := Run(context.Background(), "test-run", &Request{}); err == nil {
+		t.Error("generator error was not returned")
+	}
+	req := &Request{Options: map[string]string{"a": ""}}
+	if req.Option("a", "x") != "" || req.Option("b", "x") != "x" {
+		t.Errorf("Option defaults wrong")
+	}
+}
@@ -0,0 +1,359 @@
+// @file 	jsonschema.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	jsonschema
+
+package jsonschema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/gen"
+)
+
+// Draft 生成的JSON Schema版本
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+func init() {
+	gen.Register(New())
+}
+
+// integers 整数内置类型的取值范围
+var integers = map[rune][2]string{
+	gslang.KeyByte:   {"0", "255"},
+	gslang.KeySByte:  {"-128", "127"},
+	gslang.KeyInt16:  {"-32768", "32767"},
+	gslang.KeyUInt16: {"0", "65535"},
+	gslang.KeyInt32:  {"-2147483648", "2147483647"},
+	gslang.KeyUInt32: {"0", "4294967295"},
+	gslang.KeyInt64:  {"-9223372036854775808", "9223372036854775807"},
+	gslang.KeyUInt64: {"0", "18446744073709551615"},
+}
+
+// Schema JSON Schema节点 只包含生成器用到的关键字
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Minimum              json.Number        `json:"minimum,omitempty"`
+	Maximum              json.Number        `json:"maximum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Properties           *Properties        `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"` // false 或者 *Schema
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Properties 按域声明顺序输出的属性列表
+type Properties struct {
+	names   []string
+	schemas map[string]*Schema
+}
+
+// Add 添加属性
+func (properties *Properties) Add(name string, schema *Schema) {
+	if properties.schemas == nil {
+		properties.schemas = make(map[string]*Schema)
+	}
+	if _, ok := properties.schemas[name]; !ok {
+		properties.names = append(properties.names, name)
+	}
+	properties.schemas[name] = schema
+}
+
+// Get 查找属性
+func (properties *Properties) Get(name string) (*Schema, bool) {
+	schema, ok := properties.schemas[name]
+	return schema, ok
+}
+
+// MarshalJSON 实现json.Marshaler接口 保持属性的添加顺序
+func (properties *Properties) MarshalJSON() ([]byte, error) {
+	var buff bytes.Buffer
+	buff.WriteString("{")
+	for i, name := range properties.names {
+		if i > 0 {
+			buff.WriteString(",")
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		buff.Write(key)
+		buff.WriteString(":")
+		value, err := json.Marshal(properties.schemas[name])
+		if err != nil {
+			return nil, err
+		}
+		buff.Write(value)
+	}
+	buff.WriteString("}")
+	return buff.Bytes(), nil
+}
+
+// Generator JSON Schema生成器 每个表及结构体生成一个自包含的schema文件
+// 被引用的表及枚举放在$defs中 以$ref引用
+type Generator struct{}
+
+// New 新建JSON Schema生成器
+func New() *Generator {
+	return &Generator{}
+}
+
+// Name 实现gen.Generator接口
+func (generator *Generator) Name() string {
+	return "jsonschema"
+}
+
+// Options 实现gen.Generator接口
+func (generator *Generator) Options() []*gen.Option {
+	return []*gen.Option{
+		{Name: "id", Usage: "$id prefix, the schema file name is appended"},
+		{Name: "required", Default: "false", Usage: "mark every field as required"},
+		{Name: "int64_as_number", Default: "false", Usage: "describe int64 and uint64 as json numbers instead of the codec's decimal strings"},
+	}
+}
+
+// Generate 实现gen.Generator接口
+func (generator *Generator) Generate(ctx context.Context, req *gen.Request) ([]*gen.File, error) {
+	builder := &Builder{
+		Required:      req.Option("required", "false") == "true",
+		Int64AsNumber: req.Option("int64_as_number", "false") == "true",
+	}
+	var files []*gen.File
+	for _, pkg := range req.Packages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for _, expr := range gslang.Types(pkg) {
+			table, ok := expr.(*ast.Table)
+			if !ok {
+				continue
+			}
+			schema, err := builder.Build(table)
+			if err != nil {
+				return nil, err
+			}
+			name := FileName(table)
+			if prefix := req.Option("id", ""); prefix != "" {
+				schema.ID = prefix + name
+			}
+			content, err := json.MarshalIndent(schema, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, gen.NewFile(name, append(content, '\n')))
+		}
+	}
+	return files, nil
+}
+
+// FileName 返回表对应的schema文件名 如 skea3344/foo.Bar -> skea3344/foo/Bar.schema.json
+func FileName(table *ast.Table) string {
+	return table.Package().Name() + "/" + table.Name() + ".schema.json"
+}
+
+// DefName 返回类型在$defs中的名字 包名中的/替换为. 以免在JSON Pointer中转义
+func DefName(expr ast.Expr) string {
+	return strings.Replace(gslang.TypeName(expr), "/", ".", -1)
+}
+
+// Builder 根据表生成自包含的JSON Schema 默认描述codec包的JSON规范映射
+type Builder struct {
+	Required      bool               // 所有域都是必须的
+	Int64AsNumber bool               // 64位整数使用数字表示 codec使用十进制字符串
+	defs          map[string]*Schema // 当前schema的$defs
+}
+
+// Build 生成以表为根的schema
+func (builder *Builder) Build(table *ast.Table) (schema *Schema, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(gserrors.GSError); ok {
+				err = e.(error)
+			} else {
+				panic(e)
+			}
+		}
+	}()
+	builder.defs = make(map[string]*Schema)
+	schema = &Schema{
+		Schema: Draft,
+		Ref:    builder.ref(table),
+		Defs:   builder.defs,
+	}
+	return schema, nil
+}
+
+// ref 返回类型的$ref 第一次引用时生成对应的定义
+func (builder *Builder) ref(expr ast.Expr) string {
+	name := DefName(expr)
+	if _, ok := builder.defs[name]; !ok {
+		// 先占位 以支持表的递归引用
+		builder.defs[name] = nil
+		switch node := expr.(type) {
+		case *ast.Table:
+			builder.defs[name] = builder.table(node)
+		case *ast.Enum:
+			builder.defs[name] = builder.enum(node)
+		}
+	}
+	return "#/$defs/" + name
+}
+
+// table 生成表或结构体的定义 不允许出现未声明的域
+func (builder *Builder) table(table *ast.Table) *Schema {
+	schema := &Schema{
+		Title:                table.Name(),
+		Description:          gen.CommentText(gen.Comments(table)),
+		Type:                 "object",
+		Properties:           &Properties{},
+		AdditionalProperties: false,
+	}
+	for _, field := range table.Fields {
+		property := builder.typeSchema(field, field.Type)
+		property.Description = gen.CommentText(gen.Comments(field))
+		schema.Properties.Add(field.Name(), property)
+		if builder.Required {
+			schema.Required = append(schema.Required, field.Name())
+		}
+	}
+	return schema
+}
+
+// enum 生成枚举的定义 枚举值使用名字表示 没有对应名字的值为取值范围内的数字
+// 带注释的枚举值说明追加在描述中
+func (builder *Builder) enum(enum *ast.Enum) *Schema {
+	names := &Schema{Type: "string"}
+	lines := gen.Comments(enum)
+	for _, val := range gslang.EnumVals(enum) {
+		names.Enum = append(names.Enum, val.Name())
+		if comments := gen.Comments(val); len(comments) > 0 {
+			lines = append(lines, val.Name()+": "+strings.Join(comments, " "))
+		}
+	}
+	min, max, _ := gslang.IntRange(enum)
+	return &Schema{
+		Title:       enum.Name(),
+		Description: gen.CommentText(lines),
+		AnyOf: []*Schema{
+			names,
+			{Type: "integer", Minimum: json.Number(min.String()), Maximum: json.Number(max.String())},
+		},
+	}
+}
+
+// typeSchema 生成类型表达式对应的schema
+// node用于报告错误位置
+func (builder *Builder) typeSchema(node ast.Node, expr ast.Expr) *Schema {
+	if key, ok := gslang.Builtin(expr); ok {
+		return builder.builtin(key)
+	}
+	switch typ := expr.(type) {
+	case *ast.TypeRef:
+		switch target := typ.Ref.(type) {
+		case *ast.Table, *ast.Enum:
+			return &Schema{Ref: builder.ref(target)}
+		case nil:
+			gserrors.Panicf(gen.ErrGen, "%s: unlinked type %s", gslang.Pos(node), typ)
+		default:
+			gserrors.Panicf(gen.ErrGen, "%s: type %s can not be encoded as json", gslang.Pos(node), gslang.TypeName(target))
+		}
+	case *ast.List:
+		return &Schema{
+			Type:  "array",
+			Items: builder.typeSchema(node, typ.Element),
+		}
+	case *ast.Array:
+		length := int(typ.Length)
+		return &Schema{
+			Type:     "array",
+			Items:    builder.typeSchema(node, typ.Element),
+			MinItems: &length,
+			MaxItems: &length,
+		}
+	case *ast.Map:
+		return &Schema{
+			Type:                 "object",
+			PropertyNames:        builder.mapKey(node, typ.Key),
+			AdditionalProperties: builder.typeSchema(node, typ.Value),
+		}
+	}
+	gserrors.Panicf(gen.ErrGen, "%s: unsupported type %s", gslang.Pos(node), gslang.TypeName(expr))
+	return nil
+}
+
+// builtin 生成内置类型的schema 整数类型带取值范围
+// 64位整数为十进制字符串 浮点数的NaN及正负无穷为字符串
+func (builder *Builder) builtin(key rune) *Schema {
+	switch key {
+	case gslang.KeyBool:
+		return &Schema{Type: "boolean"}
+	case gslang.KeyString:
+		return &Schema{Type: "string"}
+	case gslang.KeyFloat32, gslang.KeyFloat64:
+		return &Schema{AnyOf: []*Schema{
+			{Type: "number"},
+			{Type: "string", Enum: []string{"NaN", "Infinity", "-Infinity"}},
+		}}
+	case gslang.KeyInt64, gslang.KeyUInt64:
+		if !builder.Int64AsNumber {
+			pattern := "^-?[0-9]+$"
+			if key == gslang.KeyUInt64 {
+				pattern = "^[0-9]+$"
+			}
+			return &Schema{Type: "string", Pattern: pattern}
+		}
+	}
+	bounds := integers[key]
+	return &Schema{
+		Type:    "integer",
+		Minimum: json.Number(bounds[0]),
+		Maximum: json.Number(bounds[1]),
+	}
+}
+
+// mapKey 生成字典key的约束 JSON对象的key只能是字符串 字符串key不需要约束
+func (builder *Builder) mapKey(node ast.Node, expr ast.Expr) *Schema {
+	if key, ok := gslang.Builtin(expr); ok {
+		switch key {
+		case gslang.KeyString:
+			return nil
+		case gslang.KeyBool:
+			return &Schema{Pattern: "^(true|false)$"}
+		case gslang.KeyFloat32, gslang.KeyFloat64:
+			gserrors.Panicf(gen.ErrGen, "%s: %s can not be used as json object key", gslang.Pos(node), gslang.TypeName(expr))
+		}
+		if bounds := integers[key]; bounds[0] == "0" {
+			return &Schema{Pattern: "^[0-9]+$"}
+		}
+		return &Schema{Pattern: "^-?[0-9]+$"}
+	}
+	// 枚举key使用名字 没有对应名字的值为十进制数字
+	if ref, ok := expr.(*ast.TypeRef); ok {
+		if enum, ok := ref.Ref.(*ast.Enum); ok {
+			pattern := "^[0-9]+$"
+			if enum.Signed {
+				pattern = "^-?[0-9]+$"
+			}
+			return &Schema{AnyOf: []*Schema{{Ref: builder.ref(enum)}, {Pattern: pattern}}}
+		}
+	}
+	gserrors.Panicf(gen.ErrGen, "%s: %s can not be used as json object key", gslang.Pos(node), gslang.TypeName(expr))
+	return nil
+}
@@ -0,0 +1,327 @@
+// @file 	jsonschema_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	jsonschema_test
+
+package jsonschema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/codec"
+	"github.com/skea3344/gslang/gen"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// testFiles 测试使用的代码
+var testFiles = map[string]string{
+	"demo/shop/shop.gs": `
+enum Color(byte) {
+    // 红
+    Red(1), Green(2), Blue(3)
+}
+
+enum Level(int16) {
+    Low(-1), High(1)
+}
+
+struct Point {
+    X int32;
+    Y float32;
+}
+
+// 订单
+table Order {
+    // 编号
+    ID uint64;
+    Delta int64;
+    Price float64;
+    Color Color;
+    Pos [2]Point;
+    Tags []string;
+    Counts map[Color]uint32;
+    Levels map[Level]bool;
+    ByID map[int32]string;
+    Next Order;
+}
+`,
+}
+
+// generate 生成demo/shop的schema 返回文件名到JSON对象的映射
+func generate(t *testing.T, options map[string]string) (map[string]map[string]interface{}, *ast.Package) {
+	pkg := gstest.Compile(t, testFiles, "demo/shop").Loaded["demo/shop"]
+	files, err := New().Generate(context.Background(), &gen.Request{Packages: []*ast.Package{pkg}, Options: options})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas := make(map[string]map[string]interface{})
+	for _, file := range files {
+		decoder := json.NewDecoder(bytes.NewReader(file.Content))
+		decoder.UseNumber()
+		var schema map[string]interface{}
+		if err := decoder.Decode(&schema); err != nil {
+			t.Fatalf("%s: %s", file.Name, err)
+		}
+		schemas[file.Name] = schema
+	}
+	return schemas, pkg
+}
+
+// def 返回schema中指定名字的定义
+func def(t *testing.T, schema map[string]interface{}, name string) map[string]interface{} {
+	defs, _ := schema["$defs"].(map[string]interface{})
+	result, ok := defs[name].(map[string]interface{})
+	if !ok {
+		t.Fatalf("$defs[%s] not found", name)
+	}
+	return result
+}
+
+// compact 将JSON对象输出为紧凑的文本 用于比较 对象的key按字母顺序输出
+func compact(t *testing.T, value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestGenerate(t *testing.T) {
+	schemas, _ := generate(t, map[string]string{"id": "https://example.com/"})
+	var names []string
+	for name := range schemas {
+		names = append(names, name)
+	}
+	if len(schemas) != 2 || schemas["demo/shop/Order.schema.json"] == nil || schemas["demo/shop/Point.schema.json"] == nil {
+		t.Fatalf("files = %v", names)
+	}
+	order := schemas["demo/shop/Order.schema.json"]
+	if order["$schema"] != Draft || order["$id"] != "https://example.com/demo/shop/Order.schema.json" || order["$ref"] != "#/$defs/demo.shop.Order" {
+		t.Errorf("root = %v %v %v", order["$schema"], order["$id"], order["$ref"])
+	}
+	properties := compact(t, def(t, order, "demo.shop.Order")["properties"])
+	for _, want := range []string{
+		// 64位整数与codec一致使用十进制字符串
+		`"ID":{"description":"编号","pattern":"^[0-9]+$","type":"string"}`,
+		`"Delta":{"pattern":"^-?[0-9]+$","type":"string"}`,
+		// 浮点数的NaN及正负无穷为字符串
+		`"Price":{"anyOf":[{"type":"number"},{"enum":["NaN","Infinity","-Infinity"],"type":"string"}]}`,
+		`"Color":{"$ref":"#/$defs/demo.shop.Color"}`,
+		`"Pos":{"items":{"$ref":"#/$defs/demo.shop.Point"},"maxItems":2,"minItems":2,"type":"array"}`,
+		// 枚举key为名字或者十进制数字
+		`"Counts":{"additionalProperties":{"maximum":4294967295,"minimum":0,"type":"integer"},"propertyNames":{"anyOf":[{"$ref":"#/$defs/demo.shop.Color"},{"pattern":"^[0-9]+$"}]},"type":"object"}`,
+		`"Levels":{"additionalProperties":{"type":"boolean"},"propertyNames":{"anyOf":[{"$ref":"#/$defs/demo.shop.Level"},{"pattern":"^-?[0-9]+$"}]},"type":"object"}`,
+		`"ByID":{"additionalProperties":{"type":"string"},"propertyNames":{"pattern":"^-?[0-9]+$"},"type":"object"}`,
+		`"Next":{"$ref":"#/$defs/demo.shop.Order"}`,
+	} {
+		if !strings.Contains(properties, want) {
+			t.Errorf("properties missing %s\n%s", want, properties)
+		}
+	}
+	// 枚举为名字 没有对应名字的值为取值范围内的数字
+	color := compact(t, def(t, order, "demo.shop.Color"))
+	want := `{"anyOf":[{"enum":["Red","Green","Blue"],"type":"string"},{"maximum":255,"minimum":0,"type":"integer"}],"description":"Red: 红","title":"Color"}`
+	if color != want {
+		t.Errorf("Color =\n%s\nwant\n%s", color, want)
+	}
+	if level := compact(t, def(t, order, "demo.shop.Level")); !strings.Contains(level, `{"maximum":32767,"minimum":-32768,"type":"integer"}`) {
+		t.Errorf("Level = %s", level)
+	}
+	if _, ok := def(t, order, "demo.shop.Order")["required"]; ok {
+		t.Error("fields are required by default")
+	}
+}
+
+func TestGenerateOptions(t *testing.T) {
+	schemas, _ := generate(t, map[string]string{"required": "true", "int64_as_number": "true"})
+	order := def(t, schemas["demo/shop/Order.schema.json"], "demo.shop.Order")
+	if got := compact(t, order["required"]); got != `["ID","Delta","Price","Color","Pos","Tags","Counts","Levels","ByID","Next"]` {
+		t.Errorf("required = %s", got)
+	}
+	properties := compact(t, order["properties"])
+	if !strings.Contains(properties, `"Delta":{"maximum":9223372036854775807,"minimum":-9223372036854775808,"type":"integer"}`) {
+		t.Errorf("int64_as_number properties = %s", properties)
+	}
+	if _, ok := schemas["demo/shop/Order.schema.json"]["$id"]; ok {
+		t.Error("$id without id option")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	files := map[string]string{"demo/bad/bad.gs": "table Bad { M map[float32]int32; }\n"}
+	pkg := gstest.Compile(t, files, "demo/bad").Loaded["demo/bad"]
+	_, err := New().Generate(context.Background(), &gen.Request{Packages: []*ast.Package{pkg}})
+	if err == nil || !strings.Contains(err.Error(), "bad.gs(1:13): float32 can not be used as json object key") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+// TestCodecConformance codec编码的JSON符合生成的schema 不符合codec映射的JSON被拒绝
+func TestCodecConformance(t *testing.T) {
+	schemas, pkg := generate(t, nil)
+	root := schemas["demo/shop/Order.schema.json"]
+	order := pkg.Types["Order"]
+	values := []map[string]interface{}{
+		{},
+		{"ID": uint64(math.MaxUint64), "Delta": int64(math.MinInt64), "Price": 1.5},
+		{"Price": math.NaN()},
+		{"Price": math.Inf(-1), "Color": uint64(2)},
+		// 没有对应名字的枚举值
+		{"Color": uint64(200), "Counts": map[interface{}]interface{}{uint64(3): uint32(1), uint64(9): uint32(2)}},
+		{"Levels": map[interface{}]interface{}{int64(-1): true, int64(7): false}},
+		{"ByID": map[interface{}]interface{}{int32(-5): "a"}, "Tags": []interface{}{"x", "y"}},
+		{"Pos": []interface{}{
+			map[string]interface{}{"X": int32(1), "Y": float32(math.Inf(1))},
+			map[string]interface{}{"X": int32(2), "Y": float32(0.5)},
+		}},
+		{"Next": map[string]interface{}{"ID": uint64(1), "Next": map[string]interface{}{"Color": uint64(1)}}},
+	}
+	for _, value := range values {
+		data, err := codec.MarshalJSON(order, value)
+		if err != nil {
+			t.Fatalf("MarshalJSON(%v): %s", value, err)
+		}
+		if err := validate(root, root, decode(t, string(data))); err != nil {
+			t.Errorf("%s: %s", data, err)
+		}
+	}
+	invalid := []string{
+		`{"ID":1}`,
+		`{"Delta":"1.5"}`,
+		`{"Price":"nan"}`,
+		`{"Color":"Purple"}`,
+		`{"Color":256}`,
+		`{"Counts":{"Purple":1}}`,
+		`{"Counts":{"-1":1}}`,
+		`{"Pos":[{"X":1,"Y":2}]}`,
+		`{"ByID":{"x":"a"}}`,
+		`{"Other":1}`,
+		`{"Next":{"Tags":[1]}}`,
+	}
+	for _, data := range invalid {
+		if err := validate(root, root, decode(t, data)); err == nil {
+			t.Errorf("%s: accepted", data)
+		}
+	}
+}
+
+// decode 解析JSON文本 数字保持为json.Number
+func decode(t *testing.T, data string) interface{} {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("%s: %s", data, err)
+	}
+	return value
+}
+
+// validate 按生成器用到的JSON Schema关键字校验JSON值
+func validate(root map[string]interface{}, schema map[string]interface{}, value interface{}) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		defs := root["$defs"].(map[string]interface{})
+		target, ok := defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unresolved $ref %s", ref)
+		}
+		return validate(root, target, value)
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		for _, item := range anyOf {
+			if validate(root, item.(map[string]interface{}), value) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%v matches none of anyOf", value)
+	}
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%v is not an object", value)
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, item := range object {
+			if names, ok := schema["propertyNames"].(map[string]interface{}); ok {
+				if err := validate(root, names, name); err != nil {
+					return fmt.Errorf("property name: %s", err)
+				}
+			}
+			sub, ok := properties[name].(map[string]interface{})
+			if !ok {
+				additional, ok := schema["additionalProperties"].(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("unexpected property %s", name)
+				}
+				sub = additional
+			}
+			if err := validate(root, sub, item); err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+		}
+		return nil
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%v is not an array", value)
+		}
+		if min, ok := schema["minItems"].(json.Number); ok && min.String() != fmt.Sprint(len(array)) {
+			return fmt.Errorf("array length %d want %s", len(array), min)
+		}
+		for _, item := range array {
+			if err := validate(root, schema["items"].(map[string]interface{}), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "integer":
+		number, ok := value.(json.Number)
+		n, isInt := new(big.Int).SetString(number.String(), 10)
+		if !ok || !isInt {
+			return fmt.Errorf("%v is not an integer", value)
+		}
+		min, _ := new(big.Int).SetString(schema["minimum"].(json.Number).String(), 10)
+		max, _ := new(big.Int).SetString(schema["maximum"].(json.Number).String(), 10)
+		if n.Cmp(min) < 0 || n.Cmp(max) > 0 {
+			return fmt.Errorf("%s out of range [%s, %s]", n, min, max)
+		}
+		return nil
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return fmt.Errorf("%v is not a number", value)
+		}
+		return nil
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%v is not a boolean", value)
+		}
+		return nil
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%v is not a string", value)
+		}
+	}
+	// 字符串约束 也用于没有type的propertyNames
+	text, _ := value.(string)
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		for _, item := range enum {
+			if item == text {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not in enum", text)
+	}
+	if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(text) {
+		return fmt.Errorf("%q does not match %s", text, pattern)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// @file 	naming.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	naming
+
+package gen
+
+import (
+	"strings"
+	"unicode"
+)
+
+// splitWords 将标识符按下划线 连字符 空格及大小写边界拆分成单词列表
+// 如 HTTPServerName -> [HTTP Server Name]  user_id -> [user id]
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := -1
+	for i, ch := range runes {
+		if ch == '_' || ch == '-' || ch == ' ' || ch == '.' {
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := runes[i-1]
+		switch {
+		case unicode.IsUpper(ch) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			// fooBar foo1Bar
+			words = append(words, string(runes[start:i]))
+			start = i
+		case unicode.IsUpper(ch) && unicode.IsUpper(prev) &&
+			i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			// HTTPServer 在S处断开
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// upperFirst 首字母大写 其余小写
+func upperFirst(word string) string {
+	runes := []rune(strings.ToLower(word))
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
+
+// PascalCase 转换成大驼峰 如 user_id -> UserId
+func PascalCase(name string) string {
+	var buff strings.Builder
+	for _, word := range splitWords(name) {
+		buff.WriteString(upperFirst(word))
+	}
+	return buff.String()
+}
+
+// CamelCase 转换成小驼峰 如 UserID -> userId
+func CamelCase(name string) string {
+	var buff strings.Builder
+	for i, word := range splitWords(name) {
+		if i == 0 {
+			buff.WriteString(strings.ToLower(word))
+			continue
+		}
+		buff.WriteString(upperFirst(word))
+	}
+	return buff.String()
+}
+
+// SnakeCase 转换成下划线分隔的小写形式 如 UserID -> user_id
+func SnakeCase(name string) string {
+	words := splitWords(name)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return strings.Join(words, "_")
+}
+
+// KebabCase 转换成连字符分隔的小写形式 如 UserID -> user-id
+func KebabCase(name string) string {
+	words := splitWords(name)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return strings.Join(words, "-")
+}
+
+// ScreamingCase 转换成下划线分隔的大写形式 如 UserID -> USER_ID
+func ScreamingCase(name string) string {
+	return strings.ToUpper(SnakeCase(name))
+}
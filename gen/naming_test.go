@@ -0,0 +1,56 @@
+// @file 	naming_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	naming_test
+
+package gen
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"", nil},
+		{"user", []string{"user"}},
+		{"userID", []string{"user", "ID"}},
+		{"HTTPServerName", []string{"HTTP", "Server", "Name"}},
+		{"user_id", []string{"user", "id"}},
+		{"__user--id  ", []string{"user", "id"}},
+		{"demo.shop", []string{"demo", "shop"}},
+		{"foo1Bar", []string{"foo1", "Bar"}},
+		{"V2", []string{"V2"}},
+		{"ABC", []string{"ABC"}},
+		{"名字Name", []string{"名字Name"}},
+	}
+	for _, tc := range tests {
+		if got := splitWords(tc.name); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitWords(%q) = %q want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCase(t *testing.T) {
+	tests := []struct {
+		name                                   string
+		pascal, camel, snake, kebab, screaming string
+	}{
+		{"user_id", "UserId", "userId", "user_id", "user-id", "USER_ID"},
+		{"UserID", "UserId", "userId", "user_id", "user-id", "USER_ID"},
+		{"HTTPServer", "HttpServer", "httpServer", "http_server", "http-server", "HTTP_SERVER"},
+		{"getItem2Name", "GetItem2Name", "getItem2Name", "get_item2_name", "get-item2-name", "GET_ITEM2_NAME"},
+		{"x", "X", "x", "x", "x", "X"},
+		{"", "", "", "", "", ""},
+	}
+	for _, tc := range tests {
+		got := []string{PascalCase(tc.name), CamelCase(tc.name), SnakeCase(tc.name), KebabCase(tc.name), ScreamingCase(tc.name)}
+		want := []string{tc.pascal, tc.camel, tc.snake, tc.kebab, tc.screaming}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("case(%q) = %q want %q", tc.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// @file 	plugin.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	plugin
+
+package gen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/skea3344/gserrors"
+)
+
+// PluginRequest 通过标准输入发送给外部插件的请求
+type PluginRequest struct {
+	Generator string            `json:"generator"` // 生成器名字
+	Options   map[string]string `json:"options"`   // 生成器选项
+	Schema    *Schema           `json:"schema"`    // 需要生成代码的包描述
+}
+
+// PluginResponse 外部插件通过标准输出返回的结果
+type PluginResponse struct {
+	Files []*File `json:"files,omitempty"` // 输出文件列表
+	Error string  `json:"error,omitempty"` // 生成错误 非空时忽略输出文件
+}
+
+// Plugin 外部插件代码生成器 以子进程方式运行可执行文件
+// 请求以JSON格式写入插件的标准输入 插件将JSON格式的结果写入标准输出
+type Plugin struct {
+	name string   // 生成器名字
+	path string   // 可执行文件路径
+	args []string // 命令行参数
+}
+
+// NewPlugin 新建外部插件代码生成器
+func NewPlugin(name string, path string, args ...string) *Plugin {
+	return &Plugin{
+		name: name,
+		path: path,
+		args: args,
+	}
+}
+
+// Name 实现Generator接口
+func (plugin *Plugin) Name() string {
+	return plugin.name
+}
+
+// Options 实现Generator接口 外部插件的选项由插件自行解释
+func (plugin *Plugin) Options() []*Option {
+	return nil
+}
+
+// Generate 实现Generator接口 运行插件并读取输出文件
+func (plugin *Plugin) Generate(ctx context.Context, req *Request) ([]*File, error) {
+	input, err := json.Marshal(&PluginRequest{
+		Generator: plugin.name,
+		Options:   req.Options,
+		Schema:    NewSchema(req.Packages),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, plugin.path, plugin.args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, gserrors.Newf(ErrGen, "run plugin(%s) error : %s\n%s",
+			plugin.path, err, strings.TrimSpace(stderr.String()))
+	}
+	var resp PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, gserrors.Newf(ErrGen, "plugin(%s) return invalid response : %s", plugin.path, err)
+	}
+	if resp.Error != "" {
+		return nil, gserrors.Newf(ErrGen, "plugin(%s) error : %s", plugin.path, resp.Error)
+	}
+	return resp.Files, nil
+}
+
+// RunPlugin 外部插件的入口 从标准输入读取请求 调用f生成代码 并将结果写入标准输出
+func RunPlugin(f func(ctx context.Context, req *PluginRequest) ([]*File, error)) {
+	if err := servePlugin(context.Background(), os.Stdin, os.Stdout, f); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+// servePlugin 处理一次插件请求 生成错误写入结果 读写错误直接返回
+func servePlugin(ctx context.Context, reader io.Reader, writer io.Writer,
+	f func(ctx context.Context, req *PluginRequest) ([]*File, error)) error {
+	var req PluginRequest
+	if err := json.NewDecoder(reader).Decode(&req); err != nil {
+		return err
+	}
+	var resp PluginResponse
+	files, err := f(ctx, &req)
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Files = files
+	}
+	return json.NewEncoder(writer).Encode(&resp)
+}
@@ -0,0 +1,156 @@
+// @file 	plugin_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	plugin_test
+
+package gen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// pluginModeEnv 设置后测试程序作为外部插件运行 值为插件行为
+const pluginModeEnv = "GSLANG_GEN_TEST_PLUGIN"
+
+// TestPluginHelper 被外部插件测试作为子进程调用 不单独运行
+func TestPluginHelper(t *testing.T) {
+	mode := os.Getenv(pluginModeEnv)
+	if mode == "" {
+		t.Skip("plugin helper process")
+	}
+	switch mode {
+	case "crash":
+		os.Stderr.WriteString("boom\n")
+		os.Exit(3)
+	case "garbage":
+		os.Stdout.WriteString("not json")
+		os.Exit(0)
+	}
+	RunPlugin(func(ctx context.Context, req *PluginRequest) ([]*File, error) {
+		if mode == "error" {
+			return nil, errors.New("bad schema")
+		}
+		var names []string
+		for _, pkg := range req.Schema.Packages {
+			names = append(names, pkg.Name)
+		}
+		content := fmt.Sprintf("%s %s %s", req.Generator, req.Options["suffix"], strings.Join(names, ","))
+		return []*File{NewFile("out.txt", []byte(content))}, nil
+	})
+	os.Exit(0)
+}
+
+// installPlugin 在临时目录中生成调用测试程序的插件脚本 并将该目录设为PATH
+func installPlugin(t *testing.T, name string, mode string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin script needs a unix shell")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, PluginPrefix+name)
+	script := fmt.Sprintf("#!/bin/sh\n%s=%s exec %q -test.run='^TestPluginHelper$'\n", pluginModeEnv, mode, os.Args[0])
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	return path
+}
+
+func TestPluginGenerate(t *testing.T) {
+	path := installPlugin(t, "test-echo", "ok")
+	generator, err := Find("test-echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin, ok := generator.(*Plugin)
+	if !ok || plugin.path != path || plugin.Name() != "test-echo" || plugin.Options() != nil {
+		t.Fatalf("Find = %#v", generator)
+	}
+	files, err := plugin.Generate(context.Background(), &Request{
+		Packages: packages(t),
+		Options:  map[string]string{"suffix": ".x"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "out.txt" || string(files[0].Content) != "test-echo .x demo/shop,demo/base" {
+		t.Fatalf("files = %v", files)
+	}
+}
+
+func TestPluginErrors(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"error", "error : bad schema"},
+		{"crash", "boom"},
+		{"garbage", "return invalid response"},
+	}
+	for _, tc := range tests {
+		path := installPlugin(t, "test-"+tc.mode, tc.mode)
+		_, err := NewPlugin("test-"+tc.mode, path).Generate(context.Background(), &Request{Packages: packages(t)})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want %q", tc.mode, err, tc.want)
+		}
+	}
+}
+
+func TestServePlugin(t *testing.T) {
+	input, err := json.Marshal(&PluginRequest{
+		Generator: "echo",
+		Options:   map[string]string{"a": "b"},
+		Schema:    NewSchema(packages(t)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got *PluginRequest
+	var output bytes.Buffer
+	err = servePlugin(context.Background(), bytes.NewReader(input), &output, func(ctx context.Context, req *PluginRequest) ([]*File, error) {
+		got = req
+		return []*File{NewFile("a.txt", []byte("a"))}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Generator != "echo" || got.Options["a"] != "b" || len(got.Schema.Packages) != 2 {
+		t.Errorf("request = %+v", got)
+	}
+	var resp PluginResponse
+	if err := json.Unmarshal(output.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != "" || len(resp.Files) != 1 || string(resp.Files[0].Content) != "a" {
+		t.Errorf("response = %+v", resp)
+	}
+	// 生成错误写入结果
+	output.Reset()
+	err = servePlugin(context.Background(), bytes.NewReader(input), &output, func(ctx context.Context, req *PluginRequest) ([]*File, error) {
+		return []*File{NewFile("a.txt", nil)}, errors.New("failed")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = PluginResponse{}
+	if err := json.Unmarshal(output.Bytes(), &resp); err != nil || resp.Error != "failed" || resp.Files != nil {
+		t.Errorf("error response = %+v, %v", resp, err)
+	}
+	// 无法解析的请求直接返回错误
+	output.Reset()
+	err = servePlugin(context.Background(), strings.NewReader("{"), &output, func(ctx context.Context, req *PluginRequest) ([]*File, error) {
+		t.Error("f called with invalid request")
+		return nil, nil
+	})
+	if err == nil || output.Len() != 0 {
+		t.Errorf("invalid request error = %v, output = %q", err, output.String())
+	}
+}
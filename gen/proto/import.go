@@ -0,0 +1,310 @@
+// @file 	import.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	import
+
+package proto
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/gen"
+)
+
+// emptyMessage 作为参数或者返回值时表示没有参数的protobuf消息
+const emptyMessage = "google.protobuf.Empty"
+
+// protoScalars protobuf标量类型对应的gslang内置类型名
+var protoScalars = map[string]string{
+	"double":   "Float64",
+	"float":    "Float32",
+	"int32":    "Int32",
+	"sint32":   "Int32",
+	"sfixed32": "Int32",
+	"int64":    "Int64",
+	"sint64":   "Int64",
+	"sfixed64": "Int64",
+	"uint32":   "Uint32",
+	"fixed32":  "Uint32",
+	"uint64":   "Uint64",
+	"fixed64":  "Uint64",
+	"bool":     "Bool",
+	"string":   "String",
+}
+
+// importer 将解析后的proto文件转换为gslang语法树
+type importer struct {
+	pkg   *ast.Package
+	names map[string]string // proto完整名字 -> gslang类型名
+	kinds map[string]string // proto完整名字 -> message enum
+}
+
+// Import 将多个proto文件转换为一个gslang包 每个proto文件对应一个代码节点
+// 嵌套的消息及枚举平铺为 外层名字+内层名字 不支持的结构以注释的形式保留在生成的代码中
+func Import(pkgName string, paths ...string) (*ast.Package, error) {
+	var files []*protoSource
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parseProto(path, content)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return importFiles(pkgName, files)
+}
+
+// Convert 将多个proto文件转换为gslang源码文件 文件名为proto文件名替换扩展名为.gs
+func Convert(pkgName string, paths ...string) ([]*gen.File, error) {
+	pkg, err := Import(pkgName, paths...)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var files []*gen.File
+	for _, name := range names {
+		var buff bytes.Buffer
+		if err := gslang.Format(&buff, pkg.Scripts[name]); err != nil {
+			return nil, err
+		}
+		files = append(files, gen.NewFile(name, buff.Bytes()))
+	}
+	return files, nil
+}
+
+// importFiles 转换已解析的proto文件
+func importFiles(pkgName string, files []*protoSource) (pkg *ast.Package, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(gserrors.GSError); ok {
+				err = e.(error)
+			} else {
+				err = fmt.Errorf("%v", e)
+			}
+		}
+	}()
+	im := &importer{
+		pkg:   ast.NewPackage(pkgName),
+		names: make(map[string]string),
+		kinds: make(map[string]string),
+	}
+	// 先收集所有类型的名字 用于解析类型引用
+	for _, file := range files {
+		for _, message := range file.messages {
+			im.declare(file, message.name, "message")
+		}
+		for _, enum := range file.enums {
+			im.declare(file, enum.name, "enum")
+		}
+	}
+	for _, file := range files {
+		im.convert(file)
+	}
+	return im.pkg, nil
+}
+
+// declare 声明proto类型对应的gslang类型名 去掉包名前缀后将各层名字拼接
+func (im *importer) declare(file *protoSource, fullName string, kind string) {
+	name := strings.TrimPrefix(strings.TrimPrefix(fullName, file.pkg), ".")
+	name = strings.ReplaceAll(name, ".", "")
+	for other, old := range im.names {
+		if old == name {
+			gserrors.Panicf(ErrProto, "%s: type %s conflict with %s", file.filename, fullName, other)
+		}
+	}
+	im.names[fullName] = name
+	im.kinds[fullName] = kind
+}
+
+// resolve 按protobuf作用域规则解析类型名 返回完整名字
+func (im *importer) resolve(scope string, name string) (string, bool) {
+	if strings.HasPrefix(name, ".") {
+		name = name[1:]
+		_, ok := im.names[name]
+		return name, ok
+	}
+	for {
+		if _, ok := im.names[scoped(scope, name)]; ok {
+			return scoped(scope, name), true
+		}
+		if scope == "" {
+			return "", false
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// convert 转换单个proto文件
+func (im *importer) convert(file *protoSource) {
+	name := strings.TrimSuffix(filepath.Base(file.filename), filepath.Ext(file.filename)) + ".gs"
+	script, err := im.pkg.NewScript(name)
+	if err != nil {
+		gserrors.Panic(err)
+	}
+	for _, note := range file.notes {
+		gslang.AddComments(script, "unsupported: "+note)
+	}
+	for _, enum := range file.enums {
+		im.enum(script, enum)
+	}
+	for _, message := range file.messages {
+		im.message(script, file, message)
+	}
+	for _, service := range file.services {
+		im.service(script, file, service)
+	}
+}
+
+// newType 将类型添加到代码节点 不能有重名类型
+func (im *importer) newType(script *ast.Script, expr ast.Expr) {
+	if _, ok := script.NewType(expr); !ok {
+		gserrors.Panicf(ErrProto, "duplicate type name %s", expr)
+	}
+}
+
+// builtin 生成gslang内置类型的类型引用
+func builtin(script *ast.Script, name string) *ast.TypeRef {
+	return script.NewTypeRef([]string{"gslang", name})
+}
+
+// typeRef 将proto类型名转换为类型表达式 bytes转换为[]byte
+func (im *importer) typeRef(script *ast.Script, file *protoSource, scope string, name string) (ast.Expr, error) {
+	if scalar, ok := protoScalars[name]; ok {
+		return builtin(script, scalar), nil
+	}
+	if name == "bytes" {
+		return script.NewList(builtin(script, "Byte")), nil
+	}
+	fullName, ok := im.resolve(scope, name)
+	if !ok {
+		return nil, fmt.Errorf("%s: unresolved type %s, convert the file which declares it together", file.filename, name)
+	}
+	return script.NewTypeRef([]string{im.names[fullName]}), nil
+}
+
+// enum 转换枚举 枚举值去掉 ENUM_NAME_ 前缀后转换为大驼峰
+func (im *importer) enum(script *ast.Script, enum *protoEnum) {
+	name := im.names[enum.name]
+	expr := script.NewEnum(name, 4, true)
+	im.newType(script, expr)
+	gslang.AddComments(expr, enum.comments...)
+	prefix := gen.ScreamingCase(name) + "_"
+	for _, val := range enum.values {
+		valName := gen.PascalCase(strings.TrimPrefix(val.name, prefix))
+		enumVal, ok := expr.NewVal(valName, val.value)
+		if !ok {
+			gserrors.Panicf(ErrProto, "duplicate enum value %s.%s", name, valName)
+		}
+		gslang.AddComments(enumVal, val.comments...)
+	}
+}
+
+// message 转换消息为表 域按tag排序 tag不连续时在注释中保留原tag
+func (im *importer) message(script *ast.Script, file *protoSource, message *protoMessage) {
+	table := script.NewTable(im.names[message.name])
+	im.newType(script, table)
+	gslang.AddComments(table, message.comments...)
+	for _, note := range message.notes {
+		gslang.AddComments(table, "unsupported: "+note)
+	}
+	fields := append([]*protoField(nil), message.fields...)
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].tag < fields[j].tag
+	})
+	for _, field := range fields {
+		expr, err := im.fieldType(script, file, message.name, field)
+		if err != nil {
+			gslang.AddComments(table, fmt.Sprintf("unsupported: field %s = %d: %s", field.name, field.tag, err))
+			continue
+		}
+		node, ok := table.NewField(gen.CamelCase(field.name))
+		if !ok {
+			gserrors.Panicf(ErrProto, "%s(%d): duplicate field name %s", file.filename, field.line, node)
+		}
+		node.Type = expr
+		expr.SetParent(node)
+		gslang.AddComments(node, field.comments...)
+		if int(node.ID)+1 != field.tag {
+			gslang.AddComments(node, fmt.Sprintf("proto tag %d", field.tag))
+		}
+		if field.oneof != "" {
+			gslang.AddComments(node, "oneof "+field.oneof)
+		}
+	}
+}
+
+// fieldType 转换域的类型 repeated转换为切片 map转换为字典
+func (im *importer) fieldType(script *ast.Script, file *protoSource, scope string, field *protoField) (ast.Expr, error) {
+	value, err := im.typeRef(script, file, scope, field.typ)
+	if err != nil {
+		return nil, err
+	}
+	if field.key != "" {
+		key, err := im.typeRef(script, file, scope, field.key)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := value.(*ast.List); ok {
+			return nil, fmt.Errorf("map value bytes is not supported")
+		}
+		return script.NewMap(key, value), nil
+	}
+	if field.label == "repeated" {
+		if _, ok := value.(*ast.List); ok {
+			return nil, fmt.Errorf("repeated bytes is not supported")
+		}
+		return script.NewList(value), nil
+	}
+	return value, nil
+}
+
+// service 转换服务为协议 google.protobuf.Empty参数或者返回值转换为空参数列表
+func (im *importer) service(script *ast.Script, file *protoSource, service *protoService) {
+	contract := script.NewContract(strings.TrimPrefix(strings.TrimPrefix(service.name, file.pkg), "."))
+	im.newType(script, contract)
+	gslang.AddComments(contract, service.comments...)
+	for _, rpc := range service.rpcs {
+		if rpc.streaming {
+			gslang.AddComments(contract, fmt.Sprintf("unsupported: streaming rpc %s", rpc.name))
+			continue
+		}
+		method, ok := contract.NewMethod(rpc.name)
+		if !ok {
+			gserrors.Panicf(ErrProto, "%s(%d): duplicate rpc name %s", file.filename, rpc.line, rpc.name)
+		}
+		gslang.AddComments(method, rpc.comments...)
+		if rpc.input != emptyMessage && rpc.input != "."+emptyMessage {
+			param, err := im.typeRef(script, file, file.pkg, rpc.input)
+			if err != nil {
+				gserrors.Panicf(ErrProto, "%s(%d): %s", file.filename, rpc.line, err)
+			}
+			method.NewParam(param)
+		}
+		if rpc.output != emptyMessage && rpc.output != "."+emptyMessage {
+			ret, err := im.typeRef(script, file, file.pkg, rpc.output)
+			if err != nil {
+				gserrors.Panicf(ErrProto, "%s(%d): %s", file.filename, rpc.line, err)
+			}
+			method.NewReturn(ret)
+		}
+	}
+}
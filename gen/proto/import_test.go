@@ -0,0 +1,157 @@
+// @file 	import_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	import_test
+
+package proto
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// writeProto 在临时目录中写入proto文件 返回文件路径
+func writeProto(t *testing.T, files map[string]string) []string {
+	dir := t.TempDir()
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// wantShopGS shopProto转换得到的gslang代码
+const wantShopGS = `enum ItemKind(int32) {
+    KindNone(0),
+    // 一些
+    KindSome(1)
+}
+
+enum Status(int32) {
+    Ok(0),
+    Bad(-1)
+}
+
+// 物品
+table Item {
+    // 编号
+    itemId uint64;
+    // 标签
+    tags []string;
+    // proto tag 4
+    counts map[string]int32;
+    // proto tag 5
+    kind ItemKind;
+    // proto tag 6
+    data []byte;
+    // proto tag 7
+    // oneof target
+    name string;
+    // proto tag 8
+    // oneof target
+    inner ItemInner;
+}
+
+// 内嵌
+// 消息
+table ItemInner {
+    value int64;
+}
+
+// 商店
+// unsupported: streaming rpc Watch
+contract Shop {
+    // 查询
+    Get(Item) -> (Item);
+    Ping();
+}
+
+// unsupported: line 43: extend is not supported
+`
+
+func TestConvert(t *testing.T) {
+	paths := writeProto(t, map[string]string{"shop.proto": shopProto})
+	files, err := Convert("demo/shop", paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "shop.gs" {
+		t.Fatalf("files = %v", files)
+	}
+	if got := string(files[0].Content); got != wantShopGS {
+		t.Fatalf("shop.gs:\n%s\nwant\n%s", got, wantShopGS)
+	}
+	// 转换结果是合法的gslang代码 默认枚举值为proto的0值
+	cs := gstest.Compile(t, map[string]string{"demo/shop/shop.gs": wantShopGS}, "demo/shop")
+	status := gstest.Type(t, cs, "demo/shop", "Status").(*ast.Enum)
+	if status.Default.Name() != "Ok" {
+		t.Errorf("Status default = %s", status.Default.Name())
+	}
+}
+
+// TestImportFiles 多个proto文件转换为同一个包 类型引用可以跨文件
+func TestImportFiles(t *testing.T) {
+	paths := writeProto(t, map[string]string{
+		"a.proto": "package demo;\nmessage A { B b = 1; }\n",
+		"b.proto": "package demo;\nmessage B { .demo.A a = 1; }\n",
+	})
+	pkg, err := Import("demo/ab", paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Name() != "demo/ab" || len(pkg.Scripts) != 2 || pkg.Scripts["a.gs"] == nil || pkg.Scripts["b.gs"] == nil {
+		t.Fatalf("scripts = %v", pkg.Scripts)
+	}
+	files, err := Convert("demo/ab", paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := map[string]string{}
+	for _, file := range files {
+		sources["demo/ab/"+file.Name] = string(file.Content)
+	}
+	gstest.Compile(t, sources, "demo/ab")
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		files map[string]string
+		want  string
+	}{
+		{map[string]string{"a.proto": "message A { message B {} }\nmessage AB {}"}, "type AB conflict with A.B"},
+		{map[string]string{"a.proto": "message A {}", "b.proto": "message A {}"}, "conflict with A"},
+		{map[string]string{"a.proto": "service S { rpc Get(Missing) returns (Missing); }"}, "a.proto(1): "},
+		{map[string]string{"a.proto": "message A {}\nservice S {\n rpc Get(A) returns (A);\n rpc Get(A) returns (A);\n}"}, "a.proto(4): duplicate rpc name Get"},
+		{map[string]string{"a.proto": "enum E { E_A = 0; A = 1; }"}, "duplicate enum value E.A"},
+		{map[string]string{"a.proto": "message A {"}, "a.proto(1): expect '}'"},
+	}
+	for _, tc := range tests {
+		_, err := Import("demo/bad", writeProto(t, tc.files)...)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Import(%v) error = %v, want %q", tc.files, err, tc.want)
+		}
+	}
+	// 无法解析的域类型以注释的形式保留
+	files, err := Convert("demo/bad", writeProto(t, map[string]string{"a.proto": "message A { Missing m = 1; int32 n = 2; }"})...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(files[0].Content)
+	for _, want := range []string{"// unsupported: field m = 1: ", "unresolved type Missing", "    n int32;\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("unresolved field missing %q:\n%s", want, got)
+		}
+	}
+	if _, err := Convert("demo/bad", filepath.Join(t.TempDir(), "none.proto")); err == nil {
+		t.Error("Convert missing file succeeded")
+	}
+}
@@ -0,0 +1,516 @@
+// @file 	parser.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	parser
+
+package proto
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/skea3344/gserrors"
+)
+
+var (
+	// ErrProto proto文件解析错误
+	ErrProto = errors.New("proto parse error")
+)
+
+// protoToken proto文件词法符号
+type protoToken struct {
+	kind     rune     // 'i' 标识符 'n' 数字 's' 字符串 其余为符号本身 0为文件结束
+	text     string   // 符号文本 字符串为去掉引号后的内容
+	line     int      // 行号
+	comments []string // 符号之前紧邻的注释
+	trailing []string // 与符号同一行的行尾注释
+}
+
+// protoLexer proto文件词法分析器
+type protoLexer struct {
+	filename string
+	src      []rune
+	pos      int
+	line     int
+	last     *protoToken // 上一个符号 用于收集行尾注释
+}
+
+// errorf 格式化报错
+func (lexer *protoLexer) errorf(line int, format string, args ...interface{}) {
+	gserrors.Panicf(ErrProto, "%s(%d): %s", lexer.filename, line, fmt.Sprintf(format, args...))
+}
+
+// next 返回下一个符号
+func (lexer *protoLexer) next() *protoToken {
+	var comments []string
+	for lexer.pos < len(lexer.src) {
+		ch := lexer.src[lexer.pos]
+		switch {
+		case ch == '\n':
+			lexer.line++
+			lexer.pos++
+			// 空行分隔的注释不属于后面的声明
+			if lexer.pos < len(lexer.src) && lexer.src[lexer.pos] == '\n' {
+				comments = nil
+			}
+		case unicode.IsSpace(ch):
+			lexer.pos++
+		case ch == '/' && lexer.peek(1) == '/':
+			start := lexer.pos + 2
+			for lexer.pos < len(lexer.src) && lexer.src[lexer.pos] != '\n' {
+				lexer.pos++
+			}
+			text := strings.TrimSpace(string(lexer.src[start:lexer.pos]))
+			if lexer.last != nil && lexer.last.line == lexer.line {
+				lexer.last.trailing = append(lexer.last.trailing, text)
+				continue
+			}
+			comments = append(comments, text)
+		case ch == '/' && lexer.peek(1) == '*':
+			start := lexer.pos + 2
+			line := lexer.line
+			lexer.pos = start
+			for lexer.pos < len(lexer.src) && !(lexer.src[lexer.pos] == '*' && lexer.peek(1) == '/') {
+				if lexer.src[lexer.pos] == '\n' {
+					lexer.line++
+				}
+				lexer.pos++
+			}
+			if lexer.pos >= len(lexer.src) {
+				lexer.errorf(line, "comment not terminated")
+			}
+			for _, text := range strings.Split(string(lexer.src[start:lexer.pos]), "\n") {
+				text = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), "*"))
+				if text != "" {
+					comments = append(comments, text)
+				}
+			}
+			lexer.pos += 2
+		default:
+			token := lexer.scan()
+			token.comments = comments
+			lexer.last = token
+			return token
+		}
+	}
+	return &protoToken{line: lexer.line, comments: comments}
+}
+
+// peek 查看当前位置之后第n个字符
+func (lexer *protoLexer) peek(n int) rune {
+	if lexer.pos+n < len(lexer.src) {
+		return lexer.src[lexer.pos+n]
+	}
+	return 0
+}
+
+// scan 扫描标识符 数字 字符串或者符号
+func (lexer *protoLexer) scan() *protoToken {
+	start := lexer.pos
+	ch := lexer.src[lexer.pos]
+	token := &protoToken{line: lexer.line}
+	switch {
+	case unicode.IsLetter(ch) || ch == '_' || ch == '.':
+		// 标识符包含限定名 如 google.protobuf.Empty .foo.Bar
+		for lexer.pos < len(lexer.src) {
+			ch = lexer.src[lexer.pos]
+			if !(unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '_' || ch == '.') {
+				break
+			}
+			lexer.pos++
+		}
+		token.kind = 'i'
+	case unicode.IsDigit(ch) || ch == '-' || ch == '+':
+		lexer.pos++
+		for lexer.pos < len(lexer.src) {
+			ch = lexer.src[lexer.pos]
+			if !(unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '.') {
+				break
+			}
+			lexer.pos++
+		}
+		token.kind = 'n'
+	case ch == '"' || ch == '\'':
+		quote := ch
+		lexer.pos++
+		for lexer.pos < len(lexer.src) && lexer.src[lexer.pos] != quote {
+			if lexer.src[lexer.pos] == '\\' {
+				lexer.pos++
+			}
+			if lexer.pos < len(lexer.src) && lexer.src[lexer.pos] == '\n' {
+				lexer.errorf(token.line, "literal not terminated")
+			}
+			lexer.pos++
+		}
+		if lexer.pos >= len(lexer.src) {
+			lexer.errorf(token.line, "literal not terminated")
+		}
+		lexer.pos++
+		text, err := strconv.Unquote(`"` + strings.ReplaceAll(string(lexer.src[start+1:lexer.pos-1]), `"`, `\"`) + `"`)
+		if err != nil {
+			text = string(lexer.src[start+1 : lexer.pos-1])
+		}
+		token.kind = 's'
+		token.text = text
+		return token
+	default:
+		lexer.pos++
+		token.kind = ch
+	}
+	token.text = string(lexer.src[start:lexer.pos])
+	return token
+}
+
+// protoField 消息域
+type protoField struct {
+	name     string
+	typ      string // 类型名 map域为value类型
+	key      string // map域的key类型
+	label    string // repeated optional required
+	tag      int
+	oneof    string // 所属oneof
+	comments []string
+	line     int
+}
+
+// protoMessage 消息
+type protoMessage struct {
+	name     string // 完整名字 如 pkg.Outer.Inner
+	fields   []*protoField
+	comments []string
+	notes    []string // 不支持的结构说明
+}
+
+// protoEnumVal 枚举值
+type protoEnumVal struct {
+	name     string
+	value    int64
+	comments []string
+}
+
+// protoEnum 枚举
+type protoEnum struct {
+	name     string // 完整名字
+	values   []*protoEnumVal
+	comments []string
+}
+
+// protoRPC 服务函数
+type protoRPC struct {
+	name          string
+	input, output string
+	streaming     bool
+	comments      []string
+	line          int
+}
+
+// protoService 服务
+type protoService struct {
+	name     string // 完整名字
+	rpcs     []*protoRPC
+	comments []string
+}
+
+// protoSource 解析后的proto文件
+type protoSource struct {
+	filename string
+	pkg      string
+	imports  []string
+	messages []*protoMessage
+	enums    []*protoEnum
+	services []*protoService
+	notes    []string // 文件级别不支持的结构说明
+}
+
+// protoParser proto文件语法分析器 支持proto2/proto3常用子集
+type protoParser struct {
+	lexer *protoLexer
+	token *protoToken
+	file  *protoSource
+}
+
+// parseProto 解析proto文件内容
+func parseProto(filename string, content []byte) (file *protoSource, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(gserrors.GSError); ok {
+				err = e.(error)
+			} else {
+				err = fmt.Errorf("%v", e)
+			}
+		}
+	}()
+	parser := &protoParser{
+		lexer: &protoLexer{
+			filename: filename,
+			src:      []rune(string(content)),
+			line:     1,
+		},
+		file: &protoSource{filename: filename},
+	}
+	parser.token = parser.lexer.next()
+	parser.parseFile()
+	return parser.file, nil
+}
+
+// next 前进到下一个符号 返回当前符号
+func (parser *protoParser) next() *protoToken {
+	token := parser.token
+	parser.token = parser.lexer.next()
+	return token
+}
+
+// expect 期望当前符号为指定类型
+func (parser *protoParser) expect(kind rune, text string) *protoToken {
+	token := parser.next()
+	if token.kind != kind || (text != "" && token.text != text) {
+		if text == "" {
+			text = string(kind)
+		}
+		parser.lexer.errorf(token.line, "expect '%s', but got '%s'", text, token.text)
+	}
+	return token
+}
+
+// is 检查当前符号是否为指定关键字或者符号
+func (parser *protoParser) is(text string) bool {
+	return parser.token.text == text && (parser.token.kind == 'i' || len(text) == 1)
+}
+
+// skipStatement 跳过到语句结束 包括嵌套的大括号及方括号
+func (parser *protoParser) skipStatement() {
+	depth := 0
+	for {
+		token := parser.next()
+		switch token.kind {
+		case 0:
+			parser.lexer.errorf(token.line, "unexpected end of file")
+		case '{', '[', '(':
+			depth++
+		case '}', ']', ')':
+			depth--
+			if depth == 0 && token.kind == '}' {
+				return
+			}
+		case ';':
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// scoped 返回作用域内的完整名字
+func scoped(scope string, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// parseFile 解析文件
+func (parser *protoParser) parseFile() {
+	for parser.token.kind != 0 {
+		switch {
+		case parser.is("syntax"), parser.is("edition"), parser.is("option"):
+			parser.skipStatement()
+		case parser.is("package"):
+			parser.next()
+			parser.file.pkg = parser.expect('i', "").text
+			parser.expect(';', "")
+		case parser.is("import"):
+			parser.next()
+			if parser.is("public") || parser.is("weak") {
+				parser.next()
+			}
+			parser.file.imports = append(parser.file.imports, parser.expect('s', "").text)
+			parser.expect(';', "")
+		case parser.is("message"):
+			parser.parseMessage(parser.file.pkg)
+		case parser.is("enum"):
+			parser.parseEnum(parser.file.pkg)
+		case parser.is("service"):
+			parser.parseService()
+		case parser.is("extend"):
+			parser.file.notes = append(parser.file.notes,
+				fmt.Sprintf("line %d: extend is not supported", parser.token.line))
+			parser.skipStatement()
+		case parser.is(";"):
+			parser.next()
+		default:
+			parser.lexer.errorf(parser.token.line, "unexpected '%s'", parser.token.text)
+		}
+	}
+}
+
+// parseMessage 解析消息 嵌套的消息及枚举按完整名字平铺
+func (parser *protoParser) parseMessage(scope string) {
+	comments := parser.next().comments
+	message := &protoMessage{
+		name:     scoped(scope, parser.expect('i', "").text),
+		comments: comments,
+	}
+	parser.file.messages = append(parser.file.messages, message)
+	parser.expect('{', "")
+	parser.parseMessageBody(message, "")
+	parser.expect('}', "")
+}
+
+// parseMessageBody 解析消息体 oneof内的域所属oneof为oneof
+func (parser *protoParser) parseMessageBody(message *protoMessage, oneof string) {
+	for parser.token.kind != '}' && parser.token.kind != 0 {
+		switch {
+		case parser.is("message"):
+			parser.parseMessage(message.name)
+		case parser.is("enum"):
+			parser.parseEnum(message.name)
+		case parser.is("oneof"):
+			parser.next()
+			name := parser.expect('i', "").text
+			parser.expect('{', "")
+			parser.parseMessageBody(message, name)
+			parser.expect('}', "")
+		case parser.is("option"), parser.is("reserved"), parser.is("extensions"):
+			parser.skipStatement()
+		case parser.is("extend"), parser.is("group"):
+			message.notes = append(message.notes,
+				fmt.Sprintf("line %d: %s is not supported", parser.token.line, parser.token.text))
+			parser.skipStatement()
+		case parser.is(";"):
+			parser.next()
+		default:
+			message.fields = append(message.fields, parser.parseField(oneof))
+		}
+	}
+}
+
+// parseField 解析单个域
+func (parser *protoParser) parseField(oneof string) *protoField {
+	field := &protoField{
+		oneof:    oneof,
+		comments: parser.token.comments,
+		line:     parser.token.line,
+	}
+	if parser.is("repeated") || parser.is("optional") || parser.is("required") {
+		field.label = parser.next().text
+	}
+	if parser.is("map") {
+		parser.next()
+		parser.expect('<', "")
+		field.key = parser.expect('i', "").text
+		parser.expect(',', "")
+		field.typ = parser.expect('i', "").text
+		parser.expect('>', "")
+	} else {
+		field.typ = parser.expect('i', "").text
+	}
+	field.name = parser.expect('i', "").text
+	parser.expect('=', "")
+	tag := parser.expect('n', "")
+	val, err := strconv.ParseInt(tag.text, 0, 32)
+	if err != nil {
+		parser.lexer.errorf(tag.line, "invalid field number '%s'", tag.text)
+	}
+	field.tag = int(val)
+	if parser.token.kind == '[' {
+		for parser.next().kind != ']' {
+			if parser.token.kind == 0 {
+				parser.lexer.errorf(tag.line, "field options not terminated")
+			}
+		}
+	}
+	field.comments = append(field.comments, parser.expect(';', "").trailing...)
+	return field
+}
+
+// parseEnum 解析枚举
+func (parser *protoParser) parseEnum(scope string) {
+	comments := parser.next().comments
+	enum := &protoEnum{
+		name:     scoped(scope, parser.expect('i', "").text),
+		comments: comments,
+	}
+	parser.file.enums = append(parser.file.enums, enum)
+	parser.expect('{', "")
+	for parser.token.kind != '}' && parser.token.kind != 0 {
+		switch {
+		case parser.is("option"), parser.is("reserved"):
+			parser.skipStatement()
+		case parser.is(";"):
+			parser.next()
+		default:
+			val := &protoEnumVal{comments: parser.token.comments}
+			val.name = parser.expect('i', "").text
+			parser.expect('=', "")
+			number := parser.expect('n', "")
+			value, err := strconv.ParseInt(number.text, 0, 32)
+			if err != nil {
+				parser.lexer.errorf(number.line, "invalid enum value '%s'", number.text)
+			}
+			val.value = value
+			if parser.token.kind == '[' {
+				for parser.next().kind != ']' {
+					if parser.token.kind == 0 {
+						parser.lexer.errorf(number.line, "enum value options not terminated")
+					}
+				}
+			}
+			val.comments = append(val.comments, parser.expect(';', "").trailing...)
+			enum.values = append(enum.values, val)
+		}
+	}
+	parser.expect('}', "")
+}
+
+// parseService 解析服务
+func (parser *protoParser) parseService() {
+	comments := parser.next().comments
+	service := &protoService{
+		name:     scoped(parser.file.pkg, parser.expect('i', "").text),
+		comments: comments,
+	}
+	parser.file.services = append(parser.file.services, service)
+	parser.expect('{', "")
+	for parser.token.kind != '}' && parser.token.kind != 0 {
+		switch {
+		case parser.is("rpc"):
+			rpc := &protoRPC{
+				comments: parser.token.comments,
+				line:     parser.token.line,
+			}
+			parser.next()
+			rpc.name = parser.expect('i', "").text
+			parser.expect('(', "")
+			if parser.is("stream") {
+				parser.next()
+				rpc.streaming = true
+			}
+			rpc.input = parser.expect('i', "").text
+			parser.expect(')', "")
+			parser.expect('i', "returns")
+			parser.expect('(', "")
+			if parser.is("stream") {
+				parser.next()
+				rpc.streaming = true
+			}
+			rpc.output = parser.expect('i', "").text
+			parser.expect(')', "")
+			if parser.token.kind == '{' {
+				parser.skipStatement()
+			} else {
+				rpc.comments = append(rpc.comments, parser.expect(';', "").trailing...)
+			}
+			service.rpcs = append(service.rpcs, rpc)
+		case parser.is("option"):
+			parser.skipStatement()
+		case parser.is(";"):
+			parser.next()
+		default:
+			parser.lexer.errorf(parser.token.line, "unexpected '%s' in service", parser.token.text)
+		}
+	}
+	parser.expect('}', "")
+}
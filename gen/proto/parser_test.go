@@ -0,0 +1,173 @@
+// @file 	parser_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	parser_test
+
+package proto
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// shopProto 解析及导入测试使用的proto文件
+const shopProto = `syntax = "proto3";
+
+package demo.shop;
+
+option go_package = "example.com/shop";
+
+import "google/protobuf/empty.proto";
+import public "demo/base.proto";
+
+// 孤立注释
+
+// 物品
+message Item {
+  // 编号
+  uint64 item_id = 1;
+  repeated string tags = 2 [packed = true]; // 标签
+  map<string, int32> counts = 4;
+  Kind kind = 5;
+  bytes data = 6;
+  reserved 3;
+  oneof target {
+    string name = 7;
+    Inner inner = 8;
+  }
+  /* 内嵌
+   * 消息 */
+  message Inner {
+    sint64 value = 1;
+  }
+  enum Kind {
+    KIND_NONE = 0;
+    KIND_SOME = 1 [deprecated = true]; // 一些
+  }
+  extensions 100 to 200;
+}
+
+enum Status {
+  option allow_alias = true;
+  STATUS_OK = 0;
+  STATUS_BAD = -1;
+}
+
+extend Item {
+  string extra = 101;
+}
+
+// 商店
+service Shop {
+  // 查询
+  rpc Get(Item) returns (Item);
+  rpc Ping(google.protobuf.Empty) returns (.google.protobuf.Empty) {
+    option deprecated = true;
+  }
+  rpc Watch(Item) returns (stream Item);
+}
+`
+
+func TestParseProto(t *testing.T) {
+	file, err := parseProto("shop.proto", []byte(shopProto))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.pkg != "demo.shop" || !reflect.DeepEqual(file.imports, []string{"google/protobuf/empty.proto", "demo/base.proto"}) {
+		t.Errorf("pkg = %s imports = %v", file.pkg, file.imports)
+	}
+	if len(file.notes) != 1 || !strings.Contains(file.notes[0], "extend is not supported") {
+		t.Errorf("notes = %v", file.notes)
+	}
+	// 嵌套的消息及枚举使用完整名字
+	var names []string
+	for _, message := range file.messages {
+		names = append(names, message.name)
+	}
+	for _, enum := range file.enums {
+		names = append(names, enum.name)
+	}
+	want := []string{"demo.shop.Item", "demo.shop.Item.Inner", "demo.shop.Item.Kind", "demo.shop.Status"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("names = %v want %v", names, want)
+	}
+	item := file.messages[0]
+	if !reflect.DeepEqual(item.comments, []string{"物品"}) || len(item.notes) != 0 {
+		t.Errorf("Item comments = %q notes = %v", item.comments, item.notes)
+	}
+	var fields []string
+	for _, field := range item.fields {
+		fields = append(fields, strings.TrimSpace(strings.Join([]string{
+			field.label, field.key, field.typ, field.name, field.oneof, strings.Join(field.comments, ","),
+		}, " ")))
+	}
+	wantFields := []string{
+		"uint64 item_id  编号",
+		"repeated  string tags  标签",
+		"string int32 counts",
+		"Kind kind",
+		"bytes data",
+		"string name target",
+		"Inner inner target",
+	}
+	if !reflect.DeepEqual(fields, wantFields) {
+		t.Errorf("fields = %q\nwant %q", fields, wantFields)
+	}
+	if item.fields[2].tag != 4 || item.fields[6].tag != 8 || item.fields[0].line != 15 {
+		t.Errorf("tags = %d %d line %d", item.fields[2].tag, item.fields[6].tag, item.fields[0].line)
+	}
+	if inner := file.messages[1]; !reflect.DeepEqual(inner.comments, []string{"内嵌", "消息"}) {
+		t.Errorf("Inner comments = %q", inner.comments)
+	}
+	kind := file.enums[0]
+	if len(kind.values) != 2 || kind.values[1].name != "KIND_SOME" || kind.values[1].value != 1 ||
+		!reflect.DeepEqual(kind.values[1].comments, []string{"一些"}) {
+		t.Errorf("Kind = %+v", kind.values)
+	}
+	if status := file.enums[1]; len(status.values) != 2 || status.values[1].value != -1 {
+		t.Errorf("Status = %+v", status.values)
+	}
+	// 服务
+	if len(file.services) != 1 {
+		t.Fatalf("services = %d", len(file.services))
+	}
+	service := file.services[0]
+	if service.name != "demo.shop.Shop" || !reflect.DeepEqual(service.comments, []string{"商店"}) || len(service.rpcs) != 3 {
+		t.Fatalf("service = %+v", service)
+	}
+	get, ping, watch := service.rpcs[0], service.rpcs[1], service.rpcs[2]
+	if get.input != "Item" || get.output != "Item" || get.streaming || !reflect.DeepEqual(get.comments, []string{"查询"}) {
+		t.Errorf("Get = %+v", get)
+	}
+	if ping.input != "google.protobuf.Empty" || ping.output != ".google.protobuf.Empty" || ping.streaming {
+		t.Errorf("Ping = %+v", ping)
+	}
+	if !watch.streaming {
+		t.Errorf("Watch = %+v", watch)
+	}
+}
+
+func TestParseProtoErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"message A {\n  int32 a = 1;\n", "a.proto(3): expect '}'"},
+		{"/* never closed", "a.proto(1): comment not terminated"},
+		{"import \"a.proto;\n", "a.proto(1): literal not terminated"},
+		{"message A {\n  int32 a = x;\n}", "a.proto(2): expect 'n'"},
+		{"message A {\n  int32 a = 99999999999;\n}", "a.proto(2): invalid field number '99999999999'"},
+		{"enum E {\n  A = 1x;\n}", "a.proto(2): invalid enum value '1x'"},
+		{"message A {\n  int32 a = 1 [deprecated = true;\n", "field options not terminated"},
+		{"service S {\n  message A {}\n}", "a.proto(2): unexpected 'message' in service"},
+		{"option (x) = {", "a.proto(1): unexpected end of file"},
+		{"garbage", "a.proto(1): unexpected 'garbage'"},
+	}
+	for _, tc := range tests {
+		_, err := parseProto("a.proto", []byte(tc.source))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("parseProto(%q) error = %v, want %q", tc.source, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+// @file 	proto.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	proto
+
+package proto
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/gen"
+)
+
+func init() {
+	gen.Register(New())
+}
+
+// scalars 内置类型对应的protobuf标量类型 note非空表示类型被放宽
+var scalars = map[rune]struct {
+	name string
+	note string
+}{
+	gslang.KeyByte:    {"uint32", "gslang byte (8-bit unsigned) widened to uint32"},
+	gslang.KeySByte:   {"int32", "gslang sbyte (8-bit signed) widened to int32"},
+	gslang.KeyInt16:   {"int32", "gslang int16 widened to int32"},
+	gslang.KeyUInt16:  {"uint32", "gslang uint16 widened to uint32"},
+	gslang.KeyInt32:   {"int32", ""},
+	gslang.KeyUInt32:  {"uint32", ""},
+	gslang.KeyInt64:   {"int64", ""},
+	gslang.KeyUInt64:  {"uint64", ""},
+	gslang.KeyFloat32: {"float", ""},
+	gslang.KeyFloat64: {"double", ""},
+	gslang.KeyBool:    {"bool", ""},
+	gslang.KeyString:  {"string", ""},
+}
+
+// protobuf枚举值的取值范围
+var (
+	minProtoEnum = big.NewInt(math.MinInt32)
+	maxProtoEnum = big.NewInt(math.MaxInt32)
+)
+
+// mapKeys protobuf允许作为map key的标量类型
+var mapKeys = map[string]bool{
+	"int32": true, "int64": true, "uint32": true, "uint64": true, "bool": true, "string": true,
+}
+
+// Generator protobuf代码生成器 每个包生成一个proto3文件
+// Table/Struct -> message Enum -> enum List -> repeated Map -> map<,> Contract -> service
+type Generator struct{}
+
+// New 新建protobuf代码生成器
+func New() *Generator {
+	return &Generator{}
+}
+
+// Name 实现gen.Generator接口
+func (generator *Generator) Name() string {
+	return "proto"
+}
+
+// Options 实现gen.Generator接口
+func (generator *Generator) Options() []*gen.Option {
+	return []*gen.Option{
+		{Name: "strict", Default: "false", Usage: "treat unsupported or widened constructs as errors"},
+		{Name: "go_package", Usage: "go_package option prefix, the package name is appended"},
+	}
+}
+
+// Generate 实现gen.Generator接口
+func (generator *Generator) Generate(ctx context.Context, req *gen.Request) ([]*gen.File, error) {
+	var files []*gen.File
+	var problems []string
+	for _, pkg := range req.Packages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		file := newProtoFile(pkg, req.Option("go_package", ""))
+		file.generate()
+		problems = append(problems, file.problems...)
+		files = append(files, gen.NewFile(FileName(pkg.Name()), file.bytes()))
+	}
+	if req.Option("strict", "false") == "true" && len(problems) > 0 {
+		return nil, gserrors.Newf(gen.ErrGen, "proto generator found unsupported constructs:\n\t%s",
+			strings.Join(problems, "\n\t"))
+	}
+	return files, nil
+}
+
+// PackageName 返回gslang包对应的protobuf包名 如 skea3344/foo -> skea3344.foo
+func PackageName(name string) string {
+	return strings.NewReplacer("/", ".", "-", "_").Replace(name)
+}
+
+// FileName 返回gslang包对应的proto文件名 如 skea3344/foo -> skea3344/foo/foo.proto
+func FileName(name string) string {
+	return name + "/" + path.Base(name) + ".proto"
+}
+
+// protoFile 单个包对应的proto文件
+type protoFile struct {
+	pkg       *ast.Package    // 对应的gslang包
+	goPackage string          // go_package前缀
+	imports   map[string]bool // 引用的其他proto文件
+	body      bytes.Buffer    // 文件内容
+	problems  []string        // 不支持或者被放宽的结构
+}
+
+// newProtoFile 新建包对应的proto文件
+func newProtoFile(pkg *ast.Package, goPackage string) *protoFile {
+	return &protoFile{
+		pkg:       pkg,
+		goPackage: goPackage,
+		imports:   make(map[string]bool),
+	}
+}
+
+// printf 向文件内容写入格式化文本
+func (file *protoFile) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&file.body, format, args...)
+}
+
+// report 记录不支持或者被放宽的结构 返回用于注释的说明文本
+func (file *protoFile) report(node ast.Node, format string, args ...interface{}) string {
+	msg := fmt.Sprintf(format, args...)
+	file.problems = append(file.problems, fmt.Sprintf("%s: %s", gslang.Pos(node), msg))
+	return msg
+}
+
+// comments 输出节点的注释
+func (file *protoFile) comments(node ast.Node, indent string) {
+	for _, line := range gen.Comments(node) {
+		file.printf("%s// %s\n", indent, line)
+	}
+}
+
+// bytes 返回完整的proto文件内容
+func (file *protoFile) bytes() []byte {
+	var buff bytes.Buffer
+	fmt.Fprintf(&buff, "// generated by gslang proto generator from package %s. DO NOT EDIT.\n\n", file.pkg.Name())
+	buff.WriteString("syntax = \"proto3\";\n\n")
+	fmt.Fprintf(&buff, "package %s;\n\n", PackageName(file.pkg.Name()))
+	if file.goPackage != "" {
+		fmt.Fprintf(&buff, "option go_package = \"%s\";\n\n", path.Join(file.goPackage, file.pkg.Name()))
+	}
+	var imports []string
+	for name := range file.imports {
+		imports = append(imports, name)
+	}
+	sort.Strings(imports)
+	for _, name := range imports {
+		fmt.Fprintf(&buff, "import \"%s\";\n", name)
+	}
+	if len(imports) > 0 {
+		buff.WriteString("\n")
+	}
+	buff.Write(bytes.TrimRight(file.body.Bytes(), "\n"))
+	buff.WriteString("\n")
+	return buff.Bytes()
+}
+
+// generate 按声明顺序生成包内所有类型
+func (file *protoFile) generate() {
+	for _, expr := range gslang.Types(file.pkg) {
+		switch node := expr.(type) {
+		case *ast.Table:
+			file.message(node)
+		case *ast.Enum:
+			file.enum(node)
+		case *ast.Contract:
+			file.service(node)
+		}
+	}
+}
+
+// typeName 返回被引用类型在当前文件中的名字 其他包的类型需要引用对应的proto文件
+func (file *protoFile) typeName(target ast.Expr) string {
+	pkg := target.Package()
+	if pkg == nil || pkg == file.pkg {
+		return target.Name()
+	}
+	file.imports[FileName(pkg.Name())] = true
+	return "." + PackageName(pkg.Name()) + "." + target.Name()
+}
+
+// fieldType 返回类型表达式对应的protobuf类型 及是否为repeated 和放宽说明
+// 不支持的类型返回错误说明
+func (file *protoFile) fieldType(node ast.Node, expr ast.Expr) (name string, repeated bool, note string, err string) {
+	if key, ok := gslang.Builtin(expr); ok {
+		scalar := scalars[key]
+		note = scalar.note
+		if note != "" {
+			file.report(node, "%s", note)
+		}
+		return scalar.name, false, note, ""
+	}
+	switch typ := expr.(type) {
+	case *ast.TypeRef:
+		switch target := typ.Ref.(type) {
+		case *ast.Table, *ast.Enum:
+			return file.typeName(target), false, "", ""
+		case nil:
+			return "", false, "", fmt.Sprintf("unlinked type %s", typ)
+		default:
+			return "", false, "", fmt.Sprintf("type %s can not be used as message field", gslang.TypeName(target))
+		}
+	case *ast.List:
+		name, _, note, err = file.fieldType(node, typ.Element)
+		return name, true, note, err
+	case *ast.Array:
+		name, _, note, err = file.fieldType(node, typ.Element)
+		if err != "" {
+			return
+		}
+		fixed := file.report(node, "fixed array length %d is not enforced by protobuf", typ.Length)
+		if note != "" {
+			fixed = note + "; " + fixed
+		}
+		return name, true, fixed, ""
+	case *ast.Map:
+		key, _, keyNote, keyErr := file.fieldType(node, typ.Key)
+		if keyErr != "" {
+			return "", false, "", keyErr
+		}
+		if !mapKeys[key] {
+			return "", false, "", fmt.Sprintf("%s can not be used as protobuf map key", gslang.TypeName(typ.Key))
+		}
+		value, _, valueNote, valueErr := file.fieldType(node, typ.Value)
+		if valueErr != "" {
+			return "", false, "", valueErr
+		}
+		var notes []string
+		for _, n := range []string{keyNote, valueNote} {
+			if n != "" {
+				notes = append(notes, n)
+			}
+		}
+		return fmt.Sprintf("map<%s, %s>", key, value), false, strings.Join(notes, "; "), ""
+	}
+	return "", false, "", fmt.Sprintf("unsupported type %s", gslang.TypeName(expr))
+}
+
+// field 输出单个message域 tag为ID加1
+func (file *protoFile) field(node ast.Node, name string, tag int, expr ast.Expr) {
+	typ, repeated, note, err := file.fieldType(node, expr)
+	if err != "" {
+		file.report(node, "%s", err)
+		file.printf("  // unsupported: %s = %d (%s)\n", name, tag, err)
+		return
+	}
+	if repeated {
+		typ = "repeated " + typ
+	}
+	file.printf("  %s %s = %d;", typ, name, tag)
+	if note != "" {
+		file.printf(" // %s", note)
+	}
+	file.printf("\n")
+}
+
+// message 生成表或者结构体对应的message
+func (file *protoFile) message(table *ast.Table) {
+	file.comments(table, "")
+	file.printf("message %s {\n", table.Name())
+	for _, field := range table.Fields {
+		file.comments(field, "  ")
+		file.field(field, gen.SnakeCase(field.Name()), int(field.ID)+1, field.Type)
+	}
+	file.printf("}\n\n")
+}
+
+// enum 生成枚举 proto3要求第一个枚举值为0 没有0值的枚举会补充 <ENUM>_UNSPECIFIED
+func (file *protoFile) enum(enum *ast.Enum) {
+	prefix := gen.ScreamingCase(enum.Name()) + "_"
+	vals := gslang.EnumVals(enum)
+	file.comments(enum, "")
+	file.printf("enum %s {\n", enum.Name())
+	zero := false
+	for _, val := range vals {
+		if val.Value == 0 {
+			zero = true
+		}
+	}
+	if !zero {
+		note := file.report(enum, "enum %s has no zero value, %sUNSPECIFIED added", enum, prefix)
+		file.printf("  %sUNSPECIFIED = 0; // %s\n", prefix, note)
+	}
+	// 0值必须排在第一个
+	sort.SliceStable(vals, func(i, j int) bool {
+		return vals[i].Value == 0 && vals[j].Value != 0
+	})
+	for _, val := range vals {
+		file.comments(val, "  ")
+		name := prefix + gen.ScreamingCase(val.Name())
+		if value := gslang.EnumValue(val); value.Cmp(minProtoEnum) < 0 || value.Cmp(maxProtoEnum) > 0 {
+			err := file.report(val, "enum value %s(%s) out of protobuf enum range", val, value)
+			file.printf("  // unsupported: %s = %s (%s)\n", name, value, err)
+			continue
+		}
+		file.printf("  %s = %d;\n", name, val.Value)
+	}
+	file.printf("}\n\n")
+}
+
+// messageParam 如果参数列表只有一个表或者结构体参数 则直接返回该类型名
+func (file *protoFile) messageParam(params []*ast.Param) (string, bool) {
+	if len(params) != 1 {
+		return "", false
+	}
+	if _, ok := gslang.Builtin(params[0].Type); ok {
+		return "", false
+	}
+	if ref, ok := params[0].Type.(*ast.TypeRef); ok {
+		if table, ok := ref.Ref.(*ast.Table); ok {
+			return file.typeName(table), true
+		}
+	}
+	return "", false
+}
+
+// wrapper 为参数列表生成包装message
+func (file *protoFile) wrapper(name string, prefix string, params []*ast.Param) {
+	file.printf("message %s {\n", name)
+	for _, param := range params {
+		file.comments(param, "  ")
+		file.field(param, fmt.Sprintf("%s%d", prefix, param.ID), param.ID+1, param.Type)
+	}
+	file.printf("}\n\n")
+}
+
+// service 生成协议对应的service 多参数或者非message参数生成包装message
+func (file *protoFile) service(contract *ast.Contract) {
+	var rpcs bytes.Buffer
+	for _, method := range gslang.Methods(contract) {
+		for _, line := range gen.Comments(method) {
+			fmt.Fprintf(&rpcs, "  // %s\n", line)
+		}
+		request, ok := file.messageParam(method.Params)
+		if !ok {
+			request = contract.Name() + gen.PascalCase(method.Name()) + "Request"
+			file.wrapper(request, "arg", method.Params)
+		}
+		response, ok := file.messageParam(method.Return)
+		if !ok {
+			response = contract.Name() + gen.PascalCase(method.Name()) + "Response"
+			file.wrapper(response, "ret", method.Return)
+		}
+		fmt.Fprintf(&rpcs, "  rpc %s(%s) returns (%s); // gslang method id %d\n",
+			gen.PascalCase(method.Name()), request, response, method.ID)
+	}
+	file.comments(contract, "")
+	for _, base := range contract.Bases {
+		file.printf("// inherits %s\n", gslang.TypeName(base))
+	}
+	file.printf("service %s {\n", contract.Name())
+	file.body.Write(rpcs.Bytes())
+	file.printf("}\n\n")
+}
@@ -0,0 +1,257 @@
+// @file 	proto_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	proto_test
+
+package proto
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/gen"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// exportFiles 导出测试使用的代码 demo/shop引用demo/base
+var exportFiles = map[string]string{
+	"demo/base/base.gs": `
+// 坐标
+struct Point {
+    X int32;
+    Y int32;
+}
+`,
+	"demo/shop/shop.gs": `
+import "demo/base"
+
+// 物品
+table Item {
+    // 编号
+    ItemID uint64;
+    Count byte;
+    Pos base.Point;
+    Tags []string;
+    Slots [4]int16;
+    Prices map[string]float64;
+}
+
+// 颜色
+enum Color(byte) {
+    Red(1),
+    // 绿色
+    Green(2)
+}
+
+enum Status(int32) {
+    Unknown(0), Fine(1)
+}
+
+enum Huge(int64) {
+    Small(1), Large(0x100000000)
+}
+
+contract Base {
+    // 心跳
+    Ping();
+}
+
+// 商店
+contract Shop(Base) {
+    Get(Item) -> (Item);
+    Find(id uint64, name string) -> (Item, Status);
+}
+`,
+}
+
+// export 编译并导出测试代码
+func export(t *testing.T, files map[string]string, options map[string]string, pkgs ...string) ([]*gen.File, error) {
+	cs := gstest.Compile(t, files, pkgs...)
+	var loaded []*ast.Package
+	for _, name := range pkgs {
+		loaded = append(loaded, cs.Loaded[name])
+	}
+	return New().Generate(context.Background(), &gen.Request{Packages: loaded, Options: options})
+}
+
+// wantShop demo/shop包的导出结果
+const wantShop = `// generated by gslang proto generator from package demo/shop. DO NOT EDIT.
+
+syntax = "proto3";
+
+package demo.shop;
+
+option go_package = "example.com/pb/demo/shop";
+
+import "demo/base/base.proto";
+
+// 物品
+message Item {
+  // 编号
+  uint64 item_id = 1;
+  uint32 count = 2; // gslang byte (8-bit unsigned) widened to uint32
+  .demo.base.Point pos = 3;
+  repeated string tags = 4;
+  repeated int32 slots = 5; // gslang int16 widened to int32; fixed array length 4 is not enforced by protobuf
+  map<string, double> prices = 6;
+}
+
+// 颜色
+enum Color {
+  COLOR_UNSPECIFIED = 0; // enum Color has no zero value, COLOR_UNSPECIFIED added
+  COLOR_RED = 1;
+  // 绿色
+  COLOR_GREEN = 2;
+}
+
+enum Status {
+  STATUS_UNKNOWN = 0;
+  STATUS_FINE = 1;
+}
+
+enum Huge {
+  HUGE_UNSPECIFIED = 0; // enum Huge has no zero value, HUGE_UNSPECIFIED added
+  HUGE_SMALL = 1;
+  // unsupported: HUGE_LARGE = 4294967296 (enum value Large(4294967296) out of protobuf enum range)
+}
+
+message BasePingRequest {
+}
+
+message BasePingResponse {
+}
+
+service Base {
+  // 心跳
+  rpc Ping(BasePingRequest) returns (BasePingResponse); // gslang method id 0
+}
+
+message ShopPingRequest {
+}
+
+message ShopPingResponse {
+}
+
+message ShopFindRequest {
+  uint64 arg0 = 1;
+  string arg1 = 2;
+}
+
+message ShopFindResponse {
+  Item ret0 = 1;
+  Status ret1 = 2;
+}
+
+// 商店
+// inherits demo/shop.Base
+service Shop {
+  // 心跳
+  rpc Ping(ShopPingRequest) returns (ShopPingResponse); // gslang method id 0
+  rpc Get(Item) returns (Item); // gslang method id 1
+  rpc Find(ShopFindRequest) returns (ShopFindResponse); // gslang method id 2
+}
+`
+
+// wantBase demo/base包的导出结果 没有设置go_package
+const wantBase = `// generated by gslang proto generator from package demo/base. DO NOT EDIT.
+
+syntax = "proto3";
+
+package demo.base;
+
+// 坐标
+message Point {
+  int32 x = 1;
+  int32 y = 2;
+}
+`
+
+func TestExport(t *testing.T) {
+	files, err := export(t, exportFiles, map[string]string{"go_package": "example.com/pb"}, "demo/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "demo/shop/shop.proto" {
+		t.Fatalf("files = %v", files)
+	}
+	if got := string(files[0].Content); got != wantShop {
+		t.Errorf("demo/shop:\n%s\nwant\n%s", got, wantShop)
+	}
+	files, err = export(t, exportFiles, nil, "demo/base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(files[0].Content); files[0].Name != "demo/base/base.proto" || got != wantBase {
+		t.Errorf("%s:\n%s\nwant\n%s", files[0].Name, got, wantBase)
+	}
+}
+
+func TestExportStrict(t *testing.T) {
+	// 没有放宽及不支持的结构时 严格模式正常导出
+	files, err := export(t, exportFiles, map[string]string{"strict": "true"}, "demo/base")
+	if err != nil || len(files) != 1 {
+		t.Fatalf("strict demo/base = %v, %v", files, err)
+	}
+	_, err = export(t, exportFiles, map[string]string{"strict": "true"}, "demo/shop")
+	if err == nil {
+		t.Fatal("strict demo/shop succeeded")
+	}
+	for _, want := range []string{
+		"shop.gs(8:5): gslang byte (8-bit unsigned) widened to uint32",
+		"gslang int16 widened to int32",
+		"fixed array length 4 is not enforced by protobuf",
+		"enum Color has no zero value, COLOR_UNSPECIFIED added",
+		"enum value Large(4294967296) out of protobuf enum range",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("strict error missing %q:\n%s", want, err)
+		}
+	}
+}
+
+func TestExportUnsupported(t *testing.T) {
+	files := map[string]string{
+		"demo/bad/bad.gs": `
+enum Kind(int32) {
+    None(0)
+}
+
+table Bad {
+    ByKind map[Kind]string;
+    ByFloat map[float32]string;
+    Fine int32;
+}
+`,
+	}
+	got, err := export(t, files, nil, "demo/bad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `message Bad {
+  // unsupported: by_kind = 1 (demo/bad.Kind can not be used as protobuf map key)
+  // unsupported: by_float = 2 (float32 can not be used as protobuf map key)
+  int32 fine = 3;
+}`
+	if !strings.Contains(string(got[0].Content), want) {
+		t.Errorf("content:\n%s\nwant\n%s", got[0].Content, want)
+	}
+	if _, err := export(t, files, map[string]string{"strict": "true"}, "demo/bad"); err == nil || !strings.Contains(err.Error(), "bad.gs(7:5)") {
+		t.Errorf("strict error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New().Generate(ctx, &gen.Request{Packages: []*ast.Package{gstest.Compile(t, files, "demo/bad").Loaded["demo/bad"]}}); err != context.Canceled {
+		t.Errorf("canceled error = %v", err)
+	}
+}
+
+func TestNames(t *testing.T) {
+	if got := PackageName("skea3344/my-pkg"); got != "skea3344.my_pkg" {
+		t.Errorf("PackageName = %s", got)
+	}
+	if got := FileName("skea3344/foo"); got != "skea3344/foo/foo.proto" {
+		t.Errorf("FileName = %s", got)
+	}
+}
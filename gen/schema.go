@@ -0,0 +1,357 @@
+// @file 	schema.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	schema
+
+package gen
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// 类型种类
+const (
+	KindBuiltin  = "builtin"  // 内置数据类型
+	KindTable    = "table"    // 表
+	KindStruct   = "struct"   // 结构体
+	KindEnum     = "enum"     // 枚举
+	KindContract = "contract" // 协议
+	KindList     = "list"     // 切片
+	KindArray    = "array"    // 数组
+	KindMap      = "map"      // 字典
+)
+
+// Schema 已连接的包的可序列化描述 用于外部插件及模板
+type Schema struct {
+	Packages []*Package `json:"packages"`
+}
+
+// Package 包描述
+type Package struct {
+	Name    string   `json:"name"`              // 包名
+	Imports []string `json:"imports,omitempty"` // 引用的其他包 不包括自动引用的gslang包
+	Attrs   []*Attr  `json:"attrs,omitempty"`   // 包属性
+	Types   []*Type  `json:"types"`             // 按代码文件及声明顺序排列的类型
+}
+
+// Type 类型描述 根据Kind不同 使用不同的字段
+type Type struct {
+	Kind     string          `json:"kind"`     // table struct enum contract
+	Name     string          `json:"name"`     // 类型名
+	FullName string          `json:"fullName"` // 包名.类型名
+	Script   string          `json:"script"`   // 声明所在代码文件
+	Pos      gslang.Position `json:"pos"`      // 声明位置
+	Comments []string        `json:"comments,omitempty"`
+	Attrs    []*Attr         `json:"attrs,omitempty"`
+	Fields   []*Field        `json:"fields,omitempty"`  // 表及结构体的域
+	Values   []*EnumVal      `json:"values,omitempty"`  // 枚举值
+	Length   uint            `json:"length,omitempty"`  // 枚举类型长度
+	Signed   bool            `json:"signed,omitempty"`  // 枚举值是否有符号
+	Error    bool            `json:"error,omitempty"`   // 是否为错误枚举
+	Bases    []string        `json:"bases,omitempty"`   // 协议的父协议
+	Methods  []*Method       `json:"methods,omitempty"` // 展开后按ID排序的协议函数
+}
+
+// Field 域描述
+type Field struct {
+	Name     string          `json:"name"`
+	ID       uint16          `json:"id"`
+	Type     *TypeExpr       `json:"type"`
+	Pos      gslang.Position `json:"pos"`
+	Comments []string        `json:"comments,omitempty"`
+	Attrs    []*Attr         `json:"attrs,omitempty"`
+}
+
+// EnumVal 枚举值描述
+type EnumVal struct {
+	Name     string          `json:"name"`
+	Value    *big.Int        `json:"value"` // 实际数值 无符号64位枚举的值可能大于MaxInt64
+	Pos      gslang.Position `json:"pos"`
+	Comments []string        `json:"comments,omitempty"`
+	Attrs    []*Attr         `json:"attrs,omitempty"`
+}
+
+// Method 协议函数描述
+type Method struct {
+	Name     string          `json:"name"`
+	ID       uint16          `json:"id"`
+	Params   []*Param        `json:"params,omitempty"`
+	Return   []*Param        `json:"return,omitempty"`
+	Pos      gslang.Position `json:"pos"`
+	Comments []string        `json:"comments,omitempty"`
+	Attrs    []*Attr         `json:"attrs,omitempty"`
+}
+
+// Param 函数参数描述
+type Param struct {
+	ID       int       `json:"id"`
+	Type     *TypeExpr `json:"type"`
+	Comments []string  `json:"comments,omitempty"`
+	Attrs    []*Attr   `json:"attrs,omitempty"`
+}
+
+// TypeExpr 类型表达式描述 引用类型的Kind为被引用类型的种类
+type TypeExpr struct {
+	Kind     string    `json:"kind"`              // builtin table struct enum contract list array map
+	Name     string    `json:"name"`              // 内置类型为关键字 引用类型为类型名
+	FullName string    `json:"fullName"`          // 规范名字 见gslang.TypeName
+	Package  string    `json:"package,omitempty"` // 引用类型所属包
+	Length   uint16    `json:"length,omitempty"`  // 数组长度
+	Element  *TypeExpr `json:"element,omitempty"` // 切片及数组的元素类型
+	Key      *TypeExpr `json:"key,omitempty"`     // 字典key类型
+	Value    *TypeExpr `json:"value,omitempty"`   // 字典value类型
+}
+
+// Attr 属性描述 参数按属性类型的域名字解析求值
+type Attr struct {
+	Type string                 `json:"type"` // 属性类型的规范名字
+	Name string                 `json:"name"` // 属性类型名
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// NewSchema 根据已连接的包生成可序列化描述
+func NewSchema(pkgs []*ast.Package) *Schema {
+	schema := &Schema{}
+	for _, pkg := range pkgs {
+		schema.Packages = append(schema.Packages, newPackage(pkg))
+	}
+	return schema
+}
+
+// Package 在描述中查找指定名字的包
+func (schema *Schema) Package(name string) (*Package, bool) {
+	for _, pkg := range schema.Packages {
+		if pkg.Name == name {
+			return pkg, true
+		}
+	}
+	return nil, false
+}
+
+// Type 在包描述中查找指定名字的类型
+func (pkg *Package) Type(name string) (*Type, bool) {
+	for _, typ := range pkg.Types {
+		if typ.Name == name {
+			return typ, true
+		}
+	}
+	return nil, false
+}
+
+// CommentText 将注释列表拼接成一段文本
+func CommentText(comments []string) string {
+	return strings.Join(comments, "\n")
+}
+
+// newPackage 生成包描述
+func newPackage(pkg *ast.Package) *Package {
+	result := &Package{
+		Name:  pkg.Name(),
+		Attrs: newAttrs(pkg),
+	}
+	imports := make(map[string]bool)
+	for _, script := range pkg.Scripts {
+		for _, ref := range script.Imports {
+			if ref.Ref != nil && ref.Ref.Name() != gslang.GSLangPackage {
+				imports[ref.Ref.Name()] = true
+			}
+		}
+	}
+	for name := range imports {
+		result.Imports = append(result.Imports, name)
+	}
+	sort.Strings(result.Imports)
+	for _, expr := range gslang.Types(pkg) {
+		if typ := newType(expr); typ != nil {
+			result.Types = append(result.Types, typ)
+		}
+	}
+	return result
+}
+
+// newType 生成类型描述
+func newType(expr ast.Expr) *Type {
+	result := &Type{
+		Name:     expr.Name(),
+		FullName: gslang.TypeName(expr),
+		Script:   expr.Script().Name(),
+		Pos:      gslang.Pos(expr),
+		Comments: Comments(expr),
+		Attrs:    newAttrs(expr),
+	}
+	switch node := expr.(type) {
+	case *ast.Table:
+		result.Kind = KindTable
+		if gslang.IsStruct(node) {
+			result.Kind = KindStruct
+		}
+		for _, field := range node.Fields {
+			result.Fields = append(result.Fields, &Field{
+				Name:     field.Name(),
+				ID:       field.ID,
+				Type:     newTypeExpr(field.Type),
+				Pos:      gslang.Pos(field),
+				Comments: Comments(field),
+				Attrs:    newAttrs(field),
+			})
+		}
+	case *ast.Enum:
+		result.Kind = KindEnum
+		result.Length = node.Length
+		result.Signed = node.Signed
+		result.Error = gslang.IsError(node)
+		for _, val := range gslang.EnumVals(node) {
+			result.Values = append(result.Values, &EnumVal{
+				Name:     val.Name(),
+				Value:    gslang.EnumValue(val),
+				Pos:      gslang.Pos(val),
+				Comments: Comments(val),
+				Attrs:    newAttrs(val),
+			})
+		}
+	case *ast.Contract:
+		result.Kind = KindContract
+		for _, base := range node.Bases {
+			result.Bases = append(result.Bases, gslang.TypeName(base))
+		}
+		for _, method := range gslang.Methods(node) {
+			result.Methods = append(result.Methods, &Method{
+				Name:     method.Name(),
+				ID:       method.ID,
+				Params:   newParams(method.Params),
+				Return:   newParams(method.Return),
+				Pos:      gslang.Pos(method),
+				Comments: Comments(method),
+				Attrs:    newAttrs(method),
+			})
+		}
+	default:
+		return nil
+	}
+	return result
+}
+
+// newParams 生成参数列表描述
+func newParams(params []*ast.Param) []*Param {
+	var result []*Param
+	for _, param := range params {
+		result = append(result, &Param{
+			ID:       param.ID,
+			Type:     newTypeExpr(param.Type),
+			Comments: Comments(param),
+			Attrs:    newAttrs(param),
+		})
+	}
+	return result
+}
+
+// newTypeExpr 生成类型表达式描述
+func newTypeExpr(expr ast.Expr) *TypeExpr {
+	result := &TypeExpr{
+		Name:     gslang.TypeName(expr),
+		FullName: gslang.TypeName(expr),
+	}
+	if _, ok := gslang.Builtin(expr); ok {
+		result.Kind = KindBuiltin
+		return result
+	}
+	switch node := expr.(type) {
+	case *ast.TypeRef:
+		if node.Ref == nil {
+			return result
+		}
+		target := newTypeExpr(node.Ref)
+		target.Name = node.Ref.Name()
+		if pkg := node.Ref.Package(); pkg != nil {
+			target.Package = pkg.Name()
+		}
+		return target
+	case *ast.Table:
+		result.Kind = KindTable
+		if gslang.IsStruct(node) {
+			result.Kind = KindStruct
+		}
+	case *ast.Enum:
+		result.Kind = KindEnum
+	case *ast.Contract:
+		result.Kind = KindContract
+	case *ast.List:
+		result.Kind = KindList
+		result.Element = newTypeExpr(node.Element)
+	case *ast.Array:
+		result.Kind = KindArray
+		result.Length = node.Length
+		result.Element = newTypeExpr(node.Element)
+	case *ast.Map:
+		result.Kind = KindMap
+		result.Key = newTypeExpr(node.Key)
+		result.Value = newTypeExpr(node.Value)
+	}
+	return result
+}
+
+// newAttrs 生成节点的属性描述列表
+func newAttrs(node ast.Node) []*Attr {
+	var result []*Attr
+	for _, attr := range node.Attrs() {
+		result = append(result, &Attr{
+			Type: gslang.TypeName(attr.Type),
+			Name: attr.Type.NamePath[len(attr.Type.NamePath)-1],
+			Args: AttrArgs(attr),
+		})
+	}
+	return result
+}
+
+// AttrArgs 按属性类型的域名字对属性参数求值 枚举值及其组合求值为整数
+func AttrArgs(attr *ast.Attr) map[string]interface{} {
+	table, ok := attr.Type.Ref.(*ast.Table)
+	if !ok || attr.Args == nil {
+		return nil
+	}
+	args := make(map[string]interface{})
+	for _, field := range table.Fields {
+		if arg, ok := gslang.EvalFieldInitArg(field, attr.Args); ok {
+			args[field.Name()] = evalArg(arg)
+		}
+	}
+	return args
+}
+
+// evalArg 对单个属性参数求值
+func evalArg(expr ast.Expr) interface{} {
+	switch node := expr.(type) {
+	case *ast.Int:
+		return node.Value
+	case *ast.Float:
+		return node.Value
+	case *ast.String:
+		return node.Value
+	case *ast.Bool:
+		return node.Value
+	case *ast.BinaryOp:
+		return gslang.EvalEnumVal(node)
+	case *ast.TypeRef:
+		if _, ok := node.Ref.(*ast.EnumVal); ok {
+			return gslang.EvalEnumVal(node)
+		}
+		return gslang.TypeName(node)
+	}
+	return nil
+}
+
+// Comments 返回节点的注释文本列表
+func Comments(node ast.Node) []string {
+	var result []string
+	for _, token := range gslang.Comments(node) {
+		if text, ok := token.Value.(string); ok {
+			result = append(result, strings.TrimSpace(text))
+		}
+	}
+	return result
+}
@@ -0,0 +1,197 @@
+// @file 	schema_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	schema_test
+
+package gen
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// testFiles 测试使用的代码 demo/shop引用demo/base
+var testFiles = map[string]string{
+	"demo/base/base.gs": `
+// 坐标
+struct Point {
+    X int32;
+    Y int32;
+}
+
+@gslang.AttrUsage(gslang.AttrTarget.Table|gslang.AttrTarget.Field|gslang.AttrTarget.Method)
+table Meta {
+    Name string;
+    Weight float64;
+    Target gslang.AttrTarget;
+}
+`,
+	"demo/shop/shop.gs": `
+import "demo/base"
+
+@base.Meta(Name: "item", Weight: 1.5, Target: gslang.AttrTarget.Table|gslang.AttrTarget.Field)
+// 物品
+// 第二行
+table Item {
+    ID uint64;
+    Pos [2]base.Point;
+    Tags []string;
+    Counts map[Color]int32;
+}
+
+enum Color(byte) {
+    Red(1), Green(2)
+}
+
+enum Big(uint64) {
+    Max(0xFFFFFFFFFFFFFFFF)
+}
+
+@gslang.Error
+enum ShopError(int32) {
+    NotFound(-1)
+}
+
+contract Base {
+    Ping();
+}
+
+contract Shop(Base) {
+    @base.Meta(Name: "get")
+    Get(id uint64) -> (Item, ShopError);
+}
+`,
+}
+
+// packages 编译测试代码 返回demo/shop及demo/base
+func packages(t *testing.T) []*ast.Package {
+	cs := gstest.Compile(t, testFiles, "demo/shop")
+	return []*ast.Package{cs.Loaded["demo/shop"], cs.Loaded["demo/base"]}
+}
+
+func TestNewSchema(t *testing.T) {
+	schema := NewSchema(packages(t))
+	if len(schema.Packages) != 2 {
+		t.Fatalf("packages = %d", len(schema.Packages))
+	}
+	shop, ok := schema.Package("demo/shop")
+	if !ok {
+		t.Fatal("demo/shop not found")
+	}
+	if _, ok := schema.Package("demo/nope"); ok {
+		t.Error("found demo/nope")
+	}
+	if !reflect.DeepEqual(shop.Imports, []string{"demo/base"}) {
+		t.Errorf("imports = %v", shop.Imports)
+	}
+	var names []string
+	for _, typ := range shop.Types {
+		names = append(names, typ.Kind+" "+typ.Name)
+	}
+	want := []string{"table Item", "enum Color", "enum Big", "enum ShopError", "contract Base", "contract Shop"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("types = %v want %v", names, want)
+	}
+	item, _ := shop.Type("Item")
+	if item.FullName != "demo/shop.Item" || item.Script != "shop.gs" || item.Pos.Line != 7 {
+		t.Errorf("item = %s %s %s", item.FullName, item.Script, item.Pos)
+	}
+	if !reflect.DeepEqual(item.Comments, []string{"物品", "第二行"}) {
+		t.Errorf("comments = %q", item.Comments)
+	}
+	if len(item.Attrs) != 1 || item.Attrs[0].Type != "demo/base.Meta" || item.Attrs[0].Name != "Meta" {
+		t.Fatalf("attrs = %v", item.Attrs)
+	}
+	args := item.Attrs[0].Args
+	if args["Name"] != "item" || args["Weight"] != 1.5 || args["Target"] != int64(4|16) {
+		t.Errorf("attr args = %v", args)
+	}
+	// 域类型描述
+	fields := map[string]string{}
+	for _, field := range item.Fields {
+		fields[field.Name] = describeType(field.Type)
+	}
+	wantFields := map[string]string{
+		"ID":     "builtin uint64",
+		"Pos":    "array[2] struct Point demo/base",
+		"Tags":   "list builtin string",
+		"Counts": "map enum Color demo/shop -> builtin int32",
+	}
+	if !reflect.DeepEqual(fields, wantFields) {
+		t.Errorf("fields = %v\nwant %v", fields, wantFields)
+	}
+	if item.Fields[3].ID != 3 {
+		t.Errorf("Counts id = %d", item.Fields[3].ID)
+	}
+	// 枚举
+	big, _ := shop.Type("Big")
+	if big.Length != 8 || big.Signed || big.Values[0].Value.String() != "18446744073709551615" {
+		t.Errorf("Big = %d %v %s", big.Length, big.Signed, big.Values[0].Value)
+	}
+	shopError, _ := shop.Type("ShopError")
+	if !shopError.Error || !shopError.Signed || shopError.Values[0].Value.Int64() != -1 {
+		t.Errorf("ShopError = %+v", shopError)
+	}
+	// 协议函数包括继承的函数 按ID排序
+	contract, _ := shop.Type("Shop")
+	if !reflect.DeepEqual(contract.Bases, []string{"demo/shop.Base"}) || len(contract.Methods) != 2 {
+		t.Fatalf("Shop = %v %v", contract.Bases, contract.Methods)
+	}
+	get := contract.Methods[1]
+	if contract.Methods[0].Name != "Ping" || get.Name != "Get" || get.ID != 1 {
+		t.Errorf("methods = %s(%d) %s(%d)", contract.Methods[0].Name, contract.Methods[0].ID, get.Name, get.ID)
+	}
+	if len(get.Params) != 1 || len(get.Return) != 2 || get.Return[1].Type.Kind != KindEnum || len(get.Attrs) != 1 {
+		t.Errorf("Get = %+v", get)
+	}
+	base, _ := schema.Package("demo/base")
+	if point, _ := base.Type("Point"); point == nil || point.Kind != KindStruct {
+		t.Errorf("Point = %v", point)
+	}
+}
+
+// describeType 类型表达式的简短描述
+func describeType(expr *TypeExpr) string {
+	switch expr.Kind {
+	case KindList:
+		return "list " + describeType(expr.Element)
+	case KindArray:
+		return "array[" + big.NewInt(int64(expr.Length)).String() + "] " + describeType(expr.Element)
+	case KindMap:
+		return "map " + describeType(expr.Key) + " -> " + describeType(expr.Value)
+	case KindBuiltin:
+		return "builtin " + expr.Name
+	}
+	return expr.Kind + " " + expr.Name + " " + expr.Package
+}
+
+// TestSchemaJSON 描述可以序列化为JSON 大的枚举值不丢失精度
+func TestSchemaJSON(t *testing.T) {
+	schema := NewSchema(packages(t))
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Schema
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	shop, _ := decoded.Package("demo/shop")
+	big, _ := shop.Type("Big")
+	if big.Values[0].Value.String() != "18446744073709551615" {
+		t.Errorf("decoded Big.Max = %s", big.Values[0].Value)
+	}
+	// 再次序列化的结果相同
+	again, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("round trip mismatch:\n%s\nwant\n%s", again, data)
+	}
+}
@@ -0,0 +1,205 @@
+// @file 	tmpl.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	tmpl
+
+package tmpl
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/gen"
+)
+
+// 默认输出文件名模板 完整包名/模板名去掉.tmpl后缀 不同路径下同名的包输出到不同目录
+const defaultOut = "{{.Package.Name}}/{{.Template}}"
+
+func init() {
+	gen.Register(New())
+}
+
+// Data 传给每一个模板的数据
+type Data struct {
+	Template string            // 模板名 即模板文件名去掉.tmpl后缀
+	Package  *gen.Package      // 当前生成的包
+	Schema   *gen.Schema       // 本次请求的所有包
+	Options  map[string]string // 生成器选项
+}
+
+// Generator 模板代码生成器 使用text/template对每个包执行用户提供的模板文件
+type Generator struct{}
+
+// New 新建模板代码生成器
+func New() *Generator {
+	return &Generator{}
+}
+
+// Name 实现gen.Generator接口
+func (generator *Generator) Name() string {
+	return "template"
+}
+
+// Options 实现gen.Generator接口
+func (generator *Generator) Options() []*gen.Option {
+	return []*gen.Option{
+		{Name: "templates", Usage: "comma separated template files"},
+		{Name: "out", Default: defaultOut, Usage: "output file name template, executed with the template data"},
+		{Name: "typemap", Usage: "json file which overrides the builtin type mapping tables"},
+		{Name: "lang", Default: "go", Usage: "default type mapping table used by the type func"},
+	}
+}
+
+// Generate 实现gen.Generator接口 每个模板文件对每个包生成一个输出文件
+func (generator *Generator) Generate(ctx context.Context, req *gen.Request) ([]*gen.File, error) {
+	var paths []string
+	for _, path := range strings.Split(req.Option("templates", ""), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, gserrors.Newf(gen.ErrGen, "template generator expect option: templates")
+	}
+	typeMaps, err := loadTypeMaps(req.Option("typemap", ""))
+	if err != nil {
+		return nil, err
+	}
+	funcs := newFuncs(typeMaps, req.Option("lang", "go"), reachable(req.Packages))
+	out, err := template.New("out").Funcs(funcs).Parse(req.Option("out", defaultOut))
+	if err != nil {
+		return nil, gserrors.Newf(gen.ErrGen, "invalid out option : %s", err)
+	}
+	schema := gen.NewSchema(req.Packages)
+	var files []*gen.File
+	for _, path := range paths {
+		tpl, err := template.New(filepath.Base(path)).Funcs(funcs).ParseFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range schema.Packages {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			data := &Data{
+				Template: strings.TrimSuffix(filepath.Base(path), ".tmpl"),
+				Package:  pkg,
+				Schema:   schema,
+				Options:  req.Options,
+			}
+			var content, name bytes.Buffer
+			if err := tpl.Execute(&content, data); err != nil {
+				return nil, err
+			}
+			if err := out.Execute(&name, data); err != nil {
+				return nil, err
+			}
+			files = append(files, gen.NewFile(name.String(), content.Bytes()))
+		}
+	}
+	return files, nil
+}
+
+// reachable 返回请求的包及其直接或间接引用的所有包的描述 用于解析类型引用
+func reachable(pkgs []*ast.Package) *gen.Schema {
+	visited := make(map[*ast.Package]bool)
+	var all []*ast.Package
+	var visit func(pkg *ast.Package)
+	visit = func(pkg *ast.Package) {
+		if pkg == nil || visited[pkg] {
+			return
+		}
+		visited[pkg] = true
+		all = append(all, pkg)
+		for _, script := range pkg.Scripts {
+			for _, ref := range script.Imports {
+				visit(ref.Ref)
+			}
+		}
+	}
+	for _, pkg := range pkgs {
+		visit(pkg)
+	}
+	return gen.NewSchema(all)
+}
+
+// newFuncs 模板辅助函数
+func newFuncs(typeMaps map[string]*TypeMap, lang string, all *gen.Schema) template.FuncMap {
+	types := make(map[string]*gen.Type)
+	for _, pkg := range all.Packages {
+		for _, typ := range pkg.Types {
+			types[typ.FullName] = typ
+		}
+	}
+	return template.FuncMap{
+		"pascal":    gen.PascalCase,
+		"camel":     gen.CamelCase,
+		"snake":     gen.SnakeCase,
+		"kebab":     gen.KebabCase,
+		"screaming": gen.ScreamingCase,
+		"upper":     strings.ToUpper,
+		"lower":     strings.ToLower,
+		"join":      strings.Join,
+		"base":      path.Base,
+		"quote":     strconv.Quote,
+		"add": func(a, b int) int {
+			return a + b
+		},
+		// comment 为每一行注释加上前缀 如 {{comment "// " .Comments}}
+		"comment": func(prefix string, lines []string) string {
+			var buff strings.Builder
+			for i, line := range lines {
+				if i > 0 {
+					buff.WriteString("\n")
+				}
+				buff.WriteString(prefix + line)
+			}
+			return buff.String()
+		},
+		// type 使用默认映射表映射类型 如 {{type .Type}}
+		"type": func(expr *gen.TypeExpr) (string, error) {
+			typeMap, ok := typeMaps[lang]
+			if !ok {
+				return "", gserrors.Newf(gen.ErrGen, "unknown type mapping table(%s)", lang)
+			}
+			return typeMap.Type(expr), nil
+		},
+		// typeOf 使用指定的映射表映射类型 如 {{typeOf "cpp" .Type}}
+		"typeOf": func(name string, expr *gen.TypeExpr) (string, error) {
+			typeMap, ok := typeMaps[name]
+			if !ok {
+				return "", gserrors.Newf(gen.ErrGen, "unknown type mapping table(%s)", name)
+			}
+			return typeMap.Type(expr), nil
+		},
+		// resolve 返回类型引用指向的类型描述 内置类型及复合类型返回nil
+		"resolve": func(expr *gen.TypeExpr) *gen.Type {
+			return types[expr.FullName]
+		},
+		// attr 在属性列表中查找指定名字的属性
+		"attr": func(name string, attrs []*gen.Attr) *gen.Attr {
+			for _, attr := range attrs {
+				if attr.Name == name || attr.Type == name {
+					return attr
+				}
+			}
+			return nil
+		},
+		// hasAttr 检查属性列表中是否有指定名字的属性
+		"hasAttr": func(name string, attrs []*gen.Attr) bool {
+			for _, attr := range attrs {
+				if attr.Name == name || attr.Type == name {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
@@ -0,0 +1,124 @@
+// @file 	tmpl_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	tmpl_test
+
+package tmpl
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/gen"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// testFiles 测试使用的代码 a/shop与b/shop包名最后一段相同
+var testFiles = map[string]string{
+	"a/shop/shop.gs": `
+import "b/shop"
+
+// 物品
+table Item {
+    ItemID uint64;
+    Tags []string;
+    Other shop.Other;
+    Colors map[Color]int32;
+    Pos [2]int32;
+}
+
+enum Color(byte) {
+    Red(1)
+}
+`,
+	"b/shop/shop.gs": `
+@gslang.Error
+enum Other(int32) {
+    NotFound(1)
+}
+`,
+}
+
+// itemTemplate 对每个包输出类型列表
+const itemTemplate = `package {{base .Package.Name}} {{.Options.suffix}}
+{{range .Package.Types}}{{comment "// " .Comments}}
+{{screaming .Name}}{{if hasAttr "Error" .Attrs}} error{{end}}
+{{range .Fields}}  {{snake .Name}} {{type .Type}} / {{typeOf "cpp" .Type}}{{with resolve .Type}} -> {{.Kind}}{{end}}
+{{end}}{{end}}`
+
+// packages 编译测试代码 返回a/shop及b/shop
+func packages(t *testing.T) []*ast.Package {
+	cs := gstest.Compile(t, testFiles, "a/shop")
+	return []*ast.Package{cs.Loaded["a/shop"], cs.Loaded["b/shop"]}
+}
+
+func TestGenerate(t *testing.T) {
+	path := writeFile(t, "types.go.tmpl", itemTemplate)
+	files, err := New().Generate(context.Background(), &gen.Request{
+		Packages: packages(t),
+		Options:  map[string]string{"templates": " , " + path, "suffix": "v1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("files = %d", len(files))
+	}
+	// 默认输出路径使用完整包名 同名的包不会互相覆盖
+	if files[0].Name != "a/shop/types.go" || files[1].Name != "b/shop/types.go" {
+		t.Errorf("names = %s %s", files[0].Name, files[1].Name)
+	}
+	want := `package shop v1
+// 物品
+ITEM
+  item_id uint64 / uint64_t
+  tags []string / std::vector<std::string>
+  other Other / Other -> enum
+  colors map[Color]int32 / std::map<Color, int32_t>
+  pos [2]int32 / std::array<int32_t, 2>
+
+COLOR
+`
+	if got := string(files[0].Content); got != want {
+		t.Errorf("a/shop content:\n%s\nwant\n%s", got, want)
+	}
+	if got := string(files[1].Content); !strings.Contains(got, "OTHER error") {
+		t.Errorf("b/shop content:\n%s", got)
+	}
+}
+
+func TestGenerateOptions(t *testing.T) {
+	path := writeFile(t, "names.tmpl", `{{range .Package.Types}}{{range .Fields}}{{type .Type}}{{end}}{{end}}`)
+	tests := []struct {
+		options map[string]string
+		want    string
+	}{
+		{map[string]string{}, "expect option: templates"},
+		{map[string]string{"templates": path, "out": "{{"}, "invalid out option"},
+		{map[string]string{"templates": path, "lang": "cobol"}, "unknown type mapping table(cobol)"},
+		{map[string]string{"templates": path + ".none"}, "no such file"},
+		{map[string]string{"templates": writeFile(t, "bad.tmpl", "{{.Nope}}")}, "can't evaluate field Nope"},
+	}
+	for _, tc := range tests {
+		_, err := New().Generate(context.Background(), &gen.Request{Packages: packages(t), Options: tc.options})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: error = %v want %q", tc.options, err, tc.want)
+		}
+	}
+	// 自定义输出路径
+	files, err := New().Generate(context.Background(), &gen.Request{
+		Packages: packages(t),
+		Options:  map[string]string{"templates": path, "out": "{{kebab (base .Package.Name)}}-{{.Template}}.txt"},
+	})
+	if err != nil || len(files) != 2 || files[0].Name != "shop-names.txt" {
+		t.Errorf("custom out = %v, %v", files, err)
+	}
+	// 取消
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New().Generate(ctx, &gen.Request{Packages: packages(t), Options: map[string]string{"templates": path}}); err != context.Canceled {
+		t.Errorf("canceled error = %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// @file 	typemap.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	typemap
+
+package tmpl
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang/gen"
+)
+
+// TypeMap 单个目标语言的类型映射表
+// 复合类型使用占位符 $elem $len $key $value 引用类型使用占位符 $name $package
+type TypeMap struct {
+	Builtin map[string]string `json:"builtin"` // 内置类型关键字 -> 目标语言类型
+	List    string            `json:"list"`    // 切片
+	Array   string            `json:"array"`   // 数组
+	Map     string            `json:"map"`     // 字典
+	Ref     string            `json:"ref"`     // 引用的表 结构体 枚举 协议
+}
+
+// typeMaps 内置的目标语言类型映射表
+var typeMaps = map[string]*TypeMap{
+	"go": {
+		Builtin: map[string]string{
+			"byte": "byte", "sbyte": "int8", "int16": "int16", "uint16": "uint16",
+			"int32": "int32", "uint32": "uint32", "int64": "int64", "uint64": "uint64",
+			"float32": "float32", "float64": "float64", "bool": "bool", "string": "string",
+		},
+		List:  "[]$elem",
+		Array: "[$len]$elem",
+		Map:   "map[$key]$value",
+		Ref:   "$name",
+	},
+	"cpp": {
+		Builtin: map[string]string{
+			"byte": "uint8_t", "sbyte": "int8_t", "int16": "int16_t", "uint16": "uint16_t",
+			"int32": "int32_t", "uint32": "uint32_t", "int64": "int64_t", "uint64": "uint64_t",
+			"float32": "float", "float64": "double", "bool": "bool", "string": "std::string",
+		},
+		List:  "std::vector<$elem>",
+		Array: "std::array<$elem, $len>",
+		Map:   "std::map<$key, $value>",
+		Ref:   "$name",
+	},
+	"csharp": {
+		Builtin: map[string]string{
+			"byte": "byte", "sbyte": "sbyte", "int16": "short", "uint16": "ushort",
+			"int32": "int", "uint32": "uint", "int64": "long", "uint64": "ulong",
+			"float32": "float", "float64": "double", "bool": "bool", "string": "string",
+		},
+		List:  "List<$elem>",
+		Array: "$elem[]",
+		Map:   "Dictionary<$key, $value>",
+		Ref:   "$name",
+	},
+	"java": {
+		Builtin: map[string]string{
+			"byte": "byte", "sbyte": "byte", "int16": "short", "uint16": "int",
+			"int32": "int", "uint32": "long", "int64": "long", "uint64": "long",
+			"float32": "float", "float64": "double", "bool": "boolean", "string": "String",
+		},
+		List:  "List<$elem>",
+		Array: "$elem[]",
+		Map:   "Map<$key, $value>",
+		Ref:   "$name",
+	},
+	"sql": {
+		Builtin: map[string]string{
+			"byte": "SMALLINT", "sbyte": "SMALLINT", "int16": "SMALLINT", "uint16": "INTEGER",
+			"int32": "INTEGER", "uint32": "BIGINT", "int64": "BIGINT", "uint64": "NUMERIC(20)",
+			"float32": "REAL", "float64": "DOUBLE PRECISION", "bool": "BOOLEAN", "string": "TEXT",
+		},
+		List:  "JSON",
+		Array: "JSON",
+		Map:   "JSON",
+		Ref:   "JSON",
+	},
+}
+
+// loadTypeMaps 读取JSON格式的类型映射表文件 并覆盖内置的同名映射表
+func loadTypeMaps(path string) (map[string]*TypeMap, error) {
+	maps := make(map[string]*TypeMap)
+	for lang, typeMap := range typeMaps {
+		maps[lang] = typeMap
+	}
+	if path == "" {
+		return maps, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var custom map[string]*TypeMap
+	if err := json.Unmarshal(content, &custom); err != nil {
+		return nil, gserrors.Newf(gen.ErrGen, "invalid typemap file(%s) : %s", path, err)
+	}
+	for lang, typeMap := range custom {
+		// 未声明的部分沿用内置映射表
+		if base, ok := maps[lang]; ok {
+			merged := *base
+			merged.Builtin = make(map[string]string)
+			for name, target := range base.Builtin {
+				merged.Builtin[name] = target
+			}
+			for name, target := range typeMap.Builtin {
+				merged.Builtin[name] = target
+			}
+			if typeMap.List != "" {
+				merged.List = typeMap.List
+			}
+			if typeMap.Array != "" {
+				merged.Array = typeMap.Array
+			}
+			if typeMap.Map != "" {
+				merged.Map = typeMap.Map
+			}
+			if typeMap.Ref != "" {
+				merged.Ref = typeMap.Ref
+			}
+			typeMap = &merged
+		}
+		maps[lang] = typeMap
+	}
+	return maps, nil
+}
+
+// Type 将类型表达式映射成目标语言类型
+func (typeMap *TypeMap) Type(expr *gen.TypeExpr) string {
+	switch expr.Kind {
+	case gen.KindBuiltin:
+		if target, ok := typeMap.Builtin[expr.Name]; ok {
+			return target
+		}
+		return expr.Name
+	case gen.KindList:
+		return strings.NewReplacer("$elem", typeMap.Type(expr.Element)).Replace(typeMap.List)
+	case gen.KindArray:
+		return strings.NewReplacer(
+			"$elem", typeMap.Type(expr.Element),
+			"$len", strconv.Itoa(int(expr.Length)),
+		).Replace(typeMap.Array)
+	case gen.KindMap:
+		return strings.NewReplacer(
+			"$key", typeMap.Type(expr.Key),
+			"$value", typeMap.Type(expr.Value),
+		).Replace(typeMap.Map)
+	}
+	return strings.NewReplacer(
+		"$name", expr.Name,
+		"$package", expr.Package,
+	).Replace(typeMap.Ref)
+}
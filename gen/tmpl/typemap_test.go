@@ -0,0 +1,97 @@
+// @file 	typemap_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	typemap_test
+
+package tmpl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang/gen"
+)
+
+// writeFile 在临时目录中写入文件 返回文件路径
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// exprs 测试使用的类型表达式 map[uint64][3]demo/shop.Item及[]string
+var exprs = []*gen.TypeExpr{
+	{
+		Kind: gen.KindMap,
+		Key:  &gen.TypeExpr{Kind: gen.KindBuiltin, Name: "uint64"},
+		Value: &gen.TypeExpr{
+			Kind:    gen.KindArray,
+			Length:  3,
+			Element: &gen.TypeExpr{Kind: gen.KindTable, Name: "Item", Package: "demo/shop"},
+		},
+	},
+	{Kind: gen.KindList, Element: &gen.TypeExpr{Kind: gen.KindBuiltin, Name: "string"}},
+}
+
+func TestTypeMap(t *testing.T) {
+	tests := []struct {
+		lang string
+		want []string
+	}{
+		{"go", []string{"map[uint64][3]Item", "[]string"}},
+		{"cpp", []string{"std::map<uint64_t, std::array<Item, 3>>", "std::vector<std::string>"}},
+		{"csharp", []string{"Dictionary<ulong, Item[]>", "List<string>"}},
+		{"java", []string{"Map<long, Item[]>", "List<String>"}},
+		{"sql", []string{"JSON", "JSON"}},
+	}
+	maps, err := loadTypeMaps("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range tests {
+		for i, expr := range exprs {
+			if got := maps[tc.lang].Type(expr); got != tc.want[i] {
+				t.Errorf("%s: Type = %s want %s", tc.lang, got, tc.want[i])
+			}
+		}
+	}
+	// 映射表中没有的内置类型原样输出
+	if got := maps["go"].Type(&gen.TypeExpr{Kind: gen.KindBuiltin, Name: "int128"}); got != "int128" {
+		t.Errorf("unknown builtin = %s", got)
+	}
+}
+
+func TestLoadTypeMaps(t *testing.T) {
+	path := writeFile(t, "typemap.json", `{
+		"go": {"builtin": {"uint64": "big.Int"}, "ref": "$package.$name"},
+		"rust": {"builtin": {"uint64": "u64", "string": "String"}, "list": "Vec<$elem>", "array": "[$elem; $len]", "map": "HashMap<$key, $value>", "ref": "$name"}
+	}`)
+	maps, err := loadTypeMaps(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 覆盖的部分使用自定义映射 其余沿用内置映射表
+	if got := maps["go"].Type(exprs[0]); got != "map[big.Int][3]demo/shop.Item" {
+		t.Errorf("go = %s", got)
+	}
+	if got := maps["go"].Type(&gen.TypeExpr{Kind: gen.KindBuiltin, Name: "int32"}); got != "int32" {
+		t.Errorf("go int32 = %s", got)
+	}
+	if got := maps["rust"].Type(exprs[0]); got != "HashMap<u64, [Item; 3]>" {
+		t.Errorf("rust = %s", got)
+	}
+	// 内置映射表不被修改
+	if typeMaps["go"].Builtin["uint64"] != "uint64" || typeMaps["go"].Ref != "$name" {
+		t.Errorf("builtin go table modified: %+v", typeMaps["go"])
+	}
+	if _, err := loadTypeMaps(writeFile(t, "bad.json", "{")); err == nil || !strings.Contains(err.Error(), "invalid typemap file") {
+		t.Errorf("invalid file error = %v", err)
+	}
+	if _, err := loadTypeMaps(filepath.Join(t.TempDir(), "none.json")); err == nil {
+		t.Error("missing file succeeded")
+	}
+}
@@ -0,0 +1,103 @@
+// @file 	gstest.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	gstest
+
+// Package gstest 测试辅助函数 在临时目录下构造包含gslang内置包及测试代码的GOPATH并编译
+// 根目录包的测试使用外部测试包gslang_test 以避免循环引用
+package gstest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// Builtin gslang内置包skea3344/gslang的代码 链接器依赖其中的AttrUsage Struct Error及内置数据类型
+const Builtin = `// gslang builtin package
+enum AttrTarget(uint32) {
+    Package(1), Script(2), Table(4), Struct(8), Field(16), Enum(32),
+    EnumVal(64), Contract(128), Method(256), Param(512), Return(1024)
+}
+
+@AttrUsage(AttrTarget.Table)
+table AttrUsage {
+    Target AttrTarget;
+}
+
+@AttrUsage(AttrTarget.Table|AttrTarget.Struct)
+table Struct {}
+
+@AttrUsage(AttrTarget.Enum)
+table Error {}
+
+table Byte {}
+table Sbyte {}
+table Int16 {}
+table Uint16 {}
+table Int32 {}
+table Uint32 {}
+table Int64 {}
+table Uint64 {}
+table Float32 {}
+table Float64 {}
+table Bool {}
+table String {}
+`
+
+// GOPATH 在测试的临时目录下创建GOPATH 写入内置包及files中的代码文件 返回GOPATH路径
+// files的key为相对于src目录的文件路径 如 demo/shop/shop.gs
+func GOPATH(t testing.TB, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	Write(t, dir, map[string]string{"skea3344/gslang/gslang.gs": Builtin})
+	Write(t, dir, files)
+	return dir
+}
+
+// Write 将files中的代码文件写入GOPATH 已有的文件被覆盖 内容为空字符串时删除文件
+func Write(t testing.TB, gopath string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(gopath, "src", filepath.FromSlash(name))
+		if content == "" {
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Compile 在临时GOPATH下编译指定的包 编译失败时终止测试
+func Compile(t testing.TB, files map[string]string, packages ...string) *gslang.CompileS {
+	t.Helper()
+	result, err := gslang.Compile(context.Background(), gslang.Options{
+		Packages: packages,
+		GOPATH:   []string{GOPATH(t, files)},
+	})
+	if err != nil {
+		t.Fatalf("compile %v: %s", packages, err)
+	}
+	return result.CompileS
+}
+
+// Type 返回已编译的类型 类型不存在时终止测试
+func Type(t testing.TB, cs *gslang.CompileS, packageName, typeName string) ast.Expr {
+	t.Helper()
+	expr, err := cs.Type(packageName, typeName)
+	if err != nil {
+		t.Fatalf("type %s.%s: %s", packageName, typeName, err)
+	}
+	return expr
+}
@@ -0,0 +1,131 @@
+// @file 	layout.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	layout
+
+package gslang
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang/ast"
+)
+
+var (
+	// ErrLayout 结构体布局错误
+	ErrLayout = errors.New("struct layout error")
+)
+
+// builtinSizes 内置数据类型的字节宽度 string不是定长类型
+var builtinSizes = map[rune]int{
+	KeyByte:    1,
+	KeySByte:   1,
+	KeyBool:    1,
+	KeyInt16:   2,
+	KeyUInt16:  2,
+	KeyInt32:   4,
+	KeyUInt32:  4,
+	KeyFloat32: 4,
+	KeyInt64:   8,
+	KeyUInt64:  8,
+	KeyFloat64: 8,
+}
+
+// FieldLayout 结构体域的内存布局
+type FieldLayout struct {
+	Field        *ast.Field // 域节点
+	Offset       int        // 按自然对齐的偏移
+	PackedOffset int        // 紧凑排列(1字节对齐)时的偏移
+	Size         int        // 按自然对齐的字节宽度 数组为所有元素的总宽度
+	Align        int        // 域的对齐字节数
+}
+
+// StructLayout 结构体的内存布局
+type StructLayout struct {
+	Struct     *ast.Table     // 结构体节点
+	Size       int            // 按自然对齐的总字节数 包含尾部填充
+	Align      int            // 结构体的对齐字节数 为所有域对齐的最大值
+	PackedSize int            // 紧凑排列(1字节对齐)时的总字节数 不是codec的编码长度
+	Fields     []*FieldLayout // 按声明顺序的域布局
+}
+
+// Layout 计算结构体的内存布局 偏移及对齐规则与C语言的自然对齐一致
+// 枚举按Length宽度计算 定长数组为元素宽度乘以长度 嵌套结构体按其自身布局计算 空结构体的Size为0
+// 结构体中出现string 切片 字典或者表等变长类型时返回错误 结构体必须已经连接
+//
+// 布局描述的是内存中的定长表示 用于生成C等语言的结构体或者共享内存 与codec包的二进制编码不同:
+// codec在定长数组之前写入varint元素个数 因此编码长度通常大于PackedSize
+func Layout(table *ast.Table) (*StructLayout, error) {
+	if !IsStruct(table) {
+		return nil, gserrors.Newf(ErrLayout, "%s is not a struct:\n\tsee: %s", table, Pos(table))
+	}
+	layout, err := layoutOf(table)
+	if err != nil {
+		return nil, gserrors.Newf(ErrLayout, "%s", err)
+	}
+	return layout, nil
+}
+
+// layoutOf 计算结构体的内存布局
+func layoutOf(table *ast.Table) (*StructLayout, error) {
+	layout := &StructLayout{Struct: table, Align: 1}
+	for _, field := range table.Fields {
+		size, packed, align, err := sizeOf(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s %s\n\tsee: %s", table, field, err, Pos(field))
+		}
+		layout.Size = (layout.Size + align - 1) / align * align
+		layout.Fields = append(layout.Fields, &FieldLayout{
+			Field:        field,
+			Offset:       layout.Size,
+			PackedOffset: layout.PackedSize,
+			Size:         size,
+			Align:        align,
+		})
+		layout.Size += size
+		layout.PackedSize += packed
+		if align > layout.Align {
+			layout.Align = align
+		}
+	}
+	// 尾部填充 使结构体数组中的每个元素都满足对齐
+	layout.Size = (layout.Size + layout.Align - 1) / layout.Align * layout.Align
+	return layout, nil
+}
+
+// sizeOf 返回定长类型按自然对齐的字节宽度 紧凑排列时的字节宽度 以及对齐字节数
+func sizeOf(expr ast.Expr) (size int, packed int, align int, err error) {
+	if key, ok := Builtin(expr); ok {
+		if size, ok := builtinSizes[key]; ok {
+			return size, size, size, nil
+		}
+		return 0, 0, 0, fmt.Errorf("has variable-size type %s", TokenName(key))
+	}
+	switch node := expr.(type) {
+	case *ast.TypeRef:
+		if node.Ref == nil {
+			return 0, 0, 0, fmt.Errorf("has unlinked type %s", node)
+		}
+		return sizeOf(node.Ref)
+	case *ast.Enum:
+		return int(node.Length), int(node.Length), int(node.Length), nil
+	case *ast.Table:
+		if !IsStruct(node) {
+			return 0, 0, 0, fmt.Errorf("has variable-size table %s", TypeName(node))
+		}
+		layout, err := layoutOf(node)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return layout.Size, layout.PackedSize, layout.Align, nil
+	case *ast.Array:
+		size, packed, align, err := sizeOf(node.Element)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return size * int(node.Length), packed * int(node.Length), align, nil
+	}
+	return 0, 0, 0, fmt.Errorf("has variable-size type %s", TypeName(expr))
+}
@@ -0,0 +1,155 @@
+// @file 	layout_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	layout_test
+
+package gslang_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/codec"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// layoutFiles 测试结构体布局的代码
+var layoutFiles = map[string]string{
+	"demo/shop/shop.gs": `
+enum Color(int16) {
+    Red(1)
+}
+
+struct Mixed {
+    A byte;
+    B int32;
+    C int16;
+    D [3]byte;
+    E int64;
+}
+
+struct Outer {
+    X byte;
+    M Mixed;
+    Color Color;
+}
+
+struct Point {
+    X int32;
+    Y int32;
+}
+
+struct Path {
+    Points [2]Point;
+    Ok bool;
+}
+
+struct Empty {}
+
+struct Named {
+    Name string;
+}
+
+struct Items {
+    IDs []int32;
+}
+
+table Item {}
+
+struct Holder {
+    Item Item;
+}
+
+struct Deep {
+    X byte;
+    Named Named;
+}
+`,
+}
+
+// layout 计算结构体布局 出错时终止测试
+func layout(t *testing.T, cs *gslang.CompileS, name string) *gslang.StructLayout {
+	t.Helper()
+	result, err := gslang.Layout(gstest.Type(t, cs, "demo/shop", name).(*ast.Table))
+	if err != nil {
+		t.Fatalf("Layout(%s): %s", name, err)
+	}
+	return result
+}
+
+func TestLayout(t *testing.T) {
+	cs := gstest.Compile(t, layoutFiles, "demo/shop")
+	tests := []struct {
+		name       string
+		size       int
+		align      int
+		packedSize int
+		offsets    []int // 每个域的 Offset
+		packed     []int // 每个域的 PackedOffset
+	}{
+		{"Mixed", 24, 8, 18, []int{0, 4, 8, 10, 16}, []int{0, 1, 5, 7, 10}},
+		{"Outer", 40, 8, 21, []int{0, 8, 32}, []int{0, 1, 19}},
+		{"Point", 8, 4, 8, []int{0, 4}, []int{0, 4}},
+		{"Path", 20, 4, 17, []int{0, 16}, []int{0, 16}},
+		{"Empty", 0, 1, 0, nil, nil},
+	}
+	for _, tc := range tests {
+		result := layout(t, cs, tc.name)
+		if result.Size != tc.size || result.Align != tc.align || result.PackedSize != tc.packedSize {
+			t.Errorf("%s: size %d align %d packed %d, want %d %d %d",
+				tc.name, result.Size, result.Align, result.PackedSize, tc.size, tc.align, tc.packedSize)
+		}
+		if len(result.Fields) != len(tc.offsets) {
+			t.Fatalf("%s: %d fields want %d", tc.name, len(result.Fields), len(tc.offsets))
+		}
+		for i, field := range result.Fields {
+			if field.Offset != tc.offsets[i] || field.PackedOffset != tc.packed[i] {
+				t.Errorf("%s.%s: offset %d packed %d, want %d %d",
+					tc.name, field.Field.Name(), field.Offset, field.PackedOffset, tc.offsets[i], tc.packed[i])
+			}
+		}
+	}
+	// 数组域的Size为所有元素的总宽度
+	if field := layout(t, cs, "Path").Fields[0]; field.Size != 16 || field.Align != 4 {
+		t.Errorf("Path.Points size %d align %d", field.Size, field.Align)
+	}
+}
+
+// TestLayoutNotWireSize 布局是内存中的表示 codec在数组前写入元素个数 编码长度不等于PackedSize
+func TestLayoutNotWireSize(t *testing.T) {
+	cs := gstest.Compile(t, layoutFiles, "demo/shop")
+	mixed := gstest.Type(t, cs, "demo/shop", "Mixed")
+	data, err := codec.Marshal(mixed, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packed := layout(t, cs, "Mixed").PackedSize; len(data) != packed+1 {
+		t.Errorf("codec size %d, packed size %d", len(data), packed)
+	}
+	point := gstest.Type(t, cs, "demo/shop", "Point")
+	if data, err := codec.Marshal(point, nil); err != nil || len(data) != layout(t, cs, "Point").PackedSize {
+		t.Errorf("Point codec size %d, %v", len(data), err)
+	}
+}
+
+func TestLayoutErrors(t *testing.T) {
+	cs := gstest.Compile(t, layoutFiles, "demo/shop")
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Item", "Item is not a struct"},
+		{"Named", "has variable-size type string"},
+		{"Items", "has variable-size type []int32"},
+		{"Holder", "has variable-size table demo/shop.Item"},
+		{"Deep", "has variable-size type string"},
+	}
+	for _, tc := range tests {
+		_, err := gslang.Layout(gstest.Type(t, cs, "demo/shop", tc.name).(*ast.Table))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Layout(%s) error = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
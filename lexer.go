@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"strconv"
 	"unicode"
 	"unicode/utf8"
@@ -27,7 +28,7 @@ var (
 const (
 	TokenEOF        rune = -(iota + 1) // TokenEOF 文件结束符
 	TokenID                            // TokenID 标识符
-	TokenINT                           // TokenINT 整形
+	TokenINT                           // TokenINT 整形 值为*big.Int
 	TokenFLOAT                         // TokenFLOAT 浮点
 	TokenTrue                          // TokenTrue 真
 	TokenFalse                         // TokenFalse 假
@@ -53,6 +54,8 @@ const (
 	KeyContract                        // KeyContract contract
 	KeyImport                          // KeyImport import
 	KeyMap                             // KeyMap map
+	TokenWhitespace                    // TokenWhitespace 空白 仅在Scanner中出现
+	TokenError                         // TokenError 非法输入 仅在Scanner中出现
 )
 
 var tokenName = map[rune]string{
@@ -84,6 +87,8 @@ var tokenName = map[rune]string{
 	KeyContract:     "contract",
 	KeyImport:       "import",
 	KeyMap:          "map",
+	TokenWhitespace: "WHITESPACE",
+	TokenError:      "ERROR",
 }
 
 var keyMap = map[string]rune{
@@ -146,9 +151,11 @@ type Lexer struct {
 	token       *Token
 	buff        [utf8.UTFMax]byte
 	buffPos     int
-	offset      int
+	offset      int // 已读取的字节数
+	width       int // 当前字符的字节数
 	ws          uint64
 	curr        rune
+	trivia      bool // 是否将空白作为TokenWhitespace返回 供Scanner使用
 }
 
 // NewLexer 新建一个词法分析器
@@ -160,31 +167,47 @@ func NewLexer(filename string, reader io.Reader) *Lexer {
 		position: Position{
 			Filename: filename,
 			Line:     1,
-			Column:   1,
+			Column:   0, // 读取第一个字符后为1
 		},
 		curr: TokenEOF,
 	}
 }
 
+// lexError 词法错误 记录出错位置 语法分析出错时据此生成诊断信息
+type lexError struct {
+	gserrors.GSError
+	pos Position
+}
+
 // newerror 返回一个yferrors.YFError
 func (lexer *Lexer) newerror(fmtstring string, args ...interface{}) error {
-	return gserrors.Newf(ErrLexer, "[lexer] %s\n\t%s", fmt.Sprintf(fmtstring, args...), lexer.position)
+	return &lexError{
+		GSError: gserrors.Newf(ErrLexer, "[lexer] %s\n\t%s", fmt.Sprintf(fmtstring, args...), lexer.position),
+		pos:     lexer.position,
+	}
 }
 
 // nextChar 读取下一个utf8字符
 func (lexer *Lexer) nextChar() error {
+	// 上一个字符是换行 则行号加1 列号归零 字符串及注释跨行时位置同样正确
+	if lexer.curr == '\n' {
+		lexer.position.Column = 0
+		lexer.position.Line++
+	}
 	// 从reader中读取一个字节
 	c, err := lexer.reader.ReadByte()
 	if err != nil {
 		// 如果是文件结束标志 则返回
 		if err == io.EOF {
 			lexer.curr = TokenEOF
+			lexer.width = 0
 			return nil
 		}
 		return err
 	}
 	// 偏移量加1
 	lexer.offset++
+	lexer.width = 1
 	if c >= utf8.RuneSelf {
 		// 保存字节到buff buffPos加1
 		lexer.buff[0] = c
@@ -194,19 +217,32 @@ func (lexer *Lexer) nextChar() error {
 			c, err = lexer.reader.ReadByte()
 			if err != nil {
 				if err == io.EOF {
-					lexer.curr = TokenEOF
-					return nil
+					// 源码在utf8编码中间结束 已读取的字节作为一个非法字符
+					lexer.curr = utf8.RuneError
+					lexer.position.Column++
+					return lexer.newerror("illegal utf8 character")
 				}
 				return err
 			}
+			lexer.offset++
+			lexer.width++
 			lexer.buff[lexer.buffPos] = c
 			lexer.buffPos++
-			// buffPos必须小于buff的长度
-			gserrors.Assert(lexer.buffPos < len(lexer.buff), "utf8.UTFMax must < len(lexer.buff)")
+			// 完整的utf8编码最多utf8.UTFMax个字节 buffPos不会超过buff的长度
+			gserrors.Assert(lexer.buffPos <= len(lexer.buff), "utf8.UTFMax must <= len(lexer.buff)")
 		}
 		// 对buff进行utf8解码 得到一个utf8编码的rune
 		c, width := utf8.DecodeRune(lexer.buff[0:lexer.buffPos])
 		if c == utf8.RuneError && width == 1 {
+			// 多个字节时最后读取的字节使编码非法 放回reader 作为下一个字符分析
+			if lexer.buffPos > 1 {
+				lexer.reader.UnreadByte()
+				lexer.offset--
+				lexer.width--
+			}
+			// 非法字符也占一列 出错后可以跳过
+			lexer.curr = utf8.RuneError
+			lexer.position.Column++
 			return lexer.newerror("illegal utf8 character")
 		}
 		lexer.curr = c
@@ -232,12 +268,17 @@ func (lexer *Lexer) next() (token *Token, err error) {
 			return
 		}
 	}
-	// 忽略 \t \r 空格  回车的话 处理位置
-	for lexer.ws&(1<<uint(lexer.curr)) != 0 {
-		if lexer.curr == '\n' {
-			lexer.position.Column = 0
-			lexer.position.Line++
+	// Scanner需要空白 则将连续的空白作为一个Token返回
+	if lexer.trivia && lexer.isWhitespace(lexer.curr) {
+		position := lexer.position
+		token, err = lexer.scanWhitespace()
+		if err == nil {
+			token.Pos = position
 		}
+		return
+	}
+	// 忽略 \t \r 空格 回车 位置在nextChar中处理
+	for lexer.isWhitespace(lexer.curr) {
 		if err = lexer.nextChar(); err != nil {
 			return
 		}
@@ -278,13 +319,15 @@ func (lexer *Lexer) next() (token *Token, err error) {
 		token, err = lexer.scanString('"')
 	case lexer.curr == '\'': // '  进入字符串字面量扫描
 		token, err = lexer.scanString('\'')
+	case lexer.curr == '`': // ` 进入原始字符串字面量扫描 可以跨行
+		token, err = lexer.scanRawString()
 	case lexer.curr == '/': // / 判断是不是注释 单行或者块注释
 		err = lexer.nextChar()
 		if err == nil {
 			if lexer.curr == '/' || lexer.curr == '*' {
 				token, err = lexer.scanComment(lexer.curr)
-			} else { // 不是注释 则 以/后的那一个rune 作为类型返回 nil值的Token
-				token = NewToken(lexer.curr, nil)
+			} else { // 不是注释 则返回/ 之后的rune留给下一个Token
+				token = NewToken('/', nil)
 			}
 		}
 	case lexer.curr == '-': // 如果是- 则判断是不是->
@@ -307,6 +350,43 @@ func (lexer *Lexer) next() (token *Token, err error) {
 	return
 }
 
+// isWhitespace 检查字符是否为空白 超出掩码位数的字符及EOF都不是空白
+func (lexer *Lexer) isWhitespace(ch rune) bool {
+	return ch >= 0 && ch < 64 && lexer.ws&(1<<uint(ch)) != 0
+}
+
+// scanWhitespace 扫描连续的空白 返回TokenWhitespace的Token
+func (lexer *Lexer) scanWhitespace() (*Token, error) {
+	var buff bytes.Buffer
+	for lexer.isWhitespace(lexer.curr) {
+		buff.WriteRune(lexer.curr)
+		if err := lexer.nextChar(); err != nil {
+			return nil, err
+		}
+	}
+	return NewToken(TokenWhitespace, buff.String()), nil
+}
+
+// mark 返回下一个未分析字符的字节偏移及位置
+func (lexer *Lexer) mark() (int, Position) {
+	if lexer.curr == TokenEOF {
+		// 当前字符已被消费 下一个字符紧随其后
+		position := lexer.position
+		position.Column++
+		return lexer.offset, position
+	}
+	return lexer.offset - lexer.width, lexer.position
+}
+
+// skip 出错后丢弃缓存的Token 当前字符是非法utf8字符时将其跳过
+// 其他错误发生时当前字符是尚未分析的下一个字符 保留
+func (lexer *Lexer) skip() {
+	lexer.token = nil
+	if lexer.curr == utf8.RuneError {
+		lexer.curr = TokenEOF
+	}
+}
+
 // scanComment 判断接下来的块是不是注释  返回TokenCOMMENT的Token
 func (lexer *Lexer) scanComment(ch rune) (*Token, error) {
 	// buff存储注释内容
@@ -338,10 +418,6 @@ func (lexer *Lexer) scanComment(ch rune) (*Token, error) {
 		if lexer.curr < 0 {
 			return nil, lexer.newerror("comment not terminated")
 		}
-		if lexer.curr == '\n' {
-			lexer.position.Column = 0
-			lexer.position.Line++
-		}
 		ch0 := lexer.curr
 		err := lexer.nextChar()
 		if err != nil {
@@ -361,28 +437,73 @@ func (lexer *Lexer) scanComment(ch rune) (*Token, error) {
 	return NewToken(TokenCOMMENT, buff.String()), nil
 }
 
-// scanEscape 判断是不是字符串中需要转义的括号内容　\" \'
-func (lexer *Lexer) scanEscape(buff *bytes.Buffer, quote rune) (err error) {
-	err = lexer.nextChar()
-	if err != nil {
+// simpleEscapes 单字符转义 转义字符 -> 对应字符
+var simpleEscapes = map[rune]rune{
+	'a':  '\a',
+	'b':  '\b',
+	'f':  '\f',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'v':  '\v',
+	'0':  0,
+	'\\': '\\',
+	'\'': '\'',
+	'"':  '"',
+}
+
+// scanEscape 扫描字符串中\之后的转义内容 支持单字符转义 \xHH \uHHHH \UHHHHHHHH
+func (lexer *Lexer) scanEscape(buff *bytes.Buffer) (err error) {
+	if err = lexer.nextChar(); err != nil {
 		return
 	}
+	if ch, ok := simpleEscapes[lexer.curr]; ok {
+		buff.WriteRune(ch)
+		return lexer.nextChar()
+	}
+	var digits int
 	switch lexer.curr {
-	case quote:
-		buff.WriteRune(lexer.curr)
-		err = lexer.nextChar()
-		if err != nil {
+	case 'x':
+		digits = 2
+	case 'u':
+		digits = 4
+	case 'U':
+		digits = 8
+	default:
+		if lexer.curr < 0 {
+			return lexer.newerror("escape sequence not terminated")
+		}
+		return lexer.newerror("unknown escape sequence \\%c", lexer.curr)
+	}
+	escape := lexer.curr
+	var val uint32
+	for i := 0; i < digits; i++ {
+		if err = lexer.nextChar(); err != nil {
 			return
 		}
-	default:
-		err = lexer.newerror("illegal char escape")
+		d := digitVal(lexer.curr)
+		if d >= 16 {
+			return lexer.newerror("illegal character in \\%c escape sequence, expect %d hex digits", escape, digits)
+		}
+		val = val<<4 | uint32(d)
 	}
-	return
+	if escape == 'x' {
+		// \xHH 写入单个字节
+		buff.WriteByte(byte(val))
+	} else {
+		if !utf8.ValidRune(rune(val)) {
+			return lexer.newerror("escape sequence is invalid Unicode code point U+%04X", val)
+		}
+		buff.WriteRune(rune(val))
+	}
+	return lexer.nextChar()
 }
 
 // scanString 字符串字面量判断 quote 指明是哪种引号
+// 转义出错时继续扫描到字符串结束 再返回第一个转义错误
 func (lexer *Lexer) scanString(quote rune) (token *Token, err error) {
 	var buff bytes.Buffer
+	var bad error
 	err = lexer.nextChar()
 	if err != nil {
 		return nil, err
@@ -394,8 +515,18 @@ func (lexer *Lexer) scanString(quote rune) (token *Token, err error) {
 			return
 		}
 		if lexer.curr == '\\' {
-			// 判断转义内容 \" \' 需要处理
-			_ = lexer.scanEscape(&buff, quote)
+			// 处理转义内容
+			if err = lexer.scanEscape(&buff); err != nil {
+				if bad == nil {
+					bad = err
+				}
+				// 跳过出错的字符
+				if lexer.curr != quote && lexer.curr != '\n' && lexer.curr >= 0 {
+					if err = lexer.nextChar(); err != nil {
+						return nil, err
+					}
+				}
+			}
 		} else {
 			// 其余作为字符串内容写入
 			buff.WriteRune(lexer.curr)
@@ -409,11 +540,41 @@ func (lexer *Lexer) scanString(quote rune) (token *Token, err error) {
 	if err != nil {
 		return nil, err
 	}
+	if bad != nil {
+		return nil, bad
+	}
 	// 返回TokenSTRING类Token
 	token = NewToken(TokenSTRING, buff.String())
 	return
 }
 
+// scanRawString 原始字符串字面量判断 以`包围 可以跨行 不处理转义 \r被丢弃
+func (lexer *Lexer) scanRawString() (token *Token, err error) {
+	var buff bytes.Buffer
+	err = lexer.nextChar()
+	if err != nil {
+		return nil, err
+	}
+	for lexer.curr != '`' {
+		if lexer.curr < 0 {
+			err = lexer.newerror("raw string literal not terminated")
+			return
+		}
+		if lexer.curr != '\r' {
+			buff.WriteRune(lexer.curr)
+		}
+		if err = lexer.nextChar(); err != nil {
+			return nil, err
+		}
+	}
+	err = lexer.nextChar()
+	if err != nil {
+		return nil, err
+	}
+	token = NewToken(TokenSTRING, buff.String())
+	return
+}
+
 // scanID 判断标识符
 func (lexer *Lexer) scanID() (token *Token, err error) {
 	var buff bytes.Buffer
@@ -442,78 +603,95 @@ func digitVal(ch rune) int {
 	return 16
 }
 
-// scanNum 判断数字字面量
+// scanNum 判断数字字面量 支持0x 0b 0o前缀 旧式0开头的八进制 数字间的_分隔符 十进制及十六进制浮点数
+// 整数的值为任意精度的*big.Int 由使用者根据目标类型检查取值范围 浮点数的值为float64
 func (lexer *Lexer) scanNum() (*Token, error) {
 	var buff bytes.Buffer
+	base := 10
 	if lexer.curr == '0' {
 		buff.WriteRune(lexer.curr)
-		_ = lexer.nextChar()
-		// 判断是是不是十六进制数值
-		if lexer.curr == 'x' || lexer.curr == 'X' {
+		if err := lexer.nextChar(); err != nil {
+			return nil, err
+		}
+		switch lexer.curr {
+		case 'x', 'X':
+			base = 16
+		case 'b', 'B':
+			base = 2
+		case 'o', 'O':
+			base = 8
+		}
+		if base != 10 {
 			buff.WriteRune(lexer.curr)
-			_ = lexer.nextChar()
-			for digitVal(lexer.curr) < 16 {
-				buff.WriteRune(lexer.curr)
-				_ = lexer.nextChar()
+			if err := lexer.nextChar(); err != nil {
+				return nil, err
 			}
-			if buff.Len() < 3 {
-				return nil, lexer.newerror("illegal hexadecimal num")
+		}
+	}
+	if err := lexer.scanDigits(&buff, base); err != nil {
+		return nil, err
+	}
+	float := false
+	// 只有十进制及十六进制支持浮点数 十六进制浮点数必须带p指数
+	if base == 10 || base == 16 {
+		if lexer.curr == '.' {
+			float = true
+			buff.WriteRune(lexer.curr)
+			if err := lexer.nextChar(); err != nil {
+				return nil, err
 			}
-			val, err := strconv.ParseInt(buff.String(), 0, 64)
-			if err != nil {
-				return nil, lexer.newerror(err.Error())
+			if err := lexer.scanDigits(&buff, base); err != nil {
+				return nil, err
+			}
+		}
+		if (base == 10 && (lexer.curr == 'e' || lexer.curr == 'E')) ||
+			(base == 16 && (lexer.curr == 'p' || lexer.curr == 'P')) {
+			float = true
+			if err := lexer.scanExponent(&buff); err != nil {
+				return nil, err
 			}
-			return NewToken(TokenINT, val), nil
 		}
 	}
-	lexer.scanMantissa(&buff)
-	switch lexer.curr {
-	case '.', 'e', 'E':
-		lexer.scanFraction(&buff)
-		lexer.scanExponent(&buff)
-		// 返回浮点数
-		val, err := strconv.ParseFloat(buff.String(), 64)
+	text := buff.String()
+	if float {
+		val, err := strconv.ParseFloat(text, 64)
 		if err != nil {
-			return nil, lexer.newerror(err.Error())
+			return nil, lexer.newerror("invalid float literal %s", text)
 		}
 		return NewToken(TokenFLOAT, val), nil
 	}
-	// 返回整数
-	val, err := strconv.ParseInt(buff.String(), 0, 64)
-	if err != nil {
-		return nil, lexer.newerror(err.Error())
+	// 整数使用与Go相同的语法解析 包括_分隔符的位置检查
+	val, ok := new(big.Int).SetString(text, 0)
+	if !ok {
+		return nil, lexer.newerror("invalid integer literal %s", text)
 	}
 	return NewToken(TokenINT, val), nil
 }
 
-// scanMantissa 扫描尾数　只要是连续0-9数字就合法
-func (lexer *Lexer) scanMantissa(buff *bytes.Buffer) {
-	for isDecimal(lexer.curr) {
+// scanDigits 扫描数字及_分隔符 十六进制扫描0-9a-fA-F 其他进制扫描0-9 非法数字由解析时报错
+func (lexer *Lexer) scanDigits(buff *bytes.Buffer, base int) error {
+	for lexer.curr == '_' || isDecimal(lexer.curr) || (base == 16 && digitVal(lexer.curr) < 16) {
 		buff.WriteRune(lexer.curr)
-		_ = lexer.nextChar()
+		if err := lexer.nextChar(); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-// scanFraction 扫描分数 小数部分
-func (lexer *Lexer) scanFraction(buff *bytes.Buffer) {
-	if lexer.curr == '.' {
-		buff.WriteRune(lexer.curr)
-		_ = lexer.nextChar()
-		lexer.scanMantissa(buff)
+// scanExponent 扫描指数 e或者p之后可以带符号
+func (lexer *Lexer) scanExponent(buff *bytes.Buffer) error {
+	buff.WriteRune(lexer.curr)
+	if err := lexer.nextChar(); err != nil {
+		return err
 	}
-}
-
-// scanExponent 扫描指数
-func (lexer *Lexer) scanExponent(buff *bytes.Buffer) {
-	if lexer.curr == 'e' || lexer.curr == 'E' {
+	if lexer.curr == '-' || lexer.curr == '+' {
 		buff.WriteRune(lexer.curr)
-		_ = lexer.nextChar()
-		if lexer.curr == '-' || lexer.curr == '+' {
-			buff.WriteRune(lexer.curr)
-			_ = lexer.nextChar()
+		if err := lexer.nextChar(); err != nil {
+			return err
 		}
-		lexer.scanMantissa(buff)
 	}
+	return lexer.scanDigits(buff, 10)
 }
 
 // Peek 看一眼 返回分析器当面的token 如果为nil则获取下一个Token保存并返回
@@ -0,0 +1,170 @@
+// @file 	lexer_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	lexer_test
+
+package gslang_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang"
+)
+
+// lex 扫描源码 返回除注释之外的所有符号 出错时返回已扫描的符号及错误
+func lex(source string) ([]*gslang.Token, error) {
+	lexer := gslang.NewLexer("a.gs", strings.NewReader(source))
+	var tokens []*gslang.Token
+	for {
+		token, err := lexer.Next()
+		if err != nil {
+			return tokens, err
+		}
+		if token.Type == gslang.TokenEOF {
+			return tokens, nil
+		}
+		if token.Type != gslang.TokenCOMMENT {
+			tokens = append(tokens, token)
+		}
+	}
+}
+
+func TestLexerStrings(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`"plain"`, "plain"},
+		{`'single'`, "single"},
+		{`"a\tb\nc\rd"`, "a\tb\nc\rd"},
+		{`"\a\b\f\v\0"`, "\a\b\f\v\x00"},
+		{`"\\ \" \'"`, `\ " '`},
+		{`'it\'s'`, "it's"},
+		{`"\x41\x7a"`, "Az"},
+		{`"\xff"`, "\xff"},
+		{`"\u00e9中"`, "é中"},
+		{`"\U0001F600"`, "\U0001F600"},
+		{"`raw \\n \\x41 \"q\"`", `raw \n \x41 "q"`},
+		{"`line1\nline2`", "line1\nline2"},
+		{"`crlf\r\nline`", "crlf\nline"},
+		{"``", ""},
+	}
+	for _, tc := range tests {
+		tokens, err := lex(tc.source)
+		if err != nil {
+			t.Errorf("lex(%s): %s", tc.source, err)
+			continue
+		}
+		if len(tokens) != 1 || tokens[0].Type != gslang.TokenSTRING || tokens[0].Value != tc.want {
+			t.Errorf("lex(%s) = %v want string %q", tc.source, tokens, tc.want)
+		}
+	}
+}
+
+func TestLexerStringErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`"\q"`, "unknown escape sequence \\q\n\ta.gs(1:3)"},
+		{`"\x4"`, `illegal character in \x escape sequence, expect 2 hex digits`},
+		{`"\u12g4"`, `illegal character in \u escape sequence, expect 4 hex digits`},
+		{`"\uD800"`, `escape sequence is invalid Unicode code point U+D800`},
+		{`"\U00110000"`, `escape sequence is invalid Unicode code point U+110000`},
+		{`"abc`, "literal not terminated"},
+		{"\"abc\ndef\"", "literal not terminated"},
+		{"`abc", "raw string literal not terminated"},
+		// 转义出错时返回第一个错误
+		{`"\q\z"`, `unknown escape sequence \q`},
+	}
+	for _, tc := range tests {
+		_, err := lex(tc.source)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("lex(%s) error = %v, want %q", tc.source, err, tc.want)
+		}
+	}
+	// 出错的字符串之后可以继续扫描
+	lexer := gslang.NewLexer("a.gs", strings.NewReader(`"\q" next`))
+	if _, err := lexer.Next(); err == nil {
+		t.Fatal("bad escape accepted")
+	}
+	if token, err := lexer.Next(); err != nil || token.Type != gslang.TokenID || token.Value != "next" {
+		t.Fatalf("token after bad string = %v, %v", token, err)
+	}
+}
+
+// TestLexerPositions 跨行的原始字符串及注释之后的位置正确
+func TestLexerPositions(t *testing.T) {
+	source := "a\n`x\ny` b\n/* c\n d */ e\n\t// f\n  g 中 h"
+	tokens, err := lex(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"a a.gs(1:1)",
+		"x\ny a.gs(2:1)",
+		"b a.gs(3:4)",
+		"e a.gs(5:7)",
+		"g a.gs(7:3)",
+		"中 a.gs(7:5)",
+		"h a.gs(7:7)",
+	}
+	var got []string
+	for _, token := range tokens {
+		got = append(got, token.Value.(string)+" "+token.Pos.String())
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("positions:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// FuzzLexer 任意输入都不会panic 词法分析总会结束 Scanner输出的原文拼接起来为完整源码
+func FuzzLexer(f *testing.F) {
+	seeds := []string{
+		"",
+		"table T {\n\tA int32; // a\n}\n",
+		"/* c */ enum E(byte) { A(-1), B(0x7f) }",
+		"@Attr(Name: \"x\", 1.5e3) -> `raw\nline`",
+		`"\x41é\U0001F600\q" 'it\'s'`,
+		"000000000!\xda",
+		"a\xe4b \xff \"\xe4",
+		"/* unterminated",
+		"`unterminated",
+		"0x 1e+ 99999999999999999999999",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		// 每个符号或者错误至少消费一个字节
+		lexer := gslang.NewLexer("a.gs", strings.NewReader(source))
+		for i := 0; ; i++ {
+			if i > len(source)+1 {
+				t.Fatalf("lexer does not stop on %q", source)
+			}
+			token, err := lexer.Next()
+			if err != nil || token.Type == gslang.TokenEOF {
+				break
+			}
+		}
+		var text strings.Builder
+		scanner := gslang.NewScanner("a.gs", strings.NewReader(source))
+		for i := 0; ; i++ {
+			if i > len(source)+1 {
+				t.Fatalf("scanner does not stop on %q", source)
+			}
+			token := scanner.Scan()
+			if token.Type == gslang.TokenError && token.Err == nil {
+				t.Fatalf("error token without error on %q", source)
+			}
+			text.WriteString(token.Text)
+			if token.Type == gslang.TokenEOF {
+				break
+			}
+		}
+		if text.String() != source {
+			t.Fatalf("scan(%q) text = %q", source, text.String())
+		}
+	})
+}
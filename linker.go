@@ -8,6 +8,8 @@ package gslang
 import (
 	"bytes"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/skea3344/gserrors"
 	"github.com/skea3344/gslang/ast"
@@ -15,6 +17,12 @@ import (
 
 // link 编译器链接方法
 func (cs *CompileS) link(pkg *ast.Package) {
+	start := time.Now()
+	defer func() {
+		cs.mutex.Lock()
+		cs.linkTime += time.Since(start)
+		cs.mutex.Unlock()
+	}()
 	// 新建连接器并访问包
 	linker := &Linker{
 		CompileS: cs,
@@ -26,13 +34,33 @@ func (cs *CompileS) link(pkg *ast.Package) {
 		CompileS: cs,
 	}
 	// 属性连接 确保每一个属性正确挂载在对应目标类型的节点
+	cs.checkContext()
 	pkg.Accept(linker2)
 	// 新建协议连接器并访问包
 	linker3 := &contractLinker{
 		CompileS: cs,
 	}
 	// 协议展开 每一个协议都包含自己所有父协议的所有函数 并按全局编号
+	cs.checkContext()
 	pkg.Accept(linker3)
+	// 新建结构体连接器并访问包
+	linker4 := &structLinker{
+		CompileS: cs,
+	}
+	// 结构体检查 结构体不能通过值类型的域直接或者间接包含自身
+	cs.checkContext()
+	pkg.Accept(linker4)
+	// 所有类型引用连接完成后 报告没有被任何类型引用使用的包引用
+	var names []string
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, ref := range UnusedImports(pkg.Scripts[name]) {
+			cs.warnf(pkg, Pos(ref), "imported and not used: %q", ref.Ref.Name())
+		}
+	}
 }
 
 // Linker 连接器 此连接器是将所有的类型引用连接到对应的类型
@@ -181,6 +209,14 @@ func (linker *Linker) VisitArray(array *ast.Array) ast.Node {
 	return array
 }
 
+// VisitMap 访问字典
+func (linker *Linker) VisitMap(expr *ast.Map) ast.Node {
+	// 访问字典的key类型及value类型
+	expr.Key.Accept(linker)
+	expr.Value.Accept(linker)
+	return expr
+}
+
 // VisitAttr 访问属性
 func (linker *Linker) VisitAttr(attr *ast.Attr) ast.Node {
 	// 访问属性 的类型应用
@@ -188,10 +224,35 @@ func (linker *Linker) VisitAttr(attr *ast.Attr) ast.Node {
 	// 访问属性的参数列表
 	if attr.Args != nil {
 		attr.Args.Accept(linker)
+		linker.checkIntArgs(attr)
 	}
 	return attr
 }
 
+// checkIntArgs 检查属性的整数字面量参数是否在对应域类型的取值范围内
+func (linker *Linker) checkIntArgs(attr *ast.Attr) {
+	table, ok := attr.Type.Ref.(*ast.Table)
+	if !ok {
+		return
+	}
+	for _, field := range table.Fields {
+		arg, ok := EvalFieldInitArg(field, attr.Args)
+		if !ok {
+			continue
+		}
+		val, ok := arg.(*ast.Int)
+		if !ok || val.Big == nil {
+			continue
+		}
+		if _, _, ok := IntRange(field.Type); !ok {
+			continue
+		}
+		if err := CheckInt(val.Big, field.Type); err != nil {
+			linker.errorf(Pos(arg), "attr(%s) field %s: %s\n\tsee: %s", attr, field, err, Pos(field))
+		}
+	}
+}
+
 // VisitArgs 访问参数列表
 func (linker *Linker) VisitArgs(args *ast.Args) ast.Node {
 	// 轮询访问参数列表中单个参数
@@ -242,6 +303,7 @@ func (linker *Linker) VisitTypeRef(ref *ast.TypeRef) ast.Node {
 				// 在引用的包的类型列表中查找对应名字的类型并引用
 				if expr, ok := pkg.Ref.Types[ref.NamePath[1]]; ok {
 					ref.Ref = expr
+					markImportUsed(ref, pkg)
 					return ref
 				}
 			} else { // 如果不是引用包中的类型 则判断是否是当前包中的枚举类型
@@ -260,6 +322,7 @@ func (linker *Linker) VisitTypeRef(ref *ast.TypeRef) ast.Node {
 					if enum, ok := expr.(*ast.Enum); ok {
 						if val, ok := enum.Values[ref.NamePath[2]]; ok {
 							ref.Ref = val
+							markImportUsed(ref, pkg)
 							return ref
 						}
 					}
@@ -354,7 +417,7 @@ func (linker *contractLinker) unwind(expr *ast.Contract, stack []*ast.Contract)
 					Pos(old),
 					Pos(clone))
 			}
-			method.SetParent(expr)
+			clone.SetParent(expr)
 			expr.Methods[clone.Name()] = clone
 		}
 		modify = modify + uint16(len(contract.Methods))
@@ -366,6 +429,79 @@ func (linker *contractLinker) unwind(expr *ast.Contract, stack []*ast.Contract)
 	return stack
 }
 
+// structLinker 结构体连接器 检查结构体之间值类型的循环引用
+type structLinker struct {
+	*CompileS        // 所属编译器
+	ast.EmptyVisitor // 内嵌空访问者
+}
+
+// VisitPackage 访问包
+func (linker *structLinker) VisitPackage(pkg *ast.Package) ast.Node {
+	// 轮询访问包内代码列表
+	for _, script := range pkg.Scripts {
+		script.Accept(linker)
+	}
+	return pkg
+}
+
+// VisitScript 访问代码
+func (linker *structLinker) VisitScript(script *ast.Script) ast.Node {
+	// 轮询访问代码内的类型
+	for _, expr := range script.Types {
+		expr.Accept(linker)
+	}
+	return script
+}
+
+// VisitTable 访问表或者结构体
+func (linker *structLinker) VisitTable(table *ast.Table) ast.Node {
+	if IsStruct(table) {
+		linker.layout(table, nil)
+	}
+	return table
+}
+
+// layout 检查结构体布局 结构体及结构体数组类型的域按值存储 不能直接或者间接包含结构体自身
+// 切片 字典 以及表类型的域是间接引用 允许循环
+func (linker *structLinker) layout(expr *ast.Table, stack []*ast.Field) {
+	// 如果结构体已经检查过 则直接返回
+	if _, ok := expr.Extra("layout"); ok {
+		return
+	}
+	var buff bytes.Buffer
+	// 在域栈中查找是否存在从当前结构体出发的域 如果有则报错
+	for _, field := range stack {
+		if field.Parent() == ast.Node(expr) || buff.Len() != 0 {
+			buff.WriteString(fmt.Sprintf("\t%s.%s %s contains\n\t\tsee: %s\n",
+				field.Parent(), field, TypeName(field.Type), Pos(field)))
+		}
+	}
+	if buff.Len() != 0 {
+		linker.errorf(Pos(expr), "circular struct layout:\n%s\t%s", buff.String(), expr)
+	}
+	// 按值存储的结构体域 继续检查域的类型
+	for _, field := range expr.Fields {
+		if target, ok := valueStruct(field.Type); ok {
+			linker.layout(target, append(stack, field))
+		}
+	}
+	// 标记当前结构体已经检查
+	expr.NewExtra("layout", true)
+}
+
+// valueStruct 返回按值存储的域类型引用的结构体 即结构体或者结构体数组
+func valueStruct(expr ast.Expr) (*ast.Table, bool) {
+	switch node := expr.(type) {
+	case *ast.TypeRef:
+		if table, ok := node.Ref.(*ast.Table); ok && IsStruct(table) {
+			return table, true
+		}
+	case *ast.Array:
+		return valueStruct(node.Element)
+	}
+	return nil, false
+}
+
 // attrLinker 属性连接器
 type attrLinker struct {
 	*CompileS                         // 所属编译器
@@ -388,7 +524,7 @@ func (linker *attrLinker) VisitPackage(pkg *ast.Package) ast.Node {
 			}
 		}
 	} else {
-		if pkg1, ok := linker.Loaded[GSLangPackage]; ok {
+		if pkg1, ok := linker.loaded(GSLangPackage); ok {
 			if expr, ok := pkg1.Types[GSLangAttrTarget]; ok {
 				if enum, ok := expr.(*ast.Enum); ok {
 					linker.attrTarget = Enum(enum)
@@ -0,0 +1,138 @@
+// @file 	linker_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	linker_test
+
+package gslang_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// TestContractUnwind 继承的函数复制到子协议 父协议的函数不受影响
+func TestContractUnwind(t *testing.T) {
+	cs := gstest.Compile(t, map[string]string{
+		"demo/shop/shop.gs": `
+contract Shop {
+    Get(id uint64);
+    Count() -> (uint32);
+}
+
+contract AdminShop(Shop) {
+    Clear();
+}
+`,
+	}, "demo/shop")
+	shop := gstest.Type(t, cs, "demo/shop", "Shop").(*ast.Contract)
+	admin := gstest.Type(t, cs, "demo/shop", "AdminShop").(*ast.Contract)
+	ids := map[string]uint16{"Get": 0, "Count": 1, "Clear": 2}
+	for name, id := range ids {
+		method, ok := admin.Methods[name]
+		if !ok {
+			t.Fatalf("AdminShop has no method %s", name)
+		}
+		if method.ID != id {
+			t.Errorf("AdminShop.%s id = %d want %d", name, method.ID, id)
+		}
+		if method.Parent() != admin {
+			t.Errorf("AdminShop.%s parent = %v", name, method.Parent())
+		}
+	}
+	if len(shop.Methods) != 2 {
+		t.Fatalf("Shop methods = %v", shop.Methods)
+	}
+	for name, method := range shop.Methods {
+		if method.Parent() != shop {
+			t.Errorf("Shop.%s parent changed to %v", name, method.Parent())
+		}
+		if method == admin.Methods[name] {
+			t.Errorf("Shop.%s shared with AdminShop", name)
+		}
+	}
+}
+
+// compileError 编译代码 返回编译错误 编译成功时终止测试
+func compileError(t *testing.T, files map[string]string, packages ...string) error {
+	t.Helper()
+	_, err := gslang.Compile(context.Background(), gslang.Options{
+		Packages: packages,
+		GOPATH:   []string{gstest.GOPATH(t, files)},
+	})
+	if err == nil {
+		t.Fatalf("compile %v succeeded", packages)
+	}
+	return err
+}
+
+// TestStructCycle 结构体不能通过值类型的域直接或者间接包含自身
+func TestStructCycle(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want []string
+	}{
+		{"self", `
+struct A {
+    X int32;
+    Self A;
+}
+`, []string{"circular struct layout", "A.Self demo/shop.A contains"}},
+		{"array", `
+struct A {
+    B B;
+}
+
+struct B {
+    Items [2]A;
+}
+`, []string{"circular struct layout", "A.B demo/shop.B contains", "B.Items [2]demo/shop.A contains"}},
+		{"indirect", `
+struct A {
+    B B;
+}
+
+struct B {
+    C C;
+}
+
+struct C {
+    A A;
+    X int32;
+}
+`, []string{"A.B demo/shop.B contains", "B.C demo/shop.C contains", "C.A demo/shop.A contains"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := compileError(t, map[string]string{"demo/shop/shop.gs": tc.code}, "demo/shop")
+			for _, want := range tc.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Fatalf("error does not contain %q:\n%s", want, err)
+				}
+			}
+		})
+	}
+	// 切片 字典 表类型的域是间接引用 允许循环
+	gstest.Compile(t, map[string]string{"demo/shop/shop.gs": `
+struct A {
+    Next []A;
+    Index map[int32]A;
+    Owner T;
+    B B;
+}
+
+struct B {
+    Parents []A;
+}
+
+table T {
+    Self T;
+    A A;
+}
+`}, "demo/shop")
+}
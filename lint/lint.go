@@ -0,0 +1,283 @@
+// @file 	lint.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	lint
+
+// Package lint 对已连接的包进行风格及质量检查
+//
+// 每条规则都是一个ast.Visitor 由遍历器依次访问包内的所有节点 规则通过Pass报告问题
+// 规则可以在配置文件中按包启用或者禁用 也可以在源码中使用名为NoLint的属性禁止:
+//
+//	@AttrUsage(AttrTarget.Package|AttrTarget.Script|AttrTarget.Table|AttrTarget.Struct|AttrTarget.Field|AttrTarget.Enum|AttrTarget.EnumVal|AttrTarget.Contract|AttrTarget.Method)
+//	table NoLint {}
+//
+//	@NoLint("naming", "doc")
+//	table legacy_item { ... }
+//
+// NoLint可以声明在任意包中 没有参数时禁止所有规则 属性对节点本身及其所有子节点有效
+package lint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// 错误码
+var (
+	ErrLint = errors.New("lint error")
+)
+
+// NoLintAttr 禁止检查的属性类型名字
+const NoLintAttr = "NoLint"
+
+// Issue 检查发现的问题
+type Issue struct {
+	Rule    string          `json:"rule"`    // 规则名字
+	Pos     gslang.Position `json:"pos"`     // 问题位置
+	Message string          `json:"message"` // 问题描述
+}
+
+// String 实现fmt.Stringer接口
+func (issue *Issue) String() string {
+	return fmt.Sprintf("%s: %s (%s)", issue.Pos, issue.Message, issue.Rule)
+}
+
+// Rule 已注册的规则
+type Rule struct {
+	Name    string                       // 规则名字
+	Doc     string                       // 规则说明
+	Enabled bool                         // 是否默认启用
+	New     func(pass *Pass) ast.Visitor // 为每次检查新建规则的访问者
+}
+
+// rules 已注册的规则 名字 -> 规则
+var rules = make(map[string]*Rule)
+
+// Register 注册规则 重名时panic
+func Register(rule *Rule) {
+	if _, ok := rules[rule.Name]; ok {
+		gserrors.Panicf(ErrLint, "duplicate lint rule %s", rule.Name)
+	}
+	rules[rule.Name] = rule
+}
+
+// Rules 返回按名字排序的已注册规则
+func Rules() []*Rule {
+	var result []*Rule
+	for _, rule := range rules {
+		result = append(result, rule)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Finisher 需要在遍历结束后报告问题的规则实现此接口
+type Finisher interface {
+	Finish()
+}
+
+// Config 检查配置 Packages中的配置覆盖顶层配置
+type Config struct {
+	Enable   []string                          `json:"enable,omitempty"`   // 额外启用的规则
+	Disable  []string                          `json:"disable,omitempty"`  // 禁用的规则
+	Options  map[string]map[string]interface{} `json:"options,omitempty"`  // 规则选项 规则名字 -> 选项
+	Packages map[string]*Config                `json:"packages,omitempty"` // 按包名的配置
+}
+
+// LoadConfig 读取JSON格式的配置文件
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, gserrors.Newf(ErrLint, "invalid lint config %s: %s", path, err)
+	}
+	if err := config.check(); err != nil {
+		return nil, gserrors.Newf(ErrLint, "invalid lint config %s: %s", path, err)
+	}
+	return config, nil
+}
+
+// check 检查配置中的规则名字
+func (config *Config) check() error {
+	names := append(append([]string(nil), config.Enable...), config.Disable...)
+	for name := range config.Options {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if _, ok := rules[name]; !ok {
+			return fmt.Errorf("unknown rule %s", name)
+		}
+	}
+	for _, pkg := range config.Packages {
+		if err := pkg.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enabled 返回包启用的规则
+func (config *Config) enabled(packageName string) map[string]bool {
+	result := make(map[string]bool)
+	for name, rule := range rules {
+		result[name] = rule.Enabled
+	}
+	apply := func(config *Config) {
+		for _, name := range config.Enable {
+			result[name] = true
+		}
+		for _, name := range config.Disable {
+			result[name] = false
+		}
+	}
+	apply(config)
+	if pkg, ok := config.Packages[packageName]; ok {
+		apply(pkg)
+	}
+	return result
+}
+
+// options 返回包内规则的选项
+func (config *Config) options(packageName string, rule string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for name, value := range config.Options[rule] {
+		result[name] = value
+	}
+	if pkg, ok := config.Packages[packageName]; ok {
+		for name, value := range pkg.Options[rule] {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// Pass 一条规则对一个包的单次检查
+type Pass struct {
+	Package *ast.Package           // 被检查的包
+	Options map[string]interface{} // 规则选项
+	rule    string                 // 规则名字
+	issues  *[]*Issue              // 问题列表
+}
+
+// Int 返回整数选项 没有设置时返回默认值
+func (pass *Pass) Int(name string, value int) int {
+	if v, ok := pass.Options[name].(float64); ok {
+		return int(v)
+	}
+	return value
+}
+
+// Text 返回字符串选项 没有设置时返回默认值
+func (pass *Pass) Text(name string, value string) string {
+	if v, ok := pass.Options[name].(string); ok {
+		return v
+	}
+	return value
+}
+
+// Reportf 报告节点上的问题 节点或者其父节点用NoLint禁止了该规则时忽略
+func (pass *Pass) Reportf(node ast.Node, format string, args ...interface{}) {
+	if suppressed(node, pass.rule) {
+		return
+	}
+	*pass.issues = append(*pass.issues, &Issue{
+		Rule:    pass.rule,
+		Pos:     gslang.Pos(node),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// suppressed 检查节点及其父节点 所属代码 所属包上是否有禁止规则的NoLint属性
+func suppressed(node ast.Node, rule string) bool {
+	var nodes []ast.Node
+	for current := node; current != nil; current = current.Parent() {
+		nodes = append(nodes, current)
+	}
+	if expr, ok := node.(ast.Expr); ok {
+		nodes = append(nodes, expr.Script())
+	}
+	if pkg := node.Package(); pkg != nil {
+		nodes = append(nodes, pkg)
+	}
+	for _, current := range nodes {
+		for _, attr := range current.Attrs() {
+			if noLint(attr, rule) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// noLint 检查属性是否为禁止指定规则的NoLint
+func noLint(attr *ast.Attr, rule string) bool {
+	if attr.Type == nil || len(attr.Type.NamePath) == 0 || attr.Type.NamePath[len(attr.Type.NamePath)-1] != NoLintAttr {
+		return false
+	}
+	args, ok := attr.Args.(*ast.Args)
+	if !ok || len(args.Items) == 0 {
+		return true
+	}
+	for _, item := range args.Items {
+		if s, ok := item.(*ast.String); ok && (s.Value == rule || s.Value == "all") {
+			return true
+		}
+	}
+	return false
+}
+
+// Linter 检查器
+type Linter struct {
+	config *Config
+}
+
+// New 使用配置新建检查器 config为nil时使用默认配置
+func New(config *Config) *Linter {
+	if config == nil {
+		config = &Config{}
+	}
+	return &Linter{config: config}
+}
+
+// Lint 使用启用的规则检查包 返回按位置排序的问题列表
+func (linter *Linter) Lint(pkg *ast.Package) []*Issue {
+	var issues []*Issue
+	enabled := linter.config.enabled(pkg.Name())
+	for _, rule := range Rules() {
+		if !enabled[rule.Name] {
+			continue
+		}
+		pass := &Pass{
+			Package: pkg,
+			Options: linter.config.options(pkg.Name(), rule.Name),
+			rule:    rule.Name,
+			issues:  &issues,
+		}
+		visitor := rule.New(pass)
+		walk(pkg, visitor)
+		if finisher, ok := visitor.(Finisher); ok {
+			finisher.Finish()
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i].Pos, issues[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return issues
+}
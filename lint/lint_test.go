@@ -0,0 +1,204 @@
+// @file 	lint_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	lint_test
+
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// testFiles 测试使用的代码
+var testFiles = map[string]string{
+	"demo/base/base.gs": `
+// 坐标
+struct Point {
+    x int32;
+    y int32;
+}
+`,
+	"demo/shop/lint.gs": `
+@gslang.AttrUsage(gslang.AttrTarget.Table|gslang.AttrTarget.Struct|gslang.AttrTarget.Field|gslang.AttrTarget.Enum|gslang.AttrTarget.EnumVal|gslang.AttrTarget.Contract|gslang.AttrTarget.Method)
+// NoLint 禁止检查
+table NoLint {}
+`,
+	"demo/shop/shop.gs": `
+import "demo/base"
+
+// 物品
+table Item {
+    id uint64;
+    item_name string;
+}
+
+table bad_table {}
+
+// 颜色
+enum Color(byte) {
+    Red(1), green(2)
+}
+
+// 状态
+enum State(byte) {
+    None(0)
+}
+
+// 网格
+struct Grid {
+    cells [100]int32;
+    edge [65]byte;
+    small [64]byte;
+}
+
+@NoLint("naming", "doc")
+table legacy_item {
+    Old_Name string;
+}
+
+@NoLint("naming")
+// 只禁止命名规则
+table old_item {}
+
+// 禁止所有规则
+@NoLint
+enum Quiet(byte) {
+    loud(1)
+}
+
+// 商店
+contract Shop {
+    // 查询
+    Get(id uint64) -> (Item);
+    put(Item);
+}
+`,
+}
+
+// lint 编译测试代码并使用配置检查demo/shop 返回 规则: 描述 形式的问题列表
+func lint(t *testing.T, config *Config) []string {
+	cs := gstest.Compile(t, testFiles, "demo/shop")
+	pkg := gstest.Type(t, cs, "demo/shop", "Item").Package()
+	var result []string
+	for _, issue := range New(config).Lint(pkg) {
+		if issue.Pos.ShortName() != "shop.gs" && issue.Pos.ShortName() != "lint.gs" {
+			t.Errorf("issue outside demo/shop: %s", issue)
+		}
+		result = append(result, issue.Rule+": "+issue.Message)
+	}
+	return result
+}
+
+func TestLint(t *testing.T) {
+	got := lint(t, nil)
+	want := []string{
+		`unused-import: import "demo/base" is never used`,
+		`naming: field name item_name should be camelCase like itemName`,
+		`doc: table bad_table has no doc comment`,
+		`naming: table name bad_table should be PascalCase like BadTable`,
+		`enum-zero: enum Color has no zero value`,
+		`naming: enum value name green should be PascalCase like Green`,
+		`large-array: struct field Grid.cells contains a fixed array of 100 elements, more than 64`,
+		`large-array: struct field Grid.edge contains a fixed array of 65 elements, more than 64`,
+		`doc: method put has no doc comment`,
+		`naming: method name put should be PascalCase like Put`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("issues:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestConfig(t *testing.T) {
+	config := &Config{
+		Disable: []string{"doc", "unused-import", "enum-zero"},
+		Options: map[string]map[string]interface{}{"large-array": {"max": float64(80)}},
+		Packages: map[string]*Config{
+			"demo/shop": {
+				Disable: []string{"naming"},
+				Options: map[string]map[string]interface{}{"max-methods": {"max": float64(1)}},
+			},
+		},
+	}
+	got := lint(t, config)
+	want := []string{
+		`large-array: struct field Grid.cells contains a fixed array of 100 elements, more than 80`,
+		`max-methods: contract Shop has 2 methods, more than 1`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("issues:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	// 域名字使用大驼峰时 小驼峰的域被报告
+	config = &Config{
+		Enable:  []string{"naming"},
+		Disable: []string{"doc", "unused-import", "enum-zero", "large-array"},
+		Options: map[string]map[string]interface{}{"naming": {"fields": "pascal"}},
+	}
+	got = lint(t, config)
+	if len(got) == 0 || !strings.Contains(strings.Join(got, "\n"), "field name id should be PascalCase like Id") {
+		t.Fatalf("pascal field issues:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	config, err := LoadConfig(write("ok.json", `{"disable": ["doc"], "packages": {"demo/shop": {"options": {"max-methods": {"max": 4}}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled := config.enabled("demo/shop"); enabled["doc"] || !enabled["naming"] {
+		t.Errorf("enabled = %v", enabled)
+	}
+	if options := config.options("demo/shop", "max-methods"); options["max"] != float64(4) {
+		t.Errorf("options = %v", options)
+	}
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{`{"enable": ["nope"]}`, "unknown rule nope"},
+		{`{"packages": {"demo/shop": {"options": {"nope": {}}}}}`, "unknown rule nope"},
+		{`{"disable": "doc"}`, "invalid lint config"},
+	}
+	for _, tc := range tests {
+		_, err := LoadConfig(write("bad.json", tc.content))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("LoadConfig(%s) error = %v, want %q", tc.content, err, tc.want)
+		}
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadConfig of missing file succeeded")
+	}
+}
+
+func TestRules(t *testing.T) {
+	var names []string
+	for _, rule := range Rules() {
+		names = append(names, rule.Name)
+		if rule.Doc == "" || rule.New == nil {
+			t.Errorf("rule %s has no doc or constructor", rule.Name)
+		}
+	}
+	want := "doc enum-zero large-array max-methods naming unused-import"
+	if got := strings.Join(names, " "); got != want {
+		t.Fatalf("Rules = %s want %s", got, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate Register did not panic")
+		}
+	}()
+	Register(&Rule{Name: "doc", New: func(*Pass) ast.Visitor { return &ast.EmptyVisitor{} }})
+}
@@ -0,0 +1,253 @@
+// @file 	rules.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	rules
+
+package lint
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/gen"
+)
+
+func init() {
+	Register(&Rule{
+		Name:    "naming",
+		Doc:     "types, enum values and methods are PascalCase, fields are camelCase (option fields: camel|pascal)",
+		Enabled: true,
+		New:     func(pass *Pass) ast.Visitor { return &namingRule{pass: pass, fields: pass.Text("fields", "camel")} },
+	})
+	Register(&Rule{
+		Name:    "doc",
+		Doc:     "types and methods have doc comments",
+		Enabled: true,
+		New:     func(pass *Pass) ast.Visitor { return &docRule{pass: pass} },
+	})
+	Register(&Rule{
+		Name:    "unused-import",
+		Doc:     "every import is referenced by a type reference in the same script",
+		Enabled: true,
+		New:     func(pass *Pass) ast.Visitor { return &unusedImportRule{pass: pass} },
+	})
+	Register(&Rule{
+		Name:    "enum-zero",
+		Doc:     "enums declare a value equal to zero",
+		Enabled: true,
+		New:     func(pass *Pass) ast.Visitor { return &enumZeroRule{pass: pass} },
+	})
+	Register(&Rule{
+		Name:    "large-array",
+		Doc:     "structs do not contain fixed arrays longer than max elements (option max, default 64)",
+		Enabled: true,
+		New:     func(pass *Pass) ast.Visitor { return &largeArrayRule{pass: pass, max: pass.Int("max", 64)} },
+	})
+	Register(&Rule{
+		Name:    "max-methods",
+		Doc:     "contracts have at most max methods including inherited ones (option max, default 32)",
+		Enabled: true,
+		New:     func(pass *Pass) ast.Visitor { return &maxMethodsRule{pass: pass, max: pass.Int("max", 32)} },
+	})
+}
+
+// isPascal 检查名字是否为大驼峰 首字母大写且不含下划线及连字符
+func isPascal(name string) bool {
+	ch, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(ch) && !strings.ContainsAny(name, "_-")
+}
+
+// isCamel 检查名字是否为小驼峰 首字母小写且不含下划线及连字符
+func isCamel(name string) bool {
+	ch, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsLower(ch) && !strings.ContainsAny(name, "_-")
+}
+
+// namingRule 命名规则
+type namingRule struct {
+	ast.EmptyVisitor
+	pass   *Pass
+	fields string // 域的命名风格 camel或者pascal
+}
+
+// pascal 检查名字是否为大驼峰
+func (rule *namingRule) pascal(node ast.Node, what string) {
+	if !isPascal(node.Name()) {
+		rule.pass.Reportf(node, "%s name %s should be PascalCase like %s", what, node.Name(), gen.PascalCase(node.Name()))
+	}
+}
+
+// VisitTable 实现ast.Visitor接口
+func (rule *namingRule) VisitTable(table *ast.Table) ast.Node {
+	if gslang.IsStruct(table) {
+		rule.pascal(table, "struct")
+	} else {
+		rule.pascal(table, "table")
+	}
+	return table
+}
+
+// VisitEnum 实现ast.Visitor接口
+func (rule *namingRule) VisitEnum(enum *ast.Enum) ast.Node {
+	rule.pascal(enum, "enum")
+	return enum
+}
+
+// VisitEnumVal 实现ast.Visitor接口
+func (rule *namingRule) VisitEnumVal(val *ast.EnumVal) ast.Node {
+	rule.pascal(val, "enum value")
+	return val
+}
+
+// VisitContract 实现ast.Visitor接口
+func (rule *namingRule) VisitContract(contract *ast.Contract) ast.Node {
+	rule.pascal(contract, "contract")
+	return contract
+}
+
+// VisitMethod 实现ast.Visitor接口
+func (rule *namingRule) VisitMethod(method *ast.Method) ast.Node {
+	rule.pascal(method, "method")
+	return method
+}
+
+// VisitField 实现ast.Visitor接口
+func (rule *namingRule) VisitField(field *ast.Field) ast.Node {
+	if rule.fields == "pascal" {
+		rule.pascal(field, "field")
+	} else if !isCamel(field.Name()) {
+		rule.pass.Reportf(field, "field name %s should be camelCase like %s", field.Name(), gen.CamelCase(field.Name()))
+	}
+	return field
+}
+
+// docRule 文档注释规则
+type docRule struct {
+	ast.EmptyVisitor
+	pass *Pass
+}
+
+// check 检查节点是否有注释
+func (rule *docRule) check(node ast.Node, what string) {
+	if len(gslang.Comments(node)) == 0 {
+		rule.pass.Reportf(node, "%s %s has no doc comment", what, node.Name())
+	}
+}
+
+// VisitTable 实现ast.Visitor接口
+func (rule *docRule) VisitTable(table *ast.Table) ast.Node {
+	if gslang.IsStruct(table) {
+		rule.check(table, "struct")
+	} else {
+		rule.check(table, "table")
+	}
+	return table
+}
+
+// VisitEnum 实现ast.Visitor接口
+func (rule *docRule) VisitEnum(enum *ast.Enum) ast.Node {
+	rule.check(enum, "enum")
+	return enum
+}
+
+// VisitContract 实现ast.Visitor接口
+func (rule *docRule) VisitContract(contract *ast.Contract) ast.Node {
+	rule.check(contract, "contract")
+	return contract
+}
+
+// VisitMethod 实现ast.Visitor接口
+func (rule *docRule) VisitMethod(method *ast.Method) ast.Node {
+	rule.check(method, "method")
+	return method
+}
+
+// unusedImportRule 未使用的包引用规则 使用连接器记录的包引用使用情况
+type unusedImportRule struct {
+	ast.EmptyVisitor
+	pass *Pass
+}
+
+// VisitScript 实现ast.Visitor接口
+func (rule *unusedImportRule) VisitScript(script *ast.Script) ast.Node {
+	for _, imported := range gslang.UnusedImports(script) {
+		rule.pass.Reportf(imported, "import %q is never used", imported.Ref.Name())
+	}
+	return script
+}
+
+// enumZeroRule 枚举零值规则
+type enumZeroRule struct {
+	ast.EmptyVisitor
+	pass *Pass
+}
+
+// VisitEnum 实现ast.Visitor接口
+func (rule *enumZeroRule) VisitEnum(enum *ast.Enum) ast.Node {
+	for _, val := range enum.Values {
+		if val.Value == 0 {
+			return enum
+		}
+	}
+	rule.pass.Reportf(enum, "enum %s has no zero value", enum.Name())
+	return enum
+}
+
+// largeArrayRule 结构体中的大数组规则
+type largeArrayRule struct {
+	ast.EmptyVisitor
+	pass *Pass
+	max  int
+}
+
+// VisitTable 实现ast.Visitor接口
+func (rule *largeArrayRule) VisitTable(table *ast.Table) ast.Node {
+	if !gslang.IsStruct(table) {
+		return table
+	}
+	for _, field := range table.Fields {
+		if length, ok := rule.largest(field.Type); ok {
+			rule.pass.Reportf(field, "struct field %s.%s contains a fixed array of %d elements, more than %d",
+				table.Name(), field.Name(), length, rule.max)
+		}
+	}
+	return table
+}
+
+// largest 查找类型表达式中超过上限的最大数组
+func (rule *largeArrayRule) largest(expr ast.Expr) (int, bool) {
+	switch node := expr.(type) {
+	case *ast.Array:
+		length, found := int(node.Length), int(node.Length) > rule.max
+		if inner, ok := rule.largest(node.Element); ok && inner > length {
+			return inner, true
+		}
+		return length, found
+	case *ast.List:
+		return rule.largest(node.Element)
+	case *ast.Map:
+		if length, ok := rule.largest(node.Value); ok {
+			return length, ok
+		}
+		return rule.largest(node.Key)
+	}
+	return 0, false
+}
+
+// maxMethodsRule 协议函数个数规则
+type maxMethodsRule struct {
+	ast.EmptyVisitor
+	pass *Pass
+	max  int
+}
+
+// VisitContract 实现ast.Visitor接口
+func (rule *maxMethodsRule) VisitContract(contract *ast.Contract) ast.Node {
+	if len(contract.Methods) > rule.max {
+		rule.pass.Reportf(contract, "contract %s has %d methods, more than %d", contract.Name(), len(contract.Methods), rule.max)
+	}
+	return contract
+}
@@ -0,0 +1,140 @@
+// @file 	walk.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	walk
+
+package lint
+
+import (
+	"sort"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// walker 遍历包内所有节点 对每个节点调用规则访问者的对应方法
+// 规则访问者只需要处理自己关心的节点 不需要自己遍历子节点
+type walker struct {
+	visitor ast.Visitor
+}
+
+// walk 按代码文件名及声明顺序遍历包
+func walk(pkg *ast.Package, visitor ast.Visitor) {
+	w := &walker{visitor: visitor}
+	pkg.Accept(visitor)
+	w.attrs(pkg)
+	var names []string
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		script := pkg.Scripts[name]
+		script.Accept(visitor)
+		w.attrs(script)
+		for _, expr := range script.Types {
+			w.typeDecl(expr)
+		}
+	}
+}
+
+// attrs 遍历节点的属性
+func (w *walker) attrs(node ast.Node) {
+	for _, attr := range node.Attrs() {
+		attr.Accept(w.visitor)
+		w.expr(attr.Type)
+		if attr.Args != nil {
+			w.expr(attr.Args)
+		}
+	}
+}
+
+// typeDecl 遍历类型声明
+func (w *walker) typeDecl(expr ast.Expr) {
+	expr.Accept(w.visitor)
+	w.attrs(expr)
+	switch node := expr.(type) {
+	case *ast.Table:
+		for _, field := range node.Fields {
+			field.Accept(w.visitor)
+			w.attrs(field)
+			w.expr(field.Type)
+		}
+	case *ast.Enum:
+		for _, val := range gslang.EnumVals(node) {
+			val.Accept(w.visitor)
+			w.attrs(val)
+		}
+	case *ast.Contract:
+		for _, base := range node.Bases {
+			w.expr(base)
+		}
+		for _, method := range ownMethods(node) {
+			method.Accept(w.visitor)
+			w.attrs(method)
+			for _, param := range method.Params {
+				w.param(param)
+			}
+			for _, param := range method.Return {
+				w.param(param)
+			}
+		}
+	}
+}
+
+// param 遍历参数
+func (w *walker) param(param *ast.Param) {
+	param.Accept(w.visitor)
+	w.attrs(param)
+	w.expr(param.Type)
+}
+
+// expr 遍历类型表达式及属性参数
+func (w *walker) expr(expr ast.Expr) {
+	expr.Accept(w.visitor)
+	switch node := expr.(type) {
+	case *ast.List:
+		w.expr(node.Element)
+	case *ast.Array:
+		w.expr(node.Element)
+	case *ast.Map:
+		w.expr(node.Key)
+		w.expr(node.Value)
+	case *ast.Args:
+		for _, item := range node.Items {
+			w.expr(item)
+		}
+	case *ast.NamedArgs:
+		var names []string
+		for name := range node.Items {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			w.expr(node.Items[name])
+		}
+	case *ast.BinaryOp:
+		w.expr(node.Left)
+		w.expr(node.Right)
+	}
+}
+
+// ownMethods 返回协议自身声明的函数 继承的函数由父协议负责检查
+func ownMethods(contract *ast.Contract) []*ast.Method {
+	var methods []*ast.Method
+	for _, method := range contract.Methods {
+		inherited := false
+		for _, base := range contract.Bases {
+			if parent, ok := base.Ref.(*ast.Contract); ok {
+				if _, ok := parent.Methods[method.Name()]; ok {
+					inherited = true
+				}
+			}
+		}
+		if !inherited {
+			methods = append(methods, method)
+		}
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].ID < methods[j].ID })
+	return methods
+}
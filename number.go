@@ -0,0 +1,101 @@
+// @file 	number.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	number
+
+package gslang
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/skea3344/gslang/ast"
+)
+
+// 字面量整数的最大取值范围 即int64的最小值到uint64的最大值
+var (
+	minLiteral = new(big.Int).SetInt64(-1 << 63)
+	maxLiteral = new(big.Int).SetUint64(^uint64(0))
+)
+
+// intRange 返回指定位数及有无符号的整数取值范围
+func intRange(bits uint, signed bool) (min, max *big.Int) {
+	one := big.NewInt(1)
+	if signed {
+		max = new(big.Int).Sub(new(big.Int).Lsh(one, bits-1), one)
+		return new(big.Int).Neg(new(big.Int).Add(max, one)), max
+	}
+	return new(big.Int), new(big.Int).Sub(new(big.Int).Lsh(one, bits), one)
+}
+
+// BuiltinRange 返回整数内置类型的取值范围 key为内置类型关键字 如KeyInt32
+func BuiltinRange(key rune) (min, max *big.Int, ok bool) {
+	switch key {
+	case KeyByte:
+		min, max = intRange(8, false)
+	case KeySByte:
+		min, max = intRange(8, true)
+	case KeyInt16:
+		min, max = intRange(16, true)
+	case KeyUInt16:
+		min, max = intRange(16, false)
+	case KeyInt32:
+		min, max = intRange(32, true)
+	case KeyUInt32:
+		min, max = intRange(32, false)
+	case KeyInt64:
+		min, max = intRange(64, true)
+	case KeyUInt64:
+		min, max = intRange(64, false)
+	default:
+		return nil, nil, false
+	}
+	return min, max, true
+}
+
+// IntRange 返回整数类型的取值范围 支持整数内置类型 枚举以及引用它们的类型引用
+func IntRange(expr ast.Expr) (min, max *big.Int, ok bool) {
+	if key, ok := Builtin(expr); ok {
+		return BuiltinRange(key)
+	}
+	switch node := expr.(type) {
+	case *ast.TypeRef:
+		if node.Ref != nil {
+			return IntRange(node.Ref)
+		}
+	case *ast.Enum:
+		min, max = intRange(node.Length*8, node.Signed)
+		return min, max, true
+	}
+	return nil, nil, false
+}
+
+// CheckInt 检查任意精度整数是否在目标整数类型的取值范围内 目标不是整数类型时也返回错误
+func CheckInt(val *big.Int, expr ast.Expr) error {
+	min, max, ok := IntRange(expr)
+	if !ok {
+		return fmt.Errorf("%s is not an integer type", TypeName(expr))
+	}
+	if val.Cmp(min) < 0 || val.Cmp(max) > 0 {
+		return fmt.Errorf("%s overflows %s, range is [%s, %s]", val, TypeName(expr), min, max)
+	}
+	return nil
+}
+
+// literalInRange 检查字面量整数是否能用64位表示
+func literalInRange(val *big.Int) bool {
+	return val.Cmp(minLiteral) >= 0 && val.Cmp(maxLiteral) <= 0
+}
+
+// intBits 返回整数的64位补码 用于保存到int64 大于MaxInt64的uint64值为对应的负数
+func intBits(val *big.Int) int64 {
+	return int64(new(big.Int).And(val, maxLiteral).Uint64())
+}
+
+// EnumValue 返回枚举值的实际数值 无符号64位枚举中大于MaxInt64的值以补码保存在EnumVal.Value中
+func EnumValue(val *ast.EnumVal) *big.Int {
+	if enum, ok := val.Parent().(*ast.Enum); ok && !enum.Signed && val.Value < 0 {
+		return new(big.Int).SetUint64(uint64(val.Value))
+	}
+	return big.NewInt(val.Value)
+}
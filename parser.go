@@ -10,9 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"os"
+	"math/big"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/skea3344/gserrors"
 	"github.com/skea3344/gslang/ast"
@@ -29,6 +30,8 @@ const (
 	posExtra = "gslang_parser_pos"
 	// 注释额外信息名字
 	commentExtra = "gslang_parser_comment"
+	// 包引用路径额外信息名字 只解析不加载时包引用为空 格式化时使用
+	importPathExtra = "gslang_parser_import_path"
 )
 
 // attachPos 为某个节点添加额外的位置信息
@@ -83,19 +86,28 @@ func Comments(node ast.Node) []*Token {
 
 // Parser 分析器
 type Parser struct {
-	logger.ILog             // 内嵌通用日志接口
-	*Lexer                  // 内嵌词法分析器
-	cs          *CompileS   // 隶属的编译器
-	script      *ast.Script // 指向的代码节点
-	comments    []*Token    // 注释列表
-	attrs       []*ast.Attr // 属性列表
+	logger.ILog                  // 内嵌通用日志接口
+	*Lexer                       // 内嵌词法分析器
+	cs          *CompileS        // 隶属的编译器
+	script      *ast.Script      // 指向的代码节点
+	comments    []*Token         // 注释列表
+	attrs       []*ast.Attr      // 属性列表
+	imports     []*pendingImport // 分析到的包引用 分析完所有引用后统一编译
+	importTime  time.Duration    // 等待引用的包编译的耗时
+	errpos      Position         // 出错位置 用于生成诊断信息
+}
+
+// pendingImport 等待编译的包引用
+type pendingImport struct {
+	ref  *ast.PackageRef // 包引用节点
+	path string          // 包路径
 }
 
 // Peek 从词法分析器 取当前Token
 func (parser *Parser) Peek() *Token {
 	token, err := parser.Lexer.Peek()
 	if err != nil {
-		gserrors.Panic(err)
+		parser.panicLexer(err)
 	}
 	return token
 }
@@ -104,13 +116,22 @@ func (parser *Parser) Peek() *Token {
 func (parser *Parser) Next() *Token {
 	token, err := parser.Lexer.Next()
 	if err != nil {
-		gserrors.Panic(err)
+		parser.panicLexer(err)
 	}
 	return token
 }
 
+// panicLexer 记录词法错误的位置并报错
+func (parser *Parser) panicLexer(err error) {
+	if e, ok := err.(*lexError); ok {
+		parser.errpos = e.pos
+	}
+	gserrors.Panic(err)
+}
+
 // errorf 格式化报错
 func (parser *Parser) errorf(position Position, fmtstring string, args ...interface{}) {
+	parser.errpos = position
 	gserrors.Panicf(ErrParse, fmt.Sprintf("parse %s error: %s", position, fmt.Sprintf(fmtstring, args...)))
 }
 
@@ -162,17 +183,12 @@ func (parser *Parser) parseTypeRef() *ast.TypeRef {
 }
 
 // parse 编译器进行分析流程
-func (cs *CompileS) parse(pkg *ast.Package, path string) (*ast.Script, error) {
+func (cs *CompileS) parse(pkg *ast.Package, path string, content []byte) (*ast.Script, error) {
 	// 在目标代码包中新建代码节点 代码节点name为其相对文件名
 	script, err := pkg.NewScript(filepath.Base(path))
 	if err != nil {
 		return nil, err
 	}
-	// 读取整个文件内容到一个字节切片 []byte
-	content, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
 	// 新建分析器
 	parser := &Parser{
 		ILog:   logger.Get("gslang[parser]"),                      // 获取通用日志
@@ -180,22 +196,49 @@ func (cs *CompileS) parse(pkg *ast.Package, path string) (*ast.Script, error) {
 		cs:     cs,                                                // 设置所属编译器
 		script: script,                                            // 分析器指向的代码节点
 	}
-	// 分析器进行分析
+	// 分析器进行分析 累计不包括等待引用的包编译的解析耗时
+	start := time.Now()
 	err = parser.parse()
+	cs.mutex.Lock()
+	cs.parseTime += time.Since(start) - parser.importTime
+	cs.mutex.Unlock()
 	// 返回分析后的代码节点树
 	return script, err
 }
 
+// ParseSource 解析单个源文件 不加载导入的包也不进行连接 导入的包引用为空
+// 任意输入都只返回语法树或者诊断信息 不会panic 出错时返回已解析的部分语法树
+func ParseSource(filename string, source []byte) (*ast.Script, []*Diagnostic) {
+	script, err := ast.NewPackage(filepath.Dir(filename)).NewScript(filepath.Base(filename))
+	if err != nil {
+		return nil, []*Diagnostic{{Severity: SeverityError, Message: err.Error()}}
+	}
+	parser := &Parser{
+		ILog:   logger.Get("gslang[parser]"),
+		Lexer:  NewLexer(script.Name(), bytes.NewReader(source)),
+		script: script,
+	}
+	if err := parser.parse(); err != nil {
+		// 出错位置为出错的符号或者词法错误的位置 其他错误使用词法分析器的当前位置
+		position := parser.errpos
+		if !position.Valid() {
+			position = parser.Lexer.position
+		}
+		return script, []*Diagnostic{{
+			Severity: SeverityError,
+			Pos:      position,
+			Message:  err.Error(),
+		}}
+	}
+	return script, nil
+}
+
 // parse 分析器入口函数
 func (parser *Parser) parse() (err error) {
 	// 捕获错误 并返回该错误
 	defer func() {
 		if e := recover(); e != nil {
-			if _, ok := e.(gserrors.GSError); ok {
-				err = e.(error)
-			} else {
-				err = gserrors.New(err)
-			}
+			err = recoverError(e)
 		}
 	}()
 	// 先分析 代码内导入的其他包
@@ -242,6 +285,11 @@ func (parser *Parser) parseComments() {
 
 // attachComments 将分析器保存的注释列表中符合条件的注释附加给对应节点
 func (parser *Parser) attachComments(node ast.Node) {
+	attachComments(node, parser.selectComments(node))
+}
+
+// selectComments 从分析器保存的注释列表中取出属于节点的注释 按行号递增返回
+func (parser *Parser) selectComments(node ast.Node) []*Token {
 	// 节点位置
 	pos := Pos(node)
 	// 节点的位置必须有效
@@ -265,15 +313,18 @@ func (parser *Parser) attachComments(node ast.Node) {
 			rest = append(rest, comment)
 		}
 	}
-	// 分析器保存未被选中的注释
-	parser.comments = rest
-	// 将被选中的注释列表反序 以此得到按行号递增的注释列表
-	var revert []*Token
-	for i := len(selected) - 1; i >= 0; i-- {
-		revert = append(revert, selected[i])
+	// 分析器保存未被选中的注释 两个列表都是倒序遍历得到的 反序后恢复按行号递增
+	parser.comments = reverseComments(rest)
+	return reverseComments(selected)
+}
+
+// reverseComments 返回反序的注释列表
+func reverseComments(comments []*Token) []*Token {
+	var result []*Token
+	for i := len(comments) - 1; i >= 0; i-- {
+		result = append(result, comments[i])
 	}
-	// 将此结果注释列表附加给目标节点
-	attachComments(node, revert)
+	return result
 }
 
 // parseImports 分析 当前代码 需要导入的 包
@@ -311,13 +362,22 @@ func (parser *Parser) parseImports() {
 			parser.errorf(token.Pos, "expect import body: TokenString or '('")
 		}
 	}
+	// 没有所属编译器时只解析不加载 包引用为空
+	if parser.cs == nil {
+		return
+	}
+	// 并发编译所有引用的包
+	var paths []string
+	for _, item := range parser.imports {
+		paths = append(paths, item.path)
+	}
+	for i, pkg := range parser.importPackages(paths) {
+		parser.imports[i].ref.Ref = pkg
+	}
 	// 无论什么包都要默认引入gslang包 编译器自动引入 设置位置1,1
 	if parser.script.Package().Name() != GSLangPackage &&
 		parser.script.Imports["gslang"] == nil {
-		pkg, err := parser.cs.Compile(GSLangPackage)
-		if err != nil {
-			panic(err)
-		}
+		pkg := parser.importPackages([]string{GSLangPackage})[0]
 		pos := Position{
 			Filename: parser.script.Name(),
 			Line:     1,
@@ -327,6 +387,7 @@ func (parser *Parser) parseImports() {
 		gserrors.Assert(pkg != nil, "check CompileS and Compile implement")
 		gserrors.Assert(ok, "chech if the script manual import gslang package")
 		attachPos(ref, pos)
+		markAsImplicit(ref)
 	}
 }
 
@@ -349,13 +410,8 @@ func (parser *Parser) parseImport() *ast.PackageRef {
 	} else {
 		return nil
 	}
-	// 编译目标路径的包
-	pkg, err := parser.cs.Compile(path)
-	if err != nil {
-		gserrors.Panic(err)
-	}
-	// 将该包生成包引用节点并加入到代码节点的包引用列表中
-	ref, ok := parser.script.NewPackageRef(key, pkg)
+	// 将该包生成包引用节点并加入到代码节点的包引用列表中 引用的包在所有引用分析完成后编译
+	ref, ok := parser.script.NewPackageRef(key, nil)
 	// 检查是否已经引用了 同名的包
 	if !ok {
 		parser.errorf(token.Pos,
@@ -364,9 +420,24 @@ func (parser *Parser) parseImport() *ast.PackageRef {
 	}
 	// 为目标包引用 添加 源文件中的位置
 	attachPos(ref, token.Pos)
+	ref.NewExtra(importPathExtra, path)
+	parser.imports = append(parser.imports, &pendingImport{ref: ref, path: path})
 	return ref
 }
 
+// importPackages 并发编译当前代码引用的包 返回结果与paths顺序一致
+func (parser *Parser) importPackages(paths []string) []*ast.Package {
+	start := time.Now()
+	defer func() {
+		parser.importTime += time.Since(start)
+	}()
+	pkgs, err := parser.cs.compileImports(parser.script.Package().Name(), paths)
+	if err != nil {
+		gserrors.Panic(err)
+	}
+	return pkgs
+}
+
 // parseAttrs 分析属性
 func (parser *Parser) parseAttrs() {
 	// 先检查是否有注释
@@ -381,6 +452,8 @@ func (parser *Parser) parseAttrs() {
 		// 通过类型引用分析在代码节点内新建属性
 		attr := parser.script.NewAttr(parser.parseTypeRef())
 		attachPos(attr, token.Pos)
+		// 属性之前的注释先取出 避免被第一个参数取走
+		comments := parser.selectComments(attr)
 		token = parser.Peek()
 		if token.Type == '(' { // 如果后面跟了()则表示有参数列表 解析此参数列表附加到此属性
 			parser.Next()
@@ -392,8 +465,8 @@ func (parser *Parser) parseAttrs() {
 		parser.attrs = append(parser.attrs, attr)
 		// 分析是否有注释
 		parser.parseComments()
-		// 将对应注释附加到此属性节点
-		parser.attachComments(attr)
+		// 将对应注释附加到此属性节点 属性之前的注释在前 行尾注释在后
+		attachComments(attr, append(comments, parser.selectComments(attr)...))
 	}
 }
 
@@ -419,7 +492,7 @@ func (parser *Parser) parseArgs() ast.Expr {
 		parser.Next()
 		name := token
 		for {
-			if arg, ok := args.NewArg(token.Value.(string), parser.parseArg()); !ok {
+			if arg, ok := args.NewArg(name.Value.(string), parser.parseArg()); !ok {
 				// 命令参数列表内已存在同名的参数
 				parser.errorf(name.Pos, "duplicate param assign: \n\tsee: %s", Pos(arg))
 			} else {
@@ -451,10 +524,24 @@ func (parser *Parser) parseArgs() ast.Expr {
 		if token.Type != ',' {
 			break
 		}
+		parser.Next()
 	}
 	return args
 }
 
+// newInt 用整数token新建字面量整数 negative为true时取负 超出64位整数的表示范围时报错
+// 是否在目标类型的取值范围内由使用者检查
+func (parser *Parser) newInt(token *Token, negative bool) *ast.Int {
+	val := token.Value.(*big.Int)
+	if negative {
+		val = new(big.Int).Neg(val)
+	}
+	if !literalInRange(val) {
+		parser.errorf(token.Pos, "integer literal %s out of range", val)
+	}
+	return parser.script.NewBigInt(val)
+}
+
 // parseArg 分析参数
 func (parser *Parser) parseArg() ast.Expr {
 	// 二元运算表达式
@@ -465,7 +552,7 @@ func (parser *Parser) parseArg() ast.Expr {
 		switch token.Type {
 		case TokenINT: // 字面量整数值  100
 			parser.Next()
-			rhs = parser.script.NewInt(token.Value.(int64))
+			rhs = parser.newInt(token, false)
 		case TokenFLOAT: // 字面量浮点值 3.14
 			parser.Next()
 			rhs = parser.script.NewFloat(token.Value.(float64))
@@ -482,20 +569,22 @@ func (parser *Parser) parseArg() ast.Expr {
 			parser.Next()
 			next := parser.Next()
 			if next.Type == TokenINT {
-				rhs = parser.script.NewInt(-next.Value.(int64))
+				rhs = parser.newInt(next, true)
 			} else if next.Type == TokenFLOAT {
 				rhs = parser.script.NewFloat(-next.Value.(float64))
+			} else {
+				parser.errorf(token.Pos, "unexpect token '-'")
 			}
-			parser.errorf(token.Pos, "unexpect token '-'")
 		case '+': // 字面量 正整数  正浮点数
 			parser.Next()
 			next := parser.Next()
 			if next.Type == TokenINT {
-				rhs = parser.script.NewInt(next.Value.(int64))
+				rhs = parser.newInt(next, false)
 			} else if next.Type == TokenFLOAT {
 				rhs = parser.script.NewFloat(next.Value.(float64))
+			} else {
+				parser.errorf(token.Pos, "unexpect token '+'")
 			}
-			parser.errorf(token.Pos, "unexpect token '+'")
 		case TokenID: // 标识符 节点对象
 			rhs = parser.parseTypeRef()
 		default:
@@ -581,8 +670,10 @@ func (parser *Parser) parseContract() {
 			// 单个协议内不能有同名函数
 			parser.errorf(methodName.Pos, "duplicate method name:\n\tsee: %s", Pos(method))
 		}
-		// 附加位置
+		// 附加位置 函数名之前的注释及属性先取出 避免被第一个参数取走
 		attachPos(method, methodName.Pos)
+		comments := parser.selectComments(method)
+		parser.attachAttrs(method)
 		// 取函数参数列表
 		parser.expect('(')
 		next := parser.Peek()
@@ -647,10 +738,9 @@ func (parser *Parser) parseContract() {
 		}
 		// 多个函数声明以分好分隔
 		parser.expect(';')
-		// 给函数附加注释和属性
+		// 给函数附加注释 函数名之前的注释在前 行尾注释在后
 		parser.parseComments()
-		parser.attachComments(method)
-		parser.attachAttrs(method)
+		attachComments(method, append(comments, parser.selectComments(method)...))
 	}
 	parser.expect('}')
 }
@@ -685,11 +775,11 @@ func (parser *Parser) parseType() ast.Expr {
 		// 有长度的数组 无长度的切片
 		if next.Type == TokenINT {
 			parser.Next()
-			val := next.Value.(int64)
-			if val < 1 || val > math.MaxUint16 {
-				parser.errorf(next.Pos, "array length out of range: %d", val)
+			val := next.Value.(*big.Int)
+			if !val.IsUint64() || val.Uint64() < 1 || val.Uint64() > math.MaxUint16 {
+				parser.errorf(next.Pos, "array length out of range: %s", val)
 			}
-			length = uint16(val)
+			length = uint16(val.Uint64())
 		}
 		parser.expect(']')
 		// 递归分析类型
@@ -817,6 +907,12 @@ func (parser *Parser) parseEnumBase() (length uint, signed bool) {
 	case KeyUInt32:
 		length = 4
 		signed = false
+	case KeyInt64:
+		length = 8
+		signed = true
+	case KeyUInt64:
+		length = 8
+		signed = false
 	default:
 		parser.errorf(token.Pos, "enum must inherit from integer types, got: %s", TokenName(token.Type))
 	}
@@ -858,39 +954,17 @@ func (parser *Parser) parseEnum() {
 			negative = true
 		}
 		valToken := parser.expect(TokenINT)
-		val := valToken.Value.(int64)
+		val := valToken.Value.(*big.Int)
 		if negative {
-			val = -val
+			val = new(big.Int).Neg(val)
 		}
-		switch { // 判断值是否越界
-		case enum.Length == 1 && enum.Signed:
-			if val > math.MaxInt8 || val < math.MinInt8 {
-				parser.errorf(valToken.Pos, "out of enum[%s] type's range", enum)
-			}
-		case enum.Length == 1 && !enum.Signed:
-			if val > math.MaxUint8 || val < 0 {
-				parser.errorf(valToken.Pos, "out of enum[%s] type's range", enum)
-			}
-		case enum.Length == 2 && enum.Signed:
-			if val > math.MaxInt16 || val < math.MinInt16 {
-				parser.errorf(valToken.Pos, "out of enum[%s] type's range", enum)
-			}
-		case enum.Length == 2 && !enum.Signed:
-			if val > math.MaxUint16 || val < 0 {
-				parser.errorf(valToken.Pos, "out of enum[%s] type's range", enum)
-			}
-		case enum.Length == 4 && enum.Signed:
-			if val > math.MaxInt32 || val < math.MinInt32 {
-				parser.errorf(valToken.Pos, "out of enum[%s] type's range", enum)
-			}
-		case enum.Length == 4 && !enum.Signed:
-			if val > math.MaxUint32 || val < 0 {
-				parser.errorf(valToken.Pos, "out of enum[%s] type's range", enum)
-			}
+		// 判断值是否越界
+		if err := CheckInt(val, enum); err != nil {
+			parser.errorf(valToken.Pos, "out of enum[%s] type's range: %s", enum, err)
 		}
 		parser.expect(')')
 		// 在枚举内新建单挑枚举值
-		enumVal, ok := enum.NewVal(token.Value.(string), val)
+		enumVal, ok := enum.NewVal(token.Value.(string), intBits(val))
 		if !ok { // 不能有重名枚举值
 			parser.errorf(token.Pos,
 				"duplicate enum val name(%s):\n\tsee: %s",
@@ -0,0 +1,170 @@
+// @file 	parser_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	parser_test
+
+package gslang_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// parseSeeds 语法分析的种子语料 包括合法及各种非法的源码
+var parseSeeds = []string{
+	"",
+	"table T { A int32; }",
+	"import \"demo/base\"\n\n// 物品\ntable Item {\n    Pos base.Point;\n    Tags []string;\n}\n",
+	"import (\n\t\"a\"\n\t\"b\"\n)\n",
+	"struct P {\n    X int32;\n    Y [4]byte;\n}\n",
+	"enum Color(byte) {\n    Red(1), Green(0x2), Blue(-1)\n}\n",
+	"@gslang.AttrUsage(gslang.AttrTarget.Table)\ntable Tag { Name string; }\n@Tag(Name: \"x\")\ntable T {}\n",
+	"contract Shop(Base) {\n    Get(id uint64) -> (Item);\n    Put(map[string]Item);\n}\n",
+	"table T { A map[int32][]byte; }",
+	"table T { A [][2]int32; }",
+	"enum E(float32) {}",
+	"enum E(byte) { A(256) }",
+	"table T; x",
+	"table T { A int32 }",
+	"/* unterminated",
+	"table T { A \"\\q\"; }",
+	"table T { a int32; }\xe4",
+	"\xff",
+}
+
+// TestParseSourcePos 诊断信息的位置为出错的符号或者词法错误的位置
+func TestParseSourcePos(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"table T; x", "a.gs(1:8)"},
+		{"table T { A int32 }", "a.gs(1:19)"},
+		{"table T {\n    A int32;\n    A int32;\n}", "a.gs(3:5)"},
+		{"enum E(byte) { A(256) }", "a.gs(1:18)"},
+		{"table T { A \"\\q\"; }", "a.gs(1:15)"},
+		{"table T {}\n/* c", "a.gs(2:4)"},
+		{"enum E { A(1), A(2) }", "a.gs(1:16)"},
+		{"table T { a int32; }\xe4", "a.gs(1:21)"},
+	}
+	for _, tc := range tests {
+		_, diagnostics := gslang.ParseSource("demo/a.gs", []byte(tc.source))
+		if len(diagnostics) != 1 {
+			t.Errorf("ParseSource(%q) diagnostics = %v", tc.source, diagnostics)
+			continue
+		}
+		diagnostic := diagnostics[0]
+		if diagnostic.Severity != gslang.SeverityError || diagnostic.Pos.String() != tc.want {
+			t.Errorf("ParseSource(%q) = %s, want position %s", tc.source, diagnostic, tc.want)
+		}
+		if !strings.Contains(diagnostic.Message, tc.want) {
+			t.Errorf("ParseSource(%q) message %q does not contain position %s", tc.source, diagnostic.Message, tc.want)
+		}
+	}
+	if _, diagnostics := gslang.ParseSource("demo/a.gs", []byte(parseSeeds[2])); len(diagnostics) != 0 {
+		t.Fatalf("valid source: %v", diagnostics)
+	}
+}
+
+// commentSource 注释附加测试使用的代码
+const commentSource = `// 孤立注释1
+// 孤立注释2
+
+// 协议
+contract Shop {
+    @Tag
+    // 查询
+    Get(id uint64 /* 编号 */, name string) -> (Item); // 行尾
+    // 保存
+    Put(Item);
+}
+
+// 结尾
+`
+
+// commentTexts 返回节点的注释文本
+func commentTexts(node ast.Node) string {
+	var texts []string
+	for _, token := range gslang.Comments(node) {
+		texts = append(texts, strings.TrimSpace(token.Value.(string)))
+	}
+	return strings.Join(texts, "|")
+}
+
+// TestParseComments 注释附加给所属节点 剩余注释按行号顺序附加给代码节点
+func TestParseComments(t *testing.T) {
+	script, diagnostics := gslang.ParseSource("demo/a.gs", []byte(commentSource))
+	if len(diagnostics) != 0 {
+		t.Fatal(diagnostics)
+	}
+	contract := script.Types[0].(*ast.Contract)
+	get, put := contract.Methods["Get"], contract.Methods["Put"]
+	tests := []struct {
+		node ast.Node
+		want string
+	}{
+		{script, "孤立注释1|孤立注释2|结尾"},
+		{contract, "协议"},
+		{get, "查询|行尾"},
+		{get.Params[0], "编号"},
+		{get.Params[1], ""},
+		{get.Return[0], ""},
+		{put, "保存"},
+		{put.Params[0], ""},
+	}
+	for i, tc := range tests {
+		if got := commentTexts(tc.node); got != tc.want {
+			t.Errorf("%d: %s comments = %q want %q", i, tc.node, got, tc.want)
+		}
+	}
+	// 函数名之前的属性附加给函数 而不是第一个参数
+	if len(get.Attrs()) != 1 || len(get.Params[0].Attrs()) != 0 || len(put.Attrs()) != 0 {
+		t.Errorf("attrs: Get %d Get.Params[0] %d Put %d", len(get.Attrs()), len(get.Params[0].Attrs()), len(put.Attrs()))
+	}
+}
+
+// TestParseAttrComments 属性之前的注释附加给属性 而不是属性的第一个参数
+func TestParseAttrComments(t *testing.T) {
+	source := "table Item {\n    // 编号\n    @Tag(Name: \"id\" /* 名字 */, Weight: 1) // 属性\n    ID uint64; // 行尾\n}\n"
+	script, diagnostics := gslang.ParseSource("demo/a.gs", []byte(source))
+	if len(diagnostics) != 0 {
+		t.Fatal(diagnostics)
+	}
+	field := script.Types[0].(*ast.Table).Fields[0]
+	attr := field.Attrs()[0]
+	args := attr.Args.(*ast.NamedArgs)
+	tests := []struct {
+		node ast.Node
+		want string
+	}{
+		{field, "行尾"},
+		{attr, "编号|属性"},
+		{args.Items["Name"], "名字"},
+		{args.Items["Weight"], ""},
+	}
+	for i, tc := range tests {
+		if got := commentTexts(tc.node); got != tc.want {
+			t.Errorf("%d: %s comments = %q want %q", i, tc.node, got, tc.want)
+		}
+	}
+}
+
+func FuzzParseSource(f *testing.F) {
+	for _, seed := range parseSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, source []byte) {
+		script, diagnostics := gslang.ParseSource("demo/a.gs", source)
+		if script == nil {
+			t.Fatalf("ParseSource(%q) returned no script", source)
+		}
+		for _, diagnostic := range diagnostics {
+			if !diagnostic.Pos.Valid() || diagnostic.Pos.Filename != "a.gs" {
+				t.Fatalf("ParseSource(%q) diagnostic position %s", source, diagnostic)
+			}
+		}
+	})
+}
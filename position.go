@@ -8,6 +8,8 @@ package gslang
 import (
 	"fmt"
 	"path/filepath"
+
+	"github.com/skea3344/gslang/ast"
 )
 
 // Position 源码文件中的具体位置
@@ -31,3 +33,14 @@ func (pos Position) String() string {
 func (pos Position) Valid() bool {
 	return pos.Line != 0
 }
+
+// FilePos 返回节点位置 文件名使用代码文件的绝对路径 以区分同一个包不同版本中的同名文件
+func FilePos(node ast.Node) Position {
+	position := Pos(node)
+	if expr, ok := node.(ast.Expr); ok {
+		if path, ok := FilePath(expr.Script()); ok {
+			position.Filename = path
+		}
+	}
+	return position
+}
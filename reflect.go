@@ -6,6 +6,10 @@
 package gslang
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/skea3344/gserrors"
 	"github.com/skea3344/gslang/ast"
 )
@@ -103,3 +107,146 @@ func (cs *CompileS) EvalAttrUsage(attr *ast.Attr) int64 {
 	gserrors.Panicf(ErrCompileS, "target table can no be used as attribute type:\n\tattr def: %s\n\ttype def: %s", Pos(attr), Pos(attr.Type.Ref))
 	return 0
 }
+
+// builtinTypes gslang内置数据类型名字 与对应关键字的映射表
+var builtinTypes = map[string]rune{
+	"Byte":    KeyByte,
+	"Sbyte":   KeySByte,
+	"Int16":   KeyInt16,
+	"Uint16":  KeyUInt16,
+	"Int32":   KeyInt32,
+	"Uint32":  KeyUInt32,
+	"Int64":   KeyInt64,
+	"Uint64":  KeyUInt64,
+	"Float32": KeyFloat32,
+	"Float64": KeyFloat64,
+	"Bool":    KeyBool,
+	"String":  KeyString,
+}
+
+// Builtin 检查类型表达式是否引用gslang内置数据类型 返回对应的关键字 如KeyInt32
+// 未连接的类型引用按名字路径判断
+func Builtin(expr ast.Expr) (rune, bool) {
+	ref, ok := expr.(*ast.TypeRef)
+	if !ok {
+		return 0, false
+	}
+	if ref.Ref != nil {
+		pkg := ref.Ref.Package()
+		if pkg == nil || pkg.Name() != GSLangPackage {
+			return 0, false
+		}
+		key, ok := builtinTypes[ref.Ref.Name()]
+		return key, ok
+	}
+	// 未连接 gslang.Int32 或者gslang包内的 Int32
+	switch len(ref.NamePath) {
+	case 1:
+		if ref.Package() == nil || ref.Package().Name() != GSLangPackage {
+			return 0, false
+		}
+	case 2:
+		if ref.NamePath[0] != "gslang" {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	key, ok := builtinTypes[ref.NamePath[len(ref.NamePath)-1]]
+	return key, ok
+}
+
+// TypeName 返回类型表达式的规范名字 内置类型为关键字 如int32 引用类型为 包名.类型名
+// 切片为[]T 数组为[N]T 字典为map[K]V
+func TypeName(expr ast.Expr) string {
+	if key, ok := Builtin(expr); ok {
+		return TokenName(key)
+	}
+	switch node := expr.(type) {
+	case *ast.TypeRef:
+		if node.Ref == nil {
+			return strings.Join(node.NamePath, ".")
+		}
+		return TypeName(node.Ref)
+	case *ast.List:
+		return "[]" + TypeName(node.Element)
+	case *ast.Array:
+		return fmt.Sprintf("[%d]%s", node.Length, TypeName(node.Element))
+	case *ast.Map:
+		return fmt.Sprintf("map[%s]%s", TypeName(node.Key), TypeName(node.Value))
+	case *ast.EnumVal:
+		if enum, ok := node.Parent().(*ast.Enum); ok {
+			return TypeName(enum) + "." + node.Name()
+		}
+		return node.Name()
+	}
+	if pkg := expr.Package(); pkg != nil {
+		return pkg.Name() + "." + expr.Name()
+	}
+	return expr.Name()
+}
+
+// KindName 返回类型种类的名字 table struct enum contract 其他类型为type
+func KindName(expr ast.Expr) string {
+	switch node := expr.(type) {
+	case *ast.Table:
+		if IsStruct(node) {
+			return "struct"
+		}
+		return "table"
+	case *ast.Enum:
+		return "enum"
+	case *ast.Contract:
+		return "contract"
+	}
+	return "type"
+}
+
+// EnumBaseName 返回枚举长度及符号对应的内置类型名字 如 byte int32
+func EnumBaseName(enum *ast.Enum) string {
+	return TokenName(enumBase(enum))
+}
+
+// Types 按代码文件名及声明顺序返回包内的类型列表
+func Types(pkg *ast.Package) []ast.Expr {
+	var names []string
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var types []ast.Expr
+	for _, name := range names {
+		types = append(types, pkg.Scripts[name].Types...)
+	}
+	return types
+}
+
+// Methods 返回协议内按ID排序的函数列表
+func Methods(contract *ast.Contract) []*ast.Method {
+	var methods []*ast.Method
+	for _, method := range contract.Methods {
+		methods = append(methods, method)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		if methods[i].ID == methods[j].ID {
+			return methods[i].Name() < methods[j].Name()
+		}
+		return methods[i].ID < methods[j].ID
+	})
+	return methods
+}
+
+// EnumVals 返回枚举内按值排序的枚举值列表
+func EnumVals(enum *ast.Enum) []*ast.EnumVal {
+	var vals []*ast.EnumVal
+	for _, val := range enum.Values {
+		vals = append(vals, val)
+	}
+	sort.Slice(vals, func(i, j int) bool {
+		if vals[i].Value == vals[j].Value {
+			return vals[i].Name() < vals[j].Name()
+		}
+		return EnumValue(vals[i]).Cmp(EnumValue(vals[j])) < 0
+	})
+	return vals
+}
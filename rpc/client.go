@@ -0,0 +1,198 @@
+// @file 	client.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	client
+
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// Client 客户端 通过连接调用远端服务 实现Invoker接口 可以被多个goroutine同时使用
+type Client struct {
+	writing sync.Mutex             // 保护连接写入
+	mutex   sync.Mutex             // 保护以下字段
+	conn    io.ReadWriteCloser     // 底层连接
+	seq     uint64                 // 最后使用的调用序号
+	pending map[uint64]chan *frame // 等待响应的调用 序号 -> 响应
+	err     error                  // 连接关闭的原因 不为nil时连接已关闭
+}
+
+// NewClient 在已建立的连接上新建客户端 客户端关闭时关闭连接
+func NewClient(conn io.ReadWriteCloser) *Client {
+	client := &Client{
+		conn:    conn,
+		pending: make(map[uint64]chan *frame),
+	}
+	go client.receive()
+	return client
+}
+
+// Dial 连接TCP服务端并新建客户端
+func Dial(ctx context.Context, network, address string) (*Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// Pipe 新建通过进程内管道连接到服务端的客户端 调用经过完整的编解码
+func Pipe(server *Server) *Client {
+	local, remote := net.Pipe()
+	go server.ServeConn(remote)
+	return NewClient(local)
+}
+
+// receive 读取响应并分发给等待的调用 连接出错时结束所有等待的调用
+func (client *Client) receive() {
+	var err error
+	for {
+		var f *frame
+		f, err = readFrame(client.conn)
+		if err != nil {
+			break
+		}
+		if f.kind != frameResponse {
+			err = gserrors.Newf(ErrFrame, "unexpected frame type %d", f.kind)
+			break
+		}
+		client.mutex.Lock()
+		call, ok := client.pending[f.seq]
+		delete(client.pending, f.seq)
+		client.mutex.Unlock()
+		if ok {
+			call <- f
+		}
+	}
+	client.shutdown(err)
+}
+
+// shutdown 关闭连接 并结束所有等待的调用
+func (client *Client) shutdown(err error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.err != nil {
+		return
+	}
+	if err == nil || err == io.EOF {
+		err = ErrClosed
+	}
+	client.err = err
+	client.conn.Close()
+	for seq, call := range client.pending {
+		delete(client.pending, seq)
+		close(call)
+	}
+}
+
+// Close 关闭客户端 等待中的调用返回ErrClosed
+func (client *Client) Close() error {
+	client.shutdown(ErrClosed)
+	return nil
+}
+
+// Invoke 实现Invoker接口 ctx的截止时间会传递到服务端 ctx取消时通知服务端取消调用
+func (client *Client) Invoke(ctx context.Context, contract *ast.Contract, method *ast.Method, params []interface{}) ([]interface{}, error) {
+	if err := checkParams(contract, method, method.Params, params, "params"); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	request := &frame{
+		kind:     frameRequest,
+		contract: gslang.TypeName(contract),
+		method:   method.ID,
+		values:   params,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		// 传递剩余时间而不是本地时钟的截止时间 已经到期的调用在上面返回 剩余时间至少为1纳秒
+		request.timeout = int64(time.Until(deadline))
+		if request.timeout <= 0 {
+			request.timeout = 1
+		}
+	}
+	call := make(chan *frame, 1)
+	client.mutex.Lock()
+	if client.err != nil {
+		err := client.err
+		client.mutex.Unlock()
+		return nil, err
+	}
+	client.seq++
+	request.seq = client.seq
+	client.pending[request.seq] = call
+	client.mutex.Unlock()
+	data, err := encodeFrame(request, method.Params)
+	if err != nil {
+		client.mutex.Lock()
+		delete(client.pending, request.seq)
+		client.mutex.Unlock()
+		return nil, err
+	}
+	if err := client.write(data); err != nil {
+		client.shutdown(err)
+		return nil, err
+	}
+	select {
+	case response, ok := <-call:
+		if !ok {
+			client.mutex.Lock()
+			err := client.err
+			client.mutex.Unlock()
+			return nil, err
+		}
+		return client.response(method, response)
+	case <-ctx.Done():
+		client.cancel(request.seq)
+		return nil, ctx.Err()
+	}
+}
+
+// cancel 放弃等待调用的响应 并通知服务端取消调用
+func (client *Client) cancel(seq uint64) {
+	client.mutex.Lock()
+	_, ok := client.pending[seq]
+	delete(client.pending, seq)
+	closed := client.err != nil
+	client.mutex.Unlock()
+	if !ok || closed {
+		return
+	}
+	data, err := encodeFrame(&frame{kind: frameCancel, seq: seq}, nil)
+	if err == nil {
+		client.write(data)
+	}
+}
+
+// write 写入一帧
+func (client *Client) write(data []byte) error {
+	client.writing.Lock()
+	defer client.writing.Unlock()
+	_, err := client.conn.Write(data)
+	return err
+}
+
+// response 解析响应帧
+func (client *Client) response(method *ast.Method, response *frame) ([]interface{}, error) {
+	switch response.status {
+	case statusError:
+		return nil, response.err
+	case statusFail:
+		return nil, gserrors.Newf(ErrRemote, "%s", response.message)
+	case statusTimeout:
+		return nil, context.DeadlineExceeded
+	}
+	return decodeValues(response.payload, method.Return)
+}
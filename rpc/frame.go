@@ -0,0 +1,246 @@
+// @file 	frame.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	frame
+
+package rpc
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/codec"
+)
+
+// 帧类型
+const (
+	frameRequest  byte = 1
+	frameResponse byte = 2
+	frameCancel   byte = 3
+)
+
+// 响应状态
+const (
+	statusOK      byte = 0
+	statusError   byte = 1
+	statusFail    byte = 2
+	statusTimeout byte = 3
+)
+
+// MaxFrameSize 帧的最大长度
+const MaxFrameSize = 64 << 20
+
+// frame 帧内容
+type frame struct {
+	kind     byte          // 帧类型
+	seq      uint64        // 调用序号
+	contract string        // 协议全名 请求帧
+	method   uint16        // 函数ID 请求帧
+	timeout  int64         // 距离截止时间的剩余时间 纳秒 0表示没有截止时间 请求帧
+	status   byte          // 响应状态 响应帧
+	err      *Error        // 错误 响应状态为statusError时
+	message  string        // 错误描述 响应状态为statusFail或statusTimeout时
+	payload  []byte        // 参数或者返回值的编码数据
+	values   []interface{} // 解码前待编码的参数或者返回值
+}
+
+// frameWriter 帧编码缓冲
+type frameWriter struct {
+	buff []byte
+}
+
+// uvarint 写入无符号变长整数
+func (writer *frameWriter) uvarint(v uint64) {
+	writer.buff = binary.AppendUvarint(writer.buff, v)
+}
+
+// varint 写入有符号变长整数
+func (writer *frameWriter) varint(v int64) {
+	writer.buff = binary.AppendVarint(writer.buff, v)
+}
+
+// string 写入字符串
+func (writer *frameWriter) string(s string) {
+	writer.uvarint(uint64(len(s)))
+	writer.buff = append(writer.buff, s...)
+}
+
+// encodeFrame 编码帧 values按params的类型编码
+func encodeFrame(f *frame, params []*ast.Param) ([]byte, error) {
+	writer := &frameWriter{buff: make([]byte, 4, 64)}
+	writer.buff = append(writer.buff, f.kind)
+	writer.uvarint(f.seq)
+	switch f.kind {
+	case frameRequest:
+		writer.string(f.contract)
+		writer.uvarint(uint64(f.method))
+		writer.varint(f.timeout)
+	case frameResponse:
+		writer.buff = append(writer.buff, f.status)
+		switch f.status {
+		case statusError:
+			writer.string(f.err.Enum)
+			writer.string(f.err.Name)
+			writer.varint(f.err.Code)
+			writer.string(f.err.Message)
+		case statusFail, statusTimeout:
+			writer.string(f.message)
+		}
+	}
+	if len(params) != 0 {
+		encoder := codec.NewEncoder()
+		for i, param := range params {
+			if err := encoder.Encode(param.Type, f.values[i]); err != nil {
+				return nil, err
+			}
+		}
+		writer.buff = append(writer.buff, encoder.Bytes()...)
+	}
+	if len(writer.buff)-4 > MaxFrameSize {
+		return nil, gserrors.Newf(ErrFrame, "frame size %d exceeds %d", len(writer.buff)-4, MaxFrameSize)
+	}
+	binary.LittleEndian.PutUint32(writer.buff, uint32(len(writer.buff)-4))
+	return writer.buff, nil
+}
+
+// frameReader 帧解码缓冲
+type frameReader struct {
+	buff []byte
+}
+
+// uvarint 读取无符号变长整数
+func (reader *frameReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(reader.buff)
+	if n <= 0 {
+		return 0, gserrors.Newf(ErrFrame, "invalid uvarint")
+	}
+	reader.buff = reader.buff[n:]
+	return v, nil
+}
+
+// varint 读取有符号变长整数
+func (reader *frameReader) varint() (int64, error) {
+	v, n := binary.Varint(reader.buff)
+	if n <= 0 {
+		return 0, gserrors.Newf(ErrFrame, "invalid varint")
+	}
+	reader.buff = reader.buff[n:]
+	return v, nil
+}
+
+// byte 读取单个字节
+func (reader *frameReader) byte() (byte, error) {
+	if len(reader.buff) == 0 {
+		return 0, gserrors.Newf(ErrFrame, "unexpected end of frame")
+	}
+	b := reader.buff[0]
+	reader.buff = reader.buff[1:]
+	return b, nil
+}
+
+// string 读取字符串
+func (reader *frameReader) string() (string, error) {
+	n, err := reader.uvarint()
+	if err != nil {
+		return "", err
+	}
+	if n > uint64(len(reader.buff)) {
+		return "", gserrors.Newf(ErrFrame, "string length %d exceeds frame", n)
+	}
+	s := string(reader.buff[:n])
+	reader.buff = reader.buff[n:]
+	return s, nil
+}
+
+// readFrame 读取并解码一帧 参数及返回值保留为编码数据 由decodeValues解码
+func readFrame(r io.Reader) (*frame, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[:])
+	if size > MaxFrameSize {
+		return nil, gserrors.Newf(ErrFrame, "frame size %d exceeds %d", size, MaxFrameSize)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	reader := &frameReader{buff: body}
+	f := &frame{}
+	var err error
+	if f.kind, err = reader.byte(); err != nil {
+		return nil, err
+	}
+	if f.seq, err = reader.uvarint(); err != nil {
+		return nil, err
+	}
+	switch f.kind {
+	case frameRequest:
+		if f.contract, err = reader.string(); err != nil {
+			return nil, err
+		}
+		id, err := reader.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if id > 1<<16-1 {
+			return nil, gserrors.Newf(ErrFrame, "invalid method id %d", id)
+		}
+		f.method = uint16(id)
+		if f.timeout, err = reader.varint(); err != nil {
+			return nil, err
+		}
+	case frameResponse:
+		if f.status, err = reader.byte(); err != nil {
+			return nil, err
+		}
+		switch f.status {
+		case statusOK:
+		case statusError:
+			f.err = &Error{}
+			if f.err.Enum, err = reader.string(); err != nil {
+				return nil, err
+			}
+			if f.err.Name, err = reader.string(); err != nil {
+				return nil, err
+			}
+			if f.err.Code, err = reader.varint(); err != nil {
+				return nil, err
+			}
+			if f.err.Message, err = reader.string(); err != nil {
+				return nil, err
+			}
+		case statusFail, statusTimeout:
+			if f.message, err = reader.string(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, gserrors.Newf(ErrFrame, "unknown response status %d", f.status)
+		}
+	case frameCancel:
+	default:
+		return nil, gserrors.Newf(ErrFrame, "unknown frame type %d", f.kind)
+	}
+	f.payload = reader.buff
+	return f, nil
+}
+
+// decodeValues 按参数类型依次解码参数或者返回值 数据必须恰好被完全解码
+func decodeValues(data []byte, params []*ast.Param) ([]interface{}, error) {
+	decoder := codec.NewDecoder(data)
+	values := make([]interface{}, len(params))
+	for i, param := range params {
+		value, err := decoder.Decode(param.Type)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	if decoder.Len() != 0 {
+		return nil, gserrors.Newf(ErrFrame, "%d trailing bytes in frame", decoder.Len())
+	}
+	return values, nil
+}
@@ -0,0 +1,120 @@
+// @file 	rpc.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	rpc
+
+// Package rpc 根据已连接的协议定义在运行时进行远程调用 不需要生成代码
+//
+// 函数按 (协议全名, Method.ID) 分发 参数及返回值使用codec包的Go值表示并按codec二进制格式编码
+// 服务端返回的错误如果是由@Error枚举定义的*Error 客户端会得到同样类型及值的*Error
+// 调用的截止时间及取消通过context传递到服务端 截止时间以剩余时间传递 客户端与服务端的时钟不需要一致
+// 服务端调用超时时客户端得到context.DeadlineExceeded
+//
+// 传输层只要求io.ReadWriteCloser 包内提供TCP(Listen/Dial)及进程内(Pipe)两种传输
+//
+// 帧格式:
+//
+//	frame    = length(uint32 小端序) body
+//	request  = 0x01 seq(uvarint) contract(string) method(uvarint) timeout(varint 剩余时间 纳秒 0表示没有) params
+//	response = 0x02 seq(uvarint) status(byte) payload
+//	cancel   = 0x03 seq(uvarint)
+//	string   = length(uvarint) bytes
+//
+// status为0时payload为按顺序编码的返回值 为1时为 enum(string) name(string) code(varint) message(string)
+// 为2时为 message(string) 为3时表示服务端调用超时 payload为 message(string)
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+)
+
+// 错误码
+var (
+	ErrRPC            = errors.New("rpc error")
+	ErrClosed         = errors.New("rpc connection closed")
+	ErrRemote         = errors.New("rpc remote error")
+	ErrMethodNotFound = errors.New("rpc method not found")
+	ErrFrame          = errors.New("rpc invalid frame")
+)
+
+// Invoker 函数调用接口 客户端及服务端都实现此接口
+// params及返回值为codec包的Go值 个数与函数的参数及返回值个数一致
+type Invoker interface {
+	Invoke(ctx context.Context, contract *ast.Contract, method *ast.Method, params []interface{}) ([]interface{}, error)
+}
+
+// Method 按名字查找协议的函数 包括从父协议继承的函数
+func Method(contract *ast.Contract, name string) (*ast.Method, error) {
+	if method, ok := contract.Methods[name]; ok {
+		return method, nil
+	}
+	return nil, gserrors.Newf(ErrMethodNotFound, "%s has no method named %s", gslang.TypeName(contract), name)
+}
+
+// Call 按名字调用协议的函数
+func Call(ctx context.Context, invoker Invoker, contract *ast.Contract, name string, params ...interface{}) ([]interface{}, error) {
+	method, err := Method(contract, name)
+	if err != nil {
+		return nil, err
+	}
+	return invoker.Invoke(ctx, contract, method, params)
+}
+
+// Error 由@Error枚举定义的错误 可以跨越调用边界传递
+type Error struct {
+	Enum    string // 枚举全名 如 demo/shop.ShopError
+	Name    string // 枚举值名字
+	Code    int64  // 枚举值
+	Message string // 错误描述
+}
+
+// NewError 使用@Error枚举的值新建错误 枚举不是@Error或者没有对应名字的值时panic
+func NewError(enum *ast.Enum, name string, format string, args ...interface{}) *Error {
+	gserrors.Assert(gslang.IsError(enum), "%s is not an @Error enum", gslang.TypeName(enum))
+	code, ok := gslang.Enum(enum)[name]
+	gserrors.Assert(ok, "%s has no value named %s", gslang.TypeName(enum), name)
+	return &Error{
+		Enum:    gslang.TypeName(enum),
+		Name:    name,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Error 实现error接口
+func (err *Error) Error() string {
+	if err.Message == "" {
+		return fmt.Sprintf("%s.%s(%d)", err.Enum, err.Name, err.Code)
+	}
+	return fmt.Sprintf("%s.%s(%d): %s", err.Enum, err.Name, err.Code, err.Message)
+}
+
+// Is 支持errors.Is 枚举及值相同的错误视为同一错误
+func (err *Error) Is(target error) bool {
+	other, ok := target.(*Error)
+	return ok && other.Enum == err.Enum && other.Code == err.Code
+}
+
+// IsError 检查错误是否为指定@Error枚举值
+func IsError(err error, enum *ast.Enum, name string) bool {
+	var target *Error
+	if !errors.As(err, &target) {
+		return false
+	}
+	code, ok := gslang.Enum(enum)[name]
+	return ok && target.Enum == gslang.TypeName(enum) && target.Code == code
+}
+
+// checkParams 检查参数个数
+func checkParams(contract *ast.Contract, method *ast.Method, params []*ast.Param, values []interface{}, what string) error {
+	if len(values) != len(params) {
+		return gserrors.Newf(ErrRPC, "%s.%s expect %d %s got %d", gslang.TypeName(contract), method.Name(), len(params), what, len(values))
+	}
+	return nil
+}
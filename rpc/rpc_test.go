@@ -0,0 +1,282 @@
+// @file 	rpc_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	rpc_test
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// testFiles 测试使用的代码
+var testFiles = map[string]string{
+	"demo/shop/shop.gs": `
+table Item {
+    ID uint64;
+    Name string;
+}
+
+@gslang.Error
+enum ShopError(int32) {
+    NotFound(1),
+    Denied(2)
+}
+
+contract Shop {
+    Get(id uint64) -> (Item);
+    Put(Item);
+    Count() -> (uint32, bool);
+    Wait();
+}
+`,
+}
+
+// testServer 测试用的服务端及协议定义
+type testServer struct {
+	*Server
+	contract *ast.Contract
+	errors   *ast.Enum
+	waited   chan error // Wait的实现观察到的ctx错误
+	started  chan bool  // Wait的实现开始执行
+	deadline chan time.Duration
+}
+
+// newTestServer 编译测试代码并注册Shop的实现
+func newTestServer(t *testing.T) *testServer {
+	cs := gstest.Compile(t, testFiles, "demo/shop")
+	server := &testServer{
+		Server:   NewServer(),
+		contract: gstest.Type(t, cs, "demo/shop", "Shop").(*ast.Contract),
+		errors:   gstest.Type(t, cs, "demo/shop", "ShopError").(*ast.Enum),
+		waited:   make(chan error, 1),
+		started:  make(chan bool, 1),
+		deadline: make(chan time.Duration, 1),
+	}
+	items := map[uint64]map[string]interface{}{}
+	err := server.Register(server.contract, map[string]Handler{
+		"Get": func(ctx context.Context, params []interface{}) ([]interface{}, error) {
+			id := params[0].(uint64)
+			item, ok := items[id]
+			if !ok {
+				return nil, NewError(server.errors, "NotFound", "item %d not found", id)
+			}
+			return []interface{}{item}, nil
+		},
+		"Put": func(ctx context.Context, params []interface{}) ([]interface{}, error) {
+			item := params[0].(map[string]interface{})
+			if name, _ := item["Name"].(string); name == "" {
+				return nil, errors.New("empty name")
+			}
+			items[item["ID"].(uint64)] = item
+			return nil, nil
+		},
+		"Count": func(ctx context.Context, params []interface{}) ([]interface{}, error) {
+			return []interface{}{uint32(len(items)), len(items) > 0}, nil
+		},
+		"Wait": func(ctx context.Context, params []interface{}) ([]interface{}, error) {
+			if deadline, ok := ctx.Deadline(); ok {
+				server.deadline <- time.Until(deadline)
+			}
+			server.started <- true
+			<-ctx.Done()
+			server.waited <- ctx.Err()
+			return nil, ctx.Err()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// dial 在127.0.0.1的随机端口上启动服务端并连接
+func (server *testServer) dial(t *testing.T) *Client {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	client, err := Dial(context.Background(), "tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// transports 测试所有的传输方式 服务端自身也实现了Invoker
+func transports(t *testing.T, server *testServer) map[string]Invoker {
+	return map[string]Invoker{
+		"tcp":    server.dial(t),
+		"pipe":   Pipe(server.Server),
+		"direct": server.Server,
+	}
+}
+
+func TestCall(t *testing.T) {
+	server := newTestServer(t)
+	for name, invoker := range transports(t, server) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			item := map[string]interface{}{"ID": uint64(7), "Name": "sword"}
+			if _, err := Call(ctx, invoker, server.contract, "Put", item); err != nil {
+				t.Fatal(err)
+			}
+			results, err := Call(ctx, invoker, server.contract, "Get", uint64(7))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(results, []interface{}{item}) {
+				t.Fatalf("Get = %v", results)
+			}
+			results, err = Call(ctx, invoker, server.contract, "Count")
+			if err != nil || !reflect.DeepEqual(results, []interface{}{uint32(1), true}) {
+				t.Fatalf("Count = %v, %v", results, err)
+			}
+			if _, err := Call(ctx, invoker, server.contract, "Nope"); err == nil {
+				t.Fatalf("Call(Nope) error = %v", err)
+			}
+			if _, err := Call(ctx, invoker, server.contract, "Get"); err == nil || !strings.Contains(err.Error(), "expect 1 params got 0") {
+				t.Fatalf("Get without params error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoteError(t *testing.T) {
+	server := newTestServer(t)
+	for name, invoker := range transports(t, server) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_, err := Call(ctx, invoker, server.contract, "Get", uint64(404))
+			var target *Error
+			if !errors.As(err, &target) {
+				t.Fatalf("Get(404) error = %#v, want *Error", err)
+			}
+			want := &Error{Enum: "demo/shop.ShopError", Name: "NotFound", Code: 1, Message: "item 404 not found"}
+			if !reflect.DeepEqual(target, want) {
+				t.Fatalf("Get(404) error = %#v want %#v", target, want)
+			}
+			if !IsError(err, server.errors, "NotFound") || IsError(err, server.errors, "Denied") {
+				t.Fatalf("IsError mismatch for %s", err)
+			}
+			if !errors.Is(err, NewError(server.errors, "NotFound", "")) {
+				t.Fatalf("errors.Is mismatch for %s", err)
+			}
+			// 其他错误只传递错误描述
+			_, err = Call(ctx, invoker, server.contract, "Put", map[string]interface{}{"ID": uint64(1)})
+			if err == nil || !strings.Contains(err.Error(), "empty name") || errors.As(err, &target) {
+				t.Fatalf("Put error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	server := newTestServer(t)
+	client := server.dial(t)
+	timeout := 200 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	_, err := Call(ctx, client, server.contract, "Wait")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Wait returned after %s", elapsed)
+	}
+	// 服务端使用剩余时间作为自己的截止时间
+	select {
+	case remaining := <-server.deadline:
+		if remaining <= 0 || remaining > timeout {
+			t.Fatalf("server remaining time %s, want (0, %s]", remaining, timeout)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server call has no deadline")
+	}
+	select {
+	case err := <-server.waited:
+		if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+			t.Fatalf("server ctx error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server call was not stopped")
+	}
+	// 已经过期的ctx不发送请求
+	if _, err := Call(ctx, client, server.contract, "Count"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Count with expired ctx error = %v", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	server := newTestServer(t)
+	client := server.dial(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := Call(ctx, client, server.contract, "Wait")
+		done <- err
+	}()
+	select {
+	case <-server.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server call not started")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Wait error = %v, want canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("client call not canceled")
+	}
+	// 取消通知传递到服务端
+	select {
+	case err := <-server.waited:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("server ctx error = %v, want canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server call not canceled")
+	}
+	// 连接仍然可用
+	if _, err := Call(context.Background(), client, server.contract, "Count"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	server := newTestServer(t)
+	client := server.dial(t)
+	done := make(chan error, 1)
+	go func() {
+		_, err := Call(context.Background(), client, server.contract, "Wait")
+		done <- err
+	}()
+	<-server.started
+	server.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("call succeeded after server closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("call not finished after server closed")
+	}
+	if _, err := Call(context.Background(), client, server.contract, "Count"); err == nil {
+		t.Fatal("call succeeded on closed connection")
+	}
+}
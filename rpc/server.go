@@ -0,0 +1,330 @@
+// @file 	server.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	server
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/skea3344/gserrors"
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/logger"
+)
+
+// Handler 函数实现 params及返回值为codec包的Go值
+// 返回*Error时客户端会得到同样的*Error 超时错误(context.DeadlineExceeded)客户端也得到超时错误
+// 其他错误只传递错误描述
+type Handler func(ctx context.Context, params []interface{}) ([]interface{}, error)
+
+// service 已注册的协议
+type service struct {
+	contract *ast.Contract          // 协议定义
+	methods  map[uint16]*ast.Method // 函数ID -> 函数
+	handlers map[uint16]Handler     // 函数ID -> 实现
+}
+
+// Server 服务端 按 (协议全名, 函数ID) 分发调用 实现Invoker接口
+type Server struct {
+	logger.ILog                       // 内嵌通用日志接口
+	mutex       sync.Mutex            // 保护以下字段
+	services    map[string]*service   // 协议全名 -> 协议
+	listeners   map[net.Listener]bool // 正在服务的监听者
+	conns       map[io.Closer]bool    // 正在服务的连接
+	closed      bool                  // 是否已关闭
+}
+
+// NewServer 新建服务端
+func NewServer() *Server {
+	return &Server{
+		ILog:      logger.Get("gslang[rpc]"),
+		services:  make(map[string]*service),
+		listeners: make(map[net.Listener]bool),
+		conns:     make(map[io.Closer]bool),
+	}
+}
+
+// Handle 注册协议函数的实现 同一函数重复注册时替换原来的实现
+func (server *Server) Handle(contract *ast.Contract, name string, handler Handler) error {
+	method, err := Method(contract, name)
+	if err != nil {
+		return err
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	fullname := gslang.TypeName(contract)
+	s, ok := server.services[fullname]
+	if !ok {
+		s = &service{
+			contract: contract,
+			methods:  make(map[uint16]*ast.Method),
+			handlers: make(map[uint16]Handler),
+		}
+		for _, method := range contract.Methods {
+			s.methods[method.ID] = method
+		}
+		server.services[fullname] = s
+	} else if s.contract != contract {
+		return gserrors.Newf(ErrRPC, "another contract named %s already registered", fullname)
+	}
+	s.handlers[method.ID] = handler
+	return nil
+}
+
+// Register 使用函数名 -> 实现的字典注册协议 协议的所有函数都必须有实现
+func (server *Server) Register(contract *ast.Contract, handlers map[string]Handler) error {
+	for _, method := range gslang.Methods(contract) {
+		if _, ok := handlers[method.Name()]; !ok {
+			return gserrors.Newf(ErrRPC, "method %s.%s not implemented", gslang.TypeName(contract), method.Name())
+		}
+	}
+	for name, handler := range handlers {
+		if err := server.Handle(contract, name, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup 按协议全名及函数ID查找函数及实现
+func (server *Server) lookup(contract string, id uint16) (*service, *ast.Method, Handler, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	s, ok := server.services[contract]
+	if !ok {
+		return nil, nil, nil, gserrors.Newf(ErrMethodNotFound, "contract %s not registered", contract)
+	}
+	method, ok := s.methods[id]
+	if !ok {
+		return nil, nil, nil, gserrors.Newf(ErrMethodNotFound, "%s has no method with id %d", contract, id)
+	}
+	handler, ok := s.handlers[id]
+	if !ok {
+		return nil, nil, nil, gserrors.Newf(ErrMethodNotFound, "method %s.%s not implemented", contract, method.Name())
+	}
+	return s, method, handler, nil
+}
+
+// Invoke 实现Invoker接口 在进程内直接调用已注册的实现
+func (server *Server) Invoke(ctx context.Context, contract *ast.Contract, method *ast.Method, params []interface{}) ([]interface{}, error) {
+	if err := checkParams(contract, method, method.Params, params, "params"); err != nil {
+		return nil, err
+	}
+	_, found, handler, err := server.lookup(gslang.TypeName(contract), method.ID)
+	if err != nil {
+		return nil, err
+	}
+	return server.call(ctx, contract, found, handler, params)
+}
+
+// call 调用实现 实现panic时转换为错误 并检查返回值个数
+func (server *Server) call(ctx context.Context, contract *ast.Contract, method *ast.Method, handler Handler, params []interface{}) (results []interface{}, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			server.E("%s.%s panic: %v", gslang.TypeName(contract), method.Name(), e)
+			results, err = nil, gserrors.Newf(ErrRPC, "%s.%s panic: %v", gslang.TypeName(contract), method.Name(), e)
+		}
+	}()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	results, err = handler(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkParams(contract, method, method.Return, results, "results"); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Listen 在指定地址上监听TCP连接并服务 直到Close或者监听出错
+func (server *Server) Listen(network, address string) error {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	return server.Serve(listener)
+}
+
+// Serve 接受监听者上的连接并服务 直到Close或者监听出错
+func (server *Server) Serve(listener net.Listener) error {
+	server.mutex.Lock()
+	if server.closed {
+		server.mutex.Unlock()
+		listener.Close()
+		return ErrClosed
+	}
+	server.listeners[listener] = true
+	server.mutex.Unlock()
+	defer func() {
+		server.mutex.Lock()
+		delete(server.listeners, listener)
+		server.mutex.Unlock()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			server.mutex.Lock()
+			closed := server.closed
+			server.mutex.Unlock()
+			if closed {
+				return ErrClosed
+			}
+			return err
+		}
+		go server.ServeConn(conn)
+	}
+}
+
+// serverConn 服务端连接
+type serverConn struct {
+	writing sync.Mutex                    // 保护连接写入
+	mutex   sync.Mutex                    // 保护以下字段
+	server  *Server                       // 所属服务端
+	conn    io.ReadWriteCloser            // 底层连接
+	calls   map[uint64]context.CancelFunc // 正在处理的调用 序号 -> 取消函数
+}
+
+// ServeConn 服务单个连接 直到连接关闭或者收到非法帧
+func (server *Server) ServeConn(conn io.ReadWriteCloser) {
+	server.mutex.Lock()
+	if server.closed {
+		server.mutex.Unlock()
+		conn.Close()
+		return
+	}
+	server.conns[conn] = true
+	server.mutex.Unlock()
+	c := &serverConn{
+		server: server,
+		conn:   conn,
+		calls:  make(map[uint64]context.CancelFunc),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		conn.Close()
+		server.mutex.Lock()
+		delete(server.conns, conn)
+		server.mutex.Unlock()
+	}()
+	for {
+		f, err := readFrame(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.ErrClosedPipe) {
+				server.W("rpc connection closed: %s", err)
+			}
+			return
+		}
+		switch f.kind {
+		case frameRequest:
+			c.request(ctx, f)
+		case frameCancel:
+			c.mutex.Lock()
+			if cancel, ok := c.calls[f.seq]; ok {
+				cancel()
+			}
+			c.mutex.Unlock()
+		default:
+			server.W("rpc unexpected frame type %d", f.kind)
+			return
+		}
+	}
+}
+
+// request 处理请求帧 在新的goroutine中调用实现
+func (c *serverConn) request(parent context.Context, f *frame) {
+	s, method, handler, err := c.server.lookup(f.contract, f.method)
+	if err != nil {
+		c.reply(f.seq, nil, nil, err)
+		return
+	}
+	params, err := decodeValues(f.payload, method.Params)
+	if err != nil {
+		c.reply(f.seq, nil, nil, err)
+		return
+	}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if f.timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, time.Duration(f.timeout))
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	c.mutex.Lock()
+	c.calls[f.seq] = cancel
+	c.mutex.Unlock()
+	go func() {
+		defer func() {
+			c.mutex.Lock()
+			delete(c.calls, f.seq)
+			c.mutex.Unlock()
+			cancel()
+		}()
+		results, err := c.server.call(ctx, s.contract, method, handler, params)
+		c.reply(f.seq, method, results, err)
+	}()
+}
+
+// reply 发送响应帧
+func (c *serverConn) reply(seq uint64, method *ast.Method, results []interface{}, err error) {
+	f := &frame{kind: frameResponse, seq: seq}
+	var params []*ast.Param
+	if err == nil {
+		params, f.values = method.Return, results
+		data, e := encodeFrame(f, params)
+		if e == nil {
+			c.write(data)
+			return
+		}
+		err = e
+	}
+	var target *Error
+	if errors.As(err, &target) {
+		f.status, f.err = statusError, target
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		f.status, f.message = statusTimeout, err.Error()
+	} else {
+		f.status, f.message = statusFail, err.Error()
+	}
+	data, err := encodeFrame(f, nil)
+	if err != nil {
+		c.server.E("rpc encode response error: %s", err)
+		return
+	}
+	c.write(data)
+}
+
+// write 写入一帧
+func (c *serverConn) write(data []byte) {
+	c.writing.Lock()
+	defer c.writing.Unlock()
+	if _, err := c.conn.Write(data); err != nil {
+		c.server.D("rpc write response error: %s", err)
+	}
+}
+
+// Close 关闭服务端 停止所有监听者并关闭所有连接
+func (server *Server) Close() error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.closed {
+		return nil
+	}
+	server.closed = true
+	for listener := range server.listeners {
+		listener.Close()
+	}
+	for conn := range server.conns {
+		conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// @file 	scanner.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	scanner
+
+package gslang
+
+import (
+	"bytes"
+	"io"
+)
+
+// ScanToken Scanner输出的符号 包含源码原文及起止位置
+type ScanToken struct {
+	Type      rune        // 符号类型 与Lexer相同 另有TokenWhitespace及TokenError
+	Value     interface{} // 符号值 与Lexer相同 空白为原文
+	Text      string      // 符号在源码中的原文
+	Pos       Position    // 起始位置
+	End       Position    // 结束位置 指向符号之后的第一个字符
+	Offset    int         // 起始字节偏移
+	EndOffset int         // 结束字节偏移 不包含
+	Trivia    bool        // 是否为空白或者注释 语法分析时可以忽略
+	Err       error       // TokenError的错误信息
+}
+
+// Scanner 面向语法高亮 格式化等工具的词法扫描器
+// 输出包括空白及注释在内的所有符号 所有符号的原文拼接起来即为完整源码
+// 遇到非法输入时输出TokenError并从出错位置之后继续扫描
+type Scanner struct {
+	lexer  *Lexer
+	source bytes.Buffer // 已读取的源码
+	done   bool         // 是否已经结束
+}
+
+// NewScanner 新建一个扫描器
+func NewScanner(filename string, reader io.Reader) *Scanner {
+	scanner := &Scanner{}
+	scanner.lexer = NewLexer(filename, io.TeeReader(reader, &scanner.source))
+	scanner.lexer.trivia = true
+	return scanner
+}
+
+// Scan 返回下一个符号 源码结束后总是返回TokenEOF
+func (scanner *Scanner) Scan() *ScanToken {
+	lexer := scanner.lexer
+	offset, position := lexer.mark()
+	if scanner.done {
+		return &ScanToken{Type: TokenEOF, Pos: position, End: position, Offset: offset, EndOffset: offset}
+	}
+	token, err := lexer.Next()
+	if err != nil {
+		// 出错位置之前的内容都作为错误符号的原文
+		lexer.skip()
+		endOffset, end := lexer.mark()
+		if endOffset == offset {
+			// 没有任何进展 说明读取源码本身出错 不再继续
+			scanner.done = true
+		}
+		return &ScanToken{
+			Type:      TokenError,
+			Text:      scanner.text(offset, endOffset),
+			Pos:       position,
+			End:       end,
+			Offset:    offset,
+			EndOffset: endOffset,
+			Err:       err,
+		}
+	}
+	endOffset, end := lexer.mark()
+	if token.Type == TokenEOF {
+		scanner.done = true
+	}
+	return &ScanToken{
+		Type:      token.Type,
+		Value:     token.Value,
+		Text:      scanner.text(offset, endOffset),
+		Pos:       position,
+		End:       end,
+		Offset:    offset,
+		EndOffset: endOffset,
+		Trivia:    token.Type == TokenWhitespace || token.Type == TokenCOMMENT,
+	}
+}
+
+// text 返回指定字节范围内的源码
+func (scanner *Scanner) text(offset, endOffset int) string {
+	source := scanner.source.Bytes()
+	if endOffset > len(source) {
+		endOffset = len(source)
+	}
+	if offset > endOffset {
+		return ""
+	}
+	return string(source[offset:endOffset])
+}
@@ -0,0 +1,98 @@
+// @file 	scanner_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	scanner_test
+
+package gslang_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang"
+)
+
+// scan 扫描源码直到TokenEOF 返回所有符号
+func scan(source string) []*gslang.ScanToken {
+	scanner := gslang.NewScanner("a.gs", strings.NewReader(source))
+	var tokens []*gslang.ScanToken
+	for {
+		token := scanner.Scan()
+		tokens = append(tokens, token)
+		if token.Type == gslang.TokenEOF {
+			return tokens
+		}
+	}
+}
+
+// checkRoundTrip 检查所有符号的原文拼接起来为完整源码 且符号首尾相接
+func checkRoundTrip(t *testing.T, source string) []*gslang.ScanToken {
+	t.Helper()
+	tokens := scan(source)
+	var text strings.Builder
+	for i, token := range tokens {
+		text.WriteString(token.Text)
+		if token.Offset > token.EndOffset || token.EndOffset-token.Offset != len(token.Text) {
+			t.Fatalf("scan(%q): token %d offsets %d-%d text %q", source, i, token.Offset, token.EndOffset, token.Text)
+		}
+		if i > 0 && tokens[i-1].EndOffset != token.Offset {
+			t.Fatalf("scan(%q): token %d starts at %d, previous ends at %d", source, i, token.Offset, tokens[i-1].EndOffset)
+		}
+	}
+	if text.String() != source {
+		t.Fatalf("scan(%q) text = %q", source, text.String())
+	}
+	if eof := tokens[len(tokens)-1]; eof.Text != "" || eof.Offset != len(source) {
+		t.Fatalf("scan(%q) EOF = %q at %d", source, eof.Text, eof.Offset)
+	}
+	return tokens
+}
+
+func TestScannerRoundTrip(t *testing.T) {
+	sources := []string{
+		"",
+		"table T {\n\tA int32; // a\n}\n",
+		"/* c */ enum E(byte) { A(1) }",
+		"000000000!\xda",
+		"a\xda",
+		"\xe4",
+		"a\xe4b c",
+		"x \"\xe4",
+		"\xff\xfe `raw",
+		"中文\xe4\xb8",
+	}
+	for _, source := range sources {
+		checkRoundTrip(t, source)
+	}
+}
+
+// TestScannerIllegalUTF8 非法及不完整的utf8编码输出TokenError 之后继续扫描
+func TestScannerIllegalUTF8(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string // 每个符号的类型及原文
+	}{
+		{"a\xda", `ERROR "a\xda" | EOF ""`},
+		{"000000000!\xda", `INT "000000000" | ! "!" | ERROR "\xda" | EOF ""`},
+		{"\xe4b", `ERROR "\xe4" | ID "b" | EOF ""`},
+		{"\xe4\xb8\xad;\xe4", `ID "中" | ; ";" | ERROR "\xe4" | EOF ""`},
+	}
+	for _, tc := range tests {
+		var got []string
+		for _, token := range checkRoundTrip(t, tc.source) {
+			got = append(got, fmt.Sprintf("%s %q", gslang.TokenName(token.Type), token.Text))
+			if token.Type == gslang.TokenError && !strings.Contains(token.Err.Error(), "illegal utf8 character") {
+				t.Errorf("scan(%q) error = %s", tc.source, token.Err)
+			}
+		}
+		if strings.Join(got, " | ") != tc.want {
+			t.Errorf("scan(%q) = %s\nwant %s", tc.source, strings.Join(got, " | "), tc.want)
+		}
+	}
+	// 语法分析同样报告错误
+	_, diagnostics := gslang.ParseSource("demo/a.gs", []byte("table T { a int32; }\xe4"))
+	if len(diagnostics) != 1 || !strings.Contains(diagnostics[0].Message, "illegal utf8 character") {
+		t.Fatalf("ParseSource diagnostics = %v", diagnostics)
+	}
+}
@@ -0,0 +1,269 @@
+// @file 	unused.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	unused
+
+package gslang
+
+import (
+	"sort"
+
+	"github.com/skea3344/gslang/ast"
+)
+
+// markImportUsed 记录类型引用是通过哪个包引用解析的 并将包引用标记为已使用
+func markImportUsed(ref *ast.TypeRef, imported *ast.PackageRef) {
+	ref.NewExtra("import", imported)
+	imported.NewExtra("isUsed", true)
+}
+
+// Import 返回类型引用在连接时经过的包引用 引用当前包内的类型时返回false
+func Import(ref *ast.TypeRef) (*ast.PackageRef, bool) {
+	imported, ok := ref.Extra("import")
+	if ok {
+		return imported.(*ast.PackageRef), ok
+	}
+	return nil, false
+}
+
+// IsUsedImport 检查包引用是否被至少一个类型引用使用
+func IsUsedImport(ref *ast.PackageRef) bool {
+	_, ok := ref.Extra("isUsed")
+	return ok
+}
+
+// markAsImplicit 设置包引用为编译器自动引入
+func markAsImplicit(ref *ast.PackageRef) {
+	ref.NewExtra("isImplicit", true)
+}
+
+// IsImplicit 检查包引用是不是编译器自动引入的gslang包
+func IsImplicit(ref *ast.PackageRef) bool {
+	_, ok := ref.Extra("isImplicit")
+	return ok
+}
+
+// UnusedImports 返回代码中显式引入但没有被任何类型引用使用的包引用 按位置排序
+// 代码必须已经连接 自动引入的gslang包不会被返回
+func UnusedImports(script *ast.Script) []*ast.PackageRef {
+	var unused []*ast.PackageRef
+	for _, ref := range script.Imports {
+		if !IsImplicit(ref) && !IsUsedImport(ref) {
+			unused = append(unused, ref)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		a, b := Pos(unused[i]), Pos(unused[j])
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return unused
+}
+
+// UnusedTypes 在编译器已加载的所有包中查找没有被其他类型 协议或者属性引用的类型 按全名排序
+// 协议及错误声明枚举是对外接口 不会被返回 gslang包中的内置类型也不会被返回
+// 类型对自身的引用不计算在内
+func (cs *CompileS) UnusedTypes() []ast.Expr {
+	collector := &refCollector{refs: make(map[ast.Node]bool)}
+	for _, pkg := range cs.Loaded {
+		pkg.Accept(collector)
+	}
+	var unused []ast.Expr
+	for _, pkg := range cs.Loaded {
+		if pkg.Name() == GSLangPackage {
+			continue
+		}
+		for _, expr := range pkg.Types {
+			if collector.refs[expr] {
+				continue
+			}
+			switch node := expr.(type) {
+			case *ast.Contract:
+				continue
+			case *ast.Enum:
+				if IsError(node) {
+					continue
+				}
+			}
+			unused = append(unused, expr)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		return TypeName(unused[i]) < TypeName(unused[j])
+	})
+	return unused
+}
+
+// refCollector 收集已连接的类型引用指向的类型
+type refCollector struct {
+	ast.EmptyVisitor                   // 内嵌空访问者
+	refs             map[ast.Node]bool // 被引用的类型
+	current          ast.Expr          // 当前访问的类型 用于忽略对自身的引用
+}
+
+// VisitPackage 访问包
+func (collector *refCollector) VisitPackage(pkg *ast.Package) ast.Node {
+	collector.current = nil
+	for _, attr := range pkg.Attrs() {
+		attr.Accept(collector)
+	}
+	for _, script := range pkg.Scripts {
+		script.Accept(collector)
+	}
+	return pkg
+}
+
+// VisitScript 访问代码
+func (collector *refCollector) VisitScript(script *ast.Script) ast.Node {
+	collector.current = nil
+	for _, attr := range script.Attrs() {
+		attr.Accept(collector)
+	}
+	for _, expr := range script.Types {
+		collector.current = expr
+		expr.Accept(collector)
+	}
+	collector.current = nil
+	return script
+}
+
+// VisitTable 访问表或者结构体
+func (collector *refCollector) VisitTable(table *ast.Table) ast.Node {
+	for _, attr := range table.Attrs() {
+		attr.Accept(collector)
+	}
+	for _, field := range table.Fields {
+		field.Accept(collector)
+	}
+	return table
+}
+
+// VisitField 访问域
+func (collector *refCollector) VisitField(field *ast.Field) ast.Node {
+	for _, attr := range field.Attrs() {
+		attr.Accept(collector)
+	}
+	field.Type.Accept(collector)
+	return field
+}
+
+// VisitEnum 访问枚举
+func (collector *refCollector) VisitEnum(enum *ast.Enum) ast.Node {
+	for _, attr := range enum.Attrs() {
+		attr.Accept(collector)
+	}
+	for _, val := range enum.Values {
+		val.Accept(collector)
+	}
+	return enum
+}
+
+// VisitEnumVal 访问单条枚举值
+func (collector *refCollector) VisitEnumVal(val *ast.EnumVal) ast.Node {
+	for _, attr := range val.Attrs() {
+		attr.Accept(collector)
+	}
+	return val
+}
+
+// VisitContract 访问协议
+func (collector *refCollector) VisitContract(contract *ast.Contract) ast.Node {
+	for _, attr := range contract.Attrs() {
+		attr.Accept(collector)
+	}
+	for _, base := range contract.Bases {
+		base.Accept(collector)
+	}
+	for _, method := range contract.Methods {
+		method.Accept(collector)
+	}
+	return contract
+}
+
+// VisitMethod 访问函数
+func (collector *refCollector) VisitMethod(method *ast.Method) ast.Node {
+	for _, attr := range method.Attrs() {
+		attr.Accept(collector)
+	}
+	for _, param := range method.Return {
+		param.Accept(collector)
+	}
+	for _, param := range method.Params {
+		param.Accept(collector)
+	}
+	return method
+}
+
+// VisitParam 访问参数
+func (collector *refCollector) VisitParam(param *ast.Param) ast.Node {
+	for _, attr := range param.Attrs() {
+		attr.Accept(collector)
+	}
+	param.Type.Accept(collector)
+	return param
+}
+
+// VisitBinaryOp 访问二元操作
+func (collector *refCollector) VisitBinaryOp(op *ast.BinaryOp) ast.Node {
+	op.Left.Accept(collector)
+	op.Right.Accept(collector)
+	return op
+}
+
+// VisitList 访问切片
+func (collector *refCollector) VisitList(list *ast.List) ast.Node {
+	list.Element.Accept(collector)
+	return list
+}
+
+// VisitArray 访问数组
+func (collector *refCollector) VisitArray(array *ast.Array) ast.Node {
+	array.Element.Accept(collector)
+	return array
+}
+
+// VisitMap 访问字典
+func (collector *refCollector) VisitMap(expr *ast.Map) ast.Node {
+	expr.Key.Accept(collector)
+	expr.Value.Accept(collector)
+	return expr
+}
+
+// VisitAttr 访问属性
+func (collector *refCollector) VisitAttr(attr *ast.Attr) ast.Node {
+	attr.Type.Accept(collector)
+	if attr.Args != nil {
+		attr.Args.Accept(collector)
+	}
+	return attr
+}
+
+// VisitArgs 访问参数列表
+func (collector *refCollector) VisitArgs(args *ast.Args) ast.Node {
+	for _, arg := range args.Items {
+		arg.Accept(collector)
+	}
+	return args
+}
+
+// VisitNamedArgs 访问命名参数列表
+func (collector *refCollector) VisitNamedArgs(args *ast.NamedArgs) ast.Node {
+	for _, arg := range args.Items {
+		arg.Accept(collector)
+	}
+	return args
+}
+
+// VisitTypeRef 访问类型引用 引用枚举值时视为引用所属枚举
+func (collector *refCollector) VisitTypeRef(ref *ast.TypeRef) ast.Node {
+	var target ast.Node = ref.Ref
+	if val, ok := target.(*ast.EnumVal); ok {
+		target = val.Parent()
+	}
+	if target != nil && target != ast.Node(collector.current) {
+		collector.refs[target] = true
+	}
+	return ref
+}
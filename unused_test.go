@@ -0,0 +1,136 @@
+// @file 	unused_test.go
+// @author 	caibo
+// @email 	caibo923@gmail.com
+// @desc 	unused_test
+
+package gslang_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skea3344/gslang"
+	"github.com/skea3344/gslang/ast"
+	"github.com/skea3344/gslang/internal/gstest"
+)
+
+// unusedFiles 测试未使用的包引用及类型的代码
+var unusedFiles = map[string]string{
+	"demo/base/base.gs": `
+struct Point {
+    X int32;
+    Y int32;
+}
+
+enum Color(byte) {
+    Red(1), Green(2)
+}
+
+table Orphan {}
+`,
+	"demo/keys/keys.gs": `
+enum Key(int32) {
+    A(1)
+}
+`,
+	"demo/other/other.gs": `
+table Other {}
+`,
+	"demo/shop/shop.gs": `
+import "demo/base"
+import "demo/keys"
+import "demo/other"
+
+table Item {
+    Pos base.Point;
+    Counts map[keys.Key]int32;
+}
+
+// 只引用自身的表仍然是未使用的
+table Node {
+    Next Node;
+    Children []Node;
+}
+
+table Helper {}
+
+@gslang.Error
+enum ShopError(int32) {
+    NotFound(1)
+}
+
+enum Internal(byte) {
+    A(1)
+}
+
+@gslang.AttrUsage(gslang.AttrTarget.Table)
+table Tag {
+    Color base.Color;
+}
+
+@Tag(Color: base.Color.Green)
+table Tagged {}
+
+contract Shop {
+    Get(id uint64) -> (Item);
+    Tagged(Tagged);
+}
+`,
+}
+
+func TestUnusedImports(t *testing.T) {
+	cs := gstest.Compile(t, unusedFiles, "demo/shop")
+	script := gstest.Type(t, cs, "demo/shop", "Item").Script()
+	var names []string
+	for _, ref := range gslang.UnusedImports(script) {
+		names = append(names, ref.Ref.Name())
+	}
+	if got := strings.Join(names, " "); got != "demo/other" {
+		t.Fatalf("UnusedImports = %s want demo/other", got)
+	}
+	for _, ref := range script.Imports {
+		switch ref.Ref.Name() {
+		case gslang.GSLangPackage:
+			if !gslang.IsImplicit(ref) {
+				t.Errorf("gslang import is not implicit")
+			}
+		case "demo/base", "demo/keys":
+			if !gslang.IsUsedImport(ref) || gslang.IsImplicit(ref) {
+				t.Errorf("import %s used %v implicit %v", ref.Ref.Name(), gslang.IsUsedImport(ref), gslang.IsImplicit(ref))
+			}
+		}
+	}
+	// 类型引用记录经过的包引用
+	item := gstest.Type(t, cs, "demo/shop", "Item").(*ast.Table)
+	imported, ok := gslang.Import(item.Fields[0].Type.(*ast.TypeRef))
+	if !ok || imported.Ref.Name() != "demo/base" {
+		t.Errorf("Import(Pos) = %v, %v", imported, ok)
+	}
+	node := gstest.Type(t, cs, "demo/shop", "Node").(*ast.Table)
+	if _, ok := gslang.Import(node.Fields[0].Type.(*ast.TypeRef)); ok {
+		t.Error("reference to a local type has an import")
+	}
+	// 连接时报告警告
+	var warnings []string
+	for _, diagnostic := range cs.Diagnostics {
+		if diagnostic.Severity != gslang.SeverityWarning {
+			t.Errorf("unexpected diagnostic %s", diagnostic)
+		}
+		warnings = append(warnings, diagnostic.String())
+	}
+	if len(warnings) != 1 || !strings.HasSuffix(warnings[0], `shop.gs(4:8): warning: imported and not used: "demo/other"`) {
+		t.Fatalf("diagnostics = %v", warnings)
+	}
+}
+
+func TestUnusedTypes(t *testing.T) {
+	cs := gstest.Compile(t, unusedFiles, "demo/shop")
+	var names []string
+	for _, expr := range cs.UnusedTypes() {
+		names = append(names, gslang.TypeName(expr))
+	}
+	want := "demo/base.Orphan demo/other.Other demo/shop.Helper demo/shop.Internal demo/shop.Node"
+	if got := strings.Join(names, " "); got != want {
+		t.Fatalf("UnusedTypes = %s\nwant %s", got, want)
+	}
+}